@@ -2,21 +2,27 @@ package main
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+	"io"
 	"log"
+	"net"
 	"net/http"
 	_ "net/http/pprof"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
-	"time"
 
+	"github.com/m-molecula741/shortener/internal/app/blocklist"
 	"github.com/m-molecula741/shortener/internal/app/config"
 	"github.com/m-molecula741/shortener/internal/app/controller"
 	"github.com/m-molecula741/shortener/internal/app/logger"
 	"github.com/m-molecula741/shortener/internal/app/middleware"
 	"github.com/m-molecula741/shortener/internal/app/storage"
 	"github.com/m-molecula741/shortener/internal/app/usecase"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/rs/zerolog"
 )
 
 var (
@@ -25,26 +31,93 @@ var (
 	buildCommit  string
 )
 
+const (
+	// collisionWarnThreshold - вероятность коллизии коротких ID, выше которой выводится предупреждение
+	collisionWarnThreshold = 0.01
+	// logBodyMaxSize - максимальный размер тела запроса или ответа в логе
+	logBodyMaxSize = 4096
+)
+
+// buildInfo содержит информацию о сборке
+type buildInfo struct {
+	Version string `json:"version"`
+	Date    string `json:"date"`
+	Commit  string `json:"commit"`
+}
+
+// resolveBuildInfo возвращает информацию о сборке, подставляя N/A для незаданных значений
+func resolveBuildInfo() buildInfo {
+	info := buildInfo{
+		Version: buildVersion,
+		Date:    buildDate,
+		Commit:  buildCommit,
+	}
+
+	for _, value := range []*string{&info.Version, &info.Date, &info.Commit} {
+		if *value == "" {
+			*value = "N/A"
+		}
+	}
+
+	return info
+}
+
 // printBuildInfo выводит информацию о сборке в stdout
 func printBuildInfo() {
-	version := buildVersion
-	if version == "" {
-		version = "N/A"
-	}
+	info := resolveBuildInfo()
+
+	fmt.Printf("Build version: %s\n", info.Version)
+	fmt.Printf("Build date: %s\n", info.Date)
+	fmt.Printf("Build commit: %s\n", info.Commit)
+}
+
+// printBuildInfoJSON выводит информацию о сборке в stdout в формате JSON
+func printBuildInfoJSON() error {
+	return json.NewEncoder(os.Stdout).Encode(resolveBuildInfo())
+}
 
-	date := buildDate
-	if date == "" {
-		date = "N/A"
+// reloadStorage перечитывает данные хранилища по сигналу SIGHUP.
+// Горячая перезагрузка поддерживается только для файлового хранилища,
+// параметры конфигурации (флаги и переменные окружения) не перечитываются.
+func reloadStorage(store usecase.URLStorage) {
+	fileStorage, ok := store.(*storage.InMemoryStorage)
+	if !ok {
+		logger.Info().Msg("Received SIGHUP, reload is not supported for current storage")
+		return
 	}
 
-	commit := buildCommit
-	if commit == "" {
-		commit = "N/A"
+	if err := fileStorage.Reload(); err != nil {
+		logger.Info().
+			Err(err).
+			Msg("Failed to reload storage")
+		return
 	}
 
-	fmt.Printf("Build version: %s\n", version)
-	fmt.Printf("Build date: %s\n", date)
-	fmt.Printf("Build commit: %s\n", commit)
+	logger.Info().Msg("Storage reloaded")
+}
+
+// registerShortIDMetrics регистрирует счетчики попыток сохранения с новым коротким ID
+// и коллизий. Рост доли коллизий означает, что SHORT_ID_LENGTH пора увеличить.
+func registerShortIDMetrics(reg prometheus.Registerer, service *usecase.URLService) error {
+	attempts := prometheus.NewCounterFunc(prometheus.CounterOpts{
+		Name: "shortener_short_id_attempts_total",
+		Help: "Attempts to save a link with a newly generated short ID, including retries after collisions.",
+	}, func() float64 {
+		return float64(service.ShortIDStats().Attempts)
+	})
+	collisions := prometheus.NewCounterFunc(prometheus.CounterOpts{
+		Name: "shortener_short_id_collisions_total",
+		Help: "Generated short IDs rejected by storage because they were already taken.",
+	}, func() float64 {
+		return float64(service.ShortIDStats().Collisions)
+	})
+
+	for _, collector := range []prometheus.Collector{attempts, collisions} {
+		if err := reg.Register(collector); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
 // main является точкой входа приложения.
@@ -59,12 +132,32 @@ func main() {
 // run содержит основную логику приложения.
 // Возвращает ошибку, если приложение не может быть запущено или корректно завершено.
 func run() error {
+	cfg, err := config.NewConfig()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+
+	// С флагом -version только выводим информацию о сборке и завершаемся
+	if cfg.PrintVersion {
+		return printBuildInfoJSON()
+	}
+
 	// Выводим информацию о сборке
 	printBuildInfo()
 
-	logger.Init()
-
-	cfg := config.NewConfig()
+	var logOutput io.Writer
+	if cfg.LogFile != "" {
+		logFile := logger.NewFileOutput(cfg.LogFile, logger.FileOptions{
+			MaxSizeMB:  cfg.LogMaxSize,
+			MaxBackups: cfg.LogMaxBackups,
+			MaxAgeDays: cfg.LogMaxAge,
+		})
+		defer logFile.Close()
+		logOutput = logFile
+	}
+	if err := logger.Init(logOutput, cfg.LogLevel, cfg.LogFormat == config.LogFormatJSON); err != nil {
+		return fmt.Errorf("failed to initialize logger: %w", err)
+	}
 
 	// Запускаем pprof только если включен debug режим
 	if cfg.EnablePprof {
@@ -77,23 +170,137 @@ func run() error {
 	}
 
 	// Инициализируем middleware аутентификации
-	auth, err := middleware.NewAuthMiddleware("secret-key-for-auth")
+	sameSite, err := middleware.ParseSameSite(cfg.CookieSameSite)
+	if err != nil {
+		return fmt.Errorf("failed to initialize auth middleware: %w", err)
+	}
+	var previousKeys []string
+	if cfg.PreviousKeys != "" {
+		previousKeys = strings.Split(cfg.PreviousKeys, ",")
+	}
+	auth, err := middleware.NewAuthMiddlewareWithOptions(cfg.SecretKey, middleware.AuthOptions{
+		PreviousKeys:       previousKeys,
+		DisableHTTPOnly:    !cfg.CookieHTTPOnly,
+		Secure:             cfg.CookieSecure,
+		SameSite:           sameSite,
+		Domain:             cfg.CookieDomain,
+		FirstRequestWindow: cfg.FirstRequestWin,
+	})
 	if err != nil {
 		return fmt.Errorf("failed to initialize auth middleware: %w", err)
 	}
+	if !cfg.CookieHTTPOnly {
+		logger.Warn().Msg("Auth cookie is not HttpOnly and is readable by JavaScript; any XSS can steal user sessions")
+	}
+
+	// Предупреждаем, если длины короткого ID мало для ожидаемого количества ссылок
+	if p := usecase.ShortIDCollisionProbability(cfg.ExpectedURLs, cfg.ShortIDLength); p > collisionWarnThreshold {
+		logger.Warn().
+			Int64("expected_urls", cfg.ExpectedURLs).
+			Int("short_id_length", cfg.ShortIDLength).
+			Float64("collision_probability", p).
+			Msg("Short ID length is too short for the expected number of URLs, collisions are likely")
+	}
+
+	// Неоднозначный выбор хранилища: предупреждаем или, в строгом режиме, завершаемся
+	if err := cfg.CheckStorage(); err != nil {
+		if cfg.StrictStorage {
+			return fmt.Errorf("ambiguous storage config: %w", err)
+		}
+		logger.Warn().
+			Err(err).
+			Msg("Ambiguous storage config")
+	}
 
 	var store usecase.URLStorage
 	var dbPinger usecase.DatabasePinger
+	var idAllocator usecase.IDAllocator
+	var writeChecker usecase.WriteChecker
+	var poolStats usecase.PoolStatsProvider
+	var backend string
+
+	conflictStrategy, err := usecase.ParseConflictStrategy(cfg.ConflictStrategy)
+	if err != nil {
+		return fmt.Errorf("invalid config: %w", err)
+	}
+
+	evictionPolicy, err := storage.ParseEvictionPolicy(cfg.EvictionPolicy)
+	if err != nil {
+		return fmt.Errorf("invalid config: %w", err)
+	}
+
+	// Шард выбирается по короткому ID, поэтому дубликаты из разных шардов не найти:
+	// дедупликацию отключаем целиком, а не оставляем работающей лишь внутри шарда
+	if cfg.DatabaseShards != "" && conflictStrategy != usecase.ConflictCreateNew {
+		logger.Warn().
+			Str("conflict_strategy", string(conflictStrategy)).
+			Msg("Deduplication of original URLs is not supported with sharded storage, every URL gets a new short ID")
+		conflictStrategy = usecase.ConflictCreateNew
+	}
+
+	// При создании нового URL для дубликатов хранилище не должно их искать
+	storageOpts := storage.Options{
+		DisableDedup: conflictStrategy == usecase.ConflictCreateNew,
+		VisitCap:     cfg.VisitCap,
+
+		PersistVisits: cfg.PersistVisits,
+
+		MaxURLs:  cfg.MaxURLs,
+		Eviction: evictionPolicy,
+
+		ConnectRetries: cfg.DBConnectRetries,
+		ConnectBackoff: cfg.DBConnectBackoff,
+	}
+
+	if cfg.DatabaseShards != "" {
+		// Распределяем ссылки между несколькими базами PostgreSQL
+		var shards []usecase.URLStorage
+		var pgShards []*storage.PostgresStorage
+		for _, dsn := range strings.Split(cfg.DatabaseShards, ",") {
+			pgStorage, err := storage.NewPostgresStorage(context.Background(), strings.TrimSpace(dsn), nil, storageOpts)
+			if err != nil {
+				// Уже открытые шарды закрываем, чтобы не оставлять пулы соединений
+				for _, shard := range pgShards {
+					_ = shard.Close()
+				}
+				return fmt.Errorf("failed to initialize PostgreSQL shard %d: %w", len(shards), err)
+			}
+			pgShards = append(pgShards, pgStorage)
+			shards = append(shards, pgStorage)
+		}
+
+		shardedStorage, err := storage.NewShardedStorage(shards)
+		if err != nil {
+			return fmt.Errorf("failed to initialize sharded storage: %w", err)
+		}
+
+		store = shardedStorage
+		dbPinger = shardedStorage
+
+		defer func() {
+			if err := shardedStorage.Close(); err != nil {
+				logger.Info().
+					Err(err).
+					Msg("Failed to close PostgreSQL shard connections")
+			}
+		}()
 
-	if cfg.DatabaseDSN != "" {
+		backend = "postgres_sharded"
+		logger.Info().
+			Int("shards", len(shards)).
+			Msg("Using sharded PostgreSQL storage")
+	} else if cfg.DatabaseDSN != "" {
 		// Используем PostgreSQL как основное хранилище
-		pgStorage, err := storage.NewPostgresStorage(cfg.DatabaseDSN, nil)
+		pgStorage, err := storage.NewPostgresStorage(context.Background(), cfg.DatabaseDSN, nil, storageOpts)
 		if err != nil {
 			return fmt.Errorf("failed to initialize PostgreSQL storage: %w", err)
 		}
 
 		store = pgStorage
 		dbPinger = pgStorage // PostgreSQL поддерживает ping
+		idAllocator = pgStorage
+		writeChecker = pgStorage
+		poolStats = pgStorage
 
 		defer func() {
 			if err := pgStorage.Close(); err != nil {
@@ -103,9 +310,10 @@ func run() error {
 			}
 		}()
 
+		backend = "postgres"
 		logger.Info().Msg("Using PostgreSQL storage")
 	} else {
-		fileStorage, err := storage.NewInMemoryStorage(cfg.StorageFilePath)
+		fileStorage, err := storage.NewInMemoryStorage(cfg.StorageFilePath, storageOpts)
 		if err != nil {
 			return fmt.Errorf("failed to initialize file storage: %w", err)
 		}
@@ -113,21 +321,221 @@ func run() error {
 		store = fileStorage
 		dbPinger = nil // файловое хранилище не поддерживает ping
 
+		backend = "file"
 		logger.Info().Msg("Using file storage")
 	}
 
-	urlService := usecase.NewURLService(store, cfg.BaseURL, dbPinger)
+	// Измеряем время операций хранилища для метрик
+	serviceStore, err := storage.NewInstrumentedStorageWithOptions(store, backend, prometheus.DefaultRegisterer, storage.InstrumentOptions{
+		SlowQueryThreshold: cfg.SlowQuery,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to initialize storage metrics: %w", err)
+	}
+
+	if cfg.EncryptAtRest {
+		var previousURLKeys []string
+		if cfg.URLPreviousKeys != "" {
+			previousURLKeys = strings.Split(cfg.URLPreviousKeys, ",")
+		}
+		serviceStore, err = storage.NewEncryptedStorage(serviceStore, cfg.URLKey, previousURLKeys...)
+		if err != nil {
+			return fmt.Errorf("failed to initialize storage encryption: %w", err)
+		}
+		logger.Info().Msg("Original URLs are encrypted at rest")
+	}
+
+	// Сжатие оборачивает шифрование: шифротекст уже не сжимается
+	if cfg.CompressURLs {
+		serviceStore = storage.NewCompressedStorage(serviceStore)
+		logger.Info().Msg("Original URLs are compressed in storage")
+	}
+
+	serviceOpts := usecase.Options{
+		AllowedSchemes:  strings.Split(cfg.AllowedSchemes, ","),
+		DefaultScheme:   cfg.DefaultScheme,
+		StripFragments:  cfg.StripFragments,
+		ShortIDLength:   cfg.ShortIDLength,
+		ShortIDAttempts: cfg.ShortIDAttempts,
+		GCInterval:      cfg.GCInterval,
+		GCGracePeriod:   cfg.GCGracePeriod,
+
+		ConflictStrategy:     conflictStrategy,
+		WorkerStallThreshold: cfg.WorkerStall,
+
+		PoolStats: poolStats,
+
+		DeleteRetries:      cfg.DeleteRetries,
+		DeleteRetryBackoff: cfg.DeleteRetryBackoff,
+	}
+	if cfg.DeadLetterFile != "" {
+		serviceOpts.DeadLetters = storage.NewFileDeadLetterQueue(cfg.DeadLetterFile)
+	}
+	if cfg.ReadyzWrite {
+		// Для файлового хранилища проверки записи нет, /readyz ограничится PingDB
+		serviceOpts.WriteChecker = writeChecker
+	}
+	idLength := cfg.ShortIDLength
+	switch cfg.IDMode {
+	case "", "random":
+	case "sequential":
+		idLength = 0 // длина растет вместе с последовательностью
+		if idAllocator == nil {
+			return fmt.Errorf("ID_MODE=sequential requires DATABASE_DSN")
+		}
+		serviceOpts.IDGenerator = usecase.NewSequentialIDGenerator(idAllocator)
+		logger.Info().Msg("Short IDs are allocated from the PostgreSQL sequence")
+	default:
+		return fmt.Errorf("invalid config: unknown ID mode %q", cfg.IDMode)
+	}
+	if cfg.BlocklistFile != "" {
+		fileBlocklist, err := blocklist.NewFileBlocklist(cfg.BlocklistFile, cfg.BlocklistReload)
+		if err != nil {
+			return fmt.Errorf("failed to load blocklist: %w", err)
+		}
+		defer fileBlocklist.Close()
+
+		serviceOpts.Blocklist = fileBlocklist
+		logger.Info().
+			Str("file", cfg.BlocklistFile).
+			Msg("URL blocklist enabled")
+	}
+
+	switch {
+	case cfg.IdempotencyTTL <= 0:
+	case cfg.IdempotencyRedis != "":
+		redisStore, err := storage.NewRedisIdempotencyStore(context.Background(), cfg.IdempotencyRedis, cfg.IdempotencyTTL)
+		if err != nil {
+			return fmt.Errorf("failed to initialize idempotency store: %w", err)
+		}
+		defer redisStore.Close()
+
+		serviceOpts.IdempotencyStore = redisStore
+		logger.Info().Msg("Idempotency keys are stored in Redis")
+	default:
+		serviceOpts.IdempotencyStore = storage.NewInMemoryIdempotencyStore(cfg.IdempotencyTTL)
+	}
+
+	var trustedSubnet *net.IPNet
+	if cfg.TrustedSubnet != "" {
+		if _, trustedSubnet, err = net.ParseCIDR(cfg.TrustedSubnet); err != nil {
+			return fmt.Errorf("failed to parse trusted subnet: %w", err)
+		}
+	}
+
+	if cfg.AllowReset {
+		if trustedSubnet == nil {
+			return fmt.Errorf("ALLOW_RESET requires TRUSTED_SUBNET to be set")
+		}
+		logger.Info().Str("trusted_subnet", trustedSubnet.String()).Msg("Storage reset endpoint is enabled, do not use in production")
+	}
+
+	urlService := usecase.NewURLService(serviceStore, cfg.BaseURL, dbPinger, serviceOpts)
+
+	deadLetters := prometheus.NewCounterFunc(prometheus.CounterOpts{
+		Name: "shortener_delete_dead_letters_total",
+		Help: "Async delete requests that failed after all retries and were dead-lettered.",
+	}, func() float64 {
+		return float64(urlService.DeleteStats().DeadLettered)
+	})
+	if err := prometheus.Register(deadLetters); err != nil {
+		return fmt.Errorf("failed to register delete metrics: %w", err)
+	}
+	if err := registerShortIDMetrics(prometheus.DefaultRegisterer, urlService); err != nil {
+		return fmt.Errorf("failed to register short ID metrics: %w", err)
+	}
+	gzipRoutes, err := controller.ParseGzipRoutes(cfg.GzipRoutes)
+	if err != nil {
+		return fmt.Errorf("invalid gzip routes: %w", err)
+	}
+
+	requestMetrics, err := middleware.NewMetricsMiddleware(prometheus.DefaultRegisterer)
+	if err != nil {
+		return fmt.Errorf("failed to register HTTP metrics: %w", err)
+	}
+
 	var service controller.URLService = urlService
-	httpController := controller.NewHTTPController(service, auth)
+	httpController := controller.NewHTTPController(service, auth, controller.Options{
+		SyncDelete:       cfg.SyncDelete,
+		DeleteRetryAfter: cfg.DeleteRetryAfter,
+		ErrorFormat:      cfg.ErrorFormat,
+		TrustedSubnet:    trustedSubnet,
+		EnableSwagger:    cfg.EnableSwagger,
+		BaseURL:          cfg.BaseURL,
+		AllowReset:       cfg.AllowReset,
+
+		BatchLinkHeaders: cfg.BatchLinkHeaders,
+		GzipMinSize:      cfg.GzipMinSize,
+		GzipRoutes:       gzipRoutes,
+		RequestMetrics:   requestMetrics,
+		RedirectBody:     cfg.RedirectBody,
+		MaxBodyBytes:     cfg.MaxBodyBytes,
+
+		DeletedRedirectURL:  cfg.DeletedRedirectURL,
+		NotFoundRedirectURL: cfg.NotFoundRedirectURL,
+
+		Capabilities: controller.Capabilities{
+			Features: controller.CapabilityFeatures{
+				Idempotency: cfg.IdempotencyTTL > 0,
+			},
+			Limits: controller.CapabilityLimits{
+				IDLength: idLength,
+				VisitCap: cfg.VisitCap,
+			},
+		},
+	})
+
+	trustedProxies, err := middleware.ParseCIDRList(cfg.TrustedProxies)
+	if err != nil {
+		return fmt.Errorf("failed to parse trusted proxies: %w", err)
+	}
+	forwardedScheme := middleware.NewForwardedSchemeMiddleware(trustedProxies)
+
+	statusMetrics, err := middleware.NewStatusClassMetrics(prometheus.DefaultRegisterer)
+	if err != nil {
+		return fmt.Errorf("failed to register HTTP metrics: %w", err)
+	}
+
+	var handler http.Handler = httpController
+	if cfg.BaseURLHosts != "" {
+		// Короткие URL строятся от домена запроса; для прочих хостов используется BaseURL
+		handler = middleware.NewHostBaseURLMiddleware(strings.Split(cfg.BaseURLHosts, ","))(handler)
+	}
+	if cfg.LogBodies {
+		patterns, err := middleware.ParseRedactPatterns(append(middleware.DefaultRedactPatterns, strings.Split(cfg.LogRedact, ",")...))
+		if err != nil {
+			return fmt.Errorf("failed to parse log redact patterns: %w", err)
+		}
+		handler = middleware.NewBodyLogger(logBodyMaxSize, patterns)(handler)
+		logger.Info().Msg("Request and response bodies are logged at debug level")
+		if logger.GetLogger().GetLevel() > zerolog.DebugLevel {
+			logger.Warn().Str("log_level", cfg.LogLevel).Msg("LOG_BODIES has no effect unless LOG_LEVEL is debug")
+		}
+	}
+
+	if cfg.CORSOrigins != "" {
+		handler = middleware.NewCORSMiddleware(middleware.CORSOptions{
+			AllowedOrigins: strings.Split(cfg.CORSOrigins, ","),
+			MaxAge:         cfg.CORSMaxAge,
+			ExposedHeaders: middleware.DefaultCORSExposedHeaders,
+		})(handler)
+		logger.Info().
+			Str("origins", cfg.CORSOrigins).
+			Msg("CORS enabled")
+	}
 
 	server := &http.Server{
 		Addr:    cfg.ServerAddress,
-		Handler: middleware.RequestLogger(httpController),
+		Handler: middleware.RequestID(middleware.RequestLogger(statusMetrics(forwardedScheme(handler)))),
 	}
 
 	done := make(chan os.Signal, 1)
 	signal.Notify(done, os.Interrupt, syscall.SIGINT, syscall.SIGTERM)
 
+	// SIGHUP не завершает сервер, а перечитывает данные файлового хранилища
+	reload := make(chan os.Signal, 1)
+	signal.Notify(reload, syscall.SIGHUP)
+
 	// Канал для передачи ошибок сервера
 	serverErrChan := make(chan error, 1)
 
@@ -141,16 +549,22 @@ func run() error {
 	}()
 
 	// Ждем либо сигнал завершения, либо ошибку сервера
-	select {
-	case <-done:
-		logger.Info().Msg("Received shutdown signal")
-	case err := <-serverErrChan:
-		return err
+waitLoop:
+	for {
+		select {
+		case <-done:
+			logger.Info().Msg("Received shutdown signal")
+			break waitLoop
+		case <-reload:
+			reloadStorage(store)
+		case err := <-serverErrChan:
+			return err
+		}
 	}
 
 	logger.Info().Msg("Server stopped")
 
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
 	defer cancel()
 
 	if err := server.Shutdown(ctx); err != nil {
@@ -159,9 +573,26 @@ func run() error {
 			Msg("Failed to gracefully shutdown the server")
 	}
 
-	// Закрываем сервис удаления URL
-	urlService.Close()
+	// Закрываем сервис удаления URL со своим сроком, отсчитываемым после остановки сервера:
+	// долгие запросы не должны отнимать время у очереди удаления, а медленное удаление
+	// в БД - бесконечно задерживать остановку и бэкап
+	closeCtx, closeCancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+	defer closeCancel()
+
+	closed := make(chan struct{})
+	go func() {
+		urlService.Close()
+		close(closed)
+	}()
+	select {
+	case <-closed:
+	case <-closeCtx.Done():
+		logger.Info().
+			Dur("timeout", cfg.ShutdownTimeout).
+			Msg("Delete workers did not finish before shutdown timeout, pending deletes are dropped")
+	}
 
+	// Бэкап выполняется независимо от срока, данные в памяти иначе будут потеряны
 	if fileStorage, ok := store.(*storage.InMemoryStorage); ok {
 		if err := fileStorage.Backup(); err != nil {
 			logger.Info().
@@ -37,6 +37,12 @@ const docTemplate = `{
                         "schema": {
                             "type": "string"
                         }
+                    },
+                    {
+                        "type": "string",
+                        "description": "Ключ идемпотентности запроса",
+                        "name": "Idempotency-Key",
+                        "in": "header"
                     }
                 ],
                 "responses": {
@@ -52,8 +58,32 @@ const docTemplate = `{
                             "type": "string"
                         }
                     },
+                    "403": {
+                        "description": "Домен URL в списке запрещенных",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
                     "409": {
-                        "description": "URL уже существует",
+                        "description": "URL уже существует или запрос с тем же Idempotency-Key еще выполняется",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "413": {
+                        "description": "Тело запроса больше MAX_BODY_BYTES",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "422": {
+                        "description": "Idempotency-Key уже использован с другим URL",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "507": {
+                        "description": "Достигнуто ограничение MAX_URLS",
                         "schema": {
                             "type": "string"
                         }
@@ -61,180 +91,183 @@ const docTemplate = `{
                 }
             }
         },
-        "/api/shorten": {
-            "post": {
-                "description": "Принимает URL в формате JSON и возвращает сокращенную версию",
-                "consumes": [
+        "/api/capabilities": {
+            "get": {
+                "description": "Возвращает включенные возможности и ограничения, определенные конфигурацией сервиса",
+                "produces": [
                     "application/json"
                 ],
+                "tags": [
+                    "System"
+                ],
+                "summary": "Возможности сервиса",
+                "responses": {
+                    "200": {
+                        "description": "Возможности и ограничения",
+                        "schema": {
+                            "$ref": "#/definitions/controller.Capabilities"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/internal/db/stats": {
+            "get": {
+                "description": "Возвращает текущую статистику пула соединений PostgreSQL. Доступно только из доверенной подсети (X-Real-IP).",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "URLs"
+                    "Internal"
                 ],
-                "summary": "Сокращение URL (JSON формат)",
+                "summary": "Статистика пула соединений с БД",
                 "parameters": [
                     {
-                        "description": "URL для сокращения",
-                        "name": "request",
-                        "in": "body",
-                        "required": true,
-                        "schema": {
-                            "$ref": "#/definitions/controller.ShortenRequest"
-                        }
+                        "type": "string",
+                        "description": "IP клиента",
+                        "name": "X-Real-IP",
+                        "in": "header",
+                        "required": true
                     }
                 ],
                 "responses": {
-                    "201": {
-                        "description": "Сокращенный URL",
+                    "200": {
+                        "description": "Статистика пула",
                         "schema": {
-                            "$ref": "#/definitions/controller.ShortenResponse"
+                            "$ref": "#/definitions/usecase.PoolStats"
                         }
                     },
-                    "400": {
-                        "description": "Неверный запрос",
+                    "403": {
+                        "description": "IP не входит в доверенную подсеть",
                         "schema": {
                             "type": "string"
                         }
                     },
-                    "409": {
-                        "description": "URL уже существует",
+                    "404": {
+                        "description": "Хранилище не использует PostgreSQL",
                         "schema": {
-                            "$ref": "#/definitions/controller.ShortenResponse"
+                            "$ref": "#/definitions/controller.ErrorResponse"
                         }
                     }
                 }
             }
         },
-        "/api/shorten/batch": {
+        "/api/internal/gc": {
             "post": {
-                "description": "Принимает массив URL и возвращает их сокращенные версии",
-                "consumes": [
-                    "application/json"
-                ],
+                "description": "Физически удаляет ссылки с истекшим сроком действия и удаленные ссылки старше периода ожидания. Доступно только из доверенной подсети (X-Real-IP).",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "URLs"
+                    "Internal"
                 ],
-                "summary": "Пакетное сокращение URL",
+                "summary": "Очистка хранилища",
                 "parameters": [
                     {
-                        "description": "Массив URL для сокращения",
-                        "name": "request",
-                        "in": "body",
-                        "required": true,
-                        "schema": {
-                            "type": "array",
-                            "items": {
-                                "$ref": "#/definitions/usecase.BatchShortenRequest"
-                            }
-                        }
+                        "type": "string",
+                        "description": "IP клиента",
+                        "name": "X-Real-IP",
+                        "in": "header",
+                        "required": true
                     }
                 ],
                 "responses": {
-                    "201": {
-                        "description": "Массив сокращенных URL",
+                    "200": {
+                        "description": "Количество удаленных ссылок",
                         "schema": {
-                            "type": "array",
-                            "items": {
-                                "$ref": "#/definitions/usecase.BatchShortenResponse"
-                            }
+                            "$ref": "#/definitions/controller.GCResponse"
                         }
                     },
-                    "400": {
-                        "description": "Неверный запрос",
+                    "403": {
+                        "description": "IP не входит в доверенную подсеть",
                         "schema": {
                             "type": "string"
                         }
+                    },
+                    "500": {
+                        "description": "Внутренняя ошибка сервера",
+                        "schema": {
+                            "$ref": "#/definitions/controller.ErrorResponse"
+                        }
                     }
                 }
             }
         },
-        "/api/user/urls": {
-            "get": {
-                "security": [
-                    {
-                        "Cookie": []
-                    }
-                ],
-                "description": "Возвращает все сокращенные URL текущего пользователя",
+        "/api/internal/reset": {
+            "post": {
+                "description": "Удаляет все ссылки. Предназначено для тестовых окружений: регистрируется только при ALLOW_RESET=true и доступно только из доверенной подсети (X-Real-IP). Не чаще одного раза в секунду.",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "Users"
+                    "Internal"
+                ],
+                "summary": "Сброс хранилища",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "IP клиента",
+                        "name": "X-Real-IP",
+                        "in": "header",
+                        "required": true
+                    }
                 ],
-                "summary": "Получение URL пользователя",
                 "responses": {
                     "200": {
-                        "description": "Список URL пользователя",
+                        "description": "Количество удаленных ссылок",
                         "schema": {
-                            "type": "array",
-                            "items": {
-                                "$ref": "#/definitions/usecase.UserURL"
-                            }
+                            "$ref": "#/definitions/controller.ResetResponse"
                         }
                     },
-                    "204": {
-                        "description": "URL не найдены"
-                    },
-                    "401": {
-                        "description": "Не авторизован",
+                    "403": {
+                        "description": "IP не входит в доверенную подсеть",
                         "schema": {
                             "type": "string"
                         }
                     },
+                    "429": {
+                        "description": "Слишком частый сброс",
+                        "schema": {
+                            "$ref": "#/definitions/controller.ErrorResponse"
+                        }
+                    },
                     "500": {
                         "description": "Внутренняя ошибка сервера",
                         "schema": {
-                            "type": "string"
+                            "$ref": "#/definitions/controller.ErrorResponse"
                         }
                     }
                 }
-            },
-            "delete": {
-                "security": [
-                    {
-                        "Cookie": []
-                    }
-                ],
-                "description": "Удаляет указанные URL пользователя",
-                "consumes": [
+            }
+        },
+        "/api/internal/runtime": {
+            "get": {
+                "description": "Возвращает число горутин, статистику памяти и число воркеров удаления - часть сведений pprof без открытия его порта. Доступно только из доверенной подсети (X-Real-IP).",
+                "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "Users"
+                    "Internal"
                 ],
-                "summary": "Удаление URL пользователя",
+                "summary": "Показатели среды выполнения",
                 "parameters": [
                     {
-                        "description": "Массив коротких идентификаторов для удаления",
-                        "name": "shortIDs",
-                        "in": "body",
-                        "required": true,
-                        "schema": {
-                            "type": "array",
-                            "items": {
-                                "type": "string"
-                            }
-                        }
+                        "type": "string",
+                        "description": "IP клиента",
+                        "name": "X-Real-IP",
+                        "in": "header",
+                        "required": true
                     }
                 ],
                 "responses": {
-                    "202": {
-                        "description": "Запрос на удаление принят"
-                    },
-                    "400": {
-                        "description": "Неверный запрос",
+                    "200": {
+                        "description": "Показатели среды выполнения",
                         "schema": {
-                            "type": "string"
+                            "$ref": "#/definitions/usecase.RuntimeStats"
                         }
                     },
-                    "401": {
-                        "description": "Не авторизован",
+                    "403": {
+                        "description": "IP не входит в доверенную подсеть",
                         "schema": {
                             "type": "string"
                         }
@@ -242,37 +275,65 @@ const docTemplate = `{
                 }
             }
         },
-        "/ping": {
+        "/api/internal/stats": {
             "get": {
-                "description": "Проверяет подключение к базе данных",
+                "description": "Возвращает количество неудаленных и неистекших ссылок и пользователей, у которых они есть. Доступно только из доверенной подсети (X-Real-IP).",
+                "produces": [
+                    "application/json"
+                ],
                 "tags": [
-                    "System"
+                    "Internal"
+                ],
+                "summary": "Статистика сервиса",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "IP клиента",
+                        "name": "X-Real-IP",
+                        "in": "header",
+                        "required": true
+                    }
                 ],
-                "summary": "Проверка работоспособности",
                 "responses": {
                     "200": {
-                        "description": "БД доступна",
+                        "description": "Статистика сервиса",
+                        "schema": {
+                            "$ref": "#/definitions/usecase.Stats"
+                        }
+                    },
+                    "403": {
+                        "description": "IP не входит в доверенную подсеть",
                         "schema": {
                             "type": "string"
                         }
                     },
                     "500": {
-                        "description": "Ошибка подключения к БД",
+                        "description": "Ошибка хранилища",
                         "schema": {
-                            "type": "string"
+                            "$ref": "#/definitions/controller.ErrorResponse"
                         }
                     }
                 }
             }
         },
-        "/{shortID}": {
+        "/api/internal/urls/{shortID}/owner": {
             "get": {
-                "description": "Перенаправляет на оригинальный URL по его короткому идентификатору",
+                "description": "Возвращает пользователя, создавшего короткий URL, и время создания. Работает и для удаленных ссылок. Доступно только из доверенной подсети (X-Real-IP).",
+                "produces": [
+                    "application/json"
+                ],
                 "tags": [
-                    "URLs"
+                    "Internal"
                 ],
-                "summary": "Получение оригинального URL",
+                "summary": "Создатель короткого URL",
                 "parameters": [
+                    {
+                        "type": "string",
+                        "description": "IP клиента",
+                        "name": "X-Real-IP",
+                        "in": "header",
+                        "required": true
+                    },
                     {
                         "type": "string",
                         "description": "Короткий идентификатор URL",
@@ -282,8 +343,14 @@ const docTemplate = `{
                     }
                 ],
                 "responses": {
-                    "307": {
-                        "description": "Перенаправление",
+                    "200": {
+                        "description": "Создатель URL",
+                        "schema": {
+                            "$ref": "#/definitions/usecase.URLOwner"
+                        }
+                    },
+                    "403": {
+                        "description": "IP не входит в доверенную подсеть",
                         "schema": {
                             "type": "string"
                         }
@@ -291,62 +358,996 @@ const docTemplate = `{
                     "404": {
                         "description": "URL не найден",
                         "schema": {
-                            "type": "string"
+                            "$ref": "#/definitions/controller.ErrorResponse"
                         }
                     },
-                    "410": {
-                        "description": "URL был удален",
+                    "500": {
+                        "description": "Внутренняя ошибка сервера",
                         "schema": {
-                            "type": "string"
+                            "$ref": "#/definitions/controller.ErrorResponse"
                         }
                     }
                 }
             }
-        }
-    },
-    "definitions": {
-        "controller.ShortenRequest": {
-            "type": "object",
-            "properties": {
-                "url": {
-                    "description": "URL для сокращения",
-                    "type": "string",
-                    "example": "https://practicum.yandex.ru"
-                }
-            }
-        },
-        "controller.ShortenResponse": {
-            "type": "object",
-            "properties": {
-                "result": {
-                    "description": "Сокращенный URL",
-                    "type": "string",
-                    "example": "http://localhost:8080/abcd1234"
-                }
-            }
         },
-        "usecase.BatchShortenRequest": {
-            "type": "object",
-            "properties": {
-                "correlation_id": {
-                    "type": "string"
-                },
-                "original_url": {
-                    "type": "string"
+        "/api/public/recent": {
+            "get": {
+                "description": "Возвращает последние ссылки, созданные с \"public\": true, новые первыми. Удаленные и истекшие ссылки не показываются.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "URLs"
+                ],
+                "summary": "Публичная лента недавно созданных ссылок",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "default": 20,
+                        "description": "Количество ссылок, от 1 до 100",
+                        "name": "limit",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Публичные ссылки",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/usecase.PublicURL"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Неверный limit",
+                        "schema": {
+                            "$ref": "#/definitions/controller.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Внутренняя ошибка сервера",
+                        "schema": {
+                            "$ref": "#/definitions/controller.ErrorResponse"
+                        }
+                    }
                 }
             }
         },
-        "usecase.BatchShortenResponse": {
-            "type": "object",
-            "properties": {
-                "correlation_id": {
-                    "type": "string"
+        "/api/shorten": {
+            "post": {
+                "description": "Принимает URL в формате JSON и возвращает сокращенную версию",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "URLs"
+                ],
+                "summary": "Сокращение URL (JSON формат)",
+                "parameters": [
+                    {
+                        "description": "URL для сокращения",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/controller.ShortenRequest"
+                        }
+                    },
+                    {
+                        "type": "string",
+                        "description": "Ключ идемпотентности запроса",
+                        "name": "Idempotency-Key",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Сокращенный URL",
+                        "schema": {
+                            "$ref": "#/definitions/controller.ShortenResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Неверный запрос",
+                        "schema": {
+                            "$ref": "#/definitions/controller.ErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Домен URL в списке запрещенных",
+                        "schema": {
+                            "$ref": "#/definitions/controller.ErrorResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "URL уже существует или запрос с тем же Idempotency-Key еще выполняется",
+                        "schema": {
+                            "$ref": "#/definitions/controller.ShortenResponse"
+                        }
+                    },
+                    "413": {
+                        "description": "Тело запроса больше MAX_BODY_BYTES",
+                        "schema": {
+                            "$ref": "#/definitions/controller.ErrorResponse"
+                        }
+                    },
+                    "415": {
+                        "description": "Content-Type не application/json",
+                        "schema": {
+                            "$ref": "#/definitions/controller.ErrorResponse"
+                        }
+                    },
+                    "422": {
+                        "description": "Idempotency-Key уже использован с другим URL",
+                        "schema": {
+                            "$ref": "#/definitions/controller.ErrorResponse"
+                        }
+                    },
+                    "507": {
+                        "description": "Достигнуто ограничение MAX_URLS",
+                        "schema": {
+                            "$ref": "#/definitions/controller.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/shorten/batch": {
+            "post": {
+                "description": "Принимает массив URL и возвращает их сокращенные версии",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "URLs"
+                ],
+                "summary": "Пакетное сокращение URL",
+                "parameters": [
+                    {
+                        "description": "Массив URL для сокращения",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/usecase.BatchShortenRequest"
+                            }
+                        }
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Потоковый режим: массив читается и записывается частями по мере сокращения",
+                        "name": "stream",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Массив сокращенных URL; в потоковом режиме - массив BatchStreamItem",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/usecase.BatchShortenResponse"
+                            }
+                        },
+                        "headers": {
+                            "Link": {
+                                "type": "string",
+                                "description": "Созданные короткие URL (rel=item), если включен BATCH_LINK_HEADERS"
+                            }
+                        }
+                    },
+                    "207": {
+                        "description": "URL сохранены частично",
+                        "schema": {
+                            "$ref": "#/definitions/usecase.BatchMultiStatusResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Неверный запрос",
+                        "schema": {
+                            "$ref": "#/definitions/controller.ErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Домен URL в списке запрещенных",
+                        "schema": {
+                            "$ref": "#/definitions/controller.ErrorResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "Все URL уже существуют (CONFLICT_STRATEGY=error)",
+                        "schema": {
+                            "$ref": "#/definitions/controller.ErrorResponse"
+                        }
+                    },
+                    "413": {
+                        "description": "Тело запроса больше MAX_BODY_BYTES",
+                        "schema": {
+                            "$ref": "#/definitions/controller.ErrorResponse"
+                        }
+                    },
+                    "415": {
+                        "description": "Content-Type не application/json",
+                        "schema": {
+                            "$ref": "#/definitions/controller.ErrorResponse"
+                        }
+                    },
+                    "507": {
+                        "description": "Достигнуто ограничение MAX_URLS",
+                        "schema": {
+                            "$ref": "#/definitions/controller.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/user/urls": {
+            "get": {
+                "security": [
+                    {
+                        "Cookie": []
+                    }
+                ],
+                "description": "Возвращает сокращенные URL текущего пользователя в порядке создания постранично. Общее количество ссылок передается в заголовке X-Total-Count. Запрос без валидной куки получает 401 и новую куку.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Users"
+                ],
+                "summary": "Получение URL пользователя",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "default": 100,
+                        "description": "Количество ссылок, от 1 до 1000",
+                        "name": "limit",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "default": 0,
+                        "description": "Сколько первых ссылок пропустить",
+                        "name": "offset",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Список URL пользователя; пустой, если offset больше количества ссылок",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/usecase.UserURL"
+                            }
+                        },
+                        "headers": {
+                            "X-Total-Count": {
+                                "type": "integer",
+                                "description": "Общее количество ссылок пользователя"
+                            }
+                        }
+                    },
+                    "204": {
+                        "description": "URL не найдены",
+                        "headers": {
+                            "X-Total-Count": {
+                                "type": "integer",
+                                "description": "Общее количество ссылок пользователя"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Неверный limit или offset",
+                        "schema": {
+                            "$ref": "#/definitions/controller.ErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Не авторизован",
+                        "schema": {
+                            "$ref": "#/definitions/controller.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Внутренняя ошибка сервера",
+                        "schema": {
+                            "$ref": "#/definitions/controller.ErrorResponse"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "security": [
+                    {
+                        "Cookie": []
+                    }
+                ],
+                "description": "Удаляет указанные URL пользователя",
+                "consumes": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Users"
+                ],
+                "summary": "Удаление URL пользователя",
+                "parameters": [
+                    {
+                        "description": "Массив коротких идентификаторов для удаления",
+                        "name": "shortIDs",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Итог удаления (синхронный режим)",
+                        "schema": {
+                            "$ref": "#/definitions/usecase.DeleteResult"
+                        }
+                    },
+                    "202": {
+                        "description": "Запрос на удаление принят"
+                    },
+                    "400": {
+                        "description": "Неверный запрос",
+                        "schema": {
+                            "$ref": "#/definitions/controller.ErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Не авторизован",
+                        "schema": {
+                            "$ref": "#/definitions/controller.ErrorResponse"
+                        }
+                    },
+                    "413": {
+                        "description": "Тело запроса больше MAX_BODY_BYTES",
+                        "schema": {
+                            "$ref": "#/definitions/controller.ErrorResponse"
+                        }
+                    },
+                    "415": {
+                        "description": "Content-Type не application/json",
+                        "schema": {
+                            "$ref": "#/definitions/controller.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Внутренняя ошибка сервера",
+                        "schema": {
+                            "$ref": "#/definitions/controller.ErrorResponse"
+                        }
+                    },
+                    "503": {
+                        "description": "Очередь удаления переполнена, повторите запрос через Retry-After секунд",
+                        "schema": {
+                            "$ref": "#/definitions/controller.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/user/urls/all": {
+            "delete": {
+                "security": [
+                    {
+                        "Cookie": []
+                    }
+                ],
+                "description": "Асинхронно удаляет все URL текущего пользователя, существующие на момент обработки запроса. Запрос без валидной куки получает 401.",
+                "tags": [
+                    "Users"
+                ],
+                "summary": "Удаление всех URL пользователя",
+                "responses": {
+                    "202": {
+                        "description": "Запрос на удаление принят"
+                    },
+                    "401": {
+                        "description": "Не авторизован",
+                        "schema": {
+                            "$ref": "#/definitions/controller.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Внутренняя ошибка сервера",
+                        "schema": {
+                            "$ref": "#/definitions/controller.ErrorResponse"
+                        }
+                    },
+                    "503": {
+                        "description": "Очередь удаления переполнена, повторите запрос через Retry-After секунд",
+                        "schema": {
+                            "$ref": "#/definitions/controller.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/user/urls/{shortID}": {
+            "get": {
+                "security": [
+                    {
+                        "Cookie": []
+                    }
+                ],
+                "description": "Возвращает URL, если он принадлежит текущему пользователю. Чужие и несуществующие URL одинаково возвращают 404.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Users"
+                ],
+                "summary": "Получение URL пользователя по идентификатору",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Короткий идентификатор URL",
+                        "name": "shortID",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "URL пользователя",
+                        "schema": {
+                            "$ref": "#/definitions/usecase.UserURL"
+                        }
+                    },
+                    "401": {
+                        "description": "Не авторизован",
+                        "schema": {
+                            "$ref": "#/definitions/controller.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "URL не найден",
+                        "schema": {
+                            "$ref": "#/definitions/controller.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Внутренняя ошибка сервера",
+                        "schema": {
+                            "$ref": "#/definitions/controller.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/livez": {
+            "get": {
+                "description": "Проверяет, что воркеры асинхронного удаления обрабатывают очередь",
+                "tags": [
+                    "System"
+                ],
+                "summary": "Проверка живости",
+                "responses": {
+                    "200": {
+                        "description": "Сервис жив",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "503": {
+                        "description": "Воркеры удаления зависли",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/ping": {
+            "get": {
+                "description": "Проверяет подключение к базе данных",
+                "tags": [
+                    "System"
+                ],
+                "summary": "Проверка работоспособности",
+                "responses": {
+                    "200": {
+                        "description": "БД доступна",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Ошибка подключения к БД",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/readyz": {
+            "get": {
+                "description": "Проверяет подключение к БД и, если включено READYZ_CHECK_WRITE, возможность записи",
+                "tags": [
+                    "System"
+                ],
+                "summary": "Проверка готовности",
+                "responses": {
+                    "200": {
+                        "description": "Сервис готов",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "503": {
+                        "description": "БД недоступна или не принимает запись",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/{shortID}": {
+            "get": {
+                "description": "Перенаправляет на оригинальный URL по его короткому идентификатору",
+                "tags": [
+                    "URLs"
+                ],
+                "summary": "Получение оригинального URL",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Короткий идентификатор URL",
+                        "name": "shortID",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "302": {
+                        "description": "Перенаправление на DELETED_REDIRECT_URL или NOT_FOUND_REDIRECT_URL",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "307": {
+                        "description": "Перенаправление; при REDIRECT_BODY=true тело содержит целевой URL",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "400": {
+                        "description": "Схема URL запрещена",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "URL не найден",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "410": {
+                        "description": "URL был удален или истек срок его действия",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            },
+            "head": {
+                "description": "Возвращает тот же статус и заголовок Location, что и GET, но без тела и без учета перехода",
+                "tags": [
+                    "URLs"
+                ],
+                "summary": "Проверка короткого URL",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Короткий идентификатор URL",
+                        "name": "shortID",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "302": {
+                        "description": "Перенаправление на DELETED_REDIRECT_URL или NOT_FOUND_REDIRECT_URL",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "307": {
+                        "description": "Ссылка действительна, заголовок Location содержит оригинальный URL",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "400": {
+                        "description": "Схема URL запрещена",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "URL не найден",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "410": {
+                        "description": "URL был удален или истек срок его действия",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        }
+    },
+    "definitions": {
+        "controller.Capabilities": {
+            "type": "object",
+            "properties": {
+                "features": {
+                    "$ref": "#/definitions/controller.CapabilityFeatures"
+                },
+                "limits": {
+                    "$ref": "#/definitions/controller.CapabilityLimits"
+                }
+            }
+        },
+        "controller.CapabilityFeatures": {
+            "type": "object",
+            "properties": {
+                "batch": {
+                    "description": "пакетное сокращение /api/shorten/batch",
+                    "type": "boolean"
+                },
+                "batch_link_headers": {
+                    "description": "заголовки Link в ответе batch",
+                    "type": "boolean"
+                },
+                "custom_aliases": {
+                    "description": "собственные короткие идентификаторы",
+                    "type": "boolean"
+                },
+                "idempotency": {
+                    "description": "заголовок Idempotency-Key",
+                    "type": "boolean"
+                },
+                "qr": {
+                    "description": "QR-коды коротких ссылок",
+                    "type": "boolean"
+                },
+                "sync_delete": {
+                    "description": "синхронное удаление с итогом по каждому ID",
+                    "type": "boolean"
+                },
+                "ttl": {
+                    "description": "срок действия ссылки (expires_in, expires_at)",
+                    "type": "boolean"
+                }
+            }
+        },
+        "controller.CapabilityLimits": {
+            "type": "object",
+            "properties": {
+                "id_length": {
+                    "description": "0 - длина переменная (ID_MODE=sequential)",
+                    "type": "integer",
+                    "example": 8
+                },
+                "max_batch_size": {
+                    "type": "integer",
+                    "example": 0
+                },
+                "max_url_length": {
+                    "type": "integer",
+                    "example": 0
+                },
+                "redirect_statuses": {
+                    "type": "array",
+                    "items": {
+                        "type": "integer"
+                    },
+                    "example": [
+                        307
+                    ]
+                },
+                "visit_cap": {
+                    "type": "integer",
+                    "example": 0
+                }
+            }
+        },
+        "controller.ErrorResponse": {
+            "type": "object",
+            "properties": {
+                "error": {
+                    "type": "string",
+                    "example": "Invalid JSON"
+                }
+            }
+        },
+        "controller.GCResponse": {
+            "type": "object",
+            "properties": {
+                "purged": {
+                    "type": "integer",
+                    "example": 42
+                }
+            }
+        },
+        "controller.ResetResponse": {
+            "type": "object",
+            "properties": {
+                "removed": {
+                    "type": "integer",
+                    "example": 42
+                }
+            }
+        },
+        "controller.ShortenRequest": {
+            "type": "object",
+            "properties": {
+                "expires_at": {
+                    "description": "время истечения ссылки в формате RFC3339",
+                    "type": "string",
+                    "example": "2030-01-01T00:00:00Z"
+                },
+                "expires_in": {
+                    "description": "срок действия ссылки в формате Go duration",
+                    "type": "string",
+                    "example": "24h"
+                },
+                "public": {
+                    "description": "показывать ссылку в публичной ленте /api/public/recent",
+                    "type": "boolean",
+                    "example": false
+                },
+                "ttl_seconds": {
+                    "description": "срок действия ссылки в секундах",
+                    "type": "integer",
+                    "example": 3600
+                },
+                "url": {
+                    "description": "URL для сокращения",
+                    "type": "string",
+                    "example": "https://practicum.yandex.ru"
+                }
+            }
+        },
+        "controller.ShortenResponse": {
+            "type": "object",
+            "properties": {
+                "result": {
+                    "description": "Сокращенный URL",
+                    "type": "string",
+                    "example": "http://localhost:8080/abcd1234"
+                }
+            }
+        },
+        "usecase.BatchFailure": {
+            "type": "object",
+            "properties": {
+                "correlation_id": {
+                    "type": "string"
+                },
+                "reason": {
+                    "type": "string",
+                    "enum": [
+                        "conflict",
+                        "blocked",
+                        "storage_full",
+                        "internal"
+                    ]
+                }
+            }
+        },
+        "usecase.BatchMultiStatusResponse": {
+            "type": "object",
+            "properties": {
+                "failed": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/usecase.BatchFailure"
+                    }
+                },
+                "succeeded": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/usecase.BatchShortenResponse"
+                    }
+                }
+            }
+        },
+        "usecase.BatchShortenRequest": {
+            "type": "object",
+            "properties": {
+                "correlation_id": {
+                    "type": "string"
+                },
+                "original_url": {
+                    "type": "string"
+                }
+            }
+        },
+        "usecase.BatchShortenResponse": {
+            "type": "object",
+            "properties": {
+                "correlation_id": {
+                    "type": "string"
                 },
                 "short_url": {
                     "type": "string"
                 }
             }
         },
+        "usecase.DeleteResult": {
+            "type": "object",
+            "properties": {
+                "already_deleted": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "deleted": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "not_found": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                }
+            }
+        },
+        "usecase.PoolStats": {
+            "type": "object",
+            "properties": {
+                "acquire_count": {
+                    "type": "integer",
+                    "example": 1500
+                },
+                "acquire_duration_seconds": {
+                    "description": "суммарное время получения соединений",
+                    "type": "number",
+                    "example": 0.35
+                },
+                "acquired_conns": {
+                    "type": "integer",
+                    "example": 3
+                },
+                "canceled_acquire_count": {
+                    "type": "integer",
+                    "example": 0
+                },
+                "constructing_conns": {
+                    "type": "integer",
+                    "example": 0
+                },
+                "empty_acquire_count": {
+                    "description": "получения, ожидавшие свободного соединения",
+                    "type": "integer",
+                    "example": 12
+                },
+                "empty_acquire_wait_seconds": {
+                    "description": "суммарное время ожидания свободного соединения",
+                    "type": "number",
+                    "example": 0.2
+                },
+                "idle_conns": {
+                    "type": "integer",
+                    "example": 7
+                },
+                "max_conns": {
+                    "type": "integer",
+                    "example": 10
+                },
+                "total_conns": {
+                    "type": "integer",
+                    "example": 10
+                }
+            }
+        },
+        "usecase.PublicURL": {
+            "type": "object",
+            "properties": {
+                "created_at": {
+                    "type": "string",
+                    "example": "2025-01-01T12:00:00Z"
+                },
+                "original_url": {
+                    "type": "string",
+                    "example": "https://practicum.yandex.ru"
+                },
+                "short_url": {
+                    "type": "string",
+                    "example": "http://localhost:8080/abcd1234"
+                }
+            }
+        },
+        "usecase.RuntimeStats": {
+            "type": "object",
+            "properties": {
+                "alloc_bytes": {
+                    "description": "память, занятая объектами кучи",
+                    "type": "integer",
+                    "example": 4194304
+                },
+                "delete_workers": {
+                    "description": "запущенные воркеры асинхронного удаления",
+                    "type": "integer",
+                    "example": 3
+                },
+                "gc_count": {
+                    "description": "число завершенных циклов GC",
+                    "type": "integer",
+                    "example": 17
+                },
+                "goroutines": {
+                    "type": "integer",
+                    "example": 42
+                },
+                "heap_objects": {
+                    "description": "количество объектов в куче",
+                    "type": "integer",
+                    "example": 25000
+                },
+                "sys_bytes": {
+                    "description": "память, полученная от ОС",
+                    "type": "integer",
+                    "example": 16777216
+                }
+            }
+        },
+        "usecase.Stats": {
+            "type": "object",
+            "properties": {
+                "urls": {
+                    "type": "integer",
+                    "example": 1500
+                },
+                "users": {
+                    "type": "integer",
+                    "example": 42
+                }
+            }
+        },
+        "usecase.URLOwner": {
+            "type": "object",
+            "properties": {
+                "created_at": {
+                    "type": "string",
+                    "example": "2025-01-01T12:00:00Z"
+                },
+                "user_id": {
+                    "description": "пусто для анонимных URL",
+                    "type": "string",
+                    "example": "3f8a9c1e-0b7d-4e5f-9a2b-6c1d8e7f0a3b"
+                }
+            }
+        },
         "usecase.UserURL": {
             "type": "object",
             "properties": {
@@ -355,6 +1356,13 @@ const docTemplate = `{
                 },
                 "short_url": {
                     "type": "string"
+                },
+                "visits": {
+                    "type": "integer"
+                },
+                "visits_capped": {
+                    "description": "счетчик достиг VISIT_CAP и больше не растет, Visits - нижняя граница",
+                    "type": "boolean"
                 }
             }
         }
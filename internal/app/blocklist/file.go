@@ -0,0 +1,129 @@
+// Package blocklist предоставляет реализации списка запрещенных доменов
+package blocklist
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/m-molecula741/shortener/internal/app/logger"
+)
+
+// FileBlocklist загружает запрещенные домены из файла (по одному на строку)
+// и периодически перечитывает его. Пустые строки и строки, начинающиеся с #, пропускаются.
+type FileBlocklist struct {
+	path string
+
+	mu      sync.RWMutex
+	domains map[string]struct{}
+
+	stop chan struct{}
+	wg   sync.WaitGroup
+}
+
+// NewFileBlocklist загружает список из файла и, если interval > 0,
+// запускает его периодическое перечитывание
+func NewFileBlocklist(path string, interval time.Duration) (*FileBlocklist, error) {
+	b := &FileBlocklist{
+		path: path,
+		stop: make(chan struct{}),
+	}
+
+	if err := b.Reload(); err != nil {
+		return nil, err
+	}
+
+	if interval > 0 {
+		b.wg.Add(1)
+		go b.reloadLoop(interval)
+	}
+
+	return b, nil
+}
+
+// Reload перечитывает файл. При ошибке текущий список сохраняется.
+func (b *FileBlocklist) Reload() error {
+	file, err := os.Open(b.path)
+	if err != nil {
+		return fmt.Errorf("cannot open blocklist: %w", err)
+	}
+	defer file.Close()
+
+	domains := make(map[string]struct{})
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		domains[normalizeHost(line)] = struct{}{}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("cannot read blocklist: %w", err)
+	}
+
+	b.mu.Lock()
+	b.domains = domains
+	b.mu.Unlock()
+
+	return nil
+}
+
+// reloadLoop перечитывает файл с заданным интервалом до вызова Close
+func (b *FileBlocklist) reloadLoop(interval time.Duration) {
+	defer b.wg.Done()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if err := b.Reload(); err != nil {
+				logger.Info().
+					Err(err).
+					Msg("Failed to reload blocklist")
+			}
+		case <-b.stop:
+			return
+		}
+	}
+}
+
+// IsBlocked проверяет, входит ли хост или один из его родительских доменов в список
+func (b *FileBlocklist) IsBlocked(ctx context.Context, host string) (bool, error) {
+	host = normalizeHost(host)
+
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	for host != "" {
+		if _, blocked := b.domains[host]; blocked {
+			return true, nil
+		}
+
+		// Переходим к родительскому домену: sub.evil.com -> evil.com
+		dot := strings.IndexByte(host, '.')
+		if dot == -1 {
+			break
+		}
+		host = host[dot+1:]
+	}
+
+	return false, nil
+}
+
+// Close останавливает периодическое перечитывание файла
+func (b *FileBlocklist) Close() {
+	close(b.stop)
+	b.wg.Wait()
+}
+
+// normalizeHost приводит хост к нижнему регистру и отбрасывает завершающую точку
+func normalizeHost(host string) string {
+	return strings.TrimSuffix(strings.ToLower(strings.TrimSpace(host)), ".")
+}
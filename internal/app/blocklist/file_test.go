@@ -0,0 +1,69 @@
+package blocklist
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFileBlocklist_IsBlocked(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "blocklist.txt")
+	require.NoError(t, os.WriteFile(path, []byte("# phishing\nEvil.com\n\nbad.example.org.\n"), 0o600))
+
+	b, err := NewFileBlocklist(path, 0)
+	require.NoError(t, err)
+	defer b.Close()
+
+	tests := []struct {
+		host    string
+		blocked bool
+	}{
+		{host: "evil.com", blocked: true},
+		{host: "EVIL.COM", blocked: true},
+		{host: "login.evil.com", blocked: true},
+		{host: "bad.example.org", blocked: true},
+		{host: "example.org", blocked: false},
+		{host: "notevil.com", blocked: false},
+		{host: "example.com", blocked: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.host, func(t *testing.T) {
+			blocked, err := b.IsBlocked(context.Background(), tt.host)
+			require.NoError(t, err)
+			assert.Equal(t, tt.blocked, blocked)
+		})
+	}
+}
+
+func TestFileBlocklist_Reload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "blocklist.txt")
+	require.NoError(t, os.WriteFile(path, []byte("evil.com\n"), 0o600))
+
+	b, err := NewFileBlocklist(path, 0)
+	require.NoError(t, err)
+	defer b.Close()
+
+	require.NoError(t, os.WriteFile(path, []byte("other.com\n"), 0o600))
+	require.NoError(t, b.Reload())
+
+	blocked, _ := b.IsBlocked(context.Background(), "evil.com")
+	assert.False(t, blocked)
+	blocked, _ = b.IsBlocked(context.Background(), "other.com")
+	assert.True(t, blocked)
+
+	// При ошибке чтения остается предыдущий список
+	require.NoError(t, os.Remove(path))
+	assert.Error(t, b.Reload())
+	blocked, _ = b.IsBlocked(context.Background(), "other.com")
+	assert.True(t, blocked)
+}
+
+func TestNewFileBlocklist_MissingFile(t *testing.T) {
+	_, err := NewFileBlocklist(filepath.Join(t.TempDir(), "missing.txt"), 0)
+	assert.Error(t, err)
+}
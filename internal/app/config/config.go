@@ -3,35 +3,232 @@ package config
 
 import (
 	"flag"
+	"fmt"
+	"net"
+	"net/url"
 	"os"
 	"strconv"
+	"strings"
+	"time"
 )
 
 // Константы для конфигурации
 const (
-	defaultStorageFile = "urls.json"
+	defaultStorageFile      = "urls.json"
+	defaultIdempotencyTTL   = 24 * time.Hour
+	defaultSecretKey        = "secret-key-for-auth"
+	defaultAllowedSchemes   = "http,https"
+	defaultRedirectScheme   = "https"
+	defaultGCInterval       = time.Hour
+	defaultGCGracePeriod    = 24 * time.Hour
+	defaultBlocklistReload  = 5 * time.Minute
+	defaultWorkerStall      = time.Minute
+	defaultGzipMinSize      = 1400
+	defaultMaxBodyBytes     = 1 << 20
+	defaultGzipRoutes       = "shorten,api,swagger"
+	defaultDBPort           = "5432"
+	defaultDBRetries        = 5
+	defaultDBBackoff        = 500 * time.Millisecond
+	defaultDeleteRetries    = 3
+	defaultDeleteBackoff    = 100 * time.Millisecond
+	defaultDeleteRetryAfter = time.Second
+	defaultLogMaxSize       = 100
+	defaultLogMaxBackups    = 3
+	defaultLogMaxAge        = 28
+	defaultCORSMaxAge       = 10 * time.Minute
+	defaultShutdownTimeout  = 30 * time.Second
+	defaultShortIDLength    = 8
+	minShortIDLength        = 4
+	maxShortIDLength        = 32
+	defaultShortIDAttempts  = 5
+	defaultCookieSameSite   = "lax"
+)
+
+// Форматы логов
+const (
+	LogFormatConsole = "console" // форматированный вывод для человека
+	LogFormatJSON    = "json"    // JSON по строке на событие для сборщиков логов
 )
 
 // Config представляет конфигурацию приложения
 type Config struct {
-	ServerAddress   string // адрес HTTP-сервера
-	BaseURL         string // базовый адрес для сокращенных URL
-	StorageFilePath string // путь к файлу для хранения URL
-	DatabaseDSN     string // строка подключения к базе данных
-	EnablePprof     bool   // включить профилирование pprof
+	ServerAddress    string        // адрес HTTP-сервера
+	BaseURL          string        // базовый адрес для сокращенных URL
+	BaseURLHosts     string        // домены через запятую, для которых базовый адрес берется из Host запроса
+	StorageFilePath  string        // путь к файлу для хранения URL
+	DatabaseDSN      string        // строка подключения к базе данных
+	DatabaseShards   string        // строки подключения шардов PostgreSQL через запятую
+	DBConnectRetries int           // число повторных проверок подключения к PostgreSQL при старте
+	DBConnectBackoff time.Duration // пауза перед первой повторной проверкой подключения, удваивается с каждой попыткой
+	EnablePprof      bool          // включить профилирование pprof
+	SecretKey        string        // ключ шифрования куки аутентификации
+	PreviousKeys     string        // прежние ключи куки через запятую: ими куки только расшифровываются
+	CookieHTTPOnly   bool          // выставлять HttpOnly для куки аутентификации (отключать только для SPA)
+	CookieSecure     bool          // выставлять Secure для куки аутентификации; включается автоматически для https BaseURL
+	CookieSameSite   string        // атрибут SameSite куки аутентификации: lax, strict или none
+	CookieDomain     string        // домен куки аутентификации; пусто - только хост запроса
+	SyncDelete       bool          // удалять URL синхронно и возвращать итог удаления
+	TrustedProxies   string        // подсети доверенных прокси в формате CIDR через запятую
+	CORSOrigins      string        // источники через запятую, которым разрешены запросы из браузера; пусто - CORS отключен
+	Dedup            bool          // возвращать существующий короткий URL для повторного original_url
+	ConflictStrategy string        // поведение при повторном original_url: existing, new или error
+	IDMode           string        // генерация коротких ID: random или sequential (последовательность PostgreSQL)
+	PrintVersion     bool          // вывести информацию о сборке в JSON и завершиться
+	EncryptAtRest    bool          // хранить оригинальные URL в зашифрованном виде
+	URLKey           string        // ключ шифрования оригинальных URL, обязателен при EncryptAtRest
+	URLPreviousKeys  string        // прежние ключи шифрования URL через запятую: ими URL только расшифровываются
+	CompressURLs     bool          // хранить длинные оригинальные URL сжатыми zlib
+	PersistVisits    bool          // сохранять счетчики переходов в файл хранилища
+	AllowedSchemes   string        // разрешенные схемы оригинальных URL через запятую
+	BlocklistFile    string        // файл со списком запрещенных доменов, пусто - проверка отключена
+	DefaultScheme    string        // схема для сохраненных URL без схемы при редиректе
+	StripFragments   bool          // отбрасывать #fragment оригинальных URL перед сохранением
+	StrictStorage    bool          // завершать запуск при неоднозначной настройке хранилища
+	ErrorFormat      string        // формат ошибок API: simple или problem (RFC 7807)
+	TrustedSubnet    string        // доверенная подсеть в формате CIDR для служебных эндпоинтов
+	AllowReset       bool          // разрешить POST /api/internal/reset (только для тестовых окружений)
+	ReadyzWrite      bool          // проверять в /readyz возможность записи в БД пробной транзакцией
+	BatchLinkHeaders bool          // добавлять в ответ batch заголовки Link с созданными URL
+	LogBodies        bool          // логировать тела запросов и ответов на уровне debug
+	EnableSwagger    bool          // включить Swagger UI (рекомендуется отключать в production)
+	LogRedact        string        // дополнительные регулярные выражения для скрытия данных в логах, через запятую
+	LogFile          string        // файл логов с ротацией; пусто - вывод в stdout
+	LogLevel         string        // минимальный уровень логов: debug, info, warn или error
+	LogFormat        string        // формат логов: console (для человека) или json
+	LogMaxSize       int           // размер файла логов в мегабайтах, после которого он ротируется
+	LogMaxBackups    int           // сколько ротированных файлов логов хранить, 0 - все
+	LogMaxAge        int           // сколько дней хранить ротированные файлы логов, 0 - не удалять по возрасту
+
+	IdempotencyTTL   time.Duration // время хранения ключей идемпотентности, 0 - отключено
+	IdempotencyRedis string        // адрес Redis для ключей идемпотентности, общих для реплик; пусто - в памяти
+	BlocklistReload  time.Duration // интервал перечитывания списка запрещенных доменов, 0 - отключено
+	ExpectedURLs     int64         // ожидаемое количество ссылок для оценки вероятности коллизий, 0 - не оценивать
+	ShortIDLength    int           // длина случайных коротких ID, от minShortIDLength до maxShortIDLength
+	ShortIDAttempts  int           // попыток сохранить URL с новым коротким ID при коллизии
+	GCInterval       time.Duration // интервал фоновой очистки истекших и удаленных ссылок, 0 - отключено
+	GCGracePeriod    time.Duration // сколько хранить удаленные ссылки перед физическим удалением
+	WorkerStall      time.Duration // время без прогресса воркеров удаления, после которого /livez отвечает 503
+	CORSMaxAge       time.Duration // сколько браузер кеширует результат preflight запроса CORS
+	FirstRequestWin  time.Duration // окно, в котором первые запросы клиента без куки с одним X-Client-Nonce получают один ID; 0 - отключено
+	SlowQuery        time.Duration // длительность операции хранилища, после которой она логируется как медленная; 0 - отключено
+	ShutdownTimeout  time.Duration // сколько ждать завершения запросов и воркеров удаления при остановке
+
+	DeleteRetries      int           // число повторов асинхронного удаления при ошибке хранилища
+	DeleteRetryBackoff time.Duration // пауза перед первым повтором удаления, удваивается с каждой попыткой
+	DeadLetterFile     string        // файл для удалений, не примененных после всех повторов; пусто - только лог
+	DeleteRetryAfter   time.Duration // Retry-After ответа 503 при переполненной очереди удаления
+	GzipMinSize        int           // минимальный размер ответа в байтах, начиная с которого он сжимается gzip
+	GzipRoutes         string        // группы маршрутов через запятую, ответы которых сжимаются gzip
+	RedirectBody       bool          // писать целевой URL в тело ответа 307
+	MaxBodyBytes       int64         // ограничение размера тела запроса в байтах, сверх него - 413
+
+	DeletedRedirectURL  string // страница, на которую перенаправляются удаленные и истекшие ссылки; пусто - 410
+	NotFoundRedirectURL string // страница, на которую перенаправляются неизвестные ссылки; пусто - 404
+	VisitCap            int64  // порог, после которого счетчик переходов ссылки перестает увеличиваться; 0 - без ограничения
+	MaxURLs             int    // ограничение количества ссылок в хранилище в памяти; 0 - без ограничения
+	EvictionPolicy      string // поведение при достижении MaxURLs: reject или lru
+
+	// storageFileSet показывает, что путь к файлу хранилища задан явно, а не взят по умолчанию
+	storageFileSet bool
+}
+
+// NewConfig создает новую конфигурацию.
+// Возвращает ошибку, если не удалось прочитать файлы с секретами или BaseURL некорректен.
+func NewConfig() (*Config, error) {
+	return parseConfig(os.Args[1:])
 }
 
-// NewConfig создает новую конфигурацию
-func NewConfig() *Config {
+// parseConfig разбирает конфигурацию из аргументов командной строки args и переменных окружения
+func parseConfig(args []string) (*Config, error) {
 	cfg := &Config{}
+	fs := flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+
+	fs.StringVar(&cfg.ServerAddress, "a", "localhost:8080", "HTTP server address")
+	fs.StringVar(&cfg.BaseURL, "b", "http://localhost:8080/", "base URL for shortened URLs")
+	fs.StringVar(&cfg.StorageFilePath, "f", defaultStorageFile, "file storage path")
+	fs.StringVar(&cfg.DatabaseDSN, "d", "", "database connection string")
+	fs.StringVar(&cfg.DatabaseShards, "database-shards", "", "comma-separated connection strings of PostgreSQL shards")
+	fs.IntVar(&cfg.DBConnectRetries, "db-connect-retries", defaultDBRetries, "retries of the PostgreSQL connection check at startup")
+	fs.DurationVar(&cfg.DBConnectBackoff, "db-connect-backoff", defaultDBBackoff, "initial backoff between PostgreSQL connection retries, doubled each attempt")
+	fs.BoolVar(&cfg.EnablePprof, "pprof", false, "enable pprof profiling")
+	fs.StringVar(&cfg.SecretKey, "k", defaultSecretKey, "secret key for auth cookies")
+	fs.StringVar(&cfg.PreviousKeys, "k-previous", "", "comma-separated previous secret keys still accepted for auth cookies")
+	fs.BoolVar(&cfg.CookieHTTPOnly, "cookie-http-only", true, "set HttpOnly on the auth cookie (disable only if JS must read it)")
+	fs.BoolVar(&cfg.CookieSecure, "cookie-secure", false, "set Secure on the auth cookie (always on for an https base URL)")
+	fs.StringVar(&cfg.CookieSameSite, "cookie-same-site", defaultCookieSameSite, "SameSite of the auth cookie: lax, strict or none")
+	fs.StringVar(&cfg.CookieDomain, "cookie-domain", "", "domain of the auth cookie (empty means the request host only)")
+	fs.DurationVar(&cfg.FirstRequestWin, "auth-first-request-window", 0, "share one user ID between cookieless requests with the same X-Client-Nonce header within this window (0 disables)")
+	fs.BoolVar(&cfg.SyncDelete, "sync-delete", false, "delete URLs synchronously and report the result")
+	fs.StringVar(&cfg.BaseURLHosts, "base-url-hosts", "", "comma-separated hosts for which short URLs use the request Host")
+	fs.StringVar(&cfg.TrustedProxies, "trusted-proxies", "", "comma-separated CIDR list of trusted proxies")
+	fs.StringVar(&cfg.CORSOrigins, "cors-allowed-origins", "", "comma-separated origins allowed to call the API from a browser (empty disables CORS)")
+	fs.DurationVar(&cfg.CORSMaxAge, "cors-max-age", defaultCORSMaxAge, "how long browsers cache CORS preflight results")
+	fs.DurationVar(&cfg.ShutdownTimeout, "shutdown-timeout", defaultShutdownTimeout, "how long to wait for in-flight requests and delete workers on shutdown")
+	fs.BoolVar(&cfg.Dedup, "dedup", true, "deduplicate original URLs")
+	fs.StringVar(&cfg.IDMode, "id-mode", "random", "short ID generation: random or sequential (requires database)")
+	fs.StringVar(&cfg.ConflictStrategy, "conflict-strategy", "", "duplicate original URL handling: existing, new or error (default existing, new if -dedup=false)")
+	fs.BoolVar(&cfg.PrintVersion, "version", false, "print build info as JSON and exit")
+	fs.BoolVar(&cfg.EncryptAtRest, "encrypt-at-rest", false, "encrypt original URLs in storage")
+	fs.StringVar(&cfg.URLKey, "url-encryption-key", "", "secret key for original URLs encrypted at rest")
+	fs.StringVar(&cfg.URLPreviousKeys, "url-encryption-key-previous", "", "comma-separated previous keys still accepted for decrypting original URLs")
+	fs.BoolVar(&cfg.CompressURLs, "compress-urls", false, "zlib-compress long original URLs in storage")
+	fs.BoolVar(&cfg.PersistVisits, "persist-visits", false, "persist visit counts to the file storage")
+	fs.StringVar(&cfg.AllowedSchemes, "allowed-schemes", defaultAllowedSchemes, "comma-separated list of allowed URL schemes")
+	fs.StringVar(&cfg.DefaultScheme, "default-scheme", defaultRedirectScheme, "scheme added on redirect to stored URLs without one")
+	fs.BoolVar(&cfg.StripFragments, "strip-fragments", false, "drop #fragment from original URLs before storing and dedup")
+	fs.BoolVar(&cfg.StrictStorage, "strict-storage", false, "fail startup on ambiguous storage settings")
+	fs.StringVar(&cfg.ErrorFormat, "error-format", "simple", "API error format: simple or problem")
+	fs.StringVar(&cfg.TrustedSubnet, "t", "", "trusted subnet (CIDR) for internal endpoints")
+	fs.BoolVar(&cfg.BatchLinkHeaders, "batch-link-headers", false, "add Link headers with created short URLs to batch responses")
+	fs.BoolVar(&cfg.ReadyzWrite, "readyz-check-write", false, "verify database writability in /readyz")
+	fs.BoolVar(&cfg.AllowReset, "allow-reset", false, "enable POST /api/internal/reset to wipe all data (testing only)")
+	fs.BoolVar(&cfg.EnableSwagger, "swagger", true, "serve Swagger UI at /swagger/")
+	fs.BoolVar(&cfg.LogBodies, "log-bodies", false, "log request and response bodies at debug level")
+	fs.StringVar(&cfg.LogRedact, "log-redact", "", "comma-separated regexps redacted from logged bodies")
+	fs.StringVar(&cfg.BlocklistFile, "blocklist", "", "file with blocked domains, one per line")
+	fs.DurationVar(&cfg.IdempotencyTTL, "idempotency-ttl", defaultIdempotencyTTL, "idempotency key TTL (0 disables)")
+	fs.StringVar(&cfg.IdempotencyRedis, "idempotency-redis-url", "", "redis URL for idempotency keys shared between replicas (empty - in memory)")
+	fs.DurationVar(&cfg.GCInterval, "gc-interval", defaultGCInterval, "interval of purging expired and deleted URLs (0 disables)")
+	fs.DurationVar(&cfg.GCGracePeriod, "gc-grace-period", defaultGCGracePeriod, "how long deleted URLs are kept before purging")
+	fs.Int64Var(&cfg.ExpectedURLs, "expected-urls", 0, "expected number of URLs for short ID collision warning")
+	fs.IntVar(&cfg.ShortIDLength, "idlen", defaultShortIDLength, "length of random short IDs (4-32)")
+	fs.IntVar(&cfg.ShortIDAttempts, "id-attempts", defaultShortIDAttempts, "max attempts to save a URL with a fresh short ID on collision")
+	fs.DurationVar(&cfg.BlocklistReload, "blocklist-reload", defaultBlocklistReload, "blocklist reload interval (0 disables)")
+	fs.StringVar(&cfg.DeletedRedirectURL, "deleted-redirect-url", "", "landing page for deleted and expired links (empty - 410)")
+	fs.StringVar(&cfg.NotFoundRedirectURL, "not-found-redirect-url", "", "landing page for unknown links (empty - 404)")
+	fs.BoolVar(&cfg.RedirectBody, "redirect-body", false, "write the target URL as the 307 response body")
+	fs.IntVar(&cfg.GzipMinSize, "gzip-min-size", defaultGzipMinSize, "minimum response size in bytes to gzip")
+	fs.Int64Var(&cfg.MaxBodyBytes, "max-body-bytes", defaultMaxBodyBytes, "maximum request body size in bytes, larger bodies get 413")
+	fs.StringVar(&cfg.GzipRoutes, "gzip-routes", defaultGzipRoutes, "comma-separated route groups whose responses are gzipped (shorten, redirect, health, api, swagger, metrics)")
+	fs.Int64Var(&cfg.VisitCap, "visit-cap", 0, "stop counting visits of a link after this many (0 - unlimited)")
+	fs.IntVar(&cfg.MaxURLs, "max-urls", 0, "maximum number of links in the in-memory storage (0 - unlimited)")
+	fs.StringVar(&cfg.EvictionPolicy, "eviction-policy", "reject", "behavior when max-urls is reached: reject or lru")
+	fs.IntVar(&cfg.DeleteRetries, "delete-retries", defaultDeleteRetries, "retries of a failed async delete batch")
+	fs.DurationVar(&cfg.DeleteRetryBackoff, "delete-retry-backoff", defaultDeleteBackoff, "initial backoff between async delete retries, doubled each attempt")
+	fs.StringVar(&cfg.DeadLetterFile, "delete-dead-letter-file", "", "file for async deletes that failed after all retries (empty - log only)")
+	fs.DurationVar(&cfg.DeleteRetryAfter, "delete-retry-after", defaultDeleteRetryAfter, "Retry-After of the 503 response when the delete queue is full")
+	fs.DurationVar(&cfg.SlowQuery, "slow-query-threshold", 0, "log storage operations slower than this (0 disables)")
+	fs.DurationVar(&cfg.WorkerStall, "worker-stall-threshold", defaultWorkerStall, "delete worker inactivity with non-empty queue reported by /livez")
+	fs.StringVar(&cfg.LogFile, "log-file", "", "write logs to a rotating file instead of stdout")
+	fs.StringVar(&cfg.LogLevel, "log-level", "info", "minimum log level: debug, info, warn or error")
+	fs.StringVar(&cfg.LogFormat, "log-format", LogFormatConsole, "log format: console or json")
+	fs.IntVar(&cfg.LogMaxSize, "log-max-size", defaultLogMaxSize, "log file size in megabytes before rotation")
+	fs.IntVar(&cfg.LogMaxBackups, "log-max-backups", defaultLogMaxBackups, "rotated log files to keep (0 - all)")
+	fs.IntVar(&cfg.LogMaxAge, "log-max-age", defaultLogMaxAge, "days to keep rotated log files (0 - no age limit)")
 
-	flag.StringVar(&cfg.ServerAddress, "a", "localhost:8080", "HTTP server address")
-	flag.StringVar(&cfg.BaseURL, "b", "http://localhost:8080/", "base URL for shortened URLs")
-	flag.StringVar(&cfg.StorageFilePath, "f", defaultStorageFile, "file storage path")
-	flag.StringVar(&cfg.DatabaseDSN, "d", "", "database connection string")
-	flag.BoolVar(&cfg.EnablePprof, "pprof", false, "enable pprof profiling")
+	fs.Parse(args)
+
+	fs.Visit(func(f *flag.Flag) {
+		if f.Name == "f" {
+			cfg.storageFileSet = true
+		}
+	})
 
-	flag.Parse()
+	// С -version сервис не запускается, поэтому окружение не читается и не проверяется:
+	// неверные настройки не должны мешать узнать версию сборки
+	if cfg.PrintVersion {
+		return cfg, nil
+	}
 
 	if envServerAddr := os.Getenv("SERVER_ADDRESS"); envServerAddr != "" {
 		cfg.ServerAddress = envServerAddr
@@ -43,17 +240,533 @@ func NewConfig() *Config {
 
 	if envStoragePath := os.Getenv("FILE_STORAGE_PATH"); envStoragePath != "" {
 		cfg.StorageFilePath = envStoragePath
+		cfg.storageFileSet = true
 	}
 
 	if envDatabaseDSN := os.Getenv("DATABASE_DSN"); envDatabaseDSN != "" {
 		cfg.DatabaseDSN = envDatabaseDSN
 	}
 
+	// Файл имеет приоритет над значением из переменной окружения
+	if dsnFile := os.Getenv("DATABASE_DSN_FILE"); dsnFile != "" {
+		dsn, err := readSecretFile(dsnFile)
+		if err != nil {
+			return nil, err
+		}
+		cfg.DatabaseDSN = dsn
+	}
+
+	if envDatabaseShards := os.Getenv("DATABASE_SHARD_DSNS"); envDatabaseShards != "" {
+		cfg.DatabaseShards = envDatabaseShards
+	}
+
+	// Платформы часто передают параметры подключения отдельными переменными
+	if cfg.DatabaseDSN == "" {
+		cfg.DatabaseDSN = dsnFromEnv()
+	}
+
+	if envSecretKey := os.Getenv("SECRET_KEY"); envSecretKey != "" {
+		cfg.SecretKey = envSecretKey
+	}
+
+	if secretKeyFile := os.Getenv("SECRET_KEY_FILE"); secretKeyFile != "" {
+		secretKey, err := readSecretFile(secretKeyFile)
+		if err != nil {
+			return nil, err
+		}
+		cfg.SecretKey = secretKey
+	}
+
+	if envPreviousKeys := os.Getenv("SECRET_KEY_PREVIOUS"); envPreviousKeys != "" {
+		cfg.PreviousKeys = envPreviousKeys
+	}
+
+	if envURLKey := os.Getenv("URL_ENCRYPTION_KEY"); envURLKey != "" {
+		cfg.URLKey = envURLKey
+	}
+
+	if urlKeyFile := os.Getenv("URL_ENCRYPTION_KEY_FILE"); urlKeyFile != "" {
+		urlKey, err := readSecretFile(urlKeyFile)
+		if err != nil {
+			return nil, err
+		}
+		cfg.URLKey = urlKey
+	}
+
+	if envURLPreviousKeys := os.Getenv("URL_ENCRYPTION_KEY_PREVIOUS"); envURLPreviousKeys != "" {
+		cfg.URLPreviousKeys = envURLPreviousKeys
+	}
+
 	if envPprof := os.Getenv("ENABLE_PPROF"); envPprof != "" {
 		if enabled, err := strconv.ParseBool(envPprof); err == nil {
 			cfg.EnablePprof = enabled
 		}
 	}
 
-	return cfg
+	if envSyncDelete := os.Getenv("SYNC_DELETE"); envSyncDelete != "" {
+		if enabled, err := strconv.ParseBool(envSyncDelete); err == nil {
+			cfg.SyncDelete = enabled
+		}
+	}
+
+	if envTrustedProxies := os.Getenv("TRUSTED_PROXIES"); envTrustedProxies != "" {
+		cfg.TrustedProxies = envTrustedProxies
+	}
+
+	if envCORSOrigins := os.Getenv("CORS_ALLOWED_ORIGINS"); envCORSOrigins != "" {
+		cfg.CORSOrigins = envCORSOrigins
+	}
+
+	if envCORSMaxAge := os.Getenv("CORS_MAX_AGE"); envCORSMaxAge != "" {
+		if maxAge, err := time.ParseDuration(envCORSMaxAge); err == nil {
+			cfg.CORSMaxAge = maxAge
+		}
+	}
+
+	if envShutdownTimeout := os.Getenv("SHUTDOWN_TIMEOUT"); envShutdownTimeout != "" {
+		if timeout, err := time.ParseDuration(envShutdownTimeout); err == nil {
+			cfg.ShutdownTimeout = timeout
+		}
+	}
+
+	if envDedup := os.Getenv("DEDUP"); envDedup != "" {
+		if enabled, err := parseSwitch(envDedup); err == nil {
+			cfg.Dedup = enabled
+		}
+	}
+
+	if envConflictStrategy := os.Getenv("CONFLICT_STRATEGY"); envConflictStrategy != "" {
+		cfg.ConflictStrategy = envConflictStrategy
+	}
+
+	// DEDUP=off сохраняет прежнее поведение, если стратегия не задана явно
+	if cfg.ConflictStrategy == "" && !cfg.Dedup {
+		cfg.ConflictStrategy = "new"
+	}
+
+	if envEncrypt := os.Getenv("ENCRYPT_AT_REST"); envEncrypt != "" {
+		if enabled, err := strconv.ParseBool(envEncrypt); err == nil {
+			cfg.EncryptAtRest = enabled
+		}
+	}
+
+	if envBatchLinks := os.Getenv("BATCH_LINK_HEADERS"); envBatchLinks != "" {
+		if enabled, err := strconv.ParseBool(envBatchLinks); err == nil {
+			cfg.BatchLinkHeaders = enabled
+		}
+	}
+
+	if envReadyzWrite := os.Getenv("READYZ_CHECK_WRITE"); envReadyzWrite != "" {
+		if enabled, err := strconv.ParseBool(envReadyzWrite); err == nil {
+			cfg.ReadyzWrite = enabled
+		}
+	}
+
+	if envAllowReset := os.Getenv("ALLOW_RESET"); envAllowReset != "" {
+		if enabled, err := strconv.ParseBool(envAllowReset); err == nil {
+			cfg.AllowReset = enabled
+		}
+	}
+
+	if envCompress := os.Getenv("COMPRESS_URLS"); envCompress != "" {
+		if enabled, err := strconv.ParseBool(envCompress); err == nil {
+			cfg.CompressURLs = enabled
+		}
+	}
+
+	if envStripFragments := os.Getenv("STRIP_FRAGMENTS"); envStripFragments != "" {
+		if enabled, err := strconv.ParseBool(envStripFragments); err == nil {
+			cfg.StripFragments = enabled
+		}
+	}
+
+	if envPersistVisits := os.Getenv("PERSIST_VISITS"); envPersistVisits != "" {
+		if enabled, err := strconv.ParseBool(envPersistVisits); err == nil {
+			cfg.PersistVisits = enabled
+		}
+	}
+
+	if envAllowedSchemes := os.Getenv("ALLOWED_SCHEMES"); envAllowedSchemes != "" {
+		cfg.AllowedSchemes = envAllowedSchemes
+	}
+
+	if envIdempotencyTTL := os.Getenv("IDEMPOTENCY_TTL"); envIdempotencyTTL != "" {
+		if ttl, err := time.ParseDuration(envIdempotencyTTL); err == nil {
+			cfg.IdempotencyTTL = ttl
+		}
+	}
+
+	if envIdempotencyRedis := os.Getenv("IDEMPOTENCY_REDIS_URL"); envIdempotencyRedis != "" {
+		cfg.IdempotencyRedis = envIdempotencyRedis
+	}
+
+	if envHTTPOnly := os.Getenv("COOKIE_HTTP_ONLY"); envHTTPOnly != "" {
+		if enabled, err := strconv.ParseBool(envHTTPOnly); err == nil {
+			cfg.CookieHTTPOnly = enabled
+		}
+	}
+
+	if envCookieSecure := os.Getenv("COOKIE_SECURE"); envCookieSecure != "" {
+		if enabled, err := strconv.ParseBool(envCookieSecure); err == nil {
+			cfg.CookieSecure = enabled
+		}
+	}
+
+	if envCookieSameSite := os.Getenv("COOKIE_SAME_SITE"); envCookieSameSite != "" {
+		cfg.CookieSameSite = envCookieSameSite
+	}
+
+	if envCookieDomain := os.Getenv("COOKIE_DOMAIN"); envCookieDomain != "" {
+		cfg.CookieDomain = envCookieDomain
+	}
+
+	// Общий ID выдается только запросам с одинаковым X-Client-Nonce: по адресу и заголовкам
+	// клиенты за общим NAT или прокси неразличимы и получили бы доступ к ссылкам друг друга
+	if envFirstRequestWin := os.Getenv("AUTH_FIRST_REQUEST_WINDOW"); envFirstRequestWin != "" {
+		if window, err := time.ParseDuration(envFirstRequestWin); err == nil {
+			cfg.FirstRequestWin = window
+		}
+	}
+
+	if envIDMode := os.Getenv("ID_MODE"); envIDMode != "" {
+		cfg.IDMode = envIDMode
+	}
+
+	if envBaseURLHosts := os.Getenv("BASE_URL_HOSTS"); envBaseURLHosts != "" {
+		cfg.BaseURLHosts = envBaseURLHosts
+	}
+
+	if envDefaultScheme := os.Getenv("DEFAULT_SCHEME"); envDefaultScheme != "" {
+		cfg.DefaultScheme = envDefaultScheme
+	}
+
+	if envStrictStorage := os.Getenv("STRICT_STORAGE"); envStrictStorage != "" {
+		if enabled, err := strconv.ParseBool(envStrictStorage); err == nil {
+			cfg.StrictStorage = enabled
+		}
+	}
+
+	if envErrorFormat := os.Getenv("ERROR_FORMAT"); envErrorFormat != "" {
+		cfg.ErrorFormat = envErrorFormat
+	}
+
+	if envBlocklist := os.Getenv("BLOCKLIST_FILE"); envBlocklist != "" {
+		cfg.BlocklistFile = envBlocklist
+	}
+
+	if envBlocklistReload := os.Getenv("BLOCKLIST_RELOAD_INTERVAL"); envBlocklistReload != "" {
+		if interval, err := time.ParseDuration(envBlocklistReload); err == nil {
+			cfg.BlocklistReload = interval
+		}
+	}
+
+	if envSwagger := os.Getenv("ENABLE_SWAGGER"); envSwagger != "" {
+		if enabled, err := strconv.ParseBool(envSwagger); err == nil {
+			cfg.EnableSwagger = enabled
+		}
+	}
+
+	if envLogBodies := os.Getenv("LOG_BODIES"); envLogBodies != "" {
+		if enabled, err := strconv.ParseBool(envLogBodies); err == nil {
+			cfg.LogBodies = enabled
+		}
+	}
+
+	if envLogRedact := os.Getenv("LOG_REDACT"); envLogRedact != "" {
+		cfg.LogRedact = envLogRedact
+	}
+
+	if envTrustedSubnet := os.Getenv("TRUSTED_SUBNET"); envTrustedSubnet != "" {
+		cfg.TrustedSubnet = envTrustedSubnet
+	}
+
+	if envGCInterval := os.Getenv("GC_INTERVAL"); envGCInterval != "" {
+		if interval, err := time.ParseDuration(envGCInterval); err == nil {
+			cfg.GCInterval = interval
+		}
+	}
+
+	if envGCGracePeriod := os.Getenv("GC_GRACE_PERIOD"); envGCGracePeriod != "" {
+		if period, err := time.ParseDuration(envGCGracePeriod); err == nil {
+			cfg.GCGracePeriod = period
+		}
+	}
+
+	if envShortIDLength := os.Getenv("SHORT_ID_LENGTH"); envShortIDLength != "" {
+		if length, err := strconv.Atoi(envShortIDLength); err == nil {
+			cfg.ShortIDLength = length
+		}
+	}
+
+	if envShortIDAttempts := os.Getenv("SHORT_ID_ATTEMPTS"); envShortIDAttempts != "" {
+		if attempts, err := strconv.Atoi(envShortIDAttempts); err == nil {
+			cfg.ShortIDAttempts = attempts
+		}
+	}
+
+	if envExpectedURLs := os.Getenv("EXPECTED_URLS"); envExpectedURLs != "" {
+		if expected, err := strconv.ParseInt(envExpectedURLs, 10, 64); err == nil {
+			cfg.ExpectedURLs = expected
+		}
+	}
+
+	if envDeletedRedirect := os.Getenv("DELETED_REDIRECT_URL"); envDeletedRedirect != "" {
+		cfg.DeletedRedirectURL = envDeletedRedirect
+	}
+
+	if envNotFoundRedirect := os.Getenv("NOT_FOUND_REDIRECT_URL"); envNotFoundRedirect != "" {
+		cfg.NotFoundRedirectURL = envNotFoundRedirect
+	}
+
+	if envRedirectBody := os.Getenv("REDIRECT_BODY"); envRedirectBody != "" {
+		if enabled, err := strconv.ParseBool(envRedirectBody); err == nil {
+			cfg.RedirectBody = enabled
+		}
+	}
+
+	if envGzipMinSize := os.Getenv("GZIP_MIN_SIZE"); envGzipMinSize != "" {
+		if size, err := strconv.Atoi(envGzipMinSize); err == nil {
+			cfg.GzipMinSize = size
+		}
+	}
+
+	if envMaxBodyBytes := os.Getenv("MAX_BODY_BYTES"); envMaxBodyBytes != "" {
+		if size, err := strconv.ParseInt(envMaxBodyBytes, 10, 64); err == nil {
+			cfg.MaxBodyBytes = size
+		}
+	}
+
+	if envGzipRoutes, ok := os.LookupEnv("GZIP_ROUTES"); ok {
+		cfg.GzipRoutes = envGzipRoutes
+	}
+
+	if envMaxURLs := os.Getenv("MAX_URLS"); envMaxURLs != "" {
+		if maxURLs, err := strconv.Atoi(envMaxURLs); err == nil {
+			cfg.MaxURLs = maxURLs
+		}
+	}
+
+	if envEvictionPolicy := os.Getenv("EVICTION_POLICY"); envEvictionPolicy != "" {
+		cfg.EvictionPolicy = envEvictionPolicy
+	}
+
+	if envVisitCap := os.Getenv("VISIT_CAP"); envVisitCap != "" {
+		if visitCap, err := strconv.ParseInt(envVisitCap, 10, 64); err == nil {
+			cfg.VisitCap = visitCap
+		}
+	}
+
+	if envDBRetries := os.Getenv("DB_CONNECT_RETRIES"); envDBRetries != "" {
+		if retries, err := strconv.Atoi(envDBRetries); err == nil {
+			cfg.DBConnectRetries = retries
+		}
+	}
+
+	if envDBBackoff := os.Getenv("DB_CONNECT_BACKOFF"); envDBBackoff != "" {
+		if backoff, err := time.ParseDuration(envDBBackoff); err == nil {
+			cfg.DBConnectBackoff = backoff
+		}
+	}
+
+	if envDeleteRetries := os.Getenv("DELETE_RETRIES"); envDeleteRetries != "" {
+		if retries, err := strconv.Atoi(envDeleteRetries); err == nil {
+			cfg.DeleteRetries = retries
+		}
+	}
+
+	if envDeleteBackoff := os.Getenv("DELETE_RETRY_BACKOFF"); envDeleteBackoff != "" {
+		if backoff, err := time.ParseDuration(envDeleteBackoff); err == nil {
+			cfg.DeleteRetryBackoff = backoff
+		}
+	}
+
+	if envDeadLetterFile := os.Getenv("DELETE_DEAD_LETTER_FILE"); envDeadLetterFile != "" {
+		cfg.DeadLetterFile = envDeadLetterFile
+	}
+
+	if envDeleteRetryAfter := os.Getenv("DELETE_RETRY_AFTER"); envDeleteRetryAfter != "" {
+		if retryAfter, err := time.ParseDuration(envDeleteRetryAfter); err == nil {
+			cfg.DeleteRetryAfter = retryAfter
+		}
+	}
+
+	if envLogFile := os.Getenv("LOG_FILE"); envLogFile != "" {
+		cfg.LogFile = envLogFile
+	}
+
+	if envLogLevel := os.Getenv("LOG_LEVEL"); envLogLevel != "" {
+		cfg.LogLevel = envLogLevel
+	}
+
+	if envLogFormat := os.Getenv("LOG_FORMAT"); envLogFormat != "" {
+		cfg.LogFormat = envLogFormat
+	}
+
+	if envLogMaxSize := os.Getenv("LOG_MAX_SIZE"); envLogMaxSize != "" {
+		if size, err := strconv.Atoi(envLogMaxSize); err == nil {
+			cfg.LogMaxSize = size
+		}
+	}
+
+	if envLogMaxBackups := os.Getenv("LOG_MAX_BACKUPS"); envLogMaxBackups != "" {
+		if backups, err := strconv.Atoi(envLogMaxBackups); err == nil {
+			cfg.LogMaxBackups = backups
+		}
+	}
+
+	if envLogMaxAge := os.Getenv("LOG_MAX_AGE"); envLogMaxAge != "" {
+		if age, err := strconv.Atoi(envLogMaxAge); err == nil {
+			cfg.LogMaxAge = age
+		}
+	}
+
+	if envSlowQuery := os.Getenv("SLOW_QUERY_THRESHOLD"); envSlowQuery != "" {
+		if threshold, err := time.ParseDuration(envSlowQuery); err == nil {
+			cfg.SlowQuery = threshold
+		}
+	}
+
+	if envWorkerStall := os.Getenv("WORKER_STALL_THRESHOLD"); envWorkerStall != "" {
+		if threshold, err := time.ParseDuration(envWorkerStall); err == nil {
+			cfg.WorkerStall = threshold
+		}
+	}
+
+	if err := validateAbsoluteURL("base URL", cfg.BaseURL); err != nil {
+		return nil, err
+	}
+	if cfg.ShortIDLength < minShortIDLength || cfg.ShortIDLength > maxShortIDLength {
+		return nil, fmt.Errorf("invalid short ID length %d: must be between %d and %d", cfg.ShortIDLength, minShortIDLength, maxShortIDLength)
+	}
+	// Клиенты приходят по HTTPS, значит кука не должна уходить по открытому каналу
+	if strings.HasPrefix(cfg.BaseURL, "https://") {
+		cfg.CookieSecure = true
+	}
+	switch strings.ToLower(cfg.CookieSameSite) {
+	case "lax", "strict":
+	case "none":
+		if !cfg.CookieSecure {
+			return nil, fmt.Errorf("cookie SameSite none requires a secure cookie: set COOKIE_SECURE or an https base URL")
+		}
+	default:
+		return nil, fmt.Errorf("invalid cookie SameSite %q: must be lax, strict or none", cfg.CookieSameSite)
+	}
+	if cfg.LogFormat != LogFormatConsole && cfg.LogFormat != LogFormatJSON {
+		return nil, fmt.Errorf("invalid log format %q: must be %s or %s", cfg.LogFormat, LogFormatConsole, LogFormatJSON)
+	}
+	if cfg.DBConnectRetries < 0 {
+		return nil, fmt.Errorf("invalid database connect retries %d: must not be negative", cfg.DBConnectRetries)
+	}
+	if cfg.ShutdownTimeout <= 0 {
+		return nil, fmt.Errorf("invalid shutdown timeout %s: must be positive", cfg.ShutdownTimeout)
+	}
+	if cfg.MaxBodyBytes < 1 {
+		return nil, fmt.Errorf("invalid max body bytes %d: must be positive", cfg.MaxBodyBytes)
+	}
+	if cfg.ShortIDAttempts < 1 {
+		return nil, fmt.Errorf("invalid short ID attempts %d: must be at least 1", cfg.ShortIDAttempts)
+	}
+	// Ключ куки не подходит: он известен всем, кто проверяет сессии, и меняется по своему графику
+	if cfg.EncryptAtRest && cfg.URLKey == "" {
+		return nil, fmt.Errorf("encryption at rest requires URL_ENCRYPTION_KEY")
+	}
+	if cfg.DeletedRedirectURL != "" {
+		if err := validateAbsoluteURL("deleted redirect URL", cfg.DeletedRedirectURL); err != nil {
+			return nil, err
+		}
+	}
+	if cfg.NotFoundRedirectURL != "" {
+		if err := validateAbsoluteURL("not found redirect URL", cfg.NotFoundRedirectURL); err != nil {
+			return nil, err
+		}
+	}
+
+	return cfg, nil
+}
+
+// validateAbsoluteURL проверяет, что адрес содержит схему и хост.
+// Без схемы (BASE_URL=localhost:8080) ссылки получаются относительными
+// и ломаются в браузере без явной ошибки.
+func validateAbsoluteURL(name, rawURL string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return fmt.Errorf("invalid %s %q: %w", name, rawURL, err)
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("invalid %s %q: must include http:// or https:// scheme and host, e.g. http://localhost:8080/", name, rawURL)
+	}
+	return nil
+}
+
+// CheckStorage проверяет, что хранилище выбрано однозначно.
+// Возвращает ошибку, если вместе с шардами DATABASE_SHARD_DSNS задан DATABASE_DSN
+// или путь к файлу хранилища, либо одновременно заданы DATABASE_DSN и путь к файлу:
+// используется хранилище с наибольшим приоритетом, остальные настройки игнорируются.
+func (c *Config) CheckStorage() error {
+	if c.DatabaseShards != "" && c.DatabaseDSN != "" {
+		return fmt.Errorf("both database shards and database DSN are set: the shards are used and the DSN is ignored")
+	}
+	if c.DatabaseShards != "" && c.storageFileSet {
+		return fmt.Errorf("both database shards and file storage path %q are set: the shards are used and the file is ignored", c.StorageFilePath)
+	}
+	if c.DatabaseDSN != "" && c.storageFileSet {
+		return fmt.Errorf("both database DSN and file storage path %q are set: PostgreSQL is used and the file is ignored", c.StorageFilePath)
+	}
+	return nil
+}
+
+// dsnFromEnv собирает строку подключения из DB_HOST, DB_PORT, DB_USER, DB_PASSWORD,
+// DB_NAME и DB_SSLMODE. Возвращает пустую строку, если DB_HOST не задан.
+func dsnFromEnv() string {
+	host := os.Getenv("DB_HOST")
+	if host == "" {
+		return ""
+	}
+
+	port := os.Getenv("DB_PORT")
+	if port == "" {
+		port = defaultDBPort
+	}
+
+	dsn := url.URL{
+		Scheme: "postgres",
+		Host:   net.JoinHostPort(host, port),
+		Path:   "/" + os.Getenv("DB_NAME"),
+	}
+
+	// url.UserPassword экранирует спецсимволы пароля (@, :, / и т.д.)
+	if user := os.Getenv("DB_USER"); user != "" {
+		if password, ok := os.LookupEnv("DB_PASSWORD"); ok {
+			dsn.User = url.UserPassword(user, password)
+		} else {
+			dsn.User = url.User(user)
+		}
+	}
+
+	if sslMode := os.Getenv("DB_SSLMODE"); sslMode != "" {
+		dsn.RawQuery = url.Values{"sslmode": {sslMode}}.Encode()
+	}
+
+	return dsn.String()
+}
+
+// parseSwitch разбирает булево значение, дополнительно принимая on/off
+func parseSwitch(value string) (bool, error) {
+	switch strings.ToLower(value) {
+	case "on":
+		return true, nil
+	case "off":
+		return false, nil
+	}
+	return strconv.ParseBool(value)
+}
+
+// readSecretFile читает секрет из файла (например, Docker или Kubernetes secret),
+// отбрасывая пробельные символы и перевод строки в конце
+func readSecretFile(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("cannot read secret file %s: %w", path, err)
+	}
+	return strings.TrimSpace(string(data)), nil
 }
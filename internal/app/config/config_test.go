@@ -0,0 +1,175 @@
+package config
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseConfig_ShutdownTimeout(t *testing.T) {
+	tests := []struct {
+		name     string
+		args     []string
+		env      string
+		expected time.Duration
+		wantErr  bool
+	}{
+		{
+			name:     "значение по умолчанию",
+			expected: defaultShutdownTimeout,
+		},
+		{
+			name:     "значение из флага",
+			args:     []string{"-shutdown-timeout=5s"},
+			expected: 5 * time.Second,
+		},
+		{
+			name:     "переменная окружения приоритетнее флага",
+			args:     []string{"-shutdown-timeout=5s"},
+			env:      "1m",
+			expected: time.Minute,
+		},
+		{
+			name:     "неверное значение переменной окружения игнорируется",
+			args:     []string{"-shutdown-timeout=5s"},
+			env:      "soon",
+			expected: 5 * time.Second,
+		},
+		{
+			name:    "нулевое значение флага",
+			args:    []string{"-shutdown-timeout=0s"},
+			wantErr: true,
+		},
+		{
+			name:    "отрицательное значение переменной окружения",
+			env:     "-1s",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("SHUTDOWN_TIMEOUT", tt.env)
+
+			cfg, err := parseConfig(tt.args)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, cfg.ShutdownTimeout)
+		})
+	}
+}
+
+func TestParseConfig_VersionSkipsValidation(t *testing.T) {
+	t.Setenv("SHORT_ID_LENGTH", "1")
+	t.Setenv("BASE_URL", "localhost:8080")
+
+	_, err := parseConfig(nil)
+	require.Error(t, err, "без -version неверная конфигурация отклоняется")
+
+	cfg, err := parseConfig([]string{"-version"})
+	require.NoError(t, err)
+	assert.True(t, cfg.PrintVersion)
+}
+
+func TestParseConfig_URLEncryptionKey(t *testing.T) {
+	tests := []struct {
+		name    string
+		args    []string
+		key     string
+		wantKey string
+		wantErr bool
+	}{
+		{
+			name: "шифрование выключено, ключ не нужен",
+		},
+		{
+			name:    "шифрование без ключа",
+			args:    []string{"-encrypt-at-rest"},
+			wantErr: true,
+		},
+		{
+			name:    "ключ из флага",
+			args:    []string{"-encrypt-at-rest", "-url-encryption-key=flag-key"},
+			wantKey: "flag-key",
+		},
+		{
+			name:    "переменная окружения приоритетнее флага",
+			args:    []string{"-encrypt-at-rest", "-url-encryption-key=flag-key"},
+			key:     "env-key",
+			wantKey: "env-key",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("ENCRYPT_AT_REST", "")
+			t.Setenv("URL_ENCRYPTION_KEY", tt.key)
+			t.Setenv("URL_ENCRYPTION_KEY_FILE", "")
+
+			cfg, err := parseConfig(tt.args)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantKey, cfg.URLKey)
+		})
+	}
+}
+
+func TestConfig_CheckStorage(t *testing.T) {
+	tests := []struct {
+		name    string
+		args    []string
+		wantErr bool
+	}{
+		{
+			name: "хранилище по умолчанию",
+		},
+		{
+			name: "только PostgreSQL",
+			args: []string{"-d=postgres://localhost/db"},
+		},
+		{
+			name: "только шарды",
+			args: []string{"-database-shards=postgres://a/db,postgres://b/db"},
+		},
+		{
+			name:    "PostgreSQL и файл",
+			args:    []string{"-d=postgres://localhost/db", "-f=/tmp/urls.json"},
+			wantErr: true,
+		},
+		{
+			name:    "шарды и PostgreSQL",
+			args:    []string{"-database-shards=postgres://a/db,postgres://b/db", "-d=postgres://localhost/db"},
+			wantErr: true,
+		},
+		{
+			name:    "шарды и файл",
+			args:    []string{"-database-shards=postgres://a/db,postgres://b/db", "-f=/tmp/urls.json"},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, env := range []string{"DATABASE_DSN", "DATABASE_DSN_FILE", "DB_HOST", "DATABASE_SHARD_DSNS", "FILE_STORAGE_PATH"} {
+				t.Setenv(env, "")
+			}
+
+			cfg, err := parseConfig(tt.args)
+			require.NoError(t, err)
+
+			if tt.wantErr {
+				assert.Error(t, cfg.CheckStorage())
+			} else {
+				assert.NoError(t, cfg.CheckStorage())
+			}
+		})
+	}
+}
@@ -0,0 +1,139 @@
+package controller
+
+import (
+	"encoding/json"
+	"net/http"
+
+	appmiddleware "github.com/m-molecula741/shortener/internal/app/middleware"
+	"github.com/m-molecula741/shortener/internal/app/usecase"
+)
+
+// batchStreamChunkSize - сколько URL потоковый batch читает и сокращает за один вызов сервиса.
+// Ограничивает память сервера независимо от размера запроса.
+const batchStreamChunkSize = 1000
+
+// BatchStreamItem - элемент ответа потокового batch.
+// Для сохраненного URL заполнен ShortURL, для несохраненного - Reason.
+type BatchStreamItem struct {
+	CorrelationID        string `json:"correlation_id" example:"1"`
+	CorrelationIDNumeric bool   `json:"-"` // вернуть correlation_id числом, как его передал клиент
+	ShortURL             string `json:"short_url,omitempty" example:"http://localhost:8080/abcd1234"`
+	Reason               string `json:"reason,omitempty" enums:"conflict,blocked,storage_full,internal" example:"internal"`
+}
+
+// MarshalJSON возвращает correlation_id в том виде, в котором его передал клиент
+func (i BatchStreamItem) MarshalJSON() ([]byte, error) {
+	type plain BatchStreamItem
+	return json.Marshal(struct {
+		CorrelationID json.RawMessage `json:"correlation_id"`
+		plain
+	}{usecase.MarshalCorrelationID(i.CorrelationID, i.CorrelationIDNumeric), plain(i)})
+}
+
+// handleShortenBatchStream сокращает batch частями: массив запроса читается по batchStreamChunkSize
+// элементов, и результаты каждой части сразу отправляются клиенту элементами JSON массива.
+// Статус и заголовки отправляются после первой части, поэтому ошибка на ней возвращается
+// обычным ответом, а на последующих частях соединение обрывается и клиент получает
+// незавершенный массив. Заголовки Link в этом режиме не добавляются.
+func (c *HTTPController) handleShortenBatchStream(w http.ResponseWriter, r *http.Request) {
+	decoder := json.NewDecoder(r.Body)
+	token, err := decoder.Token()
+	if err != nil {
+		c.writeDecodeError(w, r, err)
+		return
+	}
+	if token != json.Delim('[') {
+		c.writeJSONError(w, r, http.StatusBadRequest, "Invalid JSON")
+		return
+	}
+
+	userID, _ := appmiddleware.GetUserIDFromContext(r.Context())
+
+	encoder := json.NewEncoder(w)
+	controller := http.NewResponseController(w)
+	chunk := make([]usecase.BatchShortenRequest, 0, batchStreamChunkSize)
+	started := false // статус и начало массива отправлены
+	wroteItem := false
+
+	// abort обрывает уже начатый ответ: статус отправлен, сообщить об ошибке иначе нельзя
+	abort := func() {
+		panic(http.ErrAbortHandler)
+	}
+
+	for {
+		chunk = chunk[:0]
+		for len(chunk) < batchStreamChunkSize && decoder.More() {
+			var req usecase.BatchShortenRequest
+			if err := decoder.Decode(&req); err != nil {
+				if !started {
+					c.writeDecodeError(w, r, err)
+					return
+				}
+				abort()
+			}
+			chunk = append(chunk, req)
+		}
+		if len(chunk) == 0 {
+			break
+		}
+
+		responses, err := c.service.ShortenBatchWithUser(r.Context(), chunk, userID)
+		var failed []usecase.BatchFailure
+		if partialErr, isPartial := usecase.IsBatchPartialFailure(err); isPartial {
+			failed = partialErr.Failed
+			err = nil
+		}
+		if err != nil {
+			if !started {
+				c.writeBatchError(w, r, err)
+				return
+			}
+			abort()
+		}
+
+		if !started {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusCreated)
+			w.Write([]byte("["))
+			started = true
+		}
+
+		items := make([]BatchStreamItem, 0, len(responses)+len(failed))
+		for _, response := range responses {
+			items = append(items, BatchStreamItem{
+				CorrelationID:        response.CorrelationID,
+				CorrelationIDNumeric: response.CorrelationIDNumeric,
+				ShortURL:             response.ShortURL,
+			})
+		}
+		for _, failure := range failed {
+			items = append(items, BatchStreamItem{
+				CorrelationID:        failure.CorrelationID,
+				CorrelationIDNumeric: failure.CorrelationIDNumeric,
+				Reason:               failure.Reason,
+			})
+		}
+		for _, item := range items {
+			if wroteItem {
+				w.Write([]byte(","))
+			}
+			wroteItem = true
+			if err := encoder.Encode(item); err != nil {
+				abort()
+			}
+		}
+
+		// Ошибка означает, что запись не поддерживает сброс; данные уйдут в конце ответа
+		_ = controller.Flush()
+	}
+
+	if !started {
+		c.writeJSONError(w, r, http.StatusBadRequest, "Empty batch")
+		return
+	}
+
+	if _, err := decoder.Token(); err != nil {
+		abort()
+	}
+	w.Write([]byte("]\n"))
+}
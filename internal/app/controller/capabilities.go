@@ -0,0 +1,56 @@
+package controller
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// Capabilities описывает включенные возможности и ограничения сервиса,
+// чтобы клиенты могли подстраиваться под конфигурацию во время работы.
+type Capabilities struct {
+	Features CapabilityFeatures `json:"features"`
+	Limits   CapabilityLimits   `json:"limits"`
+}
+
+// CapabilityFeatures перечисляет включенные возможности.
+type CapabilityFeatures struct {
+	CustomAliases    bool `json:"custom_aliases"`     // собственные короткие идентификаторы
+	TTL              bool `json:"ttl"`                // срок действия ссылки (expires_in, expires_at)
+	Batch            bool `json:"batch"`              // пакетное сокращение /api/shorten/batch
+	QR               bool `json:"qr"`                 // QR-коды коротких ссылок
+	Idempotency      bool `json:"idempotency"`        // заголовок Idempotency-Key
+	SyncDelete       bool `json:"sync_delete"`        // синхронное удаление с итогом по каждому ID
+	BatchLinkHeaders bool `json:"batch_link_headers"` // заголовки Link в ответе batch
+}
+
+// CapabilityLimits перечисляет ограничения; 0 означает отсутствие ограничения.
+type CapabilityLimits struct {
+	MaxBatchSize     int   `json:"max_batch_size" example:"0"`
+	MaxURLLength     int   `json:"max_url_length" example:"0"`
+	IDLength         int   `json:"id_length" example:"8"` // 0 - длина переменная (ID_MODE=sequential)
+	RedirectStatuses []int `json:"redirect_statuses" example:"307"`
+	VisitCap         int64 `json:"visit_cap" example:"0"`
+}
+
+// capabilities дополняет настройки из Options возможностями, которые определяет сам контроллер
+func (c *HTTPController) capabilities() Capabilities {
+	caps := c.opts.Capabilities
+	caps.Features.TTL = true
+	caps.Features.Batch = true
+	caps.Features.SyncDelete = c.opts.SyncDelete
+	caps.Features.BatchLinkHeaders = c.opts.BatchLinkHeaders
+	caps.Limits.RedirectStatuses = []int{http.StatusTemporaryRedirect}
+	return caps
+}
+
+// @Summary Возможности сервиса
+// @Description Возвращает включенные возможности и ограничения, определенные конфигурацией сервиса
+// @Tags System
+// @Produce json
+// @Success 200 {object} Capabilities "Возможности и ограничения"
+// @Router /api/capabilities [get]
+func (c *HTTPController) handleCapabilities(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(c.capabilities())
+}
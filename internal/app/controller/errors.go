@@ -0,0 +1,47 @@
+package controller
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// Форматы ошибок API
+const (
+	ErrorFormatSimple  = "simple"  // {"error":"..."}
+	ErrorFormatProblem = "problem" // application/problem+json по RFC 7807
+)
+
+// ErrorResponse представляет ошибку API в простом формате.
+type ErrorResponse struct {
+	Error string `json:"error" example:"Invalid JSON"`
+}
+
+// ProblemResponse представляет ошибку API в формате RFC 7807.
+type ProblemResponse struct {
+	Type     string `json:"type" example:"about:blank"`
+	Title    string `json:"title" example:"Bad Request"`
+	Status   int    `json:"status" example:"400"`
+	Detail   string `json:"detail" example:"Invalid JSON"`
+	Instance string `json:"instance,omitempty" example:"/api/shorten"`
+}
+
+// writeJSONError записывает ошибку API в формате, выбранном в Options.ErrorFormat.
+// По умолчанию используется простой формат.
+func (c *HTTPController) writeJSONError(w http.ResponseWriter, r *http.Request, status int, message string) {
+	if c.opts.ErrorFormat == ErrorFormatProblem {
+		w.Header().Set("Content-Type", "application/problem+json")
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(ProblemResponse{
+			Type:     "about:blank",
+			Title:    http.StatusText(status),
+			Status:   status,
+			Detail:   message,
+			Instance: r.URL.Path,
+		})
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(ErrorResponse{Error: message})
+}
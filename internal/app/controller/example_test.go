@@ -18,14 +18,14 @@ import (
 func Example_shortenURL() {
 	// Создаем мок сервиса
 	mockService := &MockURLService{
-		ShortenWithUserFunc: func(ctx context.Context, url, userID string) (string, error) {
+		ShortenWithUserFunc: func(ctx context.Context, url, userID string, opts usecase.ShortenOptions) (string, error) {
 			return "http://localhost:8080/abc123", nil
 		},
 	}
 
 	// Создаем контроллер
 	auth, _ := middleware.NewAuthMiddleware("test-key")
-	ctrl := controller.NewHTTPController(mockService, auth)
+	ctrl := controller.NewHTTPController(mockService, auth, controller.Options{})
 
 	// Создаем тестовый сервер
 	ts := httptest.NewServer(ctrl)
@@ -51,19 +51,19 @@ func Example_shortenURL() {
 func Example_getUserURLs() {
 	// Создаем мок сервиса
 	mockService := &MockURLService{
-		GetUserURLsFunc: func(ctx context.Context, userID string) ([]usecase.UserURL, error) {
+		GetUserURLsFunc: func(ctx context.Context, userID string, limit, offset int) ([]usecase.UserURL, int, error) {
 			return []usecase.UserURL{
 				{
 					ShortURL:    "http://localhost:8080/abc123",
 					OriginalURL: "http://example.com",
 				},
-			}, nil
+			}, 1, nil
 		},
 	}
 
 	// Создаем контроллер
 	auth, _ := middleware.NewAuthMiddleware("test-key")
-	ctrl := controller.NewHTTPController(mockService, auth)
+	ctrl := controller.NewHTTPController(mockService, auth, controller.Options{})
 
 	// Создаем тестовый сервер
 	ts := httptest.NewServer(ctrl)
@@ -72,10 +72,11 @@ func Example_getUserURLs() {
 	// Создаем HTTP клиент с куками
 	client := &http.Client{}
 	req, _ := http.NewRequest("GET", ts.URL+"/api/user/urls", nil)
-	req.AddCookie(&http.Cookie{
-		Name:  "user_id",
-		Value: "test-user-id",
-	})
+
+	// Кука выдается сервисом при первом запросе; здесь она создается напрямую
+	rec := httptest.NewRecorder()
+	auth.SetUserID(rec, "5f0c5d3c-8d3e-4a8e-9a51-8b7f3f0b6f11")
+	req.AddCookie(rec.Result().Cookies()[0])
 
 	resp, err := client.Do(req)
 	if err != nil {
@@ -88,21 +89,21 @@ func Example_getUserURLs() {
 	fmt.Printf("Status: %d\nResponse: %s\n", resp.StatusCode, body)
 	// Output:
 	// Status: 200
-	// Response: [{"short_url":"http://localhost:8080/abc123","original_url":"http://example.com"}]
+	// Response: [{"short_url":"http://localhost:8080/abc123","original_url":"http://example.com","visits":0,"visits_capped":false}]
 }
 
 // Пример сокращения URL в текстовом формате
 func Example_shortenURLPlainText() {
 	// Создаем мок сервиса
 	mockService := &MockURLService{
-		ShortenWithUserFunc: func(ctx context.Context, url, userID string) (string, error) {
+		ShortenWithUserFunc: func(ctx context.Context, url, userID string, opts usecase.ShortenOptions) (string, error) {
 			return "http://localhost:8080/abc123", nil
 		},
 	}
 
 	// Создаем контроллер
 	auth, _ := middleware.NewAuthMiddleware("test-key")
-	ctrl := controller.NewHTTPController(mockService, auth)
+	ctrl := controller.NewHTTPController(mockService, auth, controller.Options{})
 
 	// Создаем тестовый сервер
 	ts := httptest.NewServer(ctrl)
@@ -142,7 +143,7 @@ func Example_batchShorten() {
 
 	// Создаем контроллер
 	auth, _ := middleware.NewAuthMiddleware("test-key")
-	ctrl := controller.NewHTTPController(mockService, auth)
+	ctrl := controller.NewHTTPController(mockService, auth, controller.Options{})
 
 	// Создаем тестовый сервер
 	ts := httptest.NewServer(ctrl)
@@ -175,14 +176,14 @@ func Example_batchShorten() {
 func Example_getOriginalURL() {
 	// Создаем мок сервиса
 	mockService := &MockURLService{
-		ExpandFunc: func(shortID string) (string, error) {
+		ExpandFunc: func(ctx context.Context, shortID string) (string, error) {
 			return "http://example.com", nil
 		},
 	}
 
 	// Создаем контроллер
 	auth, _ := middleware.NewAuthMiddleware("test-key")
-	ctrl := controller.NewHTTPController(mockService, auth)
+	ctrl := controller.NewHTTPController(mockService, auth, controller.Options{})
 
 	// Создаем тестовый сервер
 	ts := httptest.NewServer(ctrl)
@@ -217,7 +218,7 @@ func Example_deleteUserURLs() {
 
 	// Создаем контроллер
 	auth, _ := middleware.NewAuthMiddleware("test-key")
-	ctrl := controller.NewHTTPController(mockService, auth)
+	ctrl := controller.NewHTTPController(mockService, auth, controller.Options{})
 
 	// Создаем тестовый сервер
 	ts := httptest.NewServer(ctrl)
@@ -258,7 +259,7 @@ func Example_pingService() {
 
 	// Создаем контроллер
 	auth, _ := middleware.NewAuthMiddleware("test-key")
-	ctrl := controller.NewHTTPController(mockService, auth)
+	ctrl := controller.NewHTTPController(mockService, auth, controller.Options{})
 
 	// Создаем тестовый сервер
 	ts := httptest.NewServer(ctrl)
@@ -277,28 +278,29 @@ func Example_pingService() {
 
 // MockURLService реализует интерфейс URLService для тестов
 type MockURLService struct {
-	ShortenWithUserFunc      func(ctx context.Context, url, userID string) (string, error)
-	GetUserURLsFunc          func(ctx context.Context, userID string) ([]usecase.UserURL, error)
-	ExpandFunc               func(shortID string) (string, error)
+	ShortenWithUserFunc      func(ctx context.Context, url, userID string, opts usecase.ShortenOptions) (string, error)
+	GetUserURLsFunc          func(ctx context.Context, userID string, limit, offset int) ([]usecase.UserURL, int, error)
+	ExpandFunc               func(ctx context.Context, shortID string) (string, error)
 	PingDBFunc               func() error
 	DeleteUserURLsFunc       func(userID string, shortIDs []string) error
+	DeleteAllUserURLsFunc    func(userID string) error
 	ShortenBatchWithUserFunc func(ctx context.Context, requests []usecase.BatchShortenRequest, userID string) ([]usecase.BatchShortenResponse, error)
 }
 
-func (m *MockURLService) Shorten(url string) (string, error) {
+func (m *MockURLService) Shorten(ctx context.Context, url string) (string, error) {
 	return "http://localhost:8080/abc123", nil
 }
 
-func (m *MockURLService) ShortenWithUser(ctx context.Context, url, userID string) (string, error) {
+func (m *MockURLService) ShortenWithUser(ctx context.Context, url, userID string, opts usecase.ShortenOptions) (string, error) {
 	if m.ShortenWithUserFunc != nil {
-		return m.ShortenWithUserFunc(ctx, url, userID)
+		return m.ShortenWithUserFunc(ctx, url, userID, opts)
 	}
 	return "http://localhost:8080/abc123", nil
 }
 
-func (m *MockURLService) Expand(shortID string) (string, error) {
+func (m *MockURLService) Expand(ctx context.Context, shortID string) (string, error) {
 	if m.ExpandFunc != nil {
-		return m.ExpandFunc(shortID)
+		return m.ExpandFunc(ctx, shortID)
 	}
 	return "http://example.com", nil
 }
@@ -321,11 +323,15 @@ func (m *MockURLService) ShortenBatchWithUser(ctx context.Context, requests []us
 	return nil, nil
 }
 
-func (m *MockURLService) GetUserURLs(ctx context.Context, userID string) ([]usecase.UserURL, error) {
+func (m *MockURLService) GetUserURLs(ctx context.Context, userID string, limit, offset int) ([]usecase.UserURL, int, error) {
 	if m.GetUserURLsFunc != nil {
-		return m.GetUserURLsFunc(ctx, userID)
+		return m.GetUserURLsFunc(ctx, userID, limit, offset)
 	}
-	return nil, nil
+	return nil, 0, nil
+}
+
+func (m *MockURLService) GetUserURL(ctx context.Context, userID, shortID string) (usecase.UserURL, error) {
+	return usecase.UserURL{}, usecase.ErrURLNotFound
 }
 
 func (m *MockURLService) DeleteUserURLs(userID string, shortIDs []string) error {
@@ -334,3 +340,50 @@ func (m *MockURLService) DeleteUserURLs(userID string, shortIDs []string) error
 	}
 	return nil
 }
+
+func (m *MockURLService) DeleteAllUserURLs(userID string) error {
+	if m.DeleteAllUserURLsFunc != nil {
+		return m.DeleteAllUserURLsFunc(userID)
+	}
+	return nil
+}
+
+func (m *MockURLService) DeleteUserURLsSync(ctx context.Context, userID string, shortIDs []string) (usecase.DeleteResult, error) {
+	return usecase.DeleteResult{Deleted: shortIDs}, nil
+}
+
+func (m *MockURLService) PurgeExpired(ctx context.Context) (int64, error) {
+	return 0, nil
+}
+
+func (m *MockURLService) CheckLiveness() error {
+	return nil
+}
+
+func (m *MockURLService) Reset(ctx context.Context) (int64, error) {
+	return 0, nil
+}
+
+func (m *MockURLService) GetURLOwner(ctx context.Context, shortID string) (usecase.URLOwner, error) {
+	return usecase.URLOwner{}, usecase.ErrURLNotFound
+}
+
+func (m *MockURLService) DBPoolStats() (usecase.PoolStats, error) {
+	return usecase.PoolStats{}, usecase.ErrPoolStatsUnavailable
+}
+
+func (m *MockURLService) RuntimeStats() usecase.RuntimeStats {
+	return usecase.RuntimeStats{}
+}
+
+func (m *MockURLService) GetStats(ctx context.Context) (usecase.Stats, error) {
+	return usecase.Stats{}, nil
+}
+
+func (m *MockURLService) RecentPublicURLs(ctx context.Context, limit int) ([]usecase.PublicURL, error) {
+	return nil, nil
+}
+
+func (m *MockURLService) CheckReady(ctx context.Context) error {
+	return nil
+}
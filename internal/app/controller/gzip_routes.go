@@ -0,0 +1,38 @@
+package controller
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Группы маршрутов, для которых можно включить сжатие ответов gzip
+const (
+	GzipRouteShorten  = "shorten"  // POST /
+	GzipRouteRedirect = "redirect" // GET и HEAD /{shortID}
+	GzipRouteHealth   = "health"   // /ping, /livez, /readyz
+	GzipRouteAPI      = "api"      // /api/*, включая служебные /api/internal/*
+	GzipRouteSwagger  = "swagger"  // /swagger/*
+	GzipRouteMetrics  = "metrics"  // /metrics
+)
+
+// DefaultGzipRoutes - группы маршрутов со сжатием по умолчанию: только эндпоинты с телом
+// JSON или текстом. Редирект не имеет тела, а /metrics сжимает ответ сам.
+var DefaultGzipRoutes = []string{GzipRouteShorten, GzipRouteAPI, GzipRouteSwagger}
+
+// ParseGzipRoutes разбирает список групп маршрутов через запятую; пустая строка отключает сжатие
+func ParseGzipRoutes(value string) ([]string, error) {
+	routes := []string{}
+	for _, route := range strings.Split(value, ",") {
+		route = strings.TrimSpace(route)
+		switch route {
+		case "":
+			continue
+		case GzipRouteShorten, GzipRouteRedirect, GzipRouteHealth, GzipRouteAPI,
+			GzipRouteSwagger, GzipRouteMetrics:
+			routes = append(routes, route)
+		default:
+			return nil, fmt.Errorf("unknown gzip route group %q", route)
+		}
+	}
+	return routes, nil
+}
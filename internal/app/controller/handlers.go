@@ -8,30 +8,90 @@ package controller
 
 import (
 	"encoding/json"
+	"errors"
 	"io"
+	"math"
+	"mime"
+	"net"
 	"net/http"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/go-chi/chi/v5"
 	chimiddleware "github.com/go-chi/chi/v5/middleware"
-	_ "github.com/m-molecula741/shortener/docs" // импорт сгенерированной документации
 	appmiddleware "github.com/m-molecula741/shortener/internal/app/middleware"
 	"github.com/m-molecula741/shortener/internal/app/usecase"
-	httpSwagger "github.com/swaggo/http-swagger"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
+// Options содержит настройки HTTPController.
+type Options struct {
+	SyncDelete  bool   // удалять URL синхронно и возвращать итог удаления
+	ErrorFormat string // формат ошибок /api/*: ErrorFormatSimple (по умолчанию) или ErrorFormatProblem
+
+	// DeleteRetryAfter - Retry-After ответа 503 при переполненной очереди удаления,
+	// 0 - defaultDeleteRetryAfter
+	DeleteRetryAfter time.Duration
+
+	// TrustedSubnet - подсеть, из которой доступны /api/internal/*; nil - доступ закрыт
+	TrustedSubnet *net.IPNet
+
+	EnableSwagger bool   // регистрировать Swagger UI на /swagger/
+	BaseURL       string // базовый адрес сервиса для ссылки на спецификацию Swagger
+
+	BatchLinkHeaders bool // добавлять в ответ batch заголовки Link с созданными короткими URL
+	GzipMinSize      int  // минимальный размер сжимаемого ответа, 0 - appmiddleware.DefaultGzipMinSize
+	RedirectBody     bool // писать целевой URL в тело ответа 307 помимо Location
+
+	// MaxBodyBytes - ограничение размера (распакованного) тела запроса, при превышении
+	// отвечаем 413; 0 - appmiddleware.DefaultMaxBodyBytes
+	MaxBodyBytes int64
+
+	// GzipRoutes - группы маршрутов (GzipRouteShorten и др.), ответы которых сжимаются gzip;
+	// nil - DefaultGzipRoutes. Сжатые gzip запросы распаковываются на всех маршрутах.
+	GzipRoutes []string
+
+	// RequestMetrics - middleware метрик запросов (appmiddleware.NewMetricsMiddleware),
+	// подключается к роутеру, чтобы метки содержали шаблон маршрута; nil - без метрик
+	RequestMetrics func(http.Handler) http.Handler
+
+	// Страницы, на которые перенаправляются (302) удаленные или истекшие и неизвестные ссылки
+	// вместо ответов 410 и 404; пусто - отвечать кодом ошибки
+	DeletedRedirectURL  string
+	NotFoundRedirectURL string
+
+	// AllowReset регистрирует /api/internal/reset для тестовых окружений.
+	// Эндпоинт дополнительно доступен только из TrustedSubnet.
+	AllowReset bool
+
+	// Capabilities - возможности и ограничения из конфигурации для /api/capabilities.
+	// Возможности, определяемые самим контроллером, заполняются автоматически.
+	Capabilities Capabilities
+}
+
 // HTTPController обрабатывает HTTP запросы к сервису сокращения URL.
 type HTTPController struct {
 	service URLService
 	router  *chi.Mux
 	auth    *appmiddleware.AuthMiddleware
+	opts    Options
+
+	// Ограничение частоты сброса хранилища
+	resetMu   sync.Mutex
+	lastReset time.Time
 }
 
 // NewHTTPController создает новый экземпляр HTTPController.
-func NewHTTPController(service URLService, auth *appmiddleware.AuthMiddleware) *HTTPController {
+func NewHTTPController(service URLService, auth *appmiddleware.AuthMiddleware, opts Options) *HTTPController {
 	c := &HTTPController{
 		service: service,
 		router:  chi.NewRouter(),
 		auth:    auth,
+		opts:    opts,
 	}
 	c.setupRoutes()
 	return c
@@ -39,7 +99,52 @@ func NewHTTPController(service URLService, auth *appmiddleware.AuthMiddleware) *
 
 // ShortenRequest представляет запрос на сокращение URL.
 type ShortenRequest struct {
-	URL string `json:"url" example:"https://practicum.yandex.ru"` // URL для сокращения
+	URL        string `json:"url" example:"https://practicum.yandex.ru"`           // URL для сокращения
+	ExpiresIn  string `json:"expires_in,omitempty" example:"24h"`                  // срок действия ссылки в формате Go duration
+	TTLSeconds int64  `json:"ttl_seconds,omitempty" example:"3600"`                // срок действия ссылки в секундах
+	ExpiresAt  string `json:"expires_at,omitempty" example:"2030-01-01T00:00:00Z"` // время истечения ссылки в формате RFC3339
+	Public     bool   `json:"public,omitempty" example:"false"`                    // показывать ссылку в публичной ленте /api/public/recent
+}
+
+// errInvalidExpiry возвращается при неверном сроке действия ссылки
+var errInvalidExpiry = errors.New("invalid expiry")
+
+// maxTTLSeconds - наибольший ttl_seconds, при котором срок не переполняет time.Duration (~292 года)
+const maxTTLSeconds = math.MaxInt64 / int64(time.Second)
+
+// expiresAt вычисляет время истечения ссылки из expires_in, ttl_seconds или expires_at.
+// Возвращает нулевое время, если срок не задан, и ошибку, если он задан неверно,
+// уже прошел или задан несколькими полями сразу.
+func (req ShortenRequest) expiresAt(now time.Time) (time.Time, error) {
+	fields := 0
+	for _, set := range []bool{req.ExpiresIn != "", req.TTLSeconds != 0, req.ExpiresAt != ""} {
+		if set {
+			fields++
+		}
+	}
+
+	switch {
+	case fields > 1:
+		return time.Time{}, errInvalidExpiry
+	case req.TTLSeconds != 0:
+		if req.TTLSeconds < 0 || req.TTLSeconds > maxTTLSeconds {
+			return time.Time{}, errInvalidExpiry
+		}
+		return now.Add(time.Duration(req.TTLSeconds) * time.Second), nil
+	case req.ExpiresIn != "":
+		ttl, err := time.ParseDuration(req.ExpiresIn)
+		if err != nil || ttl <= 0 {
+			return time.Time{}, errInvalidExpiry
+		}
+		return now.Add(ttl), nil
+	case req.ExpiresAt != "":
+		expiresAt, err := time.Parse(time.RFC3339, req.ExpiresAt)
+		if err != nil || !expiresAt.After(now) {
+			return time.Time{}, errInvalidExpiry
+		}
+		return expiresAt, nil
+	}
+	return time.Time{}, nil
 }
 
 // ShortenResponse представляет ответ с сокращенным URL.
@@ -47,26 +152,197 @@ type ShortenResponse struct {
 	Result string `json:"result" example:"http://localhost:8080/abcd1234"` // Сокращенный URL
 }
 
+// idempotencyHeader заголовок с ключом идемпотентности запроса на сокращение
+const idempotencyHeader = "Idempotency-Key"
+
+// withRequestBaseURL передает в сервис базовый адрес, определенный по Host запроса,
+// чтобы короткие URL строились от домена, на который пришел запрос.
+func withRequestBaseURL(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		baseURL, ok := appmiddleware.GetBaseURLFromContext(r.Context())
+		if !ok {
+			next.ServeHTTP(w, r)
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(usecase.WithBaseURL(r.Context(), baseURL)))
+	})
+}
+
+// requireJSON отклоняет с кодом 415 запросы к JSON-эндпоинтам с другим Content-Type.
+// Параметры (charset) допускаются. Для тел, сжатых gzip, клиенты часто передают
+// application/x-gzip или не передают тип вовсе, такие запросы пропускаются.
+func (c *HTTPController) requireJSON(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		contentType := r.Header.Get("Content-Type")
+		mediaType, _, err := mime.ParseMediaType(contentType)
+
+		if strings.Contains(r.Header.Get("Content-Encoding"), "gzip") {
+			switch {
+			case contentType == "", mediaType == "application/gzip", mediaType == "application/x-gzip":
+				next.ServeHTTP(w, r)
+				return
+			}
+		}
+
+		if err != nil || mediaType != "application/json" {
+			c.writeJSONError(w, r, http.StatusUnsupportedMediaType, "Content-Type must be application/json")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// writeDecodeError отвечает на ошибку разбора JSON тела запроса:
+// 413, если тело превысило ограничение размера, иначе 400
+func (c *HTTPController) writeDecodeError(w http.ResponseWriter, r *http.Request, err error) {
+	if appmiddleware.IsBodyTooLarge(err) {
+		c.writeJSONError(w, r, http.StatusRequestEntityTooLarge, "Request body too large")
+		return
+	}
+	c.writeJSONError(w, r, http.StatusBadRequest, "Invalid JSON")
+}
+
 // setupRoutes настраивает маршруты для обработки HTTP запросов.
 func (c *HTTPController) setupRoutes() {
 	c.router.Use(chimiddleware.Logger)
+	// Метрики снаружи Recoverer, чтобы паники учитывались как ответы 500
+	if c.opts.RequestMetrics != nil {
+		c.router.Use(c.opts.RequestMetrics)
+	}
 	c.router.Use(chimiddleware.Recoverer)
-	c.router.Use(appmiddleware.GzipMiddleware)
+	c.router.Use(appmiddleware.GunzipRequestMiddleware)
+	c.router.Use(appmiddleware.NewBodyLimitMiddleware(c.opts.MaxBodyBytes))
 	c.router.Use(c.auth.Middleware)
+	c.router.Use(withRequestBaseURL)
 
 	// Swagger UI и документация
-	c.router.Get("/swagger/*", httpSwagger.Handler(
-		httpSwagger.URL("http://localhost:8080/swagger/doc.json"),
-	))
+	if c.opts.EnableSwagger {
+		c.router.Group(func(r chi.Router) {
+			c.useGzip(r, GzipRouteSwagger)
+			c.setupSwagger(r)
+		})
+	}
 
 	// Основные роуты
-	c.router.Post("/", c.handleShorten)
-	c.router.Get("/{shortID}", c.handleRedirect)
-	c.router.Post("/api/shorten", c.handleShortenJSON)
-	c.router.Post("/api/shorten/batch", c.handleShortenBatch)
-	c.router.Get("/ping", c.handlePing)
-	c.router.Get("/api/user/urls", c.handleGetUserURLs)
-	c.router.Delete("/api/user/urls", c.handleDeleteUserURLs)
+	c.router.Group(func(r chi.Router) {
+		c.useGzip(r, GzipRouteShorten)
+		r.Post("/", c.handleShorten)
+	})
+	c.router.Group(func(r chi.Router) {
+		c.useGzip(r, GzipRouteRedirect)
+		r.Get("/{shortID}", c.handleRedirect)
+		r.Head("/{shortID}", c.handleRedirectHead)
+	})
+	c.router.Group(func(r chi.Router) {
+		c.useGzip(r, GzipRouteHealth)
+		r.Get("/ping", c.handlePing)
+		r.Get("/livez", c.handleLiveness)
+		r.Get("/readyz", c.handleReadiness)
+	})
+
+	// API роуты
+	c.router.Route("/api", func(r chi.Router) {
+		c.useGzip(r, GzipRouteAPI)
+		r.Get("/capabilities", c.handleCapabilities)
+		r.With(c.requireJSON).Post("/shorten", c.handleShortenJSON)
+		r.With(c.requireJSON).Post("/shorten/batch", c.handleShortenBatch)
+		r.Get("/user/urls", c.handleGetUserURLs)
+		r.Get("/user/urls/{shortID}", c.handleGetUserURL)
+		r.Get("/public/recent", c.handleRecentPublicURLs)
+		r.With(c.requireJSON).Delete("/user/urls", c.handleDeleteUserURLs)
+		r.Delete("/user/urls/all", c.handleDeleteAllUserURLs)
+
+		// Служебные роуты доступны только из доверенной подсети
+		r.Route("/internal", func(r chi.Router) {
+			r.Use(appmiddleware.NewTrustedSubnetMiddleware(c.opts.TrustedSubnet))
+			r.Post("/gc", c.handleGC)
+			r.Get("/urls/{shortID}/owner", c.handleGetURLOwner)
+			r.Get("/db/stats", c.handleDBPoolStats)
+			r.Get("/runtime", c.handleRuntimeStats)
+			r.Get("/stats", c.handleStats)
+			if c.opts.AllowReset {
+				r.Post("/reset", c.handleReset)
+			}
+		})
+
+		r.NotFound(c.handleAPINotFound)
+	})
+
+	// Метрики Prometheus
+	c.router.Group(func(r chi.Router) {
+		c.useGzip(r, GzipRouteMetrics)
+		r.Handle("/metrics", promhttp.Handler())
+	})
+}
+
+// useGzip подключает к маршрутам r сжатие ответов, если группа route указана в GzipRoutes
+func (c *HTTPController) useGzip(r chi.Router, route string) {
+	routes := c.opts.GzipRoutes
+	if routes == nil {
+		routes = DefaultGzipRoutes
+	}
+	if !slices.Contains(routes, route) {
+		return
+	}
+
+	minSize := c.opts.GzipMinSize
+	if minSize <= 0 {
+		minSize = appmiddleware.DefaultGzipMinSize
+	}
+	r.Use(appmiddleware.NewGzipResponseMiddleware(minSize))
+}
+
+// APIEndpoint описывает зарегистрированный маршрут API.
+type APIEndpoint struct {
+	Method string `json:"method" example:"POST"`
+	Path   string `json:"path" example:"/api/shorten"`
+}
+
+// APINotFoundResponse представляет ответ на запрос к несуществующему маршруту API.
+type APINotFoundResponse struct {
+	Error     string        `json:"error" example:"not found"`
+	Path      string        `json:"path" example:"/api/unknown"`
+	Endpoints []APIEndpoint `json:"endpoints"`
+}
+
+// handleAPINotFound возвращает JSON 404 со списком доступных маршрутов API.
+func (c *HTTPController) handleAPINotFound(w http.ResponseWriter, r *http.Request) {
+	// В формате RFC 7807 список эндпоинтов не передается
+	if c.opts.ErrorFormat == ErrorFormatProblem {
+		c.writeJSONError(w, r, http.StatusNotFound, "not found")
+		return
+	}
+
+	response := APINotFoundResponse{
+		Error:     "not found",
+		Path:      r.URL.Path,
+		Endpoints: c.apiEndpoints(),
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusNotFound)
+	json.NewEncoder(w).Encode(response)
+}
+
+// apiEndpoints перечисляет маршруты API, зарегистрированные в роутере.
+func (c *HTTPController) apiEndpoints() []APIEndpoint {
+	endpoints := []APIEndpoint{}
+
+	chi.Walk(c.router, func(method, route string, _ http.Handler, _ ...func(http.Handler) http.Handler) error {
+		if strings.HasPrefix(route, "/api/") {
+			endpoints = append(endpoints, APIEndpoint{Method: method, Path: route})
+		}
+		return nil
+	})
+
+	sort.Slice(endpoints, func(i, j int) bool {
+		if endpoints[i].Path != endpoints[j].Path {
+			return endpoints[i].Path < endpoints[j].Path
+		}
+		return endpoints[i].Method < endpoints[j].Method
+	})
+
+	return endpoints
 }
 
 // ServeHTTP реализует интерфейс http.Handler.
@@ -80,13 +356,22 @@ func (c *HTTPController) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 // @Accept plain
 // @Produce plain
 // @Param url body string true "URL для сокращения"
+// @Param Idempotency-Key header string false "Ключ идемпотентности запроса"
 // @Success 201 {string} string "Сокращенный URL"
 // @Failure 400 {string} string "Неверный запрос"
-// @Failure 409 {string} string "URL уже существует"
+// @Failure 403 {string} string "Домен URL в списке запрещенных"
+// @Failure 409 {string} string "URL уже существует или запрос с тем же Idempotency-Key еще выполняется"
+// @Failure 413 {string} string "Тело запроса больше MAX_BODY_BYTES"
+// @Failure 422 {string} string "Idempotency-Key уже использован с другим URL"
+// @Failure 507 {string} string "Достигнуто ограничение MAX_URLS"
 // @Router / [post]
 func (c *HTTPController) handleShorten(w http.ResponseWriter, r *http.Request) {
 	body, err := io.ReadAll(r.Body)
 	if err != nil {
+		if appmiddleware.IsBodyTooLarge(err) {
+			http.Error(w, "Request body too large", http.StatusRequestEntityTooLarge)
+			return
+		}
 		http.Error(w, "Bad request", http.StatusBadRequest)
 		return
 	}
@@ -99,7 +384,8 @@ func (c *HTTPController) handleShorten(w http.ResponseWriter, r *http.Request) {
 	// Получаем userID из контекста
 	userID, _ := appmiddleware.GetUserIDFromContext(r.Context())
 
-	shortURL, err := c.service.ShortenWithUser(r.Context(), string(body), userID)
+	opts := usecase.ShortenOptions{IdempotencyKey: r.Header.Get(idempotencyHeader)}
+	shortURL, err := c.service.ShortenWithUser(r.Context(), string(body), userID, opts)
 	if err != nil {
 		if conflictErr, isConflict := usecase.IsURLConflict(err); isConflict {
 			w.Header().Set("Content-Type", "text/plain; charset=utf-8")
@@ -107,6 +393,30 @@ func (c *HTTPController) handleShorten(w http.ResponseWriter, r *http.Request) {
 			w.Write([]byte(conflictErr.ExistingShortURL))
 			return
 		}
+		if errors.Is(err, usecase.ErrURLExists) {
+			http.Error(w, "URL already exists", http.StatusConflict)
+			return
+		}
+		if errors.Is(err, usecase.ErrIdempotencyKeyInProgress) {
+			http.Error(w, "Request with this Idempotency-Key is in progress", http.StatusConflict)
+			return
+		}
+		if errors.Is(err, usecase.ErrIdempotencyKeyMismatch) {
+			http.Error(w, "Idempotency-Key is already used with another URL", http.StatusUnprocessableEntity)
+			return
+		}
+		if usecase.IsDisallowedScheme(err) {
+			http.Error(w, "URL scheme is not allowed", http.StatusBadRequest)
+			return
+		}
+		if usecase.IsBlockedURL(err) {
+			http.Error(w, "URL is blocked", http.StatusForbidden)
+			return
+		}
+		if errors.Is(err, usecase.ErrStorageFull) {
+			http.Error(w, "Storage is full", http.StatusInsufficientStorage)
+			return
+		}
 		http.Error(w, "Shorten failed", http.StatusBadRequest)
 		return
 	}
@@ -120,9 +430,11 @@ func (c *HTTPController) handleShorten(w http.ResponseWriter, r *http.Request) {
 // @Description Перенаправляет на оригинальный URL по его короткому идентификатору
 // @Tags URLs
 // @Param shortID path string true "Короткий идентификатор URL"
-// @Success 307 {string} string "Перенаправление"
+// @Success 307 {string} string "Перенаправление; при REDIRECT_BODY=true тело содержит целевой URL"
+// @Success 302 {string} string "Перенаправление на DELETED_REDIRECT_URL или NOT_FOUND_REDIRECT_URL"
+// @Failure 400 {string} string "Схема URL запрещена"
 // @Failure 404 {string} string "URL не найден"
-// @Failure 410 {string} string "URL был удален"
+// @Failure 410 {string} string "URL был удален или истек срок его действия"
 // @Router /{shortID} [get]
 func (c *HTTPController) handleRedirect(w http.ResponseWriter, r *http.Request) {
 	shortID := chi.URLParam(r, "shortID")
@@ -130,14 +442,32 @@ func (c *HTTPController) handleRedirect(w http.ResponseWriter, r *http.Request)
 		shortID = r.URL.Path[1:]
 	}
 
-	originalURL, err := c.service.Expand(shortID)
+	originalURL, err := c.service.Expand(r.Context(), shortID)
 	if err != nil {
+		if (usecase.IsURLDeleted(err) || usecase.IsURLExpired(err)) && c.opts.DeletedRedirectURL != "" {
+			http.Redirect(w, r, c.opts.DeletedRedirectURL, http.StatusFound)
+			return
+		}
 		if usecase.IsURLDeleted(err) {
 			w.Header().Set("Content-Type", "text/plain; charset=utf-8")
 			w.WriteHeader(http.StatusGone)
 			w.Write([]byte("URL has been deleted"))
 			return
 		}
+		if usecase.IsURLExpired(err) {
+			w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+			w.WriteHeader(http.StatusGone)
+			w.Write([]byte("URL has expired"))
+			return
+		}
+		if usecase.IsDisallowedScheme(err) {
+			http.Error(w, "URL scheme is not allowed", http.StatusBadRequest)
+			return
+		}
+		if c.opts.NotFoundRedirectURL != "" {
+			http.Redirect(w, r, c.opts.NotFoundRedirectURL, http.StatusFound)
+			return
+		}
 		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
 		w.WriteHeader(http.StatusNotFound)
 		w.Write([]byte("URL not found"))
@@ -147,6 +477,35 @@ func (c *HTTPController) handleRedirect(w http.ResponseWriter, r *http.Request)
 	w.Header().Set("Location", originalURL)
 	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
 	w.WriteHeader(http.StatusTemporaryRedirect)
+
+	// Тело помогает клиентам, которые не следуют редиректу (ErrUseLastResponse)
+	if c.opts.RedirectBody {
+		w.Write([]byte(originalURL))
+	}
+}
+
+// @Summary Проверка короткого URL
+// @Description Возвращает тот же статус и заголовок Location, что и GET, но без тела и без учета перехода
+// @Tags URLs
+// @Param shortID path string true "Короткий идентификатор URL"
+// @Success 307 {string} string "Ссылка действительна, заголовок Location содержит оригинальный URL"
+// @Success 302 {string} string "Перенаправление на DELETED_REDIRECT_URL или NOT_FOUND_REDIRECT_URL"
+// @Failure 400 {string} string "Схема URL запрещена"
+// @Failure 404 {string} string "URL не найден"
+// @Failure 410 {string} string "URL был удален или истек срок его действия"
+// @Router /{shortID} [head]
+func (c *HTTPController) handleRedirectHead(w http.ResponseWriter, r *http.Request) {
+	c.handleRedirect(headResponseWriter{w}, r.WithContext(usecase.WithoutVisitCount(r.Context())))
+}
+
+// headResponseWriter отбрасывает тело ответа на HEAD запрос, сохраняя статус и заголовки
+type headResponseWriter struct {
+	http.ResponseWriter
+}
+
+// Write отбрасывает тело ответа
+func (w headResponseWriter) Write(b []byte) (int, error) {
+	return len(b), nil
 }
 
 // @Summary Сокращение URL (JSON формат)
@@ -155,27 +514,45 @@ func (c *HTTPController) handleRedirect(w http.ResponseWriter, r *http.Request)
 // @Accept json
 // @Produce json
 // @Param request body ShortenRequest true "URL для сокращения"
+// @Param Idempotency-Key header string false "Ключ идемпотентности запроса"
 // @Success 201 {object} ShortenResponse "Сокращенный URL"
-// @Failure 400 {string} string "Неверный запрос"
-// @Failure 409 {object} ShortenResponse "URL уже существует"
+// @Failure 400 {object} ErrorResponse "Неверный запрос"
+// @Failure 403 {object} ErrorResponse "Домен URL в списке запрещенных"
+// @Failure 409 {object} ShortenResponse "URL уже существует или запрос с тем же Idempotency-Key еще выполняется"
+// @Failure 413 {object} ErrorResponse "Тело запроса больше MAX_BODY_BYTES"
+// @Failure 415 {object} ErrorResponse "Content-Type не application/json"
+// @Failure 422 {object} ErrorResponse "Idempotency-Key уже использован с другим URL"
+// @Failure 507 {object} ErrorResponse "Достигнуто ограничение MAX_URLS"
 // @Router /api/shorten [post]
 func (c *HTTPController) handleShortenJSON(w http.ResponseWriter, r *http.Request) {
 	var req ShortenRequest
 
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		c.writeDecodeError(w, r, err)
 		return
 	}
 
 	if req.URL == "" {
-		http.Error(w, "URL is required", http.StatusBadRequest)
+		c.writeJSONError(w, r, http.StatusBadRequest, "URL is required")
+		return
+	}
+
+	expiresAt, err := req.expiresAt(time.Now())
+	if err != nil {
+		c.writeJSONError(w, r, http.StatusBadRequest, "Set one of expires_in (positive duration), ttl_seconds (positive number) or expires_at (future RFC3339 time)")
 		return
 	}
 
 	// Получаем userID из контекста
 	userID, _ := appmiddleware.GetUserIDFromContext(r.Context())
 
-	shortURL, err := c.service.ShortenWithUser(r.Context(), req.URL, userID)
+	opts := usecase.ShortenOptions{
+		ExpiresAt:      expiresAt,
+		Public:         req.Public,
+		IdempotencyKey: r.Header.Get(idempotencyHeader),
+	}
+
+	shortURL, err := c.service.ShortenWithUser(r.Context(), req.URL, userID, opts)
 	if err != nil {
 		if conflictErr, isConflict := usecase.IsURLConflict(err); isConflict {
 			response := ShortenResponse{
@@ -186,7 +563,31 @@ func (c *HTTPController) handleShortenJSON(w http.ResponseWriter, r *http.Reques
 			json.NewEncoder(w).Encode(response)
 			return
 		}
-		http.Error(w, "Shorten failed", http.StatusInternalServerError)
+		if errors.Is(err, usecase.ErrURLExists) {
+			c.writeJSONError(w, r, http.StatusConflict, "URL already exists")
+			return
+		}
+		if errors.Is(err, usecase.ErrIdempotencyKeyInProgress) {
+			c.writeJSONError(w, r, http.StatusConflict, "Request with this Idempotency-Key is in progress")
+			return
+		}
+		if errors.Is(err, usecase.ErrIdempotencyKeyMismatch) {
+			c.writeJSONError(w, r, http.StatusUnprocessableEntity, "Idempotency-Key is already used with another URL")
+			return
+		}
+		if usecase.IsDisallowedScheme(err) {
+			c.writeJSONError(w, r, http.StatusBadRequest, "URL scheme is not allowed")
+			return
+		}
+		if usecase.IsBlockedURL(err) {
+			c.writeJSONError(w, r, http.StatusForbidden, "URL is blocked")
+			return
+		}
+		if errors.Is(err, usecase.ErrStorageFull) {
+			c.writeJSONError(w, r, http.StatusInsufficientStorage, "Storage is full")
+			return
+		}
+		c.writeJSONError(w, r, http.StatusInternalServerError, "Shorten failed")
 		return
 	}
 
@@ -214,25 +615,68 @@ func (c *HTTPController) handlePing(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusOK)
 }
 
+// @Summary Проверка живости
+// @Description Проверяет, что воркеры асинхронного удаления обрабатывают очередь
+// @Tags System
+// @Success 200 {string} string "Сервис жив"
+// @Failure 503 {string} string "Воркеры удаления зависли"
+// @Router /livez [get]
+func (c *HTTPController) handleLiveness(w http.ResponseWriter, r *http.Request) {
+	if err := c.service.CheckLiveness(); err != nil {
+		http.Error(w, "Delete workers stalled", http.StatusServiceUnavailable)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// @Summary Проверка готовности
+// @Description Проверяет подключение к БД и, если включено READYZ_CHECK_WRITE, возможность записи
+// @Tags System
+// @Success 200 {string} string "Сервис готов"
+// @Failure 503 {string} string "БД недоступна или не принимает запись"
+// @Router /readyz [get]
+func (c *HTTPController) handleReadiness(w http.ResponseWriter, r *http.Request) {
+	if err := c.service.CheckReady(r.Context()); err != nil {
+		http.Error(w, "Not ready", http.StatusServiceUnavailable)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
 // @Summary Пакетное сокращение URL
 // @Description Принимает массив URL и возвращает их сокращенные версии
 // @Tags URLs
 // @Accept json
 // @Produce json
 // @Param request body []usecase.BatchShortenRequest true "Массив URL для сокращения"
-// @Success 201 {array} usecase.BatchShortenResponse "Массив сокращенных URL"
-// @Failure 400 {string} string "Неверный запрос"
+// @Param stream query bool false "Потоковый режим: массив читается и записывается частями по мере сокращения"
+// @Success 201 {array} usecase.BatchShortenResponse "Массив сокращенных URL; в потоковом режиме - массив BatchStreamItem"
+// @Header 201 {string} Link "Созданные короткие URL (rel=item), если включен BATCH_LINK_HEADERS"
+// @Success 207 {object} usecase.BatchMultiStatusResponse "URL сохранены частично"
+// @Failure 400 {object} ErrorResponse "Неверный запрос"
+// @Failure 403 {object} ErrorResponse "Домен URL в списке запрещенных"
+// @Failure 409 {object} ErrorResponse "Все URL уже существуют (CONFLICT_STRATEGY=error)"
+// @Failure 413 {object} ErrorResponse "Тело запроса больше MAX_BODY_BYTES"
+// @Failure 415 {object} ErrorResponse "Content-Type не application/json"
+// @Failure 507 {object} ErrorResponse "Достигнуто ограничение MAX_URLS"
 // @Router /api/shorten/batch [post]
 func (c *HTTPController) handleShortenBatch(w http.ResponseWriter, r *http.Request) {
+	if stream, _ := strconv.ParseBool(r.URL.Query().Get("stream")); stream {
+		c.handleShortenBatchStream(w, r)
+		return
+	}
+
 	var requests []usecase.BatchShortenRequest
 
 	if err := json.NewDecoder(r.Body).Decode(&requests); err != nil {
-		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		c.writeDecodeError(w, r, err)
 		return
 	}
 
 	if len(requests) == 0 {
-		http.Error(w, "Empty batch", http.StatusBadRequest)
+		c.writeJSONError(w, r, http.StatusBadRequest, "Empty batch")
 		return
 	}
 
@@ -241,45 +685,124 @@ func (c *HTTPController) handleShortenBatch(w http.ResponseWriter, r *http.Reque
 
 	responses, err := c.service.ShortenBatchWithUser(r.Context(), requests, userID)
 	if err != nil {
-		http.Error(w, "Batch shorten failed", http.StatusInternalServerError)
+		if partialErr, isPartial := usecase.IsBatchPartialFailure(err); isPartial {
+			response := usecase.BatchMultiStatusResponse{
+				Succeeded: responses,
+				Failed:    partialErr.Failed,
+			}
+			c.setBatchLinkHeaders(w, responses)
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusMultiStatus)
+			json.NewEncoder(w).Encode(response)
+			return
+		}
+		c.writeBatchError(w, r, err)
 		return
 	}
 
+	c.setBatchLinkHeaders(w, responses)
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusCreated)
 	json.NewEncoder(w).Encode(responses)
 }
 
+// linkTitleEscaper экранирует correlation_id для quoted-string в заголовке Link
+var linkTitleEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`)
+
+// writeBatchError отвечает ошибкой, соответствующей причине неудачи batch сокращения
+func (c *HTTPController) writeBatchError(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case usecase.IsDisallowedScheme(err):
+		c.writeJSONError(w, r, http.StatusBadRequest, "URL scheme is not allowed")
+	case usecase.IsBlockedURL(err):
+		c.writeJSONError(w, r, http.StatusForbidden, "URL is blocked")
+	case errors.Is(err, usecase.ErrURLExists):
+		c.writeJSONError(w, r, http.StatusConflict, "URLs already exist")
+	case errors.Is(err, usecase.ErrStorageFull):
+		c.writeJSONError(w, r, http.StatusInsufficientStorage, "Storage is full")
+	default:
+		c.writeJSONError(w, r, http.StatusInternalServerError, "Batch shorten failed")
+	}
+}
+
+// setBatchLinkHeaders добавляет заголовок Link для каждого созданного URL, если это включено.
+// Основным контрактом остается JSON тело ответа.
+func (c *HTTPController) setBatchLinkHeaders(w http.ResponseWriter, responses []usecase.BatchShortenResponse) {
+	if !c.opts.BatchLinkHeaders {
+		return
+	}
+
+	for _, resp := range responses {
+		w.Header().Add("Link", "<"+resp.ShortURL+`>; rel="item"; title="`+linkTitleEscaper.Replace(resp.CorrelationID)+`"`)
+	}
+}
+
+// Размеры страницы списка URL пользователя
+const (
+	defaultUserURLsLimit = 100
+	maxUserURLsLimit     = 1000
+)
+
 // @Summary Получение URL пользователя
-// @Description Возвращает все сокращенные URL текущего пользователя
+// @Description Возвращает сокращенные URL текущего пользователя в порядке создания постранично. Общее количество ссылок передается в заголовке X-Total-Count. Запрос без валидной куки получает 401 и новую куку.
 // @Tags Users
 // @Produce json
 // @Security Cookie
-// @Success 200 {array} usecase.UserURL "Список URL пользователя"
+// @Param limit query int false "Количество ссылок, от 1 до 1000" default(100)
+// @Param offset query int false "Сколько первых ссылок пропустить" default(0)
+// @Success 200 {array} usecase.UserURL "Список URL пользователя; пустой, если offset больше количества ссылок"
 // @Success 204 "URL не найдены"
-// @Failure 401 {string} string "Не авторизован"
-// @Failure 500 {string} string "Внутренняя ошибка сервера"
+// @Header 200,204 {integer} X-Total-Count "Общее количество ссылок пользователя"
+// @Failure 400 {object} ErrorResponse "Неверный limit или offset"
+// @Failure 401 {object} ErrorResponse "Не авторизован"
+// @Failure 500 {object} ErrorResponse "Внутренняя ошибка сервера"
 // @Router /api/user/urls [get]
 func (c *HTTPController) handleGetUserURLs(w http.ResponseWriter, r *http.Request) {
 	// Получаем ID пользователя из контекста (middleware уже добавил его)
 	userID, ok := appmiddleware.GetUserIDFromContext(r.Context())
-	if !ok {
-		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+	// Пользователь, которому ID выдан только что, не аутентифицирован и ссылок иметь не может
+	if !ok || appmiddleware.IsNewUserFromContext(r.Context()) {
+		c.writeJSONError(w, r, http.StatusUnauthorized, "Unauthorized")
 		return
 	}
 
+	query := r.URL.Query()
+	limit := defaultUserURLsLimit
+	if rawLimit := query.Get("limit"); rawLimit != "" {
+		parsed, err := strconv.Atoi(rawLimit)
+		if err != nil || parsed < 1 || parsed > maxUserURLsLimit {
+			c.writeJSONError(w, r, http.StatusBadRequest, "limit must be between 1 and "+strconv.Itoa(maxUserURLsLimit))
+			return
+		}
+		limit = parsed
+	}
+	offset := 0
+	if rawOffset := query.Get("offset"); rawOffset != "" {
+		parsed, err := strconv.Atoi(rawOffset)
+		if err != nil || parsed < 0 {
+			c.writeJSONError(w, r, http.StatusBadRequest, "offset must be a non-negative integer")
+			return
+		}
+		offset = parsed
+	}
+
 	// Получаем URL пользователя
-	urls, err := c.service.GetUserURLs(r.Context(), userID)
+	urls, total, err := c.service.GetUserURLs(r.Context(), userID, limit, offset)
 	if err != nil {
-		http.Error(w, "Failed to get user URLs", http.StatusInternalServerError)
+		c.writeJSONError(w, r, http.StatusInternalServerError, "Failed to get user URLs")
 		return
 	}
+	w.Header().Set("X-Total-Count", strconv.Itoa(total))
 
 	// Если у пользователя нет URL, возвращаем 204
-	if len(urls) == 0 {
+	if total == 0 {
 		w.WriteHeader(http.StatusNoContent)
 		return
 	}
+	// Страница за концом списка - пустой массив, а не null
+	if urls == nil {
+		urls = []usecase.UserURL{}
+	}
 
 	// Возвращаем URL пользователя
 	w.Header().Set("Content-Type", "application/json")
@@ -287,38 +810,323 @@ func (c *HTTPController) handleGetUserURLs(w http.ResponseWriter, r *http.Reques
 	json.NewEncoder(w).Encode(urls)
 }
 
+// @Summary Получение URL пользователя по идентификатору
+// @Description Возвращает URL, если он принадлежит текущему пользователю. Чужие и несуществующие URL одинаково возвращают 404.
+// @Tags Users
+// @Produce json
+// @Security Cookie
+// @Param shortID path string true "Короткий идентификатор URL"
+// @Success 200 {object} usecase.UserURL "URL пользователя"
+// @Failure 401 {object} ErrorResponse "Не авторизован"
+// @Failure 404 {object} ErrorResponse "URL не найден"
+// @Failure 500 {object} ErrorResponse "Внутренняя ошибка сервера"
+// @Router /api/user/urls/{shortID} [get]
+func (c *HTTPController) handleGetUserURL(w http.ResponseWriter, r *http.Request) {
+	userID, ok := appmiddleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		c.writeJSONError(w, r, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	url, err := c.service.GetUserURL(r.Context(), userID, chi.URLParam(r, "shortID"))
+	if err != nil {
+		if errors.Is(err, usecase.ErrURLNotFound) {
+			c.writeJSONError(w, r, http.StatusNotFound, "URL not found")
+			return
+		}
+		c.writeJSONError(w, r, http.StatusInternalServerError, "Failed to get user URL")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(url)
+}
+
+// Размеры страницы публичной ленты
+const (
+	defaultRecentLimit = 20
+	maxRecentLimit     = 100
+)
+
+// @Summary Публичная лента недавно созданных ссылок
+// @Description Возвращает последние ссылки, созданные с "public": true, новые первыми. Удаленные и истекшие ссылки не показываются.
+// @Tags URLs
+// @Produce json
+// @Param limit query int false "Количество ссылок, от 1 до 100" default(20)
+// @Success 200 {array} usecase.PublicURL "Публичные ссылки"
+// @Failure 400 {object} ErrorResponse "Неверный limit"
+// @Failure 500 {object} ErrorResponse "Внутренняя ошибка сервера"
+// @Router /api/public/recent [get]
+func (c *HTTPController) handleRecentPublicURLs(w http.ResponseWriter, r *http.Request) {
+	limit := defaultRecentLimit
+	if rawLimit := r.URL.Query().Get("limit"); rawLimit != "" {
+		parsed, err := strconv.Atoi(rawLimit)
+		if err != nil || parsed < 1 || parsed > maxRecentLimit {
+			c.writeJSONError(w, r, http.StatusBadRequest, "limit must be between 1 and "+strconv.Itoa(maxRecentLimit))
+			return
+		}
+		limit = parsed
+	}
+
+	urls, err := c.service.RecentPublicURLs(r.Context(), limit)
+	if err != nil {
+		c.writeJSONError(w, r, http.StatusInternalServerError, "Failed to get public URLs")
+		return
+	}
+
+	// Пустая лента отдается как [], а не null
+	if urls == nil {
+		urls = []usecase.PublicURL{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(urls)
+}
+
+// defaultDeleteRetryAfter - Retry-After при переполненной очереди удаления, если он не задан в Options
+const defaultDeleteRetryAfter = time.Second
+
 // @Summary Удаление URL пользователя
 // @Description Удаляет указанные URL пользователя
 // @Tags Users
 // @Accept json
 // @Security Cookie
 // @Param shortIDs body []string true "Массив коротких идентификаторов для удаления"
+// @Success 200 {object} usecase.DeleteResult "Итог удаления (синхронный режим)"
 // @Success 202 "Запрос на удаление принят"
-// @Failure 401 {string} string "Не авторизован"
-// @Failure 400 {string} string "Неверный запрос"
+// @Failure 401 {object} ErrorResponse "Не авторизован"
+// @Failure 400 {object} ErrorResponse "Неверный запрос"
+// @Failure 413 {object} ErrorResponse "Тело запроса больше MAX_BODY_BYTES"
+// @Failure 415 {object} ErrorResponse "Content-Type не application/json"
+// @Failure 500 {object} ErrorResponse "Внутренняя ошибка сервера"
+// @Failure 503 {object} ErrorResponse "Очередь удаления переполнена, повторите запрос через Retry-After секунд"
 // @Router /api/user/urls [delete]
 func (c *HTTPController) handleDeleteUserURLs(w http.ResponseWriter, r *http.Request) {
 	userID, ok := appmiddleware.GetUserIDFromContext(r.Context())
 	if !ok {
-		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		c.writeJSONError(w, r, http.StatusUnauthorized, "Unauthorized")
 		return
 	}
 
 	var shortIDs []string
 	if err := json.NewDecoder(r.Body).Decode(&shortIDs); err != nil {
-		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		c.writeDecodeError(w, r, err)
 		return
 	}
 
 	if len(shortIDs) == 0 {
-		http.Error(w, "Empty short IDs list", http.StatusBadRequest)
+		c.writeJSONError(w, r, http.StatusBadRequest, "Empty short IDs list")
+		return
+	}
+
+	if c.opts.SyncDelete {
+		result, err := c.service.DeleteUserURLsSync(r.Context(), userID, shortIDs)
+		if err != nil {
+			c.writeJSONError(w, r, http.StatusInternalServerError, "Failed to delete URLs")
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(result)
 		return
 	}
 
 	if err := c.service.DeleteUserURLs(userID, shortIDs); err != nil {
-		http.Error(w, "Failed to queue deletion request", http.StatusInternalServerError)
+		c.writeDeleteQueueError(w, r, err)
 		return
 	}
 
 	w.WriteHeader(http.StatusAccepted)
 }
+
+// @Summary Удаление всех URL пользователя
+// @Description Асинхронно удаляет все URL текущего пользователя, существующие на момент обработки запроса. Запрос без валидной куки получает 401.
+// @Tags Users
+// @Security Cookie
+// @Success 202 "Запрос на удаление принят"
+// @Failure 401 {object} ErrorResponse "Не авторизован"
+// @Failure 500 {object} ErrorResponse "Внутренняя ошибка сервера"
+// @Failure 503 {object} ErrorResponse "Очередь удаления переполнена, повторите запрос через Retry-After секунд"
+// @Router /api/user/urls/all [delete]
+func (c *HTTPController) handleDeleteAllUserURLs(w http.ResponseWriter, r *http.Request) {
+	userID, ok := appmiddleware.GetUserIDFromContext(r.Context())
+	// У только что выданного пользователя удалять нечего
+	if !ok || appmiddleware.IsNewUserFromContext(r.Context()) {
+		c.writeJSONError(w, r, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	if err := c.service.DeleteAllUserURLs(userID); err != nil {
+		c.writeDeleteQueueError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// writeDeleteQueueError отвечает на ошибку постановки удаления в очередь
+func (c *HTTPController) writeDeleteQueueError(w http.ResponseWriter, r *http.Request, err error) {
+	// Переполненная очередь - временная перегрузка, клиент должен повторить запрос позже
+	if errors.Is(err, usecase.ErrDeleteChannelFull) {
+		retryAfter := c.opts.DeleteRetryAfter
+		if retryAfter <= 0 {
+			retryAfter = defaultDeleteRetryAfter
+		}
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+		c.writeJSONError(w, r, http.StatusServiceUnavailable, "Deletion queue is full")
+		return
+	}
+	c.writeJSONError(w, r, http.StatusInternalServerError, "Failed to queue deletion request")
+}
+
+// GCResponse представляет результат очистки хранилища.
+type GCResponse struct {
+	Purged int64 `json:"purged" example:"42"`
+}
+
+// @Summary Очистка хранилища
+// @Description Физически удаляет ссылки с истекшим сроком действия и удаленные ссылки старше периода ожидания. Доступно только из доверенной подсети (X-Real-IP).
+// @Tags Internal
+// @Produce json
+// @Param X-Real-IP header string true "IP клиента"
+// @Success 200 {object} GCResponse "Количество удаленных ссылок"
+// @Failure 403 {string} string "IP не входит в доверенную подсеть"
+// @Failure 500 {object} ErrorResponse "Внутренняя ошибка сервера"
+// @Router /api/internal/gc [post]
+func (c *HTTPController) handleGC(w http.ResponseWriter, r *http.Request) {
+	purged, err := c.service.PurgeExpired(r.Context())
+	if err != nil {
+		c.writeJSONError(w, r, http.StatusInternalServerError, "Failed to purge URLs")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(GCResponse{Purged: purged})
+}
+
+// @Summary Создатель короткого URL
+// @Description Возвращает пользователя, создавшего короткий URL, и время создания. Работает и для удаленных ссылок. Доступно только из доверенной подсети (X-Real-IP).
+// @Tags Internal
+// @Produce json
+// @Param X-Real-IP header string true "IP клиента"
+// @Param shortID path string true "Короткий идентификатор URL"
+// @Success 200 {object} usecase.URLOwner "Создатель URL"
+// @Failure 403 {string} string "IP не входит в доверенную подсеть"
+// @Failure 404 {object} ErrorResponse "URL не найден"
+// @Failure 500 {object} ErrorResponse "Внутренняя ошибка сервера"
+// @Router /api/internal/urls/{shortID}/owner [get]
+func (c *HTTPController) handleGetURLOwner(w http.ResponseWriter, r *http.Request) {
+	owner, err := c.service.GetURLOwner(r.Context(), chi.URLParam(r, "shortID"))
+	if err != nil {
+		if errors.Is(err, usecase.ErrURLNotFound) {
+			c.writeJSONError(w, r, http.StatusNotFound, "URL not found")
+			return
+		}
+		c.writeJSONError(w, r, http.StatusInternalServerError, "Failed to get URL owner")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(owner)
+}
+
+// @Summary Статистика пула соединений с БД
+// @Description Возвращает текущую статистику пула соединений PostgreSQL. Доступно только из доверенной подсети (X-Real-IP).
+// @Tags Internal
+// @Produce json
+// @Param X-Real-IP header string true "IP клиента"
+// @Success 200 {object} usecase.PoolStats "Статистика пула"
+// @Failure 403 {string} string "IP не входит в доверенную подсеть"
+// @Failure 404 {object} ErrorResponse "Хранилище не использует PostgreSQL"
+// @Router /api/internal/db/stats [get]
+func (c *HTTPController) handleDBPoolStats(w http.ResponseWriter, r *http.Request) {
+	stats, err := c.service.DBPoolStats()
+	if err != nil {
+		c.writeJSONError(w, r, http.StatusNotFound, "Database pool is not used")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(stats)
+}
+
+// @Summary Показатели среды выполнения
+// @Description Возвращает число горутин, статистику памяти и число воркеров удаления - часть сведений pprof без открытия его порта. Доступно только из доверенной подсети (X-Real-IP).
+// @Tags Internal
+// @Produce json
+// @Param X-Real-IP header string true "IP клиента"
+// @Success 200 {object} usecase.RuntimeStats "Показатели среды выполнения"
+// @Failure 403 {string} string "IP не входит в доверенную подсеть"
+// @Router /api/internal/runtime [get]
+func (c *HTTPController) handleRuntimeStats(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(c.service.RuntimeStats())
+}
+
+// @Summary Статистика сервиса
+// @Description Возвращает количество неудаленных и неистекших ссылок и пользователей, у которых они есть. Доступно только из доверенной подсети (X-Real-IP).
+// @Tags Internal
+// @Produce json
+// @Param X-Real-IP header string true "IP клиента"
+// @Success 200 {object} usecase.Stats "Статистика сервиса"
+// @Failure 403 {string} string "IP не входит в доверенную подсеть"
+// @Failure 500 {object} ErrorResponse "Ошибка хранилища"
+// @Router /api/internal/stats [get]
+func (c *HTTPController) handleStats(w http.ResponseWriter, r *http.Request) {
+	stats, err := c.service.GetStats(r.Context())
+	if err != nil {
+		c.writeJSONError(w, r, http.StatusInternalServerError, "Failed to get stats")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(stats)
+}
+
+// resetMinInterval - минимальный интервал между сбросами хранилища
+const resetMinInterval = time.Second
+
+// ResetResponse представляет результат сброса хранилища.
+type ResetResponse struct {
+	Removed int64 `json:"removed" example:"42"`
+}
+
+// @Summary Сброс хранилища
+// @Description Удаляет все ссылки. Предназначено для тестовых окружений: регистрируется только при ALLOW_RESET=true и доступно только из доверенной подсети (X-Real-IP). Не чаще одного раза в секунду.
+// @Tags Internal
+// @Produce json
+// @Param X-Real-IP header string true "IP клиента"
+// @Success 200 {object} ResetResponse "Количество удаленных ссылок"
+// @Failure 403 {string} string "IP не входит в доверенную подсеть"
+// @Failure 429 {object} ErrorResponse "Слишком частый сброс"
+// @Failure 500 {object} ErrorResponse "Внутренняя ошибка сервера"
+// @Router /api/internal/reset [post]
+func (c *HTTPController) handleReset(w http.ResponseWriter, r *http.Request) {
+	c.resetMu.Lock()
+	defer c.resetMu.Unlock()
+
+	if wait := resetMinInterval - time.Since(c.lastReset); wait > 0 {
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+		c.writeJSONError(w, r, http.StatusTooManyRequests, "Reset is rate limited")
+		return
+	}
+	c.lastReset = time.Now()
+
+	removed, err := c.service.Reset(r.Context())
+	if err != nil {
+		c.writeJSONError(w, r, http.StatusInternalServerError, "Failed to reset storage")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(ResetResponse{Removed: removed})
+}
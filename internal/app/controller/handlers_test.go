@@ -2,13 +2,19 @@ package controller
 
 import (
 	"bytes"
+	"compress/gzip"
 	"context"
 	"encoding/json"
 	"errors"
+	"io"
+	"math"
+	"net"
 	"net/http"
 	"net/http/httptest"
+	"strconv"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/m-molecula741/shortener/internal/app/middleware"
@@ -19,33 +25,45 @@ import (
 
 // MockURLService мок для URLService
 type MockURLService struct {
-	ShortenFunc              func(url string) (string, error)
-	ShortenWithUserFunc      func(ctx context.Context, url, userID string) (string, error)
-	ExpandFunc               func(shortID string) (string, error)
+	ShortenFunc              func(ctx context.Context, url string) (string, error)
+	ShortenWithUserFunc      func(ctx context.Context, url, userID string, opts usecase.ShortenOptions) (string, error)
+	ExpandFunc               func(ctx context.Context, shortID string) (string, error)
 	PingDBFunc               func() error
 	ShortenBatchFunc         func(ctx context.Context, requests []usecase.BatchShortenRequest) ([]usecase.BatchShortenResponse, error)
 	ShortenBatchWithUserFunc func(ctx context.Context, requests []usecase.BatchShortenRequest, userID string) ([]usecase.BatchShortenResponse, error)
-	GetUserURLsFunc          func(ctx context.Context, userID string) ([]usecase.UserURL, error)
+	GetUserURLsFunc          func(ctx context.Context, userID string, limit, offset int) ([]usecase.UserURL, int, error)
+	GetUserURLFunc           func(ctx context.Context, userID, shortID string) (usecase.UserURL, error)
 	DeleteUserURLsFunc       func(userID string, shortIDs []string) error
+	DeleteAllUserURLsFunc    func(userID string) error
+	DeleteUserURLsSyncFunc   func(ctx context.Context, userID string, shortIDs []string) (usecase.DeleteResult, error)
+	PurgeExpiredFunc         func(ctx context.Context) (int64, error)
+	CheckLivenessFunc        func() error
+	ResetFunc                func(ctx context.Context) (int64, error)
+	CheckReadyFunc           func(ctx context.Context) error
+	GetURLOwnerFunc          func(ctx context.Context, shortID string) (usecase.URLOwner, error)
+	DBPoolStatsFunc          func() (usecase.PoolStats, error)
+	RuntimeStatsFunc         func() usecase.RuntimeStats
+	GetStatsFunc             func(ctx context.Context) (usecase.Stats, error)
+	RecentPublicURLsFunc     func(ctx context.Context, limit int) ([]usecase.PublicURL, error)
 }
 
-func (m *MockURLService) Shorten(url string) (string, error) {
+func (m *MockURLService) Shorten(ctx context.Context, url string) (string, error) {
 	if m.ShortenFunc != nil {
-		return m.ShortenFunc(url)
+		return m.ShortenFunc(ctx, url)
 	}
 	return "", nil
 }
 
-func (m *MockURLService) ShortenWithUser(ctx context.Context, url, userID string) (string, error) {
+func (m *MockURLService) ShortenWithUser(ctx context.Context, url, userID string, opts usecase.ShortenOptions) (string, error) {
 	if m.ShortenWithUserFunc != nil {
-		return m.ShortenWithUserFunc(ctx, url, userID)
+		return m.ShortenWithUserFunc(ctx, url, userID, opts)
 	}
 	return "http://localhost:8080/test123", nil
 }
 
-func (m *MockURLService) Expand(shortID string) (string, error) {
+func (m *MockURLService) Expand(ctx context.Context, shortID string) (string, error) {
 	if m.ExpandFunc != nil {
-		return m.ExpandFunc(shortID)
+		return m.ExpandFunc(ctx, shortID)
 	}
 	return "", nil
 }
@@ -87,11 +105,18 @@ func (m *MockURLService) ShortenBatchWithUser(ctx context.Context, requests []us
 	return responses, nil
 }
 
-func (m *MockURLService) GetUserURLs(ctx context.Context, userID string) ([]usecase.UserURL, error) {
+func (m *MockURLService) GetUserURLs(ctx context.Context, userID string, limit, offset int) ([]usecase.UserURL, int, error) {
 	if m.GetUserURLsFunc != nil {
-		return m.GetUserURLsFunc(ctx, userID)
+		return m.GetUserURLsFunc(ctx, userID, limit, offset)
 	}
-	return nil, nil
+	return nil, 0, nil
+}
+
+func (m *MockURLService) GetUserURL(ctx context.Context, userID, shortID string) (usecase.UserURL, error) {
+	if m.GetUserURLFunc != nil {
+		return m.GetUserURLFunc(ctx, userID, shortID)
+	}
+	return usecase.UserURL{}, usecase.ErrURLNotFound
 }
 
 func (m *MockURLService) DeleteUserURLs(userID string, shortIDs []string) error {
@@ -101,6 +126,83 @@ func (m *MockURLService) DeleteUserURLs(userID string, shortIDs []string) error
 	return nil
 }
 
+func (m *MockURLService) DeleteAllUserURLs(userID string) error {
+	if m.DeleteAllUserURLsFunc != nil {
+		return m.DeleteAllUserURLsFunc(userID)
+	}
+	return nil
+}
+
+func (m *MockURLService) DeleteUserURLsSync(ctx context.Context, userID string, shortIDs []string) (usecase.DeleteResult, error) {
+	if m.DeleteUserURLsSyncFunc != nil {
+		return m.DeleteUserURLsSyncFunc(ctx, userID, shortIDs)
+	}
+	return usecase.DeleteResult{}, nil
+}
+
+func (m *MockURLService) PurgeExpired(ctx context.Context) (int64, error) {
+	if m.PurgeExpiredFunc != nil {
+		return m.PurgeExpiredFunc(ctx)
+	}
+	return 0, nil
+}
+
+func (m *MockURLService) CheckLiveness() error {
+	if m.CheckLivenessFunc != nil {
+		return m.CheckLivenessFunc()
+	}
+	return nil
+}
+
+func (m *MockURLService) Reset(ctx context.Context) (int64, error) {
+	if m.ResetFunc != nil {
+		return m.ResetFunc(ctx)
+	}
+	return 0, nil
+}
+
+func (m *MockURLService) GetURLOwner(ctx context.Context, shortID string) (usecase.URLOwner, error) {
+	if m.GetURLOwnerFunc != nil {
+		return m.GetURLOwnerFunc(ctx, shortID)
+	}
+	return usecase.URLOwner{}, usecase.ErrURLNotFound
+}
+
+func (m *MockURLService) DBPoolStats() (usecase.PoolStats, error) {
+	if m.DBPoolStatsFunc != nil {
+		return m.DBPoolStatsFunc()
+	}
+	return usecase.PoolStats{}, usecase.ErrPoolStatsUnavailable
+}
+
+func (m *MockURLService) RuntimeStats() usecase.RuntimeStats {
+	if m.RuntimeStatsFunc != nil {
+		return m.RuntimeStatsFunc()
+	}
+	return usecase.RuntimeStats{}
+}
+
+func (m *MockURLService) GetStats(ctx context.Context) (usecase.Stats, error) {
+	if m.GetStatsFunc != nil {
+		return m.GetStatsFunc(ctx)
+	}
+	return usecase.Stats{}, nil
+}
+
+func (m *MockURLService) RecentPublicURLs(ctx context.Context, limit int) ([]usecase.PublicURL, error) {
+	if m.RecentPublicURLsFunc != nil {
+		return m.RecentPublicURLsFunc(ctx, limit)
+	}
+	return nil, nil
+}
+
+func (m *MockURLService) CheckReady(ctx context.Context) error {
+	if m.CheckReadyFunc != nil {
+		return m.CheckReadyFunc(ctx)
+	}
+	return nil
+}
+
 func TestHTTPController_handleShorten(t *testing.T) {
 	tests := []struct {
 		name           string
@@ -112,7 +214,7 @@ func TestHTTPController_handleShorten(t *testing.T) {
 		{
 			name: "successful shortening",
 			mockService: &MockURLService{
-				ShortenWithUserFunc: func(ctx context.Context, url, userID string) (string, error) {
+				ShortenWithUserFunc: func(ctx context.Context, url, userID string, opts usecase.ShortenOptions) (string, error) {
 					return "abc123", nil
 				},
 			},
@@ -123,7 +225,7 @@ func TestHTTPController_handleShorten(t *testing.T) {
 		{
 			name: "empty body",
 			mockService: &MockURLService{
-				ShortenWithUserFunc: func(ctx context.Context, url, userID string) (string, error) {
+				ShortenWithUserFunc: func(ctx context.Context, url, userID string, opts usecase.ShortenOptions) (string, error) {
 					return "unreachable", nil
 				},
 			},
@@ -134,7 +236,7 @@ func TestHTTPController_handleShorten(t *testing.T) {
 		{
 			name: "service error",
 			mockService: &MockURLService{
-				ShortenWithUserFunc: func(ctx context.Context, url, userID string) (string, error) {
+				ShortenWithUserFunc: func(ctx context.Context, url, userID string, opts usecase.ShortenOptions) (string, error) {
 					return "", errors.New("storage error")
 				},
 			},
@@ -145,7 +247,7 @@ func TestHTTPController_handleShorten(t *testing.T) {
 		{
 			name: "URL conflict",
 			mockService: &MockURLService{
-				ShortenWithUserFunc: func(ctx context.Context, url, userID string) (string, error) {
+				ShortenWithUserFunc: func(ctx context.Context, url, userID string, opts usecase.ShortenOptions) (string, error) {
 					return "http://localhost:8080/existing123", &usecase.ErrURLConflict{
 						ExistingShortURL: "http://localhost:8080/existing123",
 					}
@@ -155,13 +257,46 @@ func TestHTTPController_handleShorten(t *testing.T) {
 			expectedStatus: http.StatusConflict,
 			expectedBody:   "http://localhost:8080/existing123",
 		},
+		{
+			name: "disallowed scheme",
+			mockService: &MockURLService{
+				ShortenWithUserFunc: func(ctx context.Context, url, userID string, opts usecase.ShortenOptions) (string, error) {
+					return "", &usecase.ErrDisallowedScheme{Scheme: "javascript"}
+				},
+			},
+			requestBody:    "javascript:alert(1)",
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   "URL scheme is not allowed\n",
+		},
+		{
+			name: "blocked URL",
+			mockService: &MockURLService{
+				ShortenWithUserFunc: func(ctx context.Context, url, userID string, opts usecase.ShortenOptions) (string, error) {
+					return "", &usecase.ErrBlockedURL{Host: "evil.com"}
+				},
+			},
+			requestBody:    "https://evil.com",
+			expectedStatus: http.StatusForbidden,
+			expectedBody:   "URL is blocked\n",
+		},
+		{
+			name: "URL exists with error strategy",
+			mockService: &MockURLService{
+				ShortenWithUserFunc: func(ctx context.Context, url, userID string, opts usecase.ShortenOptions) (string, error) {
+					return "", usecase.ErrURLExists
+				},
+			},
+			requestBody:    "https://example.com",
+			expectedStatus: http.StatusConflict,
+			expectedBody:   "URL already exists\n",
+		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			auth, err := middleware.NewAuthMiddleware("test-key")
 			require.NoError(t, err)
-			controller := NewHTTPController(tt.mockService, auth)
+			controller := NewHTTPController(tt.mockService, auth, Options{})
 
 			req := httptest.NewRequest(http.MethodPost, "/", bytes.NewBufferString(tt.requestBody))
 			w := httptest.NewRecorder()
@@ -185,7 +320,7 @@ func TestHTTPController_handleRedirect(t *testing.T) {
 		{
 			name: "successful redirect",
 			mockService: &MockURLService{
-				ExpandFunc: func(shortID string) (string, error) {
+				ExpandFunc: func(ctx context.Context, shortID string) (string, error) {
 					return "https://original.url", nil
 				},
 			},
@@ -196,7 +331,7 @@ func TestHTTPController_handleRedirect(t *testing.T) {
 		{
 			name: "not found",
 			mockService: &MockURLService{
-				ExpandFunc: func(shortID string) (string, error) {
+				ExpandFunc: func(ctx context.Context, shortID string) (string, error) {
 					return "", errors.New("not found")
 				},
 			},
@@ -204,13 +339,35 @@ func TestHTTPController_handleRedirect(t *testing.T) {
 			expectedStatus: http.StatusNotFound,
 			expectedLoc:    "",
 		},
+		{
+			name: "expired",
+			mockService: &MockURLService{
+				ExpandFunc: func(ctx context.Context, shortID string) (string, error) {
+					return "", &usecase.ErrURLExpired{}
+				},
+			},
+			shortID:        "abc123",
+			expectedStatus: http.StatusGone,
+			expectedLoc:    "",
+		},
+		{
+			name: "disallowed scheme",
+			mockService: &MockURLService{
+				ExpandFunc: func(ctx context.Context, shortID string) (string, error) {
+					return "", &usecase.ErrDisallowedScheme{Scheme: "javascript"}
+				},
+			},
+			shortID:        "abc123",
+			expectedStatus: http.StatusBadRequest,
+			expectedLoc:    "",
+		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			auth, err := middleware.NewAuthMiddleware("test-key")
 			require.NoError(t, err)
-			controller := NewHTTPController(tt.mockService, auth)
+			controller := NewHTTPController(tt.mockService, auth, Options{})
 
 			req := httptest.NewRequest(http.MethodGet, "/"+tt.shortID, nil)
 			w := httptest.NewRecorder()
@@ -219,6 +376,128 @@ func TestHTTPController_handleRedirect(t *testing.T) {
 
 			assert.Equal(t, tt.expectedStatus, w.Code)
 			assert.Equal(t, tt.expectedLoc, w.Header().Get("Location"))
+			if tt.expectedStatus == http.StatusTemporaryRedirect {
+				assert.Empty(t, w.Body.String(), "по умолчанию тело редиректа пустое")
+			}
+		})
+	}
+}
+
+func TestHTTPController_handleRedirectLandingPages(t *testing.T) {
+	auth, err := middleware.NewAuthMiddleware("test-key")
+	require.NoError(t, err)
+
+	opts := Options{
+		DeletedRedirectURL:  "https://brand.example.com/gone",
+		NotFoundRedirectURL: "https://brand.example.com/missing",
+	}
+
+	tests := []struct {
+		name           string
+		opts           Options
+		expandErr      error
+		expectedStatus int
+		expectedLoc    string
+	}{
+		{name: "deleted", opts: opts, expandErr: &usecase.ErrURLDeleted{}, expectedStatus: http.StatusFound, expectedLoc: "https://brand.example.com/gone"},
+		{name: "expired", opts: opts, expandErr: &usecase.ErrURLExpired{}, expectedStatus: http.StatusFound, expectedLoc: "https://brand.example.com/gone"},
+		{name: "not found", opts: opts, expandErr: usecase.ErrURLNotFound, expectedStatus: http.StatusFound, expectedLoc: "https://brand.example.com/missing"},
+		{name: "disallowed scheme unchanged", opts: opts, expandErr: &usecase.ErrDisallowedScheme{}, expectedStatus: http.StatusBadRequest},
+		{name: "deleted without landing page", expandErr: &usecase.ErrURLDeleted{}, expectedStatus: http.StatusGone},
+		{name: "not found without landing page", expandErr: usecase.ErrURLNotFound, expectedStatus: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockService := &MockURLService{
+				ExpandFunc: func(ctx context.Context, shortID string) (string, error) {
+					return "", tt.expandErr
+				},
+			}
+			controller := NewHTTPController(mockService, auth, tt.opts)
+
+			req := httptest.NewRequest(http.MethodGet, "/abc123", nil)
+			w := httptest.NewRecorder()
+			controller.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.expectedStatus, w.Code)
+			assert.Equal(t, tt.expectedLoc, w.Header().Get("Location"))
+		})
+	}
+}
+
+func TestHTTPController_handleRedirectBody(t *testing.T) {
+	auth, err := middleware.NewAuthMiddleware("test-key")
+	require.NoError(t, err)
+
+	tests := []struct {
+		name           string
+		expandErr      error
+		expectedStatus int
+		expectedBody   string
+	}{
+		{name: "redirect", expectedStatus: http.StatusTemporaryRedirect, expectedBody: "https://original.url"},
+		{name: "not found", expandErr: usecase.ErrURLNotFound, expectedStatus: http.StatusNotFound, expectedBody: "URL not found"},
+		{name: "expired", expandErr: &usecase.ErrURLExpired{}, expectedStatus: http.StatusGone, expectedBody: "URL has expired"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockService := &MockURLService{
+				ExpandFunc: func(ctx context.Context, shortID string) (string, error) {
+					if tt.expandErr != nil {
+						return "", tt.expandErr
+					}
+					return "https://original.url", nil
+				},
+			}
+			controller := NewHTTPController(mockService, auth, Options{RedirectBody: true})
+
+			req := httptest.NewRequest(http.MethodGet, "/abc123", nil)
+			w := httptest.NewRecorder()
+			controller.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.expectedStatus, w.Code)
+			assert.Equal(t, tt.expectedBody, w.Body.String())
+		})
+	}
+}
+
+func TestHTTPController_handleRedirectHead(t *testing.T) {
+	auth, err := middleware.NewAuthMiddleware("test-key")
+	require.NoError(t, err)
+
+	tests := []struct {
+		name           string
+		expandErr      error
+		expectedStatus int
+		expectedLoc    string
+	}{
+		{name: "redirect", expectedStatus: http.StatusTemporaryRedirect, expectedLoc: "https://original.url"},
+		{name: "not found", expandErr: usecase.ErrURLNotFound, expectedStatus: http.StatusNotFound},
+		{name: "deleted", expandErr: &usecase.ErrURLDeleted{}, expectedStatus: http.StatusGone},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockService := &MockURLService{
+				ExpandFunc: func(ctx context.Context, shortID string) (string, error) {
+					if tt.expandErr != nil {
+						return "", tt.expandErr
+					}
+					return "https://original.url", nil
+				},
+			}
+			// Тело не отправляется даже при REDIRECT_BODY=true
+			controller := NewHTTPController(mockService, auth, Options{RedirectBody: true})
+
+			req := httptest.NewRequest(http.MethodHead, "/abc123", nil)
+			w := httptest.NewRecorder()
+			controller.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.expectedStatus, w.Code)
+			assert.Equal(t, tt.expectedLoc, w.Header().Get("Location"))
+			assert.Empty(t, w.Body.String())
 		})
 	}
 }
@@ -261,19 +540,59 @@ func TestHandleShortenJSON(t *testing.T) {
 			expectedStatus: http.StatusConflict,
 			expectedResult: "http://localhost:8080/existing123",
 		},
+		{
+			name: "срок действия в прошлом",
+			request: ShortenRequest{
+				URL:       "https://practicum.yandex.ru",
+				ExpiresAt: "2000-01-01T00:00:00Z",
+			},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name: "неверный срок действия",
+			request: ShortenRequest{
+				URL:       "https://practicum.yandex.ru",
+				ExpiresIn: "tomorrow",
+			},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name: "хранилище заполнено",
+			request: ShortenRequest{
+				URL: "https://practicum.yandex.ru",
+			},
+			mockError:      usecase.ErrStorageFull,
+			expectedStatus: http.StatusInsufficientStorage,
+		},
+		{
+			name: "запрос с тем же ключом идемпотентности выполняется",
+			request: ShortenRequest{
+				URL: "https://practicum.yandex.ru",
+			},
+			mockError:      usecase.ErrIdempotencyKeyInProgress,
+			expectedStatus: http.StatusConflict,
+		},
+		{
+			name: "ключ идемпотентности использован с другим URL",
+			request: ShortenRequest{
+				URL: "https://practicum.yandex.ru",
+			},
+			mockError:      usecase.ErrIdempotencyKeyMismatch,
+			expectedStatus: http.StatusUnprocessableEntity,
+		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			mockService := &MockURLService{
-				ShortenWithUserFunc: func(ctx context.Context, url, userID string) (string, error) {
+				ShortenWithUserFunc: func(ctx context.Context, url, userID string, opts usecase.ShortenOptions) (string, error) {
 					return tt.mockResponse, tt.mockError
 				},
 			}
 
 			auth, err := middleware.NewAuthMiddleware("test-key")
 			require.NoError(t, err)
-			controller := NewHTTPController(mockService, auth)
+			controller := NewHTTPController(mockService, auth, Options{})
 
 			reqBody, err := json.Marshal(tt.request)
 			require.NoError(t, err)
@@ -296,6 +615,41 @@ func TestHandleShortenJSON(t *testing.T) {
 	}
 }
 
+func TestShortenRequest_expiresAt(t *testing.T) {
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name    string
+		request ShortenRequest
+		want    time.Time
+		wantErr bool
+	}{
+		{name: "без срока", request: ShortenRequest{}, want: time.Time{}},
+		{name: "expires_in", request: ShortenRequest{ExpiresIn: "24h"}, want: now.Add(24 * time.Hour)},
+		{name: "expires_at", request: ShortenRequest{ExpiresAt: "2025-01-02T00:00:00Z"}, want: now.Add(24 * time.Hour)},
+		{name: "отрицательный expires_in", request: ShortenRequest{ExpiresIn: "-1h"}, wantErr: true},
+		{name: "expires_at в прошлом", request: ShortenRequest{ExpiresAt: "2024-12-31T00:00:00Z"}, wantErr: true},
+		{name: "неверный формат expires_at", request: ShortenRequest{ExpiresAt: "2025-01-02"}, wantErr: true},
+		{name: "оба поля", request: ShortenRequest{ExpiresIn: "1h", ExpiresAt: "2025-01-02T00:00:00Z"}, wantErr: true},
+		{name: "ttl_seconds", request: ShortenRequest{TTLSeconds: 3600}, want: now.Add(time.Hour)},
+		{name: "отрицательный ttl_seconds", request: ShortenRequest{TTLSeconds: -1}, wantErr: true},
+		{name: "слишком большой ttl_seconds", request: ShortenRequest{TTLSeconds: math.MaxInt64}, wantErr: true},
+		{name: "ttl_seconds и expires_in", request: ShortenRequest{TTLSeconds: 60, ExpiresIn: "1h"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.request.expiresAt(now)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.True(t, tt.want.Equal(got), "got %v, want %v", got, tt.want)
+		})
+	}
+}
+
 func TestHTTPController_handlePing(t *testing.T) {
 	tests := []struct {
 		name           string
@@ -326,7 +680,7 @@ func TestHTTPController_handlePing(t *testing.T) {
 		t.Run(tt.name, func(t *testing.T) {
 			auth, err := middleware.NewAuthMiddleware("test-key")
 			require.NoError(t, err)
-			controller := NewHTTPController(tt.mockService, auth)
+			controller := NewHTTPController(tt.mockService, auth, Options{})
 
 			req := httptest.NewRequest(http.MethodGet, "/ping", nil)
 			w := httptest.NewRecorder()
@@ -338,6 +692,75 @@ func TestHTTPController_handlePing(t *testing.T) {
 	}
 }
 
+func TestHTTPController_handleLiveness(t *testing.T) {
+	tests := []struct {
+		name           string
+		livenessErr    error
+		expectedStatus int
+	}{
+		{
+			name:           "воркеры работают",
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "воркеры зависли",
+			livenessErr:    usecase.ErrDeleteWorkersStalled,
+			expectedStatus: http.StatusServiceUnavailable,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockService := &MockURLService{
+				CheckLivenessFunc: func() error {
+					return tt.livenessErr
+				},
+			}
+			auth, err := middleware.NewAuthMiddleware("test-key")
+			require.NoError(t, err)
+			controller := NewHTTPController(mockService, auth, Options{})
+
+			req := httptest.NewRequest(http.MethodGet, "/livez", nil)
+			w := httptest.NewRecorder()
+
+			controller.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.expectedStatus, w.Code)
+		})
+	}
+}
+
+func TestHTTPController_handleReadiness(t *testing.T) {
+	tests := []struct {
+		name           string
+		readyErr       error
+		expectedStatus int
+	}{
+		{name: "готов", expectedStatus: http.StatusOK},
+		{name: "БД только для чтения", readyErr: errors.New("database is not writable"), expectedStatus: http.StatusServiceUnavailable},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockService := &MockURLService{
+				CheckReadyFunc: func(ctx context.Context) error {
+					return tt.readyErr
+				},
+			}
+			auth, err := middleware.NewAuthMiddleware("test-key")
+			require.NoError(t, err)
+			controller := NewHTTPController(mockService, auth, Options{})
+
+			req := httptest.NewRequest(http.MethodGet, "/readyz", nil)
+			w := httptest.NewRecorder()
+
+			controller.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.expectedStatus, w.Code)
+		})
+	}
+}
+
 func TestHTTPController_handleShortenBatch(t *testing.T) {
 	tests := []struct {
 		name           string
@@ -396,13 +819,31 @@ func TestHTTPController_handleShortenBatch(t *testing.T) {
 			expectedStatus: http.StatusInternalServerError,
 			expectedCount:  0,
 		},
+		{
+			name: "частичное сохранение",
+			requests: []usecase.BatchShortenRequest{
+				{CorrelationID: "1", OriginalURL: "https://example.com"},
+				{CorrelationID: "2", OriginalURL: "https://google.com"},
+			},
+			mockService: &MockURLService{
+				ShortenBatchWithUserFunc: func(ctx context.Context, requests []usecase.BatchShortenRequest, userID string) ([]usecase.BatchShortenResponse, error) {
+					return []usecase.BatchShortenResponse{
+						{CorrelationID: "1", ShortURL: "http://localhost:8080/test1"},
+					}, &usecase.ErrBatchPartialFailure{
+						Failed: []usecase.BatchFailure{{CorrelationID: "2", Reason: usecase.BatchReasonInternal}},
+					}
+				},
+			},
+			expectedStatus: http.StatusMultiStatus,
+			expectedCount:  1,
+		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			auth, err := middleware.NewAuthMiddleware("test-key")
 			require.NoError(t, err)
-			controller := NewHTTPController(tt.mockService, auth)
+			controller := NewHTTPController(tt.mockService, auth, Options{})
 
 			var body []byte
 			var err2 error
@@ -435,21 +876,893 @@ func TestHTTPController_handleShortenBatch(t *testing.T) {
 					assert.NotEmpty(t, response.ShortURL)
 				}
 			}
-		})
-	}
+
+			if tt.expectedStatus == http.StatusMultiStatus {
+				var response usecase.BatchMultiStatusResponse
+				err2 = json.Unmarshal(rr.Body.Bytes(), &response)
+				require.NoError(t, err2)
+
+				assert.Len(t, response.Succeeded, tt.expectedCount)
+				require.Len(t, response.Failed, 1)
+				assert.Equal(t, "2", response.Failed[0].CorrelationID)
+			}
+		})
+	}
+}
+
+func TestHTTPController_handleShortenBatchLinkHeaders(t *testing.T) {
+	mockService := &MockURLService{
+		ShortenBatchWithUserFunc: func(ctx context.Context, requests []usecase.BatchShortenRequest, userID string) ([]usecase.BatchShortenResponse, error) {
+			return []usecase.BatchShortenResponse{
+				{CorrelationID: "1", ShortURL: "http://localhost:8080/abc123"},
+				{CorrelationID: `say "hi"`, ShortURL: "http://localhost:8080/def456"},
+			}, nil
+		},
+	}
+
+	auth, err := middleware.NewAuthMiddleware("test-key")
+	require.NoError(t, err)
+
+	for _, enabled := range []bool{false, true} {
+		controller := NewHTTPController(mockService, auth, Options{BatchLinkHeaders: enabled})
+
+		body := `[{"correlation_id":"1","original_url":"https://a.com"},{"correlation_id":"say \"hi\"","original_url":"https://b.com"}]`
+		req := httptest.NewRequest(http.MethodPost, "/api/shorten/batch", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		rr := httptest.NewRecorder()
+		controller.ServeHTTP(rr, req)
+
+		require.Equal(t, http.StatusCreated, rr.Code)
+		if !enabled {
+			assert.Empty(t, rr.Header().Values("Link"))
+			continue
+		}
+		assert.Equal(t, []string{
+			`<http://localhost:8080/abc123>; rel="item"; title="1"`,
+			`<http://localhost:8080/def456>; rel="item"; title="say \"hi\""`,
+		}, rr.Header().Values("Link"))
+	}
+}
+
+func TestHTTPController_handleShortenBatchStream(t *testing.T) {
+	auth, err := middleware.NewAuthMiddleware("test-key")
+	require.NoError(t, err)
+
+	t.Run("части batch отправляются по мере сокращения", func(t *testing.T) {
+		var chunkSizes []int
+		mockService := &MockURLService{
+			ShortenBatchWithUserFunc: func(ctx context.Context, requests []usecase.BatchShortenRequest, userID string) ([]usecase.BatchShortenResponse, error) {
+				chunkSizes = append(chunkSizes, len(requests))
+				responses := make([]usecase.BatchShortenResponse, 0, len(requests))
+				failed := []usecase.BatchFailure{}
+				for _, req := range requests {
+					if req.CorrelationID == "bad" {
+						failed = append(failed, usecase.BatchFailure{CorrelationID: req.CorrelationID, Reason: usecase.BatchReasonInternal})
+						continue
+					}
+					responses = append(responses, usecase.BatchShortenResponse{
+						CorrelationID: req.CorrelationID,
+						ShortURL:      "http://localhost:8080/" + req.CorrelationID,
+					})
+				}
+				if len(failed) > 0 {
+					return responses, &usecase.ErrBatchPartialFailure{Failed: failed}
+				}
+				return responses, nil
+			},
+		}
+		controller := NewHTTPController(mockService, auth, Options{})
+
+		requests := make([]usecase.BatchShortenRequest, batchStreamChunkSize+500)
+		for i := range requests {
+			requests[i] = usecase.BatchShortenRequest{CorrelationID: strconv.Itoa(i), OriginalURL: "https://example.com/" + strconv.Itoa(i)}
+		}
+		requests[len(requests)-1].CorrelationID = "bad"
+		body, err := json.Marshal(requests)
+		require.NoError(t, err)
+
+		req := httptest.NewRequest(http.MethodPost, "/api/shorten/batch?stream=true", bytes.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		rr := httptest.NewRecorder()
+		controller.ServeHTTP(rr, req)
+
+		require.Equal(t, http.StatusCreated, rr.Code)
+		assert.Equal(t, []int{batchStreamChunkSize, 500}, chunkSizes)
+
+		var items []BatchStreamItem
+		require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &items))
+		require.Len(t, items, len(requests))
+		assert.Equal(t, BatchStreamItem{CorrelationID: "0", ShortURL: "http://localhost:8080/0"}, items[0])
+		assert.Equal(t, BatchStreamItem{CorrelationID: "bad", Reason: usecase.BatchReasonInternal}, items[len(items)-1])
+	})
+
+	tests := []struct {
+		name           string
+		body           string
+		serviceErr     error
+		expectedStatus int
+	}{
+		{name: "пустой batch", body: `[]`, expectedStatus: http.StatusBadRequest},
+		{name: "не массив", body: `{"url":"https://a.com"}`, expectedStatus: http.StatusBadRequest},
+		{name: "ошибка первой части", body: `[{"correlation_id":"1","original_url":"https://a.com"}]`, serviceErr: &usecase.ErrBlockedURL{Host: "a.com"}, expectedStatus: http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockService := &MockURLService{
+				ShortenBatchWithUserFunc: func(ctx context.Context, requests []usecase.BatchShortenRequest, userID string) ([]usecase.BatchShortenResponse, error) {
+					return nil, tt.serviceErr
+				},
+			}
+			controller := NewHTTPController(mockService, auth, Options{})
+
+			req := httptest.NewRequest(http.MethodPost, "/api/shorten/batch?stream=true", strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			rr := httptest.NewRecorder()
+			controller.ServeHTTP(rr, req)
+
+			assert.Equal(t, tt.expectedStatus, rr.Code)
+		})
+	}
+}
+
+func TestHTTPController_handleDeleteUserURLsSync(t *testing.T) {
+	mockService := &MockURLService{
+		DeleteUserURLsSyncFunc: func(ctx context.Context, userID string, shortIDs []string) (usecase.DeleteResult, error) {
+			return usecase.DeleteResult{
+				Deleted:        []string{"abc"},
+				AlreadyDeleted: []string{"def"},
+				NotFound:       []string{"ghi"},
+			}, nil
+		},
+	}
+
+	auth, err := middleware.NewAuthMiddleware("test-key")
+	require.NoError(t, err)
+	controller := NewHTTPController(mockService, auth, Options{SyncDelete: true})
+
+	req := httptest.NewRequest(http.MethodDelete, "/api/user/urls", strings.NewReader(`["abc","def","ghi"]`))
+	req = req.WithContext(middleware.SetUserIDToContext(req.Context(), "user1"))
+	rr := httptest.NewRecorder()
+
+	controller.handleDeleteUserURLs(rr, req)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+
+	var result usecase.DeleteResult
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &result))
+	assert.Equal(t, []string{"abc"}, result.Deleted)
+	assert.Equal(t, []string{"def"}, result.AlreadyDeleted)
+	assert.Equal(t, []string{"ghi"}, result.NotFound)
+}
+
+func TestHTTPController_handleDeleteUserURLsQueueFull(t *testing.T) {
+	auth, err := middleware.NewAuthMiddleware("test-key")
+	require.NoError(t, err)
+
+	tests := []struct {
+		name               string
+		serviceErr         error
+		opts               Options
+		expectedStatus     int
+		expectedRetryAfter string
+	}{
+		{name: "запрос принят", expectedStatus: http.StatusAccepted},
+		{name: "очередь переполнена", serviceErr: usecase.ErrDeleteChannelFull, expectedStatus: http.StatusServiceUnavailable, expectedRetryAfter: "1"},
+		{name: "Retry-After из настроек", serviceErr: usecase.ErrDeleteChannelFull, opts: Options{DeleteRetryAfter: 1500 * time.Millisecond}, expectedStatus: http.StatusServiceUnavailable, expectedRetryAfter: "2"},
+		{name: "внутренняя ошибка", serviceErr: errors.New("storage error"), expectedStatus: http.StatusInternalServerError},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockService := &MockURLService{
+				DeleteUserURLsFunc: func(userID string, shortIDs []string) error {
+					return tt.serviceErr
+				},
+			}
+			controller := NewHTTPController(mockService, auth, tt.opts)
+
+			req := httptest.NewRequest(http.MethodDelete, "/api/user/urls", strings.NewReader(`["abc"]`))
+			req = req.WithContext(middleware.SetUserIDToContext(req.Context(), "user1"))
+			rr := httptest.NewRecorder()
+
+			controller.handleDeleteUserURLs(rr, req)
+
+			assert.Equal(t, tt.expectedStatus, rr.Code)
+			assert.Equal(t, tt.expectedRetryAfter, rr.Header().Get("Retry-After"))
+		})
+	}
+}
+
+func TestHTTPController_handleGetUserURL(t *testing.T) {
+	mockService := &MockURLService{
+		GetUserURLFunc: func(ctx context.Context, userID, shortID string) (usecase.UserURL, error) {
+			if shortID != "own123" {
+				return usecase.UserURL{}, usecase.ErrURLNotFound
+			}
+			return usecase.UserURL{
+				ShortURL:    "http://localhost:8080/own123",
+				OriginalURL: "https://example.com",
+			}, nil
+		},
+	}
+
+	auth, err := middleware.NewAuthMiddleware("test-key")
+	require.NoError(t, err)
+	controller := NewHTTPController(mockService, auth, Options{})
+
+	tests := []struct {
+		name           string
+		shortID        string
+		expectedStatus int
+	}{
+		{name: "own URL", shortID: "own123", expectedStatus: http.StatusOK},
+		{name: "foreign or missing URL", shortID: "other123", expectedStatus: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/api/user/urls/"+tt.shortID, nil)
+			rr := httptest.NewRecorder()
+			controller.ServeHTTP(rr, req)
+
+			assert.Equal(t, tt.expectedStatus, rr.Code)
+			if tt.expectedStatus == http.StatusOK {
+				var url usecase.UserURL
+				require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &url))
+				assert.Equal(t, "https://example.com", url.OriginalURL)
+			}
+		})
+	}
+}
+
+func TestHTTPController_handleGC(t *testing.T) {
+	_, subnet, err := net.ParseCIDR("10.0.0.0/8")
+	require.NoError(t, err)
+
+	mockService := &MockURLService{
+		PurgeExpiredFunc: func(ctx context.Context) (int64, error) {
+			return 5, nil
+		},
+	}
+
+	auth, err := middleware.NewAuthMiddleware("test-key")
+	require.NoError(t, err)
+	controller := NewHTTPController(mockService, auth, Options{TrustedSubnet: subnet})
+
+	tests := []struct {
+		name           string
+		realIP         string
+		expectedStatus int
+	}{
+		{name: "trusted subnet", realIP: "10.0.0.1", expectedStatus: http.StatusOK},
+		{name: "untrusted subnet", realIP: "8.8.8.8", expectedStatus: http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/api/internal/gc", nil)
+			req.Header.Set("X-Real-IP", tt.realIP)
+			rr := httptest.NewRecorder()
+			controller.ServeHTTP(rr, req)
+
+			assert.Equal(t, tt.expectedStatus, rr.Code)
+			if tt.expectedStatus == http.StatusOK {
+				assert.JSONEq(t, `{"purged":5}`, rr.Body.String())
+			}
+		})
+	}
+}
+
+func TestHTTPController_handleReset(t *testing.T) {
+	_, subnet, err := net.ParseCIDR("10.0.0.0/8")
+	require.NoError(t, err)
+
+	mockService := &MockURLService{
+		ResetFunc: func(ctx context.Context) (int64, error) {
+			return 7, nil
+		},
+	}
+
+	auth, err := middleware.NewAuthMiddleware("test-key")
+	require.NoError(t, err)
+
+	tests := []struct {
+		name           string
+		allowReset     bool
+		realIP         string
+		expectedStatus int
+	}{
+		{name: "reset disabled", allowReset: false, realIP: "10.0.0.1", expectedStatus: http.StatusNotFound},
+		{name: "untrusted subnet", allowReset: true, realIP: "8.8.8.8", expectedStatus: http.StatusForbidden},
+		{name: "trusted subnet", allowReset: true, realIP: "10.0.0.1", expectedStatus: http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			controller := NewHTTPController(mockService, auth, Options{TrustedSubnet: subnet, AllowReset: tt.allowReset})
+
+			req := httptest.NewRequest(http.MethodPost, "/api/internal/reset", nil)
+			req.Header.Set("X-Real-IP", tt.realIP)
+			rr := httptest.NewRecorder()
+			controller.ServeHTTP(rr, req)
+
+			assert.Equal(t, tt.expectedStatus, rr.Code)
+			if tt.expectedStatus == http.StatusOK {
+				assert.JSONEq(t, `{"removed":7}`, rr.Body.String())
+			}
+		})
+	}
+
+	t.Run("rate limited", func(t *testing.T) {
+		controller := NewHTTPController(mockService, auth, Options{TrustedSubnet: subnet, AllowReset: true})
+
+		var codes []int
+		for i := 0; i < 2; i++ {
+			req := httptest.NewRequest(http.MethodPost, "/api/internal/reset", nil)
+			req.Header.Set("X-Real-IP", "10.0.0.1")
+			rr := httptest.NewRecorder()
+			controller.ServeHTTP(rr, req)
+			codes = append(codes, rr.Code)
+			if rr.Code == http.StatusTooManyRequests {
+				assert.Equal(t, "1", rr.Header().Get("Retry-After"))
+			}
+		}
+
+		assert.Equal(t, []int{http.StatusOK, http.StatusTooManyRequests}, codes)
+	})
+}
+
+func TestHTTPController_handleGetURLOwner(t *testing.T) {
+	_, subnet, err := net.ParseCIDR("10.0.0.0/8")
+	require.NoError(t, err)
+
+	createdAt := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	mockService := &MockURLService{
+		GetURLOwnerFunc: func(ctx context.Context, shortID string) (usecase.URLOwner, error) {
+			if shortID != "abc123" {
+				return usecase.URLOwner{}, usecase.ErrURLNotFound
+			}
+			return usecase.URLOwner{UserID: "user1", CreatedAt: createdAt}, nil
+		},
+	}
+
+	auth, err := middleware.NewAuthMiddleware("test-key")
+	require.NoError(t, err)
+	controller := NewHTTPController(mockService, auth, Options{TrustedSubnet: subnet})
+
+	tests := []struct {
+		name           string
+		shortID        string
+		realIP         string
+		expectedStatus int
+		expectedBody   string
+	}{
+		{
+			name:           "owner found",
+			shortID:        "abc123",
+			realIP:         "10.0.0.1",
+			expectedStatus: http.StatusOK,
+			expectedBody:   `{"user_id":"user1","created_at":"2025-01-01T12:00:00Z"}`,
+		},
+		{name: "unknown short id", shortID: "missing", realIP: "10.0.0.1", expectedStatus: http.StatusNotFound},
+		{name: "untrusted subnet", shortID: "abc123", realIP: "8.8.8.8", expectedStatus: http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/api/internal/urls/"+tt.shortID+"/owner", nil)
+			req.Header.Set("X-Real-IP", tt.realIP)
+			rr := httptest.NewRecorder()
+			controller.ServeHTTP(rr, req)
+
+			assert.Equal(t, tt.expectedStatus, rr.Code)
+			if tt.expectedBody != "" {
+				assert.JSONEq(t, tt.expectedBody, rr.Body.String())
+			}
+		})
+	}
+}
+
+func TestHTTPController_handleDBPoolStats(t *testing.T) {
+	_, subnet, err := net.ParseCIDR("10.0.0.0/8")
+	require.NoError(t, err)
+
+	auth, err := middleware.NewAuthMiddleware("test-key")
+	require.NoError(t, err)
+
+	tests := []struct {
+		name           string
+		statsErr       error
+		realIP         string
+		expectedStatus int
+	}{
+		{name: "postgres", realIP: "10.0.0.1", expectedStatus: http.StatusOK},
+		{name: "no pool", statsErr: usecase.ErrPoolStatsUnavailable, realIP: "10.0.0.1", expectedStatus: http.StatusNotFound},
+		{name: "untrusted subnet", realIP: "8.8.8.8", expectedStatus: http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockService := &MockURLService{
+				DBPoolStatsFunc: func() (usecase.PoolStats, error) {
+					return usecase.PoolStats{TotalConns: 4, IdleConns: 3, AcquiredConns: 1, MaxConns: 10}, tt.statsErr
+				},
+			}
+			controller := NewHTTPController(mockService, auth, Options{TrustedSubnet: subnet})
+
+			req := httptest.NewRequest(http.MethodGet, "/api/internal/db/stats", nil)
+			req.Header.Set("X-Real-IP", tt.realIP)
+			rr := httptest.NewRecorder()
+			controller.ServeHTTP(rr, req)
+
+			assert.Equal(t, tt.expectedStatus, rr.Code)
+			if tt.expectedStatus == http.StatusOK {
+				var stats usecase.PoolStats
+				require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &stats))
+				assert.Equal(t, int32(4), stats.TotalConns)
+				assert.Equal(t, int32(1), stats.AcquiredConns)
+			}
+		})
+	}
+}
+
+func TestHTTPController_handleRuntimeStats(t *testing.T) {
+	_, subnet, err := net.ParseCIDR("10.0.0.0/8")
+	require.NoError(t, err)
+
+	auth, err := middleware.NewAuthMiddleware("test-key")
+	require.NoError(t, err)
+
+	tests := []struct {
+		name           string
+		realIP         string
+		expectedStatus int
+	}{
+		{name: "trusted subnet", realIP: "10.0.0.1", expectedStatus: http.StatusOK},
+		{name: "untrusted subnet", realIP: "8.8.8.8", expectedStatus: http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockService := &MockURLService{
+				RuntimeStatsFunc: func() usecase.RuntimeStats {
+					return usecase.RuntimeStats{Goroutines: 12, AllocBytes: 1024, GCCount: 3, DeleteWorkers: 3}
+				},
+			}
+			controller := NewHTTPController(mockService, auth, Options{TrustedSubnet: subnet})
+
+			req := httptest.NewRequest(http.MethodGet, "/api/internal/runtime", nil)
+			req.Header.Set("X-Real-IP", tt.realIP)
+			rr := httptest.NewRecorder()
+			controller.ServeHTTP(rr, req)
+
+			assert.Equal(t, tt.expectedStatus, rr.Code)
+			if tt.expectedStatus == http.StatusOK {
+				var stats usecase.RuntimeStats
+				require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &stats))
+				assert.Equal(t, 12, stats.Goroutines)
+				assert.Equal(t, int32(3), stats.DeleteWorkers)
+			}
+		})
+	}
+}
+
+func TestHTTPController_handleStats(t *testing.T) {
+	_, subnet, err := net.ParseCIDR("10.0.0.0/8")
+	require.NoError(t, err)
+
+	auth, err := middleware.NewAuthMiddleware("test-key")
+	require.NoError(t, err)
+
+	tests := []struct {
+		name           string
+		realIP         string
+		storageErr     error
+		expectedStatus int
+		expectedBody   string
+	}{
+		{name: "trusted subnet", realIP: "10.0.0.1", expectedStatus: http.StatusOK, expectedBody: `{"urls":15,"users":4}`},
+		{name: "untrusted subnet", realIP: "8.8.8.8", expectedStatus: http.StatusForbidden},
+		{name: "storage error", realIP: "10.0.0.1", storageErr: errors.New("db down"), expectedStatus: http.StatusInternalServerError, expectedBody: `{"error":"Failed to get stats"}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockService := &MockURLService{
+				GetStatsFunc: func(ctx context.Context) (usecase.Stats, error) {
+					if tt.storageErr != nil {
+						return usecase.Stats{}, tt.storageErr
+					}
+					return usecase.Stats{URLs: 15, Users: 4}, nil
+				},
+			}
+			controller := NewHTTPController(mockService, auth, Options{TrustedSubnet: subnet})
+
+			req := httptest.NewRequest(http.MethodGet, "/api/internal/stats", nil)
+			req.Header.Set("X-Real-IP", tt.realIP)
+			rr := httptest.NewRecorder()
+			controller.ServeHTTP(rr, req)
+
+			assert.Equal(t, tt.expectedStatus, rr.Code)
+			if tt.expectedBody != "" {
+				assert.JSONEq(t, tt.expectedBody, rr.Body.String())
+			}
+		})
+	}
+}
+
+func TestHTTPController_handleRecentPublicURLs(t *testing.T) {
+	auth, err := middleware.NewAuthMiddleware("test-key")
+	require.NoError(t, err)
+
+	tests := []struct {
+		name           string
+		query          string
+		expectedStatus int
+		expectedLimit  int
+	}{
+		{name: "default limit", query: "", expectedStatus: http.StatusOK, expectedLimit: 20},
+		{name: "custom limit", query: "?limit=5", expectedStatus: http.StatusOK, expectedLimit: 5},
+		{name: "limit too large", query: "?limit=101", expectedStatus: http.StatusBadRequest},
+		{name: "invalid limit", query: "?limit=abc", expectedStatus: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var gotLimit int
+			mockService := &MockURLService{
+				RecentPublicURLsFunc: func(ctx context.Context, limit int) ([]usecase.PublicURL, error) {
+					gotLimit = limit
+					return []usecase.PublicURL{
+						{ShortURL: "http://localhost:8080/new123", OriginalURL: "https://new.example.com"},
+					}, nil
+				},
+			}
+			controller := NewHTTPController(mockService, auth, Options{})
+
+			req := httptest.NewRequest(http.MethodGet, "/api/public/recent"+tt.query, nil)
+			rr := httptest.NewRecorder()
+			controller.ServeHTTP(rr, req)
+
+			assert.Equal(t, tt.expectedStatus, rr.Code)
+			if tt.expectedStatus == http.StatusOK {
+				assert.Equal(t, tt.expectedLimit, gotLimit)
+
+				var urls []usecase.PublicURL
+				require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &urls))
+				require.Len(t, urls, 1)
+				assert.Equal(t, "http://localhost:8080/new123", urls[0].ShortURL)
+			}
+		})
+	}
+}
+
+func TestHTTPController_handleCapabilities(t *testing.T) {
+	auth, err := middleware.NewAuthMiddleware("test-key")
+	require.NoError(t, err)
+	controller := NewHTTPController(&MockURLService{}, auth, Options{
+		SyncDelete: true,
+		Capabilities: Capabilities{
+			Features: CapabilityFeatures{Idempotency: true},
+			Limits:   CapabilityLimits{IDLength: 8, VisitCap: 1000},
+		},
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/api/capabilities", nil)
+	rr := httptest.NewRecorder()
+	controller.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "application/json", rr.Header().Get("Content-Type"))
+	assert.JSONEq(t, `{
+		"features": {
+			"custom_aliases": false,
+			"ttl": true,
+			"batch": true,
+			"qr": false,
+			"idempotency": true,
+			"sync_delete": true,
+			"batch_link_headers": false
+		},
+		"limits": {
+			"max_batch_size": 0,
+			"max_url_length": 0,
+			"id_length": 8,
+			"redirect_statuses": [307],
+			"visit_cap": 1000
+		}
+	}`, rr.Body.String())
+}
+
+func TestHTTPController_handleShortenJSON_Options(t *testing.T) {
+	var got usecase.ShortenOptions
+	mockService := &MockURLService{
+		ShortenWithUserFunc: func(ctx context.Context, url, userID string, opts usecase.ShortenOptions) (string, error) {
+			got = opts
+			return "http://localhost:8080/abc123", nil
+		},
+	}
+	auth, err := middleware.NewAuthMiddleware("test-key")
+	require.NoError(t, err)
+	controller := NewHTTPController(mockService, auth, Options{})
+
+	req := httptest.NewRequest(http.MethodPost, "/api/shorten", strings.NewReader(`{"url":"https://example.com","expires_at":"2099-01-01T00:00:00Z","public":true}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(idempotencyHeader, "key-1")
+	rr := httptest.NewRecorder()
+	controller.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusCreated, rr.Code)
+	assert.Equal(t, usecase.ShortenOptions{
+		ExpiresAt:      time.Date(2099, 1, 1, 0, 0, 0, 0, time.UTC),
+		Public:         true,
+		IdempotencyKey: "key-1",
+	}, got)
+}
+
+func TestHTTPController_requireJSON(t *testing.T) {
+	mockService := &MockURLService{
+		ShortenWithUserFunc: func(ctx context.Context, url, userID string, opts usecase.ShortenOptions) (string, error) {
+			return "http://localhost:8080/abc123", nil
+		},
+	}
+	auth, err := middleware.NewAuthMiddleware("test-key")
+	require.NoError(t, err)
+	controller := NewHTTPController(mockService, auth, Options{})
+
+	gzipBody := func(s string) *bytes.Buffer {
+		var buf bytes.Buffer
+		zw := gzip.NewWriter(&buf)
+		zw.Write([]byte(s))
+		zw.Close()
+		return &buf
+	}
+
+	tests := []struct {
+		name            string
+		contentType     string
+		contentEncoding string
+		expectedStatus  int
+	}{
+		{name: "json", contentType: "application/json", expectedStatus: http.StatusCreated},
+		{name: "json with charset", contentType: "application/json; charset=utf-8", expectedStatus: http.StatusCreated},
+		{name: "text plain", contentType: "text/plain", expectedStatus: http.StatusUnsupportedMediaType},
+		{name: "missing", expectedStatus: http.StatusUnsupportedMediaType},
+		{name: "gzip without type", contentEncoding: "gzip", expectedStatus: http.StatusCreated},
+		{name: "gzip as x-gzip", contentType: "application/x-gzip", contentEncoding: "gzip", expectedStatus: http.StatusCreated},
+		{name: "gzip with text plain", contentType: "text/plain", contentEncoding: "gzip", expectedStatus: http.StatusUnsupportedMediaType},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body := bytes.NewBufferString(`{"url":"https://example.com"}`)
+			if tt.contentEncoding == "gzip" {
+				body = gzipBody(`{"url":"https://example.com"}`)
+			}
+			req := httptest.NewRequest(http.MethodPost, "/api/shorten", body)
+			if tt.contentType != "" {
+				req.Header.Set("Content-Type", tt.contentType)
+			}
+			if tt.contentEncoding != "" {
+				req.Header.Set("Content-Encoding", tt.contentEncoding)
+			}
+			rr := httptest.NewRecorder()
+			controller.ServeHTTP(rr, req)
+
+			assert.Equal(t, tt.expectedStatus, rr.Code)
+			if tt.expectedStatus == http.StatusUnsupportedMediaType {
+				assert.JSONEq(t, `{"error":"Content-Type must be application/json"}`, rr.Body.String())
+			}
+		})
+	}
+}
+
+func TestHTTPController_requireJSON_Batch(t *testing.T) {
+	mockService := &MockURLService{
+		ShortenBatchWithUserFunc: func(ctx context.Context, requests []usecase.BatchShortenRequest, userID string) ([]usecase.BatchShortenResponse, error) {
+			return []usecase.BatchShortenResponse{{CorrelationID: "1", ShortURL: "http://localhost:8080/abc123"}}, nil
+		},
+	}
+	auth, err := middleware.NewAuthMiddleware("test-key")
+	require.NoError(t, err)
+	controller := NewHTTPController(mockService, auth, Options{})
+
+	tests := []struct {
+		name           string
+		contentType    string
+		expectedStatus int
+	}{
+		{name: "корректный заголовок", contentType: "application/json", expectedStatus: http.StatusCreated},
+		{name: "заголовок с charset", contentType: "application/json; charset=utf-8", expectedStatus: http.StatusCreated},
+		{name: "неверный заголовок", contentType: "application/x-www-form-urlencoded", expectedStatus: http.StatusUnsupportedMediaType},
+		{name: "без заголовка", expectedStatus: http.StatusUnsupportedMediaType},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body := bytes.NewBufferString(`[{"correlation_id":"1","original_url":"https://example.com"}]`)
+			req := httptest.NewRequest(http.MethodPost, "/api/shorten/batch", body)
+			if tt.contentType != "" {
+				req.Header.Set("Content-Type", tt.contentType)
+			}
+			rr := httptest.NewRecorder()
+			controller.ServeHTTP(rr, req)
+
+			assert.Equal(t, tt.expectedStatus, rr.Code)
+			if tt.expectedStatus == http.StatusUnsupportedMediaType {
+				assert.JSONEq(t, `{"error":"Content-Type must be application/json"}`, rr.Body.String())
+			}
+		})
+	}
+}
+
+func TestHTTPController_MaxBodyBytes(t *testing.T) {
+	mockService := &MockURLService{
+		ShortenWithUserFunc: func(ctx context.Context, url, userID string, opts usecase.ShortenOptions) (string, error) {
+			return "http://localhost:8080/abc123", nil
+		},
+		ShortenBatchWithUserFunc: func(ctx context.Context, requests []usecase.BatchShortenRequest, userID string) ([]usecase.BatchShortenResponse, error) {
+			return []usecase.BatchShortenResponse{{CorrelationID: "1", ShortURL: "http://localhost:8080/abc123"}}, nil
+		},
+	}
+	auth, err := middleware.NewAuthMiddleware("test-key")
+	require.NoError(t, err)
+	controller := NewHTTPController(mockService, auth, Options{MaxBodyBytes: 64})
+
+	longURL := "https://example.com/" + strings.Repeat("a", 64)
+
+	tests := []struct {
+		name           string
+		target         string
+		body           string
+		contentType    string
+		gzip           bool
+		expectedStatus int
+		expectedJSON   bool
+	}{
+		{
+			name:           "текстовый запрос в пределах лимита",
+			target:         "/",
+			body:           "https://example.com",
+			expectedStatus: http.StatusCreated,
+		},
+		{
+			name:           "текстовый запрос больше лимита",
+			target:         "/",
+			body:           longURL,
+			expectedStatus: http.StatusRequestEntityTooLarge,
+		},
+		{
+			name:           "JSON запрос больше лимита",
+			target:         "/api/shorten",
+			body:           `{"url":"` + longURL + `"}`,
+			contentType:    "application/json",
+			expectedStatus: http.StatusRequestEntityTooLarge,
+			expectedJSON:   true,
+		},
+		{
+			name:           "batch больше лимита",
+			target:         "/api/shorten/batch",
+			body:           `[{"correlation_id":"1","original_url":"` + longURL + `"}]`,
+			contentType:    "application/json",
+			expectedStatus: http.StatusRequestEntityTooLarge,
+			expectedJSON:   true,
+		},
+		{
+			name:           "потоковый batch больше лимита",
+			target:         "/api/shorten/batch?stream=true",
+			body:           `[{"correlation_id":"1","original_url":"` + longURL + `"}]`,
+			contentType:    "application/json",
+			expectedStatus: http.StatusRequestEntityTooLarge,
+			expectedJSON:   true,
+		},
+		{
+			name:           "сжатое тело больше лимита после распаковки",
+			target:         "/",
+			body:           "https://example.com/" + strings.Repeat("a", 1000),
+			gzip:           true,
+			expectedStatus: http.StatusRequestEntityTooLarge,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body := bytes.NewBufferString(tt.body)
+			if tt.gzip {
+				body = &bytes.Buffer{}
+				zw := gzip.NewWriter(body)
+				zw.Write([]byte(tt.body))
+				zw.Close()
+				require.Less(t, body.Len(), 64)
+			}
+			req := httptest.NewRequest(http.MethodPost, tt.target, body)
+			if tt.contentType != "" {
+				req.Header.Set("Content-Type", tt.contentType)
+			}
+			if tt.gzip {
+				req.Header.Set("Content-Encoding", "gzip")
+			}
+			rr := httptest.NewRecorder()
+			controller.ServeHTTP(rr, req)
+
+			assert.Equal(t, tt.expectedStatus, rr.Code)
+			if tt.expectedJSON {
+				assert.JSONEq(t, `{"error":"Request body too large"}`, rr.Body.String())
+			}
+		})
+	}
+}
+
+func TestHTTPController_handleAPINotFound(t *testing.T) {
+	auth, err := middleware.NewAuthMiddleware("test-key")
+	require.NoError(t, err)
+	controller := NewHTTPController(&MockURLService{}, auth, Options{})
+
+	req := httptest.NewRequest(http.MethodGet, "/api/unknown", nil)
+	rr := httptest.NewRecorder()
+	controller.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+	assert.Equal(t, "application/json", rr.Header().Get("Content-Type"))
+
+	var response APINotFoundResponse
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &response))
+	assert.Equal(t, "/api/unknown", response.Path)
+	assert.Contains(t, response.Endpoints, APIEndpoint{Method: http.MethodPost, Path: "/api/shorten"})
+	assert.Contains(t, response.Endpoints, APIEndpoint{Method: http.MethodDelete, Path: "/api/user/urls"})
+}
+
+func TestHTTPController_writeJSONError(t *testing.T) {
+	tests := []struct {
+		name         string
+		format       string
+		expectedType string
+		expectedBody string
+	}{
+		{
+			name:         "simple format by default",
+			format:       "",
+			expectedType: "application/json",
+			expectedBody: `{"error":"Invalid JSON"}`,
+		},
+		{
+			name:         "problem format",
+			format:       ErrorFormatProblem,
+			expectedType: "application/problem+json",
+			expectedBody: `{"type":"about:blank","title":"Bad Request","status":400,"detail":"Invalid JSON","instance":"/api/shorten"}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			auth, err := middleware.NewAuthMiddleware("test-key")
+			require.NoError(t, err)
+			controller := NewHTTPController(&MockURLService{}, auth, Options{ErrorFormat: tt.format})
+
+			req := httptest.NewRequest(http.MethodPost, "/api/shorten", bytes.NewBufferString("{invalid"))
+			rr := httptest.NewRecorder()
+			controller.handleShortenJSON(rr, req)
+
+			assert.Equal(t, http.StatusBadRequest, rr.Code)
+			assert.Equal(t, tt.expectedType, rr.Header().Get("Content-Type"))
+			assert.JSONEq(t, tt.expectedBody, rr.Body.String())
+		})
+	}
 }
 
 func TestHTTPController_handleGetUserURLs(t *testing.T) {
 	tests := []struct {
 		name           string
 		mockService    *MockURLService
+		noCookie       bool
 		expectedStatus int
 		expectedURLs   []usecase.UserURL
 	}{
 		{
 			name: "успешное получение URL пользователя",
 			mockService: &MockURLService{
-				GetUserURLsFunc: func(ctx context.Context, userID string) ([]usecase.UserURL, error) {
+				GetUserURLsFunc: func(ctx context.Context, userID string, limit, offset int) ([]usecase.UserURL, int, error) {
 					return []usecase.UserURL{
 						{
 							ShortURL:    "http://localhost:8080/abc123",
@@ -459,7 +1772,7 @@ func TestHTTPController_handleGetUserURLs(t *testing.T) {
 							ShortURL:    "http://localhost:8080/def456",
 							OriginalURL: "https://google.com",
 						},
-					}, nil
+					}, 2, nil
 				},
 			},
 			expectedStatus: http.StatusOK,
@@ -477,8 +1790,8 @@ func TestHTTPController_handleGetUserURLs(t *testing.T) {
 		{
 			name: "нет URL у пользователя",
 			mockService: &MockURLService{
-				GetUserURLsFunc: func(ctx context.Context, userID string) ([]usecase.UserURL, error) {
-					return nil, nil
+				GetUserURLsFunc: func(ctx context.Context, userID string, limit, offset int) ([]usecase.UserURL, int, error) {
+					return nil, 0, nil
 				},
 			},
 			expectedStatus: http.StatusNoContent,
@@ -487,38 +1800,48 @@ func TestHTTPController_handleGetUserURLs(t *testing.T) {
 		{
 			name: "ошибка получения URL",
 			mockService: &MockURLService{
-				GetUserURLsFunc: func(ctx context.Context, userID string) ([]usecase.UserURL, error) {
-					return nil, errors.New("storage error")
+				GetUserURLsFunc: func(ctx context.Context, userID string, limit, offset int) ([]usecase.UserURL, int, error) {
+					return nil, 0, errors.New("storage error")
 				},
 			},
 			expectedStatus: http.StatusInternalServerError,
 			expectedURLs:   nil,
 		},
+		{
+			// Без куки middleware выдает новый ID, у которого заведомо нет ссылок
+			name: "без куки",
+			mockService: &MockURLService{
+				GetUserURLsFunc: func(ctx context.Context, userID string, limit, offset int) ([]usecase.UserURL, int, error) {
+					t.Error("GetUserURLs must not be called for a new user")
+					return nil, 0, nil
+				},
+			},
+			noCookie:       true,
+			expectedStatus: http.StatusUnauthorized,
+		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			auth, err := middleware.NewAuthMiddleware("test-key")
 			require.NoError(t, err)
-			controller := NewHTTPController(tt.mockService, auth)
+			controller := NewHTTPController(tt.mockService, auth, Options{})
 
 			req := httptest.NewRequest(http.MethodGet, "/api/user/urls", nil)
 
-			// Добавляем валидную куку пользователя
-			testUserID := "test-user-123"
-			err = auth.SetUserID(httptest.NewRecorder(), testUserID)
-			require.NoError(t, err)
-
-			// Получаем зашифрованную куку
-			tempW := httptest.NewRecorder()
-			err = auth.SetUserID(tempW, testUserID)
-			require.NoError(t, err)
+			if !tt.noCookie {
+				// Получаем зашифрованную куку существующего пользователя
+				testUserID := "5f0c5d3c-8d3e-4a8e-9a51-8b7f3f0b6f11"
+				tempW := httptest.NewRecorder()
+				err = auth.SetUserID(tempW, testUserID)
+				require.NoError(t, err)
 
-			result := tempW.Result()
-			cookies := result.Cookies()
-			defer result.Body.Close()
-			require.Len(t, cookies, 1)
-			req.AddCookie(cookies[0])
+				result := tempW.Result()
+				cookies := result.Cookies()
+				defer result.Body.Close()
+				require.Len(t, cookies, 1)
+				req.AddCookie(cookies[0])
+			}
 
 			w := httptest.NewRecorder()
 
@@ -526,6 +1849,10 @@ func TestHTTPController_handleGetUserURLs(t *testing.T) {
 			controller.ServeHTTP(w, req)
 
 			assert.Equal(t, tt.expectedStatus, w.Code)
+			if tt.noCookie {
+				// Клиент получает куку и сможет авторизоваться следующим запросом
+				assert.NotEmpty(t, w.Result().Cookies())
+			}
 
 			if tt.expectedStatus == http.StatusOK {
 				var urls []usecase.UserURL
@@ -540,7 +1867,7 @@ func TestHTTPController_handleGetUserURLs(t *testing.T) {
 func BenchmarkHandleShorten(b *testing.B) {
 	// Создаем мок сервиса
 	mockService := &MockURLService{
-		ShortenWithUserFunc: func(ctx context.Context, url, userID string) (string, error) {
+		ShortenWithUserFunc: func(ctx context.Context, url, userID string, opts usecase.ShortenOptions) (string, error) {
 			return "http://localhost:8080/abc123", nil
 		},
 	}
@@ -550,7 +1877,7 @@ func BenchmarkHandleShorten(b *testing.B) {
 	if err != nil {
 		b.Fatal(err)
 	}
-	controller := NewHTTPController(mockService, auth)
+	controller := NewHTTPController(mockService, auth, Options{})
 
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
@@ -564,7 +1891,7 @@ func BenchmarkHandleShorten(b *testing.B) {
 func BenchmarkHandleShortenJSON(b *testing.B) {
 	// Создаем мок сервиса
 	mockService := &MockURLService{
-		ShortenWithUserFunc: func(ctx context.Context, url, userID string) (string, error) {
+		ShortenWithUserFunc: func(ctx context.Context, url, userID string, opts usecase.ShortenOptions) (string, error) {
 			return "http://localhost:8080/abc123", nil
 		},
 	}
@@ -574,7 +1901,7 @@ func BenchmarkHandleShortenJSON(b *testing.B) {
 	if err != nil {
 		b.Fatal(err)
 	}
-	controller := NewHTTPController(mockService, auth)
+	controller := NewHTTPController(mockService, auth, Options{})
 
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
@@ -589,7 +1916,7 @@ func BenchmarkHandleShortenJSON(b *testing.B) {
 func BenchmarkHandleRedirect(b *testing.B) {
 	// Создаем мок сервиса
 	mockService := &MockURLService{
-		ExpandFunc: func(shortID string) (string, error) {
+		ExpandFunc: func(ctx context.Context, shortID string) (string, error) {
 			return "http://example.com", nil
 		},
 	}
@@ -599,7 +1926,7 @@ func BenchmarkHandleRedirect(b *testing.B) {
 	if err != nil {
 		b.Fatal(err)
 	}
-	controller := NewHTTPController(mockService, auth)
+	controller := NewHTTPController(mockService, auth, Options{})
 
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
@@ -632,7 +1959,7 @@ func BenchmarkHandleShortenBatch(b *testing.B) {
 	if err != nil {
 		b.Fatal(err)
 	}
-	controller := NewHTTPController(mockService, auth)
+	controller := NewHTTPController(mockService, auth, Options{})
 
 	// Подготавливаем тестовые данные
 	requests := []usecase.BatchShortenRequest{
@@ -656,7 +1983,7 @@ func BenchmarkHandleShortenBatch(b *testing.B) {
 func BenchmarkHandleGetUserURLs(b *testing.B) {
 	// Создаем мок сервиса
 	mockService := &MockURLService{
-		GetUserURLsFunc: func(ctx context.Context, userID string) ([]usecase.UserURL, error) {
+		GetUserURLsFunc: func(ctx context.Context, userID string, limit, offset int) ([]usecase.UserURL, int, error) {
 			return []usecase.UserURL{
 				{
 					ShortURL:    "http://localhost:8080/abc123",
@@ -666,7 +1993,7 @@ func BenchmarkHandleGetUserURLs(b *testing.B) {
 					ShortURL:    "http://localhost:8080/def456",
 					OriginalURL: "http://another.com",
 				},
-			}, nil
+			}, 2, nil
 		},
 	}
 
@@ -675,7 +2002,7 @@ func BenchmarkHandleGetUserURLs(b *testing.B) {
 	if err != nil {
 		b.Fatal(err)
 	}
-	controller := NewHTTPController(mockService, auth)
+	controller := NewHTTPController(mockService, auth, Options{})
 
 	// Создаем тестовую куку
 	cookie := &http.Cookie{
@@ -691,3 +2018,268 @@ func BenchmarkHandleGetUserURLs(b *testing.B) {
 		controller.handleGetUserURLs(w, r)
 	}
 }
+
+func TestHTTPController_GzipRoutes(t *testing.T) {
+	mockService := &MockURLService{
+		ShortenWithUserFunc: func(ctx context.Context, url, userID string, opts usecase.ShortenOptions) (string, error) {
+			return "http://localhost:8080/abc123", nil
+		},
+	}
+	auth, err := middleware.NewAuthMiddleware("test-key")
+	require.NoError(t, err)
+
+	tests := []struct {
+		name           string
+		routes         []string
+		method         string
+		path           string
+		gzipRequest    bool
+		expectedStatus int
+		expectedGzip   bool
+	}{
+		{name: "API сжимается по умолчанию", method: http.MethodGet, path: "/api/capabilities", expectedStatus: http.StatusOK, expectedGzip: true},
+		{name: "сокращение JSON сжимается по умолчанию", method: http.MethodPost, path: "/api/shorten", expectedStatus: http.StatusCreated, expectedGzip: true},
+		{name: "API не сжимается без группы api", routes: []string{GzipRouteShorten, GzipRouteRedirect}, method: http.MethodGet, path: "/api/capabilities", expectedStatus: http.StatusOK},
+		{name: "сжатие отключено", routes: []string{}, method: http.MethodGet, path: "/api/capabilities", expectedStatus: http.StatusOK},
+		{name: "сжатый запрос без сжатия ответа", routes: []string{}, method: http.MethodPost, path: "/api/shorten", gzipRequest: true, expectedStatus: http.StatusCreated},
+		{name: "сжатый запрос на POST /", routes: []string{}, method: http.MethodPost, path: "/", gzipRequest: true, expectedStatus: http.StatusCreated},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			controller := NewHTTPController(mockService, auth, Options{
+				GzipRoutes:  tt.routes,
+				GzipMinSize: 1,
+			})
+
+			var body io.Reader
+			if tt.method == http.MethodPost {
+				payload := "https://example.com"
+				if tt.path == "/api/shorten" {
+					payload = `{"url":"https://example.com"}`
+				}
+				body = strings.NewReader(payload)
+				if tt.gzipRequest {
+					var buf bytes.Buffer
+					zw := gzip.NewWriter(&buf)
+					zw.Write([]byte(payload))
+					zw.Close()
+					body = &buf
+				}
+			}
+			req := httptest.NewRequest(tt.method, tt.path, body)
+			req.Header.Set("Accept-Encoding", "gzip")
+			if tt.path == "/api/shorten" {
+				req.Header.Set("Content-Type", "application/json")
+			}
+			if tt.gzipRequest {
+				req.Header.Set("Content-Encoding", "gzip")
+			}
+			rr := httptest.NewRecorder()
+			controller.ServeHTTP(rr, req)
+
+			assert.Equal(t, tt.expectedStatus, rr.Code)
+			if tt.expectedGzip {
+				assert.Equal(t, "gzip", rr.Header().Get("Content-Encoding"))
+			} else {
+				assert.Empty(t, rr.Header().Get("Content-Encoding"))
+			}
+		})
+	}
+}
+
+func TestParseGzipRoutes(t *testing.T) {
+	routes, err := ParseGzipRoutes(" shorten, api ,metrics")
+	require.NoError(t, err)
+	assert.Equal(t, []string{GzipRouteShorten, GzipRouteAPI, GzipRouteMetrics}, routes)
+
+	routes, err = ParseGzipRoutes("")
+	require.NoError(t, err)
+	assert.Empty(t, routes)
+	assert.NotNil(t, routes)
+
+	_, err = ParseGzipRoutes("shorten,static")
+	assert.Error(t, err)
+}
+
+func TestHTTPController_handleGetUserURLsPagination(t *testing.T) {
+	tests := []struct {
+		name           string
+		query          string
+		total          int
+		expectedLimit  int
+		expectedOffset int
+		expectedStatus int
+		expectedBody   string
+	}{
+		{
+			name:           "страница по умолчанию",
+			total:          1,
+			expectedLimit:  100,
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "свой limit и offset",
+			query:          "?limit=10&offset=20",
+			total:          21,
+			expectedLimit:  10,
+			expectedOffset: 20,
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "offset за концом списка",
+			query:          "?offset=500",
+			total:          3,
+			expectedLimit:  100,
+			expectedOffset: 500,
+			expectedStatus: http.StatusOK,
+			expectedBody:   "[]\n",
+		},
+		{
+			name:           "limit больше максимума",
+			query:          "?limit=1001",
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "нулевой limit",
+			query:          "?limit=0",
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "отрицательный offset",
+			query:          "?offset=-1",
+			expectedStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			called := false
+			mockService := &MockURLService{
+				GetUserURLsFunc: func(ctx context.Context, userID string, limit, offset int) ([]usecase.UserURL, int, error) {
+					called = true
+					assert.Equal(t, tt.expectedLimit, limit)
+					assert.Equal(t, tt.expectedOffset, offset)
+					if offset >= tt.total {
+						return nil, tt.total, nil
+					}
+					return []usecase.UserURL{{ShortURL: "http://localhost:8080/abc123", OriginalURL: "https://example.com"}}, tt.total, nil
+				},
+			}
+			auth, err := middleware.NewAuthMiddleware("test-key")
+			require.NoError(t, err)
+			controller := NewHTTPController(mockService, auth, Options{})
+
+			cookieW := httptest.NewRecorder()
+			require.NoError(t, auth.SetUserID(cookieW, "5f0c5d3c-8d3e-4a8e-9a51-8b7f3f0b6f11"))
+			req := httptest.NewRequest(http.MethodGet, "/api/user/urls"+tt.query, nil)
+			req.AddCookie(cookieW.Result().Cookies()[0])
+
+			w := httptest.NewRecorder()
+			controller.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.expectedStatus, w.Code)
+			if tt.expectedStatus != http.StatusOK {
+				assert.False(t, called)
+				return
+			}
+			assert.Equal(t, strconv.Itoa(tt.total), w.Header().Get("X-Total-Count"))
+			if tt.expectedBody != "" {
+				assert.Equal(t, tt.expectedBody, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestHTTPController_handleDeleteAllUserURLs(t *testing.T) {
+	auth, err := middleware.NewAuthMiddleware("test-key")
+	require.NoError(t, err)
+
+	tests := []struct {
+		name               string
+		noCookie           bool
+		serviceErr         error
+		expectedStatus     int
+		expectedRetryAfter string
+	}{
+		{name: "запрос принят", expectedStatus: http.StatusAccepted},
+		{name: "без куки", noCookie: true, expectedStatus: http.StatusUnauthorized},
+		{name: "очередь переполнена", serviceErr: usecase.ErrDeleteChannelFull, expectedStatus: http.StatusServiceUnavailable, expectedRetryAfter: "1"},
+		{name: "внутренняя ошибка", serviceErr: errors.New("storage error"), expectedStatus: http.StatusInternalServerError},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var deletedFor []string
+			mockService := &MockURLService{
+				DeleteAllUserURLsFunc: func(userID string) error {
+					deletedFor = append(deletedFor, userID)
+					return tt.serviceErr
+				},
+			}
+			controller := NewHTTPController(mockService, auth, Options{})
+
+			const userID = "5f0c5d3c-8d3e-4a8e-9a51-8b7f3f0b6f11"
+			req := httptest.NewRequest(http.MethodDelete, "/api/user/urls/all", nil)
+			if !tt.noCookie {
+				cookieW := httptest.NewRecorder()
+				require.NoError(t, auth.SetUserID(cookieW, userID))
+				req.AddCookie(cookieW.Result().Cookies()[0])
+			}
+			rr := httptest.NewRecorder()
+
+			controller.ServeHTTP(rr, req)
+
+			assert.Equal(t, tt.expectedStatus, rr.Code)
+			assert.Equal(t, tt.expectedRetryAfter, rr.Header().Get("Retry-After"))
+			if tt.noCookie {
+				assert.Empty(t, deletedFor)
+			} else {
+				assert.Equal(t, []string{userID}, deletedFor)
+			}
+		})
+	}
+}
+
+func TestHTTPController_JSONErrorsOnAPIEndpoints(t *testing.T) {
+	auth, err := middleware.NewAuthMiddleware("test-key")
+	require.NoError(t, err)
+
+	cookieW := httptest.NewRecorder()
+	require.NoError(t, auth.SetUserID(cookieW, "5f0c5d3c-8d3e-4a8e-9a51-8b7f3f0b6f11"))
+	cookie := cookieW.Result().Cookies()[0]
+
+	tests := []struct {
+		name          string
+		method        string
+		path          string
+		body          string
+		expectedError string
+	}{
+		{name: "shorten", method: http.MethodPost, path: "/api/shorten", body: "{invalid", expectedError: "Invalid JSON"},
+		{name: "batch", method: http.MethodPost, path: "/api/shorten/batch", body: "{invalid", expectedError: "Invalid JSON"},
+		{name: "удаление", method: http.MethodDelete, path: "/api/user/urls", body: "{invalid", expectedError: "Invalid JSON"},
+		{name: "список URL", method: http.MethodGet, path: "/api/user/urls?limit=abc", expectedError: "limit must be between 1 and 1000"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			controller := NewHTTPController(&MockURLService{}, auth, Options{})
+
+			req := httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body))
+			if tt.body != "" {
+				req.Header.Set("Content-Type", "application/json")
+			}
+			req.AddCookie(cookie)
+			rr := httptest.NewRecorder()
+			controller.ServeHTTP(rr, req)
+
+			assert.Equal(t, http.StatusBadRequest, rr.Code)
+			assert.Equal(t, "application/json", rr.Header().Get("Content-Type"))
+
+			var response ErrorResponse
+			require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &response))
+			assert.Equal(t, tt.expectedError, response.Error)
+		})
+	}
+}
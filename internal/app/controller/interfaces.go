@@ -9,12 +9,24 @@ import (
 
 // URLService определяет интерфейс для сервиса URL
 type URLService interface {
-	Shorten(url string) (string, error)
-	ShortenWithUser(ctx context.Context, url, userID string) (string, error)
-	Expand(shortID string) (string, error)
+	Shorten(ctx context.Context, url string) (string, error)
+	ShortenWithUser(ctx context.Context, url, userID string, opts usecase.ShortenOptions) (string, error)
+	Expand(ctx context.Context, shortID string) (string, error)
 	PingDB() error
 	ShortenBatch(ctx context.Context, requests []usecase.BatchShortenRequest) ([]usecase.BatchShortenResponse, error)
 	ShortenBatchWithUser(ctx context.Context, requests []usecase.BatchShortenRequest, userID string) ([]usecase.BatchShortenResponse, error)
-	GetUserURLs(ctx context.Context, userID string) ([]usecase.UserURL, error)
+	GetUserURLs(ctx context.Context, userID string, limit, offset int) (urls []usecase.UserURL, total int, err error)
+	GetUserURL(ctx context.Context, userID, shortID string) (usecase.UserURL, error)
+	RecentPublicURLs(ctx context.Context, limit int) ([]usecase.PublicURL, error)
 	DeleteUserURLs(userID string, shortIDs []string) error
+	DeleteAllUserURLs(userID string) error
+	DeleteUserURLsSync(ctx context.Context, userID string, shortIDs []string) (usecase.DeleteResult, error)
+	PurgeExpired(ctx context.Context) (int64, error)
+	CheckLiveness() error
+	Reset(ctx context.Context) (int64, error)
+	CheckReady(ctx context.Context) error
+	GetURLOwner(ctx context.Context, shortID string) (usecase.URLOwner, error)
+	DBPoolStats() (usecase.PoolStats, error)
+	RuntimeStats() usecase.RuntimeStats
+	GetStats(ctx context.Context) (usecase.Stats, error)
 }
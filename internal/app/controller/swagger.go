@@ -1,14 +1,20 @@
+//go:build !noswagger
+
 package controller
 
 import (
+	"strings"
+
 	"github.com/go-chi/chi/v5"
 	_ "github.com/m-molecula741/shortener/docs" // импорт сгенерированной документации
 	httpSwagger "github.com/swaggo/http-swagger"
 )
 
-// SetupSwagger настраивает маршруты для Swagger UI и документации
-func SetupSwagger(router *chi.Mux) {
-	router.Get("/swagger/*", httpSwagger.Handler(
-		httpSwagger.URL("http://localhost:8080/swagger/doc.json"),
+// setupSwagger регистрирует Swagger UI. Адрес спецификации строится из BaseURL,
+// чтобы документация открывалась на том же домене, что и сервис.
+func (c *HTTPController) setupSwagger(r chi.Router) {
+	docURL := strings.TrimSuffix(c.opts.BaseURL, "/") + "/swagger/doc.json"
+	r.Get("/swagger/*", httpSwagger.Handler(
+		httpSwagger.URL(docURL),
 	))
 }
@@ -0,0 +1,9 @@
+//go:build noswagger
+
+package controller
+
+import "github.com/go-chi/chi/v5"
+
+// setupSwagger ничего не делает: сборка с тегом noswagger не включает
+// сгенерированный пакет docs и Swagger UI
+func (c *HTTPController) setupSwagger(chi.Router) {}
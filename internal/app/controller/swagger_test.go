@@ -0,0 +1,45 @@
+//go:build !noswagger
+
+package controller
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/m-molecula741/shortener/internal/app/middleware"
+	"github.com/m-molecula741/shortener/internal/app/usecase"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHTTPController_Swagger(t *testing.T) {
+	auth, err := middleware.NewAuthMiddleware("test-key")
+	require.NoError(t, err)
+
+	tests := []struct {
+		name           string
+		enabled        bool
+		expectedStatus int
+	}{
+		{name: "enabled", enabled: true, expectedStatus: http.StatusOK},
+		{name: "disabled", enabled: false, expectedStatus: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			controller := NewHTTPController(&MockURLService{
+				ExpandFunc: func(ctx context.Context, shortID string) (string, error) {
+					return "", usecase.ErrURLNotFound
+				},
+			}, auth, Options{EnableSwagger: tt.enabled, BaseURL: "https://sho.rt/"})
+
+			req := httptest.NewRequest(http.MethodGet, "/swagger/doc.json", nil)
+			rr := httptest.NewRecorder()
+			controller.ServeHTTP(rr, req)
+
+			assert.Equal(t, tt.expectedStatus, rr.Code)
+		})
+	}
+}
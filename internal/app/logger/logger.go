@@ -2,19 +2,64 @@
 package logger
 
 import (
+	"fmt"
+	"io"
 	"os"
 	"time"
 
 	"github.com/rs/zerolog"
+	"gopkg.in/natefinch/lumberjack.v2"
 )
 
 // log глобальный логгер приложения
 var log zerolog.Logger
 
-// Init инициализирует глобальный логгер с настроенным форматом времени
-func Init() {
-	output := zerolog.ConsoleWriter{Out: os.Stdout, TimeFormat: time.RFC3339}
-	log = zerolog.New(output).With().Timestamp().Logger()
+// FileOptions задает параметры ротации файла логов
+type FileOptions struct {
+	MaxSizeMB  int // размер файла в мегабайтах, после которого он ротируется
+	MaxBackups int // сколько ротированных файлов хранить, 0 - все
+	MaxAgeDays int // сколько дней хранить ротированные файлы, 0 - не удалять по возрасту
+}
+
+// NewFileOutput создает файл логов path, который ротируется по размеру.
+// Возвращаемый writer нужно закрыть при завершении работы.
+func NewFileOutput(path string, opts FileOptions) io.WriteCloser {
+	return &lumberjack.Logger{
+		Filename:   path,
+		MaxSize:    opts.MaxSizeMB,
+		MaxBackups: opts.MaxBackups,
+		MaxAge:     opts.MaxAgeDays,
+	}
+}
+
+// Init инициализирует глобальный логгер с настроенным форматом времени.
+// Логи пишутся в output, по умолчанию в stdout; в файл они пишутся без цветовой разметки.
+// level - минимальный уровень записей (debug, info, warn, error), пусто - info.
+// jsonOutput включает запись JSON по строке на событие для сборщиков логов вместо
+// форматированного вывода для человека.
+func Init(output io.Writer, level string, jsonOutput bool) error {
+	if output == nil {
+		output = os.Stdout
+	}
+
+	minLevel := zerolog.InfoLevel
+	if level != "" {
+		parsed, err := zerolog.ParseLevel(level)
+		if err != nil || parsed == zerolog.NoLevel {
+			return fmt.Errorf("unknown log level %q", level)
+		}
+		minLevel = parsed
+	}
+
+	if !jsonOutput {
+		output = zerolog.ConsoleWriter{
+			Out:        output,
+			TimeFormat: time.RFC3339,
+			NoColor:    output != os.Stdout,
+		}
+	}
+	log = zerolog.New(output).Level(minLevel).With().Timestamp().Logger()
+	return nil
 }
 
 // Info возвращает Event для логирования информационных сообщений
@@ -22,6 +67,21 @@ func Info() *zerolog.Event {
 	return log.Info()
 }
 
+// Warn возвращает Event для логирования предупреждений
+func Warn() *zerolog.Event {
+	return log.Warn()
+}
+
+// Debug возвращает Event для логирования отладочных сообщений
+func Debug() *zerolog.Event {
+	return log.Debug()
+}
+
+// Error возвращает Event для логирования ошибок
+func Error() *zerolog.Event {
+	return log.Error()
+}
+
 // GetLogger возвращает указатель на глобальный логгер
 func GetLogger() *zerolog.Logger {
 	return &log
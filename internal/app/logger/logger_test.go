@@ -0,0 +1,66 @@
+package logger
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInit_Level(t *testing.T) {
+	tests := []struct {
+		name          string
+		level         string
+		expectedLines []string
+	}{
+		{name: "по умолчанию info", level: "", expectedLines: []string{"info", "warn", "error"}},
+		{name: "debug", level: "debug", expectedLines: []string{"debug", "info", "warn", "error"}},
+		{name: "warn", level: "warn", expectedLines: []string{"warn", "error"}},
+		{name: "error", level: "error", expectedLines: []string{"error"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			require.NoError(t, Init(&buf, tt.level, true))
+
+			Debug().Msg("debug")
+			Info().Msg("info")
+			Warn().Msg("warn")
+			Error().Msg("error")
+
+			var messages []string
+			for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+				var entry map[string]any
+				require.NoError(t, json.Unmarshal([]byte(line), &entry), line)
+				assert.Equal(t, entry["level"], entry["message"])
+				messages = append(messages, entry["message"].(string))
+			}
+			assert.Equal(t, tt.expectedLines, messages)
+		})
+	}
+}
+
+func TestInit_Console(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, Init(&buf, "info", false))
+
+	Debug().Msg("hidden")
+	Info().Str("short_id", "abc123").Msg("URL shortened")
+
+	// Вывод для человека не является JSON и не содержит цветовой разметки вне stdout
+	output := buf.String()
+	assert.NotContains(t, output, "hidden")
+	assert.Contains(t, output, "URL shortened")
+	assert.Contains(t, output, "short_id=abc123")
+	assert.False(t, json.Valid([]byte(strings.TrimSpace(output))))
+	assert.NotContains(t, output, "\x1b[")
+}
+
+func TestInit_UnknownLevel(t *testing.T) {
+	var buf bytes.Buffer
+	assert.Error(t, Init(&buf, "verbose", true))
+}
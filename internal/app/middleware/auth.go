@@ -5,9 +5,14 @@ import (
 	"crypto/aes"
 	"crypto/cipher"
 	"crypto/rand"
+	"crypto/sha256"
 	"encoding/hex"
 	"errors"
+	"fmt"
+	"net"
 	"net/http"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/google/uuid"
@@ -17,6 +22,20 @@ import (
 const (
 	CookieName       = "user_id"
 	CookieExpiration = 24 * time.Hour
+
+	// maxCookieValueLen ограничивает длину значения куки: UUID в зашифрованном виде
+	// занимает около 130 символов, все что значительно длиннее - заведомо подделка
+	maxCookieValueLen = 256
+
+	// ClientNonceHeader - заголовок со случайным значением, которое клиент (вкладка SPA)
+	// генерирует при загрузке и передает, пока не получит куку. Запросы без куки
+	// получают общий ID в FirstRequestWindow только при одинаковом значении заголовка.
+	ClientNonceHeader = "X-Client-Nonce"
+
+	// minClientNonceLen и maxClientNonceLen ограничивают длину nonce клиента:
+	// короткое значение легко угадать, а длинное раздувает ключи окна
+	minClientNonceLen = 16
+	maxClientNonceLen = 128
 )
 
 // Ошибки для аутентификации
@@ -24,37 +43,124 @@ var (
 	ErrInvalidCookie = errors.New("invalid cookie")
 )
 
+// ParseSameSite разбирает значение атрибута SameSite куки: lax, strict или none
+func ParseSameSite(value string) (http.SameSite, error) {
+	switch strings.ToLower(value) {
+	case "lax":
+		return http.SameSiteLaxMode, nil
+	case "strict":
+		return http.SameSiteStrictMode, nil
+	case "none":
+		return http.SameSiteNoneMode, nil
+	}
+	return 0, fmt.Errorf("invalid SameSite %q: must be lax, strict or none", value)
+}
+
+// AuthOptions содержит настройки куки аутентификации.
+// Нулевое значение соответствует безопасным настройкам по умолчанию.
+type AuthOptions struct {
+	// DisableHTTPOnly разрешает JavaScript читать куку с ID пользователя.
+	// Нужен только SPA, которым ID пользователя требуется на клиенте.
+	DisableHTTPOnly bool
+
+	// Secure запрещает браузеру отправлять куку по HTTP. Включается, когда клиенты
+	// обращаются к сервису по HTTPS, в том числе через TLS на прокси.
+	Secure bool
+
+	// SameSite - атрибут SameSite куки, по умолчанию Lax.
+	// SameSite=None браузеры принимают только вместе с Secure.
+	SameSite http.SameSite
+
+	// Domain - домен куки; пусто - кука действует только для хоста запроса
+	Domain string
+
+	// PreviousKeys - прежние ключи шифрования куки. Ими куки только расшифровываются,
+	// поэтому при смене ключа выданные ранее сессии остаются действительными.
+	PreviousKeys []string
+
+	// FirstRequestWindow - время, в течение которого запросы без куки с одинаковым
+	// ClientNonceHeader, с одного адреса и с одинаковыми заголовками клиента получают
+	// один и тот же ID пользователя. SPA часто отправляют несколько запросов до получения
+	// первой куки, и без окна их ссылки расходятся по разным пользователям.
+	// Запросы без nonce всегда получают новый ID: по одному адресу и заголовкам клиентов
+	// за общим NAT или прокси не различить, и они получили бы общий ID и доступ к ссылкам
+	// друг друга. 0 - каждый запрос без куки получает новый ID.
+	FirstRequestWindow time.Duration
+}
+
+// pendingIdentity - ID, выданный клиенту без куки, который еще может быть переиспользован
+type pendingIdentity struct {
+	userID    string
+	expiresAt time.Time
+}
+
+// authKey - ключ шифрования куки и его ID, которым помечаются зашифрованные им куки
+type authKey struct {
+	id  string
+	gcm cipher.AEAD
+}
+
 // AuthMiddleware middleware для аутентификации пользователей
 type AuthMiddleware struct {
-	gcm cipher.AEAD
+	keys []authKey // первый ключ шифрует, все расшифровывают
+	opts AuthOptions
+
+	mu      sync.Mutex
+	pending map[string]pendingIdentity // отпечаток клиента -> недавно выданный ID
+}
+
+// NewAuthMiddleware создает новый middleware для аутентификации с настройками куки по умолчанию.
+// Первый ключ шифрует куки, остальные - прежние ключи, которыми куки только расшифровываются.
+func NewAuthMiddleware(keys ...string) (*AuthMiddleware, error) {
+	if len(keys) == 0 {
+		return nil, errors.New("auth middleware requires at least one secret key")
+	}
+	return NewAuthMiddlewareWithOptions(keys[0], AuthOptions{PreviousKeys: keys[1:]})
+}
+
+// NewAuthMiddlewareWithOptions создает новый middleware для аутентификации с настройками куки
+func NewAuthMiddlewareWithOptions(secretKey string, opts AuthOptions) (*AuthMiddleware, error) {
+	keys := make([]authKey, 0, 1+len(opts.PreviousKeys))
+	for _, secret := range append([]string{secretKey}, opts.PreviousKeys...) {
+		key, err := newAuthKey(secret)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+
+	return &AuthMiddleware{keys: keys, opts: opts, pending: make(map[string]pendingIdentity)}, nil
 }
 
-// NewAuthMiddleware создает новый middleware для аутентификации
-func NewAuthMiddleware(secretKey string) (*AuthMiddleware, error) {
+// newAuthKey создает шифр AES-GCM из строки ключа.
+// ID ключа - начало его SHA-256: он не зависит от порядка ключей и не раскрывает ключ.
+func newAuthKey(secretKey string) (authKey, error) {
 	// Создаем ключ из строки (должен быть 32 байта для AES-256)
 	key := make([]byte, 32)
 	copy(key, []byte(secretKey))
 
 	block, err := aes.NewCipher(key)
 	if err != nil {
-		return nil, err
+		return authKey{}, err
 	}
 
 	gcm, err := cipher.NewGCM(block)
 	if err != nil {
-		return nil, err
+		return authKey{}, err
 	}
 
-	return &AuthMiddleware{gcm: gcm}, nil
+	sum := sha256.Sum256([]byte(secretKey))
+	return authKey{id: hex.EncodeToString(sum[:4]), gcm: gcm}, nil
 }
 
 // Middleware обрабатывает аутентификацию пользователей
 func (a *AuthMiddleware) Middleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		userID, err := a.GetUserID(r)
-		if err != nil {
+		isNew := err != nil
+		if isNew {
 			// Если куки нет или она невалидна, создаем новую
-			userID = uuid.New().String()
+			userID = a.newUserID(r)
 			if err := a.SetUserID(w, userID); err != nil {
 				http.Error(w, "Failed to set user cookie", http.StatusInternalServerError)
 				return
@@ -64,12 +170,53 @@ func (a *AuthMiddleware) Middleware(next http.Handler) http.Handler {
 		// Добавляем userID в контекст запроса
 		ctx := r.Context()
 		ctx = SetUserIDToContext(ctx, userID)
+		ctx = SetUserIsNewToContext(ctx, isNew)
 		r = r.WithContext(ctx)
 
 		next.ServeHTTP(w, r)
 	})
 }
 
+// newUserID выдает ID запросу без валидной куки. В пределах FirstRequestWindow
+// одновременные первые запросы одной вкладки клиента с одинаковым nonce получают одинаковый ID.
+func (a *AuthMiddleware) newUserID(r *http.Request) string {
+	nonce := r.Header.Get(ClientNonceHeader)
+	if a.opts.FirstRequestWindow <= 0 || len(nonce) < minClientNonceLen || len(nonce) > maxClientNonceLen {
+		return uuid.New().String()
+	}
+
+	// Отпечаток не дает чужому клиенту, узнавшему nonce, получить тот же ID
+	key := nonce + "|" + clientFingerprint(r)
+	now := time.Now()
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if identity, ok := a.pending[key]; ok && now.Before(identity.expiresAt) {
+		return identity.userID
+	}
+
+	// Записи живут не дольше окна, поэтому устаревшие удаляем при выдаче нового ID
+	for k, identity := range a.pending {
+		if !now.Before(identity.expiresAt) {
+			delete(a.pending, k)
+		}
+	}
+
+	userID := uuid.New().String()
+	a.pending[key] = pendingIdentity{userID: userID, expiresAt: now.Add(a.opts.FirstRequestWindow)}
+	return userID
+}
+
+// clientFingerprint строит отпечаток клиента по адресу и заголовкам браузера
+func clientFingerprint(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	return host + "|" + r.UserAgent() + "|" + r.Header.Get("Accept-Language")
+}
+
 // GetUserID извлекает ID пользователя из куки
 func (a *AuthMiddleware) GetUserID(r *http.Request) (string, error) {
 	cookie, err := r.Cookie("user_id")
@@ -77,12 +224,22 @@ func (a *AuthMiddleware) GetUserID(r *http.Request) (string, error) {
 		return "", err
 	}
 
+	if len(cookie.Value) > maxCookieValueLen {
+		return "", ErrInvalidCookie
+	}
+
 	// Расшифровываем куку
 	userID, err := a.decrypt(cookie.Value)
 	if err != nil {
 		return "", err
 	}
 
+	// Идентификатор пользователя должен быть корректным UUID, иначе он попадет
+	// в SQL параметры и логи в непредсказуемом виде
+	if _, err := uuid.Parse(userID); err != nil {
+		return "", ErrInvalidCookie
+	}
+
 	return userID, nil
 }
 
@@ -93,42 +250,70 @@ func (a *AuthMiddleware) SetUserID(w http.ResponseWriter, userID string) error {
 		return err
 	}
 
+	sameSite := a.opts.SameSite
+	if sameSite == 0 || sameSite == http.SameSiteDefaultMode {
+		sameSite = http.SameSiteLaxMode
+	}
+
 	cookie := &http.Cookie{
 		Name:     "user_id",
 		Value:    encryptedValue,
 		Path:     "/",
-		HttpOnly: true,
+		Domain:   a.opts.Domain,
+		HttpOnly: !a.opts.DisableHTTPOnly,
+		Secure:   a.opts.Secure,
+		SameSite: sameSite,
 	}
 
 	http.SetCookie(w, cookie)
 	return nil
 }
 
-// encrypt шифрует строку
+// encrypt шифрует строку текущим ключом. Результат - "<ID ключа>.<шифротекст в hex>".
 func (a *AuthMiddleware) encrypt(plaintext string) (string, error) {
-	nonce := make([]byte, a.gcm.NonceSize())
+	key := a.keys[0]
+	nonce := make([]byte, key.gcm.NonceSize())
 	if _, err := rand.Read(nonce); err != nil {
 		return "", err
 	}
 
-	ciphertext := a.gcm.Seal(nonce, nonce, []byte(plaintext), nil)
-	return hex.EncodeToString(ciphertext), nil
+	ciphertext := key.gcm.Seal(nonce, nonce, []byte(plaintext), nil)
+	return key.id + "." + hex.EncodeToString(ciphertext), nil
 }
 
-// decrypt расшифровывает строку
+// decrypt расшифровывает строку ключом, ID которого указан в ней.
+// Куки без ID, выданные до появления ротации ключей, проверяются всеми ключами.
 func (a *AuthMiddleware) decrypt(ciphertext string) (string, error) {
-	data, err := hex.DecodeString(ciphertext)
+	keyID, payload, hasKeyID := strings.Cut(ciphertext, ".")
+	if !hasKeyID {
+		payload = ciphertext
+	}
+
+	data, err := hex.DecodeString(payload)
 	if err != nil {
 		return "", err
 	}
 
-	nonceSize := a.gcm.NonceSize()
+	for _, key := range a.keys {
+		if hasKeyID && key.id != keyID {
+			continue
+		}
+		if plaintext, err := open(key.gcm, data); err == nil {
+			return plaintext, nil
+		}
+	}
+	return "", ErrInvalidCookie
+}
+
+// open расшифровывает данные вида nonce+шифротекст
+func open(gcm cipher.AEAD, data []byte) (string, error) {
+	nonceSize := gcm.NonceSize()
 	if len(data) < nonceSize {
 		return "", errors.New("ciphertext too short")
 	}
 
 	nonce, ciphertextBytes := data[:nonceSize], data[nonceSize:]
-	plaintext, err := a.gcm.Open(nil, nonce, ciphertextBytes, nil)
+	plaintext, err := gcm.Open(nil, nonce, ciphertextBytes, nil)
 	if err != nil {
 		return "", err
 	}
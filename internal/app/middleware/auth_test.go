@@ -0,0 +1,271 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAuthMiddleware_HTTPOnly(t *testing.T) {
+	tests := []struct {
+		name         string
+		opts         AuthOptions
+		wantHTTPOnly bool
+	}{
+		{name: "по умолчанию HttpOnly", opts: AuthOptions{}, wantHTTPOnly: true},
+		{name: "доступ из JavaScript", opts: AuthOptions{DisableHTTPOnly: true}, wantHTTPOnly: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			auth, err := NewAuthMiddlewareWithOptions("test-key", tt.opts)
+			require.NoError(t, err)
+
+			rr := httptest.NewRecorder()
+			auth.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})).
+				ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/", nil))
+
+			cookies := rr.Result().Cookies()
+			require.Len(t, cookies, 1)
+			assert.Equal(t, CookieName, cookies[0].Name)
+			assert.Equal(t, tt.wantHTTPOnly, cookies[0].HttpOnly)
+		})
+	}
+}
+
+// testClientNonce - nonce вкладки клиента для тестов окна первых запросов
+const testClientNonce = "tab-nonce-0123456789"
+
+func TestAuthMiddleware_FirstRequestWindow(t *testing.T) {
+	tests := []struct {
+		name       string
+		opts       AuthOptions
+		nonce      string
+		wantShared bool
+	}{
+		// Без окна каждый запрос без куки получает свой ID, и ссылки клиента расходятся
+		{name: "без окна ID разные", opts: AuthOptions{}, nonce: testClientNonce, wantShared: false},
+		{name: "в пределах окна ID общий", opts: AuthOptions{FirstRequestWindow: time.Minute}, nonce: testClientNonce, wantShared: true},
+		// Клиентов за общим NAT с одинаковым браузером без nonce не различить
+		{name: "без nonce ID разные", opts: AuthOptions{FirstRequestWindow: time.Minute}, wantShared: false},
+		{name: "слишком короткий nonce", opts: AuthOptions{FirstRequestWindow: time.Minute}, nonce: "short", wantShared: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			auth, err := NewAuthMiddlewareWithOptions("test-key", tt.opts)
+			require.NoError(t, err)
+
+			var mu sync.Mutex
+			userIDs := make(map[string]bool)
+			handler := auth.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				userID, _ := GetUserIDFromContext(r.Context())
+				mu.Lock()
+				userIDs[userID] = true
+				mu.Unlock()
+			}))
+
+			const requests = 5
+			var wg sync.WaitGroup
+			for i := 0; i < requests; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					req := httptest.NewRequest(http.MethodGet, "/", nil)
+					req.RemoteAddr = "192.0.2.1:12345"
+					req.Header.Set("User-Agent", "test-browser")
+					req.Header.Set(ClientNonceHeader, tt.nonce)
+					handler.ServeHTTP(httptest.NewRecorder(), req)
+				}()
+			}
+			wg.Wait()
+
+			if tt.wantShared {
+				assert.Len(t, userIDs, 1)
+			} else {
+				assert.Len(t, userIDs, requests)
+			}
+		})
+	}
+}
+
+func TestAuthMiddleware_FirstRequestWindowPerClient(t *testing.T) {
+	auth, err := NewAuthMiddlewareWithOptions("test-key", AuthOptions{FirstRequestWindow: time.Minute})
+	require.NoError(t, err)
+
+	newRequest := func(remoteAddr, nonce string) *http.Request {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.RemoteAddr = remoteAddr
+		req.Header.Set(ClientNonceHeader, nonce)
+		return req
+	}
+
+	first := auth.newUserID(newRequest("192.0.2.1:1000", testClientNonce))
+	assert.Equal(t, first, auth.newUserID(newRequest("192.0.2.1:2000", testClientNonce)), "другой порт того же клиента")
+	assert.NotEqual(t, first, auth.newUserID(newRequest("192.0.2.2:1000", testClientNonce)), "другой клиент с тем же nonce")
+	assert.NotEqual(t, first, auth.newUserID(newRequest("192.0.2.1:1000", "another-tab-nonce-0002")), "другая вкладка за тем же адресом")
+}
+
+func TestAuthMiddleware_KeyRotation(t *testing.T) {
+	const userID = "5f0c5d3c-8d3e-4a8e-9a51-8b7f3f0b6f11"
+
+	oldAuth, err := NewAuthMiddleware("old-key")
+	require.NoError(t, err)
+	oldCookie, err := oldAuth.encrypt(userID)
+	require.NoError(t, err)
+	// Кука, выданная до появления ID ключа в значении
+	_, legacyCookie, _ := strings.Cut(oldCookie, ".")
+
+	tests := []struct {
+		name    string
+		keys    []string
+		cookie  string
+		wantErr bool
+	}{
+		{name: "старый ключ после добавления нового", keys: []string{"new-key", "old-key"}, cookie: oldCookie},
+		{name: "кука без ID ключа", keys: []string{"new-key", "old-key"}, cookie: legacyCookie},
+		{name: "старый ключ удален", keys: []string{"new-key"}, cookie: oldCookie, wantErr: true},
+		{name: "кука без ID ключа после удаления ключа", keys: []string{"new-key"}, cookie: legacyCookie, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			auth, err := NewAuthMiddleware(tt.keys...)
+			require.NoError(t, err)
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.AddCookie(&http.Cookie{Name: CookieName, Value: tt.cookie})
+			got, err := auth.GetUserID(req)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, userID, got)
+		})
+	}
+}
+
+func TestAuthMiddleware_EncryptsWithFirstKey(t *testing.T) {
+	auth, err := NewAuthMiddleware("new-key", "old-key")
+	require.NoError(t, err)
+
+	rr := httptest.NewRecorder()
+	require.NoError(t, auth.SetUserID(rr, "5f0c5d3c-8d3e-4a8e-9a51-8b7f3f0b6f11"))
+	cookies := rr.Result().Cookies()
+	require.Len(t, cookies, 1)
+
+	// Новая кука читается без прежнего ключа
+	newAuth, err := NewAuthMiddleware("new-key")
+	require.NoError(t, err)
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.AddCookie(cookies[0])
+	_, err = newAuth.GetUserID(req)
+	assert.NoError(t, err)
+}
+
+func TestNewAuthMiddleware_NoKeys(t *testing.T) {
+	_, err := NewAuthMiddleware()
+	assert.Error(t, err)
+}
+
+func TestAuthMiddleware_CookieAttributes(t *testing.T) {
+	tests := []struct {
+		name     string
+		opts     AuthOptions
+		expected []string
+		absent   []string
+	}{
+		{
+			name:     "по умолчанию SameSite=Lax без Secure",
+			opts:     AuthOptions{},
+			expected: []string{"SameSite=Lax", "HttpOnly", "Path=/"},
+			absent:   []string{"Secure", "Domain="},
+		},
+		{
+			name:     "Secure и Strict",
+			opts:     AuthOptions{Secure: true, SameSite: http.SameSiteStrictMode},
+			expected: []string{"Secure", "SameSite=Strict"},
+		},
+		{
+			name:     "None с Secure",
+			opts:     AuthOptions{Secure: true, SameSite: http.SameSiteNoneMode},
+			expected: []string{"Secure", "SameSite=None"},
+		},
+		{
+			name:     "домен",
+			opts:     AuthOptions{Domain: "sho.rt"},
+			expected: []string{"Domain=sho.rt", "SameSite=Lax"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			auth, err := NewAuthMiddlewareWithOptions("test-key", tt.opts)
+			require.NoError(t, err)
+
+			rr := httptest.NewRecorder()
+			require.NoError(t, auth.SetUserID(rr, "5f0c5d3c-8d3e-4a8e-9a51-8b7f3f0b6f11"))
+
+			header := rr.Header().Get("Set-Cookie")
+			for _, attr := range tt.expected {
+				assert.Contains(t, header, attr)
+			}
+			for _, attr := range tt.absent {
+				assert.NotContains(t, header, attr)
+			}
+		})
+	}
+}
+
+func TestParseSameSite(t *testing.T) {
+	tests := []struct {
+		value    string
+		expected http.SameSite
+		wantErr  bool
+	}{
+		{value: "lax", expected: http.SameSiteLaxMode},
+		{value: "Strict", expected: http.SameSiteStrictMode},
+		{value: "none", expected: http.SameSiteNoneMode},
+		{value: "always", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			sameSite, err := ParseSameSite(tt.value)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, sameSite)
+		})
+	}
+}
+
+func TestAuthMiddleware_UserIsNew(t *testing.T) {
+	auth, err := NewAuthMiddleware("test-key")
+	require.NoError(t, err)
+
+	var isNew bool
+	handler := auth.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		isNew = IsNewUserFromContext(r.Context())
+	}))
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.True(t, isNew, "без куки")
+
+	cookies := rr.Result().Cookies()
+	require.Len(t, cookies, 1)
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.AddCookie(cookies[0])
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+	assert.False(t, isNew, "с выданной кукой")
+}
@@ -0,0 +1,34 @@
+package middleware
+
+import (
+	"errors"
+	"net/http"
+)
+
+// DefaultMaxBodyBytes - ограничение размера тела запроса по умолчанию (1 MiB)
+const DefaultMaxBodyBytes int64 = 1 << 20
+
+// NewBodyLimitMiddleware создает middleware, ограничивающий тело запроса maxBytes байтами
+// с помощью http.MaxBytesReader. Чтение сверх лимита возвращает *http.MaxBytesError,
+// по которому обработчик отвечает 413 (IsBodyTooLarge). 0 и меньше - DefaultMaxBodyBytes.
+// Для сжатых тел должен применяться после GunzipRequestMiddleware, чтобы ограничивался
+// размер распакованных данных.
+func NewBodyLimitMiddleware(maxBytes int64) func(http.Handler) http.Handler {
+	if maxBytes <= 0 {
+		maxBytes = DefaultMaxBodyBytes
+	}
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Body != nil && r.Body != http.NoBody {
+				r.Body = http.MaxBytesReader(w, r.Body, maxBytes)
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// IsBodyTooLarge проверяет, что ошибка чтения тела вызвана превышением лимита NewBodyLimitMiddleware
+func IsBodyTooLarge(err error) bool {
+	var maxBytesErr *http.MaxBytesError
+	return errors.As(err, &maxBytesErr)
+}
@@ -0,0 +1,70 @@
+package middleware
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBodyLimitMiddleware(t *testing.T) {
+	tests := []struct {
+		name        string
+		maxBytes    int64
+		body        string
+		expectError bool
+	}{
+		{
+			name:     "тело в пределах лимита",
+			maxBytes: 10,
+			body:     strings.Repeat("a", 10),
+		},
+		{
+			name:        "тело больше лимита",
+			maxBytes:    10,
+			body:        strings.Repeat("a", 11),
+			expectError: true,
+		},
+		{
+			name:     "лимит по умолчанию",
+			maxBytes: 0,
+			body:     strings.Repeat("a", 1024),
+		},
+		{
+			name:        "больше лимита по умолчанию",
+			maxBytes:    0,
+			body:        strings.Repeat("a", int(DefaultMaxBodyBytes)+1),
+			expectError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var readErr error
+			var read int
+			handler := NewBodyLimitMiddleware(tt.maxBytes)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				body, err := io.ReadAll(r.Body)
+				read, readErr = len(body), err
+			}))
+
+			req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(tt.body))
+			handler.ServeHTTP(httptest.NewRecorder(), req)
+
+			if tt.expectError {
+				assert.True(t, IsBodyTooLarge(readErr))
+				return
+			}
+			assert.NoError(t, readErr)
+			assert.Equal(t, len(tt.body), read)
+		})
+	}
+}
+
+func TestIsBodyTooLarge(t *testing.T) {
+	assert.False(t, IsBodyTooLarge(nil))
+	assert.False(t, IsBodyTooLarge(io.ErrUnexpectedEOF))
+	assert.True(t, IsBodyTooLarge(&http.MaxBytesError{Limit: 1}))
+}
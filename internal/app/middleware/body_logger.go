@@ -0,0 +1,114 @@
+// Package middleware предоставляет middleware компоненты для HTTP сервера
+package middleware
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/m-molecula741/shortener/internal/app/logger"
+)
+
+// redactedValue подставляется вместо чувствительных данных в логах
+const redactedValue = "[REDACTED]"
+
+// DefaultRedactPatterns - шаблоны чувствительных данных, скрываемых по умолчанию:
+// значения JSON полей с паролями, токенами и секретами
+var DefaultRedactPatterns = []string{
+	`(?i)"(password|token|secret|api_key)"\s*:\s*"[^"]*"`,
+}
+
+// ParseRedactPatterns компилирует шаблоны для скрытия чувствительных данных
+func ParseRedactPatterns(patterns []string) ([]*regexp.Regexp, error) {
+	compiled := make([]*regexp.Regexp, 0, len(patterns))
+	for _, pattern := range patterns {
+		pattern = strings.TrimSpace(pattern)
+		if pattern == "" {
+			continue
+		}
+
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, err
+		}
+		compiled = append(compiled, re)
+	}
+	return compiled, nil
+}
+
+// redact заменяет совпадения с шаблонами на redactedValue
+func redact(body []byte, patterns []*regexp.Regexp) string {
+	for _, re := range patterns {
+		body = re.ReplaceAll(body, []byte(redactedValue))
+	}
+	return string(body)
+}
+
+// teeReadCloser отдает обработчику уже прочитанную часть тела, а затем остаток
+type teeReadCloser struct {
+	io.Reader
+	io.Closer
+}
+
+// bodyCaptureWriter сохраняет начало тела ответа для логирования
+type bodyCaptureWriter struct {
+	http.ResponseWriter
+	body    bytes.Buffer
+	maxSize int
+}
+
+// Write записывает данные в ответ и сохраняет не более maxSize байт
+func (w *bodyCaptureWriter) Write(b []byte) (int, error) {
+	if remaining := w.maxSize - w.body.Len(); remaining > 0 {
+		w.body.Write(b[:min(len(b), remaining)])
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+// Unwrap возвращает исходный ResponseWriter, чтобы http.ResponseController мог сбросить ответ
+func (w *bodyCaptureWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// NewBodyLogger создает middleware, логирующий на уровне debug тела запроса и ответа.
+// Логируется не более maxSize байт каждого тела, совпадения с patterns скрываются.
+// Тело запроса не расходуется: прочитанная часть возвращается обработчику вместе с остатком.
+// Сжатые тела не раскрываются, в лог попадает только их кодировка.
+func NewBodyLogger(maxSize int, patterns []*regexp.Regexp) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			var requestBody []byte
+			if r.Body != nil {
+				requestBody, _ = io.ReadAll(io.LimitReader(r.Body, int64(maxSize)))
+				r.Body = teeReadCloser{
+					Reader: io.MultiReader(bytes.NewReader(requestBody), r.Body),
+					Closer: r.Body,
+				}
+			}
+
+			captured := &bodyCaptureWriter{
+				ResponseWriter: w,
+				maxSize:        maxSize,
+			}
+
+			next.ServeHTTP(captured, r)
+
+			logger.Debug().
+				Str("method", r.Method).
+				Str("uri", r.RequestURI).
+				Str("request_body", loggableBody(requestBody, r.Header.Get("Content-Encoding"), patterns)).
+				Str("response_body", loggableBody(captured.body.Bytes(), w.Header().Get("Content-Encoding"), patterns)).
+				Msg("HTTP bodies")
+		})
+	}
+}
+
+// loggableBody подготавливает тело к записи в лог
+func loggableBody(body []byte, encoding string, patterns []*regexp.Regexp) string {
+	if encoding != "" && encoding != "identity" {
+		return "[" + encoding + " encoded]"
+	}
+	return redact(body, patterns)
+}
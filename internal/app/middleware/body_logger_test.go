@@ -0,0 +1,49 @@
+package middleware
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBodyLogger_PreservesBodies(t *testing.T) {
+	patterns, err := ParseRedactPatterns(DefaultRedactPatterns)
+	require.NoError(t, err)
+
+	// Тело длиннее лимита логирования должно дойти до обработчика целиком
+	requestBody := strings.Repeat("a", 100)
+
+	var received string
+	handler := NewBodyLogger(10, patterns)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+		received = string(body)
+		w.Write([]byte("response body"))
+	}))
+
+	req := httptest.NewRequest(http.MethodPost, "/", bytes.NewBufferString(requestBody))
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	assert.Equal(t, requestBody, received)
+	assert.Equal(t, "response body", rr.Body.String())
+}
+
+func TestRedact(t *testing.T) {
+	patterns, err := ParseRedactPatterns(append(DefaultRedactPatterns, `user-\d+`))
+	require.NoError(t, err)
+
+	got := redact([]byte(`{"url":"https://example.com","Token":"abc","owner":"user-42"}`), patterns)
+	assert.Equal(t, `{"url":"https://example.com",[REDACTED],"owner":"[REDACTED]"}`, got)
+}
+
+func TestParseRedactPatterns_Invalid(t *testing.T) {
+	_, err := ParseRedactPatterns([]string{"("})
+	assert.Error(t, err)
+}
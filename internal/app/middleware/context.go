@@ -4,7 +4,10 @@ import "context"
 
 type contextKey string
 
-const userIDKey contextKey = "userID"
+const (
+	userIDKey    contextKey = "userID"
+	userIsNewKey contextKey = "userIsNew"
+)
 
 // SetUserIDToContext добавляет ID пользователя в контекст
 func SetUserIDToContext(ctx context.Context, userID string) context.Context {
@@ -16,3 +19,16 @@ func GetUserIDFromContext(ctx context.Context) (string, bool) {
 	userID, ok := ctx.Value(userIDKey).(string)
 	return userID, ok
 }
+
+// SetUserIsNewToContext отмечает в контексте, что ID пользователя выдан в этом запросе,
+// а не получен из куки
+func SetUserIsNewToContext(ctx context.Context, isNew bool) context.Context {
+	return context.WithValue(ctx, userIsNewKey, isNew)
+}
+
+// IsNewUserFromContext сообщает, что ID пользователя выдан в этом запросе:
+// у клиента не было валидной куки и у такого пользователя еще нет данных
+func IsNewUserFromContext(ctx context.Context) bool {
+	isNew, _ := ctx.Value(userIsNewKey).(bool)
+	return isNew
+}
@@ -0,0 +1,83 @@
+package middleware
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultCORSExposedHeaders - заголовки ответа, которые браузер по умолчанию не отдает JavaScript:
+// ссылки batch, общее количество для пагинации, ID запроса и лимиты запросов
+var DefaultCORSExposedHeaders = []string{
+	"Link",
+	"Retry-After",
+	"X-Total-Count",
+	"X-Request-Id",
+	"X-RateLimit-Limit",
+	"X-RateLimit-Remaining",
+	"X-RateLimit-Reset",
+}
+
+// corsAllowedMethods - методы, которые разрешаются в ответ на preflight запрос
+const corsAllowedMethods = "GET, POST, DELETE, OPTIONS"
+
+// CORSOptions содержит настройки CORS
+type CORSOptions struct {
+	AllowedOrigins []string      // разрешенные источники, "*" - любой
+	MaxAge         time.Duration // сколько браузер кеширует результат preflight, 0 - не кешировать
+	ExposedHeaders []string      // заголовки ответа, доступные JavaScript
+}
+
+// NewCORSMiddleware создает middleware, разрешающий запросы из браузера с разрешенных источников.
+// Аутентификация построена на куке, поэтому источник всегда указывается явно и разрешается
+// передача учетных данных. Preflight запросы обрабатываются без передачи дальше по цепочке.
+func NewCORSMiddleware(opts CORSOptions) func(http.Handler) http.Handler {
+	allowAny := false
+	allowed := make(map[string]struct{}, len(opts.AllowedOrigins))
+	for _, origin := range opts.AllowedOrigins {
+		origin = strings.TrimSpace(origin)
+		switch origin {
+		case "":
+		case "*":
+			allowAny = true
+		default:
+			allowed[strings.TrimSuffix(origin, "/")] = struct{}{}
+		}
+	}
+
+	exposedHeaders := strings.Join(opts.ExposedHeaders, ", ")
+	maxAge := strconv.Itoa(int(opts.MaxAge.Seconds()))
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			origin := r.Header.Get("Origin")
+			w.Header().Add("Vary", "Origin")
+
+			if _, ok := allowed[origin]; origin == "" || !(ok || allowAny) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			w.Header().Set("Access-Control-Allow-Origin", origin)
+			w.Header().Set("Access-Control-Allow-Credentials", "true")
+
+			if r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != "" {
+				w.Header().Set("Access-Control-Allow-Methods", corsAllowedMethods)
+				if requestHeaders := r.Header.Get("Access-Control-Request-Headers"); requestHeaders != "" {
+					w.Header().Set("Access-Control-Allow-Headers", requestHeaders)
+				}
+				if opts.MaxAge > 0 {
+					w.Header().Set("Access-Control-Max-Age", maxAge)
+				}
+				w.WriteHeader(http.StatusNoContent)
+				return
+			}
+
+			if exposedHeaders != "" {
+				w.Header().Set("Access-Control-Expose-Headers", exposedHeaders)
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,96 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCORSMiddleware(t *testing.T) {
+	opts := CORSOptions{
+		AllowedOrigins: []string{"https://app.example.com"},
+		MaxAge:         10 * time.Minute,
+		ExposedHeaders: DefaultCORSExposedHeaders,
+	}
+
+	tests := []struct {
+		name          string
+		method        string
+		origin        string
+		preflight     bool
+		expectedCode  int
+		expectedAllow string
+		expectedAge   string
+		expectExposed bool
+		expectNext    bool
+	}{
+		{
+			name:         "без Origin",
+			method:       http.MethodGet,
+			expectedCode: http.StatusOK,
+			expectNext:   true,
+		},
+		{
+			name:         "неразрешенный источник",
+			method:       http.MethodGet,
+			origin:       "https://evil.example.com",
+			expectedCode: http.StatusOK,
+			expectNext:   true,
+		},
+		{
+			name:          "обычный запрос",
+			method:        http.MethodGet,
+			origin:        "https://app.example.com",
+			expectedCode:  http.StatusOK,
+			expectedAllow: "https://app.example.com",
+			expectExposed: true,
+			expectNext:    true,
+		},
+		{
+			name:          "preflight кешируется",
+			method:        http.MethodOptions,
+			origin:        "https://app.example.com",
+			preflight:     true,
+			expectedCode:  http.StatusNoContent,
+			expectedAllow: "https://app.example.com",
+			expectedAge:   "600",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			nextCalled := false
+			handler := NewCORSMiddleware(opts)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				nextCalled = true
+			}))
+
+			req := httptest.NewRequest(tt.method, "/api/user/urls", nil)
+			if tt.origin != "" {
+				req.Header.Set("Origin", tt.origin)
+			}
+			if tt.preflight {
+				req.Header.Set("Access-Control-Request-Method", http.MethodDelete)
+				req.Header.Set("Access-Control-Request-Headers", "Content-Type")
+			}
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+
+			assert.Equal(t, tt.expectedCode, rr.Code)
+			assert.Equal(t, tt.expectNext, nextCalled)
+			assert.Equal(t, tt.expectedAllow, rr.Header().Get("Access-Control-Allow-Origin"))
+			assert.Equal(t, tt.expectedAge, rr.Header().Get("Access-Control-Max-Age"))
+
+			exposed := rr.Header().Get("Access-Control-Expose-Headers")
+			if tt.expectExposed {
+				assert.Contains(t, exposed, "X-Total-Count")
+				assert.Contains(t, exposed, "X-Request-Id")
+				assert.Contains(t, exposed, "X-RateLimit-Remaining")
+			} else {
+				assert.Empty(t, exposed)
+			}
+		})
+	}
+}
@@ -3,22 +3,57 @@ package middleware
 
 import (
 	"compress/gzip"
+	"io"
+	"mime"
 	"net/http"
+	"strconv"
 	"strings"
+
+	"github.com/andybalholm/brotli"
+)
+
+// Поддерживаемые алгоритмы сжатия ответов (значения Content-Encoding)
+const (
+	EncodingBrotli = "br"
+	EncodingGzip   = "gzip"
 )
 
-// compressibleTypes содержит MIME-типы, для которых включается gzip сжатие.
-// Эта карта используется для определения, следует ли сжимать ответы сервера
-// и автоматически распаковывать входящие gzip-сжатые запросы от клиентов.
-var compressibleTypes = map[string]bool{
-	"application/json": true,
-	"text/html":        true,
+// DefaultGzipTypes - MIME-типы ответов, которые сжимаются по умолчанию.
+// Уже сжатые форматы (изображения, архивы) сжимать бесполезно.
+var DefaultGzipTypes = []string{
+	"application/json",
+	"text/html",
+	"text/plain",
+	"application/xml",
+	"text/css",
+	"application/javascript",
 }
 
-// GzipMiddleware обеспечивает сжатие ответов с помощью gzip
+// DefaultGzipMinSize - минимальный размер ответа, начиная с которого он сжимается.
+// Ответ меньше типичного MTU (~1500 байт) уходит одним пакетом и без сжатия.
+const DefaultGzipMinSize = 1400
+
+// GzipMiddleware обеспечивает сжатие ответов типов DefaultGzipTypes с помощью brotli или gzip
+// начиная с размера DefaultGzipMinSize
 func GzipMiddleware(next http.Handler) http.Handler {
+	return NewGzipMiddleware(DefaultGzipMinSize)(next)
+}
+
+// NewGzipMiddleware создает middleware, распаковывающий запросы и сжимающий ответы с помощью brotli или gzip.
+// Ответы меньше minSize байт отправляются без сжатия: для коротких ответов
+// сжатие тратит CPU и может увеличить размер. 0 - сжимать все ответы.
+// types - сжимаемые MIME-типы ответов, без них используются DefaultGzipTypes.
+func NewGzipMiddleware(minSize int, types ...string) func(http.Handler) http.Handler {
+	compress := NewGzipResponseMiddleware(minSize, types...)
+	return func(next http.Handler) http.Handler {
+		return GunzipRequestMiddleware(compress(next))
+	}
+}
+
+// GunzipRequestMiddleware распаковывает тела запросов с Content-Encoding: gzip.
+// Заголовок Content-Encoding сохраняется, чтобы обработчики знали об исходном сжатии.
+func GunzipRequestMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		// 1. Обработка входящего gzip
 		if strings.Contains(r.Header.Get("Content-Encoding"), "gzip") {
 			gz, err := gzip.NewReader(r.Body)
 			if err != nil {
@@ -29,91 +64,225 @@ func GzipMiddleware(next http.Handler) http.Handler {
 			r.Body = gz
 		}
 
-		// 2. Проверяем поддержку gzip клиентом
-		acceptsGzip := strings.Contains(r.Header.Get("Accept-Encoding"), "gzip")
-		if !acceptsGzip {
-			next.ServeHTTP(w, r)
-			return
+		next.ServeHTTP(w, r)
+	})
+}
+
+// NewGzipResponseMiddleware создает middleware, который только сжимает ответы типов types,
+// начиная с minSize байт. Без types используются DefaultGzipTypes.
+// Алгоритм выбирается по Accept-Encoding: brotli, если клиент его поддерживает, затем gzip.
+// Позволяет включать сжатие для отдельных маршрутов, распаковывая запросы глобально.
+func NewGzipResponseMiddleware(minSize int, types ...string) func(http.Handler) http.Handler {
+	if len(types) == 0 {
+		types = DefaultGzipTypes
+	}
+	compressible := make(map[string]bool, len(types))
+	for _, typ := range types {
+		compressible[strings.ToLower(strings.TrimSpace(typ))] = true
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			encoding := negotiateEncoding(r.Header.Get("Accept-Encoding"))
+			if encoding == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			// Используем перехватчик с копированием заголовков
+			writer := &compressResponseWriter{
+				ResponseWriter: w,
+				encoding:       encoding,
+				minSize:        minSize,
+				compressible:   compressible,
+			}
+			defer writer.Close()
+
+			next.ServeHTTP(writer, r)
+		})
+	}
+}
+
+// negotiateEncoding выбирает алгоритм сжатия по заголовку Accept-Encoding с учетом
+// q-значений. При равном q предпочитается brotli; "*" относится к не упомянутым алгоритмам.
+// Пустая строка - сжатие не поддерживается клиентом.
+func negotiateEncoding(acceptEncoding string) string {
+	quality := make(map[string]float64)
+	for _, part := range strings.Split(acceptEncoding, ",") {
+		name, params, _ := strings.Cut(part, ";")
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
 		}
 
-		// 3. Используем перехватчик с копированием заголовков
-		writer := &gzipResponseWriter{
-			ResponseWriter: w,
-			acceptsGzip:    acceptsGzip,
+		q := 1.0
+		for _, param := range strings.Split(params, ";") {
+			key, value, ok := strings.Cut(param, "=")
+			if !ok || strings.TrimSpace(key) != "q" {
+				continue
+			}
+			parsed, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+			if err != nil {
+				parsed = 0
+			}
+			q = parsed
 		}
-		defer writer.Close()
+		quality[name] = q
+	}
 
-		next.ServeHTTP(writer, r)
-	})
+	best, bestQ := "", 0.0
+	for _, encoding := range []string{EncodingBrotli, EncodingGzip} {
+		q, ok := quality[encoding]
+		if !ok {
+			q = quality["*"]
+		}
+		if q > bestQ {
+			best, bestQ = encoding, q
+		}
+	}
+	return best
+}
+
+// encoder - поток сжатия ответа
+type encoder interface {
+	io.WriteCloser
+	Flush() error
 }
 
-// gzipResponseWriter реализует интерфейс http.ResponseWriter для сжатия ответов
-type gzipResponseWriter struct {
+// newEncoder создает поток сжатия выбранного алгоритма
+func newEncoder(encoding string, w io.Writer) encoder {
+	if encoding == EncodingBrotli {
+		return brotli.NewWriterLevel(w, brotli.DefaultCompression)
+	}
+	return gzip.NewWriter(w)
+}
+
+// compressResponseWriter реализует интерфейс http.ResponseWriter для сжатия ответов.
+// Начало сжимаемого ответа накапливается в буфере, пока не станет ясно,
+// превышает ли ответ minSize; до этого момента заголовки не отправляются.
+type compressResponseWriter struct {
 	http.ResponseWriter
-	gz          *gzip.Writer
-	headers     http.Header
+	enc         encoder
+	encoding    string // выбранный алгоритм: EncodingBrotli или EncodingGzip
 	wroteHeader bool
-	acceptsGzip bool
+
+	minSize      int
+	compressible map[string]bool // сжимаемые MIME-типы
+	buffering    bool            // ответ подходит для сжатия, решение отложено до minSize байт
+	status       int
+	buf          []byte
 }
 
 // Write реализует интерфейс io.Writer для сжатия данных
-func (w *gzipResponseWriter) Write(b []byte) (int, error) {
+func (w *compressResponseWriter) Write(b []byte) (int, error) {
 	if !w.wroteHeader {
 		w.WriteHeader(http.StatusOK)
 	}
 
-	contentType := w.headers.Get("Content-Type")
-	shouldCompress := w.acceptsGzip && shouldCompressContentType(contentType)
+	if w.buffering {
+		w.buf = append(w.buf, b...)
+		if len(w.buf) < w.minSize {
+			return len(b), nil
+		}
+		if err := w.startCompression(); err != nil {
+			return 0, err
+		}
+		return len(b), nil
+	}
 
-	if shouldCompress {
-		return w.gz.Write(b)
+	// Решение о сжатии уже принято
+	if w.enc != nil {
+		return w.enc.Write(b)
 	}
 	return w.ResponseWriter.Write(b)
 }
 
-// WriteHeader устанавливает статус код и необходимые заголовки для сжатия
-func (w *gzipResponseWriter) WriteHeader(statusCode int) {
+// WriteHeader запоминает статус код и решает, может ли ответ быть сжат.
+// Для сжимаемых ответов отправка заголовков откладывается до набора minSize байт.
+func (w *compressResponseWriter) WriteHeader(statusCode int) {
 	if w.wroteHeader {
 		return
 	}
 	w.wroteHeader = true
+	w.status = statusCode
 
-	// Копируем заголовки
-	w.headers = w.Header().Clone()
-
-	contentType := w.headers.Get("Content-Type")
-	shouldCompress := w.acceptsGzip && shouldCompressContentType(contentType) &&
+	contentType := w.Header().Get("Content-Type")
+	shouldCompress := w.compressible[mediaType(contentType)] &&
 		statusCode != http.StatusNoContent &&
 		statusCode != http.StatusNotModified &&
 		!(statusCode >= 300 && statusCode < 400)
 
-	if shouldCompress {
-		w.headers.Set("Content-Encoding", "gzip")
-		w.headers.Del("Content-Length")
-		w.gz = gzip.NewWriter(w.ResponseWriter)
+	if !shouldCompress {
+		w.ResponseWriter.WriteHeader(statusCode)
+		return
 	}
 
-	// Применяем заголовки
-	for k, v := range w.headers {
-		w.ResponseWriter.Header()[k] = v
+	w.buffering = true
+}
+
+// startCompression отправляет заголовки сжатого ответа и накопленный буфер
+func (w *compressResponseWriter) startCompression() error {
+	w.buffering = false
+
+	// Меняем заголовки исходного ответа напрямую: Content-Length, выставленный
+	// обработчиком, должен быть удален при сжатии
+	headers := w.Header()
+	headers.Set("Content-Encoding", w.encoding)
+	headers.Add("Vary", "Accept-Encoding")
+	headers.Del("Content-Length")
+	w.ResponseWriter.WriteHeader(w.status)
+
+	w.enc = newEncoder(w.encoding, w.ResponseWriter)
+	_, err := w.enc.Write(w.buf)
+	w.buf = nil
+	return err
+}
+
+// Flush отправляет клиенту уже записанные данные, например при потоковом ответе.
+// Отложенное решение о сжатии принимается сразу: итоговый размер ответа заранее неизвестен.
+func (w *compressResponseWriter) Flush() {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
 	}
+	if w.buffering {
+		if err := w.startCompression(); err != nil {
+			return
+		}
+	}
+	if w.enc != nil {
+		w.enc.Flush()
+	}
+	http.NewResponseController(w.ResponseWriter).Flush()
+}
 
-	w.ResponseWriter.WriteHeader(statusCode)
+// Unwrap возвращает исходный ResponseWriter для http.ResponseController
+func (w *compressResponseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
 }
 
-// Close закрывает gzip.Writer если он был создан
-func (w *gzipResponseWriter) Close() {
-	if w.gz != nil {
-		w.gz.Close()
+// Close отправляет короткий ответ без сжатия или закрывает поток сжатия
+func (w *compressResponseWriter) Close() {
+	if w.buffering {
+		// Ответ меньше порога: отправляем как есть с точным Content-Length
+		w.buffering = false
+		w.Header().Set("Content-Length", strconv.Itoa(len(w.buf)))
+		w.ResponseWriter.WriteHeader(w.status)
+		w.ResponseWriter.Write(w.buf)
+		w.buf = nil
+		return
+	}
+
+	if w.enc != nil {
+		w.enc.Close()
 	}
 }
 
-// shouldCompressContentType проверяет, нужно ли сжимать контент данного типа
-func shouldCompressContentType(contentType string) bool {
-	for typ := range compressibleTypes {
-		if strings.Contains(contentType, typ) {
-			return true
-		}
+// mediaType возвращает MIME-тип из Content-Type без параметров вроде charset
+func mediaType(contentType string) string {
+	typ, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		typ, _, _ = strings.Cut(contentType, ";")
+		typ = strings.ToLower(strings.TrimSpace(typ))
 	}
-	return false
+	return typ
 }
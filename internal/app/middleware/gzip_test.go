@@ -0,0 +1,327 @@
+package middleware
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/andybalholm/brotli"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGzipMiddleware_ContentLength(t *testing.T) {
+	tests := []struct {
+		name             string
+		contentType      string
+		body             string
+		expectCompressed bool
+	}{
+		{
+			name:             "несжимаемый тип сохраняет Content-Length",
+			contentType:      "image/png",
+			body:             "binary-image-data",
+			expectCompressed: false,
+		},
+		{
+			name:             "сжимаемый тип удаляет Content-Length",
+			contentType:      "application/json",
+			body:             `[` + strings.Repeat(`{"short_url":"http://localhost:8080/abc123"},`, 40) + `{}]`,
+			expectCompressed: true,
+		},
+		{
+			name:             "короткий ответ сжимаемого типа не сжимается",
+			contentType:      "application/json",
+			body:             `{"result":"http://localhost:8080/abc123"}`,
+			expectCompressed: false,
+		},
+		{
+			name:             "10 байт не сжимаются",
+			contentType:      "text/plain",
+			body:             "abcdefghij",
+			expectCompressed: false,
+		},
+		{
+			name:             "ответ чуть меньше порога не сжимается",
+			contentType:      "application/json",
+			body:             `"` + strings.Repeat("a", DefaultGzipMinSize-3) + `"`,
+			expectCompressed: false,
+		},
+		{
+			name:             "JSON 5 КБ сжимается",
+			contentType:      "application/json",
+			body:             `[` + strings.Repeat(`{"short_url":"http://localhost:8080/abc123"},`, 115) + `{}]`,
+			expectCompressed: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := GzipMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", tt.contentType)
+				w.Header().Set("Content-Length", strconv.Itoa(len(tt.body)))
+				w.Write([]byte(tt.body))
+			}))
+
+			ts := httptest.NewServer(handler)
+			defer ts.Close()
+
+			req, err := http.NewRequest(http.MethodGet, ts.URL, nil)
+			require.NoError(t, err)
+			req.Header.Set("Accept-Encoding", "gzip")
+
+			resp, err := http.DefaultTransport.RoundTrip(req)
+			require.NoError(t, err)
+			defer resp.Body.Close()
+
+			var reader io.Reader = resp.Body
+			if tt.expectCompressed {
+				assert.Equal(t, "gzip", resp.Header.Get("Content-Encoding"))
+				assert.NotEqual(t, strconv.Itoa(len(tt.body)), resp.Header.Get("Content-Length"))
+
+				gz, err := gzip.NewReader(resp.Body)
+				require.NoError(t, err)
+				defer gz.Close()
+				reader = gz
+			} else {
+				assert.Empty(t, resp.Header.Get("Content-Encoding"))
+				assert.Equal(t, strconv.Itoa(len(tt.body)), resp.Header.Get("Content-Length"))
+				assert.Equal(t, int64(len(tt.body)), resp.ContentLength)
+			}
+
+			body, err := io.ReadAll(reader)
+			require.NoError(t, err)
+			assert.Equal(t, tt.body, string(body))
+		})
+	}
+}
+
+func TestGzipMiddleware_MinSize(t *testing.T) {
+	tests := []struct {
+		name             string
+		minSize          int
+		chunks           []string
+		expectCompressed bool
+	}{
+		{
+			name:             "ответ меньше порога получает Content-Length",
+			minSize:          64,
+			chunks:           []string{"short", "-body"},
+			expectCompressed: false,
+		},
+		{
+			name:             "ответ превышает порог по частям",
+			minSize:          64,
+			chunks:           []string{strings.Repeat("a", 40), strings.Repeat("b", 40)},
+			expectCompressed: true,
+		},
+		{
+			name:             "нулевой порог сжимает все",
+			minSize:          0,
+			chunks:           []string{"tiny"},
+			expectCompressed: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := NewGzipMiddleware(tt.minSize)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "text/html")
+				w.WriteHeader(http.StatusCreated)
+				for _, chunk := range tt.chunks {
+					w.Write([]byte(chunk))
+				}
+			}))
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.Header.Set("Accept-Encoding", "gzip")
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+
+			expected := strings.Join(tt.chunks, "")
+			assert.Equal(t, http.StatusCreated, rr.Code)
+
+			if !tt.expectCompressed {
+				assert.Empty(t, rr.Header().Get("Content-Encoding"))
+				assert.Equal(t, strconv.Itoa(len(expected)), rr.Header().Get("Content-Length"))
+				assert.Equal(t, expected, rr.Body.String())
+				return
+			}
+
+			assert.Equal(t, "gzip", rr.Header().Get("Content-Encoding"))
+			gz, err := gzip.NewReader(rr.Body)
+			require.NoError(t, err)
+			body, err := io.ReadAll(gz)
+			require.NoError(t, err)
+			assert.Equal(t, expected, string(body))
+		})
+	}
+}
+
+func TestGzipMiddleware_Flush(t *testing.T) {
+	handler := NewGzipMiddleware(1024)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`[{"id":1}`))
+		require.NoError(t, http.NewResponseController(w).Flush())
+
+		// Сброшенные данные уже у клиента, хотя порог minSize не набран
+		rr := w.(*compressResponseWriter).ResponseWriter.(*httptest.ResponseRecorder)
+		assert.True(t, rr.Flushed)
+		assert.Equal(t, "gzip", rr.Header().Get("Content-Encoding"))
+
+		w.Write([]byte(`]`))
+	}))
+
+	req := httptest.NewRequest(http.MethodPost, "/", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	reader, err := gzip.NewReader(rr.Body)
+	require.NoError(t, err)
+	body, err := io.ReadAll(reader)
+	require.NoError(t, err)
+	assert.Equal(t, `[{"id":1}]`, string(body))
+}
+
+func TestGzipMiddleware_Types(t *testing.T) {
+	tests := []struct {
+		name             string
+		types            []string
+		contentType      string
+		expectCompressed bool
+	}{
+		{
+			name:             "тип по умолчанию с charset",
+			contentType:      "text/plain; charset=utf-8",
+			expectCompressed: true,
+		},
+		{
+			name:             "тип не из списка по умолчанию",
+			contentType:      "image/svg+xml",
+			expectCompressed: false,
+		},
+		{
+			name:             "собственный список",
+			types:            []string{"image/svg+xml", "Application/GeoJSON"},
+			contentType:      "image/svg+xml",
+			expectCompressed: true,
+		},
+		{
+			name:             "тип из собственного списка без учета регистра",
+			types:            []string{"image/svg+xml", "Application/GeoJSON"},
+			contentType:      "application/geojson",
+			expectCompressed: true,
+		},
+		{
+			name:             "собственный список заменяет типы по умолчанию",
+			types:            []string{"image/svg+xml"},
+			contentType:      "application/json",
+			expectCompressed: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body := strings.Repeat("<svg/>", 100)
+			handler := NewGzipMiddleware(0, tt.types...)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", tt.contentType)
+				w.Write([]byte(body))
+			}))
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.Header.Set("Accept-Encoding", "gzip")
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+
+			if !tt.expectCompressed {
+				assert.Empty(t, rr.Header().Get("Content-Encoding"))
+				assert.Equal(t, body, rr.Body.String())
+				return
+			}
+
+			assert.Equal(t, "gzip", rr.Header().Get("Content-Encoding"))
+			gz, err := gzip.NewReader(rr.Body)
+			require.NoError(t, err)
+			decoded, err := io.ReadAll(gz)
+			require.NoError(t, err)
+			assert.Equal(t, body, string(decoded))
+		})
+	}
+}
+
+func TestNegotiateEncoding(t *testing.T) {
+	tests := []struct {
+		name           string
+		acceptEncoding string
+		expected       string
+	}{
+		{name: "без заголовка", acceptEncoding: "", expected: ""},
+		{name: "только gzip", acceptEncoding: "gzip", expected: EncodingGzip},
+		{name: "только brotli", acceptEncoding: "br", expected: EncodingBrotli},
+		{name: "оба - предпочитается brotli", acceptEncoding: "gzip, deflate, br", expected: EncodingBrotli},
+		{name: "gzip с большим q", acceptEncoding: "br;q=0.5, gzip;q=0.9", expected: EncodingGzip},
+		{name: "brotli запрещен", acceptEncoding: "br;q=0, gzip", expected: EncodingGzip},
+		{name: "звездочка", acceptEncoding: "*", expected: EncodingBrotli},
+		{name: "звездочка с исключением", acceptEncoding: "*, br;q=0", expected: EncodingGzip},
+		{name: "неподдерживаемый алгоритм", acceptEncoding: "deflate, identity", expected: ""},
+		{name: "регистр и пробелы", acceptEncoding: " GZIP ; q=1.0 ", expected: EncodingGzip},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, negotiateEncoding(tt.acceptEncoding))
+		})
+	}
+}
+
+func TestGzipMiddleware_Brotli(t *testing.T) {
+	tests := []struct {
+		name             string
+		acceptEncoding   string
+		expectedEncoding string
+	}{
+		{name: "br", acceptEncoding: "br", expectedEncoding: EncodingBrotli},
+		{name: "gzip", acceptEncoding: "gzip", expectedEncoding: EncodingGzip},
+		{name: "br и gzip", acceptEncoding: "gzip, br", expectedEncoding: EncodingBrotli},
+		{name: "без сжатия", acceptEncoding: "identity", expectedEncoding: ""},
+	}
+
+	body := `[` + strings.Repeat(`{"short_url":"http://localhost:8080/abc123"},`, 115) + `{}]`
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := GzipMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "application/json")
+				w.Write([]byte(body))
+			}))
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.Header.Set("Accept-Encoding", tt.acceptEncoding)
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+
+			assert.Equal(t, tt.expectedEncoding, rr.Header().Get("Content-Encoding"))
+
+			var reader io.Reader = rr.Body
+			switch tt.expectedEncoding {
+			case EncodingBrotli:
+				reader = brotli.NewReader(rr.Body)
+			case EncodingGzip:
+				gz, err := gzip.NewReader(rr.Body)
+				require.NoError(t, err)
+				reader = gz
+			}
+			if tt.expectedEncoding != "" {
+				assert.Equal(t, "Accept-Encoding", rr.Header().Get("Vary"))
+			}
+
+			decoded, err := io.ReadAll(reader)
+			require.NoError(t, err)
+			assert.Equal(t, body, string(decoded))
+		})
+	}
+}
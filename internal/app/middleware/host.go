@@ -0,0 +1,61 @@
+package middleware
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"strings"
+)
+
+const baseURLKey contextKey = "baseURL"
+
+// NewHostBaseURLMiddleware создает middleware, определяющий базовый адрес коротких URL
+// по заголовку Host запроса. Учитываются только хосты из списка allowedHosts,
+// для остальных запросов базовый адрес в контекст не добавляется.
+// Middleware должен применяться после NewForwardedSchemeMiddleware.
+func NewHostBaseURLMiddleware(allowedHosts []string) func(http.Handler) http.Handler {
+	allowed := make(map[string]struct{}, len(allowedHosts))
+	for _, host := range allowedHosts {
+		host = strings.ToLower(strings.TrimSpace(host))
+		if host != "" {
+			allowed[host] = struct{}{}
+		}
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			host := strings.ToLower(r.Host)
+			if !isAllowedHost(host, allowed) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			baseURL := GetSchemeFromContext(r.Context()) + "://" + host + "/"
+			ctx := context.WithValue(r.Context(), baseURLKey, baseURL)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// GetBaseURLFromContext возвращает базовый адрес, определенный по Host запроса
+func GetBaseURLFromContext(ctx context.Context) (string, bool) {
+	baseURL, ok := ctx.Value(baseURLKey).(string)
+	return baseURL, ok
+}
+
+// isAllowedHost проверяет хост запроса по списку как с портом, так и без него
+func isAllowedHost(host string, allowed map[string]struct{}) bool {
+	if host == "" {
+		return false
+	}
+	if _, ok := allowed[host]; ok {
+		return true
+	}
+
+	hostname, _, err := net.SplitHostPort(host)
+	if err != nil {
+		return false
+	}
+	_, ok := allowed[hostname]
+	return ok
+}
@@ -0,0 +1,66 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHostBaseURLMiddleware(t *testing.T) {
+	tests := []struct {
+		name            string
+		host            string
+		forwardedProto  string
+		expectedBaseURL string
+		expectedOK      bool
+	}{
+		{
+			name:            "разрешенный хост",
+			host:            "sho.rt",
+			expectedBaseURL: "http://sho.rt/",
+			expectedOK:      true,
+		},
+		{
+			name:            "разрешенный хост с портом",
+			host:            "Tiny.Link:8080",
+			expectedBaseURL: "http://tiny.link:8080/",
+			expectedOK:      true,
+		},
+		{
+			name:            "схема от доверенного прокси",
+			host:            "sho.rt",
+			forwardedProto:  "https",
+			expectedBaseURL: "https://sho.rt/",
+			expectedOK:      true,
+		},
+		{
+			name: "неизвестный хост",
+			host: "evil.com",
+		},
+	}
+
+	trusted, _ := ParseCIDRList("192.0.2.0/24")
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var baseURL string
+			var ok bool
+			handler := NewHostBaseURLMiddleware([]string{"sho.rt", " tiny.link "})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				baseURL, ok = GetBaseURLFromContext(r.Context())
+			}))
+			handler = NewForwardedSchemeMiddleware(trusted)(handler)
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.Host = tt.host
+			if tt.forwardedProto != "" {
+				req.Header.Set("X-Forwarded-Proto", tt.forwardedProto)
+			}
+
+			handler.ServeHTTP(httptest.NewRecorder(), req)
+
+			assert.Equal(t, tt.expectedOK, ok)
+			assert.Equal(t, tt.expectedBaseURL, baseURL)
+		})
+	}
+}
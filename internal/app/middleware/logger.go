@@ -11,29 +11,50 @@ import (
 // responseWriter реализует интерфейс http.ResponseWriter для сбора метрик
 type responseWriter struct {
 	http.ResponseWriter
-	status int
-	size   int
+	status      int
+	size        int
+	wroteHeader bool
+	writeErr    error
 }
 
-// WriteHeader устанавливает статус код ответа
+// WriteHeader устанавливает статус код ответа.
+// Учитывается только первый вызов, как и в net/http: повторные вызовы игнорируются.
 func (rw *responseWriter) WriteHeader(status int) {
-	rw.status = status
+	if !rw.wroteHeader {
+		rw.status = status
+		rw.wroteHeader = true
+	}
 	rw.ResponseWriter.WriteHeader(status)
 }
 
-// Write записывает данные и подсчитывает их размер
+// Write записывает данные и подсчитывает их размер.
+// Запись без явного WriteHeader означает статус 200, первая ошибка записи сохраняется для лога.
 func (rw *responseWriter) Write(b []byte) (int, error) {
+	if !rw.wroteHeader {
+		rw.status = http.StatusOK
+		rw.wroteHeader = true
+	}
+
 	size, err := rw.ResponseWriter.Write(b)
 	rw.size += size
+	if err != nil && rw.writeErr == nil {
+		rw.writeErr = err
+	}
 	return size, err
 }
 
+// Unwrap возвращает исходный ResponseWriter, чтобы http.ResponseController мог сбросить ответ
+func (rw *responseWriter) Unwrap() http.ResponseWriter {
+	return rw.ResponseWriter
+}
+
 // RequestLogger middleware для логирования HTTP запросов
 func RequestLogger(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		start := time.Now()
 
-		// Создаем обертку для ResponseWriter, чтобы отслеживать статус и размер ответа
+		// Создаем обертку для ResponseWriter, чтобы отслеживать статус и размер ответа.
+		// Если обработчик ничего не записал, net/http отправит 200.
 		wrapped := &responseWriter{
 			ResponseWriter: w,
 			status:         http.StatusOK,
@@ -43,12 +64,18 @@ func RequestLogger(next http.Handler) http.Handler {
 		next.ServeHTTP(wrapped, r)
 
 		// Логируем информацию о запросе и ответе
-		logger.Info().
+		event := logger.Info().
 			Str("method", r.Method).
 			Str("uri", r.RequestURI).
 			Int("status", wrapped.status).
 			Int("size", wrapped.size).
-			Dur("duration", time.Since(start)).
-			Msg("HTTP request processed")
+			Dur("duration", time.Since(start))
+		if requestID := GetRequestID(r.Context()); requestID != "" {
+			event = event.Str("request_id", requestID)
+		}
+		if wrapped.writeErr != nil {
+			event = event.AnErr("write_error", wrapped.writeErr)
+		}
+		event.Msg("HTTP request processed")
 	})
 }
@@ -0,0 +1,66 @@
+package middleware
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/m-molecula741/shortener/internal/app/logger"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// failingWriter возвращает ошибку после записи части данных
+type failingWriter struct {
+	*httptest.ResponseRecorder
+}
+
+func (w failingWriter) Write(b []byte) (int, error) {
+	return len(b) / 2, errors.New("connection reset")
+}
+
+func TestResponseWriter(t *testing.T) {
+	t.Run("первый WriteHeader определяет статус", func(t *testing.T) {
+		rw := &responseWriter{ResponseWriter: httptest.NewRecorder(), status: http.StatusOK}
+		rw.WriteHeader(http.StatusCreated)
+		rw.WriteHeader(http.StatusInternalServerError)
+		rw.Write([]byte("ok"))
+
+		assert.Equal(t, http.StatusCreated, rw.status)
+		assert.Equal(t, 2, rw.size)
+		assert.NoError(t, rw.writeErr)
+	})
+
+	t.Run("WriteHeader после Write не меняет статус", func(t *testing.T) {
+		rw := &responseWriter{ResponseWriter: httptest.NewRecorder(), status: http.StatusOK}
+		rw.Write([]byte("body"))
+		rw.WriteHeader(http.StatusInternalServerError)
+
+		assert.Equal(t, http.StatusOK, rw.status)
+	})
+
+	t.Run("ошибка записи сохраняется", func(t *testing.T) {
+		rw := &responseWriter{ResponseWriter: failingWriter{httptest.NewRecorder()}, status: http.StatusOK}
+		n, err := rw.Write([]byte("body"))
+
+		assert.Error(t, err)
+		assert.Equal(t, 2, n)
+		assert.Equal(t, 2, rw.size)
+		assert.EqualError(t, rw.writeErr, "connection reset")
+	})
+}
+
+func TestRequestLogger_RequestID(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, logger.Init(&buf, "info", true))
+	t.Cleanup(func() { logger.Init(nil, "", false) })
+
+	handler := RequestID(RequestLogger(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})))
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set(RequestIDHeader, "req-42")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	assert.Contains(t, buf.String(), `"request_id":"req-42"`)
+}
@@ -0,0 +1,110 @@
+package middleware
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// unmatchedRoute - метка маршрута для запросов, не попавших ни в один маршрут chi.
+// Сырой путь в метке не используется: иначе сканеры создают неограниченное число рядов.
+const unmatchedRoute = "unmatched"
+
+// NewMetricsMiddleware создает middleware, учитывающий запросы в счетчике shortener_http_requests_total
+// с метками method, route и status и их длительность в гистограмме shortener_http_request_duration_seconds
+// с метками method и route. route - шаблон маршрута chi, например /api/user/urls/{shortID},
+// поэтому middleware нужно подключать к роутеру chi, а не оборачивать им роутер.
+func NewMetricsMiddleware(registerer prometheus.Registerer) (func(http.Handler) http.Handler, error) {
+	requests := prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "shortener_http_requests_total",
+		Help: "Number of HTTP requests by method, route pattern and status code.",
+	}, []string{"method", "route", "status"})
+	duration := prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "shortener_http_request_duration_seconds",
+		Help:    "Duration of HTTP requests by method and route pattern.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"method", "route"})
+
+	if err := registerer.Register(requests); err != nil {
+		var alreadyRegistered prometheus.AlreadyRegisteredError
+		if !errors.As(err, &alreadyRegistered) {
+			return nil, err
+		}
+		requests = alreadyRegistered.ExistingCollector.(*prometheus.CounterVec)
+	}
+	if err := registerer.Register(duration); err != nil {
+		var alreadyRegistered prometheus.AlreadyRegisteredError
+		if !errors.As(err, &alreadyRegistered) {
+			return nil, err
+		}
+		duration = alreadyRegistered.ExistingCollector.(*prometheus.HistogramVec)
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+
+			wrapped, ok := w.(*responseWriter)
+			if !ok {
+				wrapped = &responseWriter{
+					ResponseWriter: w,
+					status:         http.StatusOK,
+				}
+			}
+
+			next.ServeHTTP(wrapped, r)
+
+			// Шаблон маршрута известен только после того, как chi сопоставил запрос
+			route := unmatchedRoute
+			if rctx := chi.RouteContext(r.Context()); rctx != nil && rctx.RoutePattern() != "" {
+				route = rctx.RoutePattern()
+			}
+
+			requests.WithLabelValues(r.Method, route, strconv.Itoa(wrapped.status)).Inc()
+			duration.WithLabelValues(r.Method, route).Observe(time.Since(start).Seconds())
+		})
+	}, nil
+}
+
+// NewStatusClassMetrics создает middleware, считающий ответы по классам статуса (2xx, 3xx, 4xx, 5xx)
+// в счетчике shortener_http_responses_total с меткой class.
+// Если запрос уже обернут RequestLogger, используется статус, сохраненный его responseWriter.
+func NewStatusClassMetrics(registerer prometheus.Registerer) (func(http.Handler) http.Handler, error) {
+	responses := prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "shortener_http_responses_total",
+		Help: "Number of HTTP responses by status class.",
+	}, []string{"class"})
+
+	if err := registerer.Register(responses); err != nil {
+		var alreadyRegistered prometheus.AlreadyRegisteredError
+		if !errors.As(err, &alreadyRegistered) {
+			return nil, err
+		}
+		responses = alreadyRegistered.ExistingCollector.(*prometheus.CounterVec)
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			wrapped, ok := w.(*responseWriter)
+			if !ok {
+				wrapped = &responseWriter{
+					ResponseWriter: w,
+					status:         http.StatusOK,
+				}
+			}
+
+			next.ServeHTTP(wrapped, r)
+
+			responses.WithLabelValues(statusClass(wrapped.status)).Inc()
+		})
+	}, nil
+}
+
+// statusClass возвращает класс статуса ответа, например 3xx для 307
+func statusClass(status int) string {
+	return strconv.Itoa(status/100) + "xx"
+}
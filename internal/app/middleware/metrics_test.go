@@ -0,0 +1,100 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStatusClassMetrics(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	metrics, err := NewStatusClassMetrics(registry)
+	require.NoError(t, err)
+
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/redirect":
+			http.Redirect(w, r, "https://example.com", http.StatusTemporaryRedirect)
+		case "/missing":
+			http.NotFound(w, r)
+		case "/fail":
+			w.WriteHeader(http.StatusInternalServerError)
+		default:
+			w.Write([]byte("ok"))
+		}
+	})
+
+	// Запросы через RequestLogger и без него должны учитываться одинаково
+	chains := []http.Handler{
+		metrics(handler),
+		RequestLogger(metrics(handler)),
+	}
+	for _, chain := range chains {
+		for _, path := range []string{"/", "/redirect", "/redirect", "/missing", "/fail"} {
+			chain.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+		}
+	}
+
+	expected := `
+# HELP shortener_http_responses_total Number of HTTP responses by status class.
+# TYPE shortener_http_responses_total counter
+shortener_http_responses_total{class="2xx"} 2
+shortener_http_responses_total{class="3xx"} 4
+shortener_http_responses_total{class="4xx"} 2
+shortener_http_responses_total{class="5xx"} 2
+`
+	assert.NoError(t, testutil.GatherAndCompare(registry, strings.NewReader(expected)))
+}
+
+func TestMetricsMiddleware(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	metrics, err := NewMetricsMiddleware(registry)
+	require.NoError(t, err)
+
+	router := chi.NewRouter()
+	router.Use(metrics)
+	router.Get("/users/{id}", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	})
+	router.Route("/api", func(r chi.Router) {
+		r.Post("/items", func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusCreated)
+		})
+	})
+
+	requests := []struct {
+		method string
+		path   string
+	}{
+		{http.MethodGet, "/users/1"},
+		{http.MethodGet, "/users/2"},
+		{http.MethodPost, "/api/items"},
+		{http.MethodGet, "/missing"},
+	}
+	for _, req := range requests {
+		router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(req.method, req.path, nil))
+	}
+
+	// Разные ID попадают в один ряд с шаблоном маршрута, неизвестные пути - в unmatched
+	expected := `
+# HELP shortener_http_requests_total Number of HTTP requests by method, route pattern and status code.
+# TYPE shortener_http_requests_total counter
+shortener_http_requests_total{method="GET",route="/users/{id}",status="200"} 2
+shortener_http_requests_total{method="GET",route="unmatched",status="404"} 1
+shortener_http_requests_total{method="POST",route="/api/items",status="201"} 1
+`
+	assert.NoError(t, testutil.GatherAndCompare(registry, strings.NewReader(expected), "shortener_http_requests_total"))
+
+	assert.Equal(t, 3, testutil.CollectAndCount(registry, "shortener_http_request_duration_seconds"))
+
+	// Повторная регистрация в том же реестре использует существующие метрики
+	_, err = NewMetricsMiddleware(registry)
+	assert.NoError(t, err)
+}
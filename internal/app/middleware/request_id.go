@@ -0,0 +1,52 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/google/uuid"
+)
+
+// RequestIDHeader - заголовок с ID запроса
+const RequestIDHeader = "X-Request-Id"
+
+// maxRequestIDLength - максимальная длина ID запроса, принимаемого от клиента
+const maxRequestIDLength = 128
+
+const requestIDKey contextKey = "requestID"
+
+// RequestID middleware берет ID запроса из заголовка X-Request-Id или генерирует UUID,
+// сохраняет его в контексте и возвращает в заголовке ответа.
+// Должен применяться снаружи RequestLogger, чтобы ID попал в лог запроса.
+func RequestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestID := r.Header.Get(RequestIDHeader)
+		if !isValidRequestID(requestID) {
+			requestID = uuid.New().String()
+		}
+
+		w.Header().Set(RequestIDHeader, requestID)
+		ctx := context.WithValue(r.Context(), requestIDKey, requestID)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// GetRequestID возвращает ID запроса из контекста или пустую строку
+func GetRequestID(ctx context.Context) string {
+	requestID, _ := ctx.Value(requestIDKey).(string)
+	return requestID
+}
+
+// isValidRequestID проверяет ID от клиента: непустой, ограниченной длины и из печатных
+// ASCII символов, чтобы не раздувать логи и не допускать в них управляющие символы
+func isValidRequestID(requestID string) bool {
+	if requestID == "" || len(requestID) > maxRequestIDLength {
+		return false
+	}
+	for i := 0; i < len(requestID); i++ {
+		if requestID[i] < '!' || requestID[i] > '~' {
+			return false
+		}
+	}
+	return true
+}
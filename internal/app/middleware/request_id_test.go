@@ -0,0 +1,71 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRequestID(t *testing.T) {
+	tests := []struct {
+		name      string
+		header    string
+		expected  string
+		generated bool
+	}{
+		{
+			name:     "ID из заголовка возвращается в ответе",
+			header:   "req-42",
+			expected: "req-42",
+		},
+		{
+			name:      "без заголовка генерируется UUID",
+			generated: true,
+		},
+		{
+			name:      "ID с управляющими символами заменяется",
+			header:    "req\x1b[31m",
+			generated: true,
+		},
+		{
+			name:      "слишком длинный ID заменяется",
+			header:    strings.Repeat("a", maxRequestIDLength+1),
+			generated: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var fromContext string
+			handler := RequestID(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				fromContext = GetRequestID(r.Context())
+			}))
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.header != "" {
+				req.Header.Set(RequestIDHeader, tt.header)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			requestID := rec.Header().Get(RequestIDHeader)
+			assert.Equal(t, requestID, fromContext)
+			if tt.generated {
+				_, err := uuid.Parse(requestID)
+				require.NoError(t, err)
+				return
+			}
+			assert.Equal(t, tt.expected, requestID)
+		})
+	}
+}
+
+func TestGetRequestID_Empty(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	assert.Empty(t, GetRequestID(req.Context()))
+}
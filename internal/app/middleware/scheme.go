@@ -0,0 +1,97 @@
+// Package middleware предоставляет middleware компоненты для HTTP сервера
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+)
+
+const schemeKey contextKey = "scheme"
+
+// Схемы запроса
+const (
+	SchemeHTTP  = "http"
+	SchemeHTTPS = "https"
+)
+
+// ParseCIDRList разбирает список подсетей в формате CIDR, разделенных запятыми
+func ParseCIDRList(list string) ([]*net.IPNet, error) {
+	var nets []*net.IPNet
+	for _, item := range strings.Split(list, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+
+		_, ipNet, err := net.ParseCIDR(item)
+		if err != nil {
+			return nil, fmt.Errorf("invalid CIDR %q: %w", item, err)
+		}
+		nets = append(nets, ipNet)
+	}
+	return nets, nil
+}
+
+// NewForwardedSchemeMiddleware создает middleware, определяющий фактическую схему запроса.
+// Заголовок X-Forwarded-Proto учитывается только для запросов от доверенных прокси,
+// иначе схема определяется по наличию TLS соединения.
+func NewForwardedSchemeMiddleware(trustedProxies []*net.IPNet) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			scheme := SchemeHTTP
+			if r.TLS != nil {
+				scheme = SchemeHTTPS
+			}
+
+			if isTrustedRemote(r.RemoteAddr, trustedProxies) {
+				// При цепочке прокси первым указан протокол исходного клиента
+				forwarded := strings.TrimSpace(strings.Split(r.Header.Get("X-Forwarded-Proto"), ",")[0])
+				switch strings.ToLower(forwarded) {
+				case SchemeHTTP:
+					scheme = SchemeHTTP
+				case SchemeHTTPS:
+					scheme = SchemeHTTPS
+				}
+			}
+
+			ctx := context.WithValue(r.Context(), schemeKey, scheme)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// GetSchemeFromContext возвращает фактическую схему запроса.
+// Если middleware не применялся, возвращается http.
+func GetSchemeFromContext(ctx context.Context) string {
+	if scheme, ok := ctx.Value(schemeKey).(string); ok {
+		return scheme
+	}
+	return SchemeHTTP
+}
+
+// isTrustedRemote проверяет, входит ли адрес клиента в одну из доверенных подсетей
+func isTrustedRemote(remoteAddr string, trusted []*net.IPNet) bool {
+	if len(trusted) == 0 {
+		return false
+	}
+
+	host, _, err := net.SplitHostPort(remoteAddr)
+	if err != nil {
+		host = remoteAddr
+	}
+
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return false
+	}
+
+	for _, ipNet := range trusted {
+		if ipNet.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,70 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestForwardedSchemeMiddleware(t *testing.T) {
+	trusted, err := ParseCIDRList("10.0.0.0/8, 192.168.1.0/24")
+	require.NoError(t, err)
+
+	tests := []struct {
+		name           string
+		remoteAddr     string
+		forwardedProto string
+		expectedScheme string
+	}{
+		{
+			name:           "доверенный прокси передает https",
+			remoteAddr:     "10.1.2.3:5000",
+			forwardedProto: "https",
+			expectedScheme: SchemeHTTPS,
+		},
+		{
+			name:           "недоверенный клиент не может подменить схему",
+			remoteAddr:     "203.0.113.5:5000",
+			forwardedProto: "https",
+			expectedScheme: SchemeHTTP,
+		},
+		{
+			name:           "цепочка прокси",
+			remoteAddr:     "192.168.1.10:5000",
+			forwardedProto: "https, http",
+			expectedScheme: SchemeHTTPS,
+		},
+		{
+			name:           "без заголовка",
+			remoteAddr:     "10.1.2.3:5000",
+			expectedScheme: SchemeHTTP,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var scheme string
+			handler := NewForwardedSchemeMiddleware(trusted)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				scheme = GetSchemeFromContext(r.Context())
+			}))
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.RemoteAddr = tt.remoteAddr
+			if tt.forwardedProto != "" {
+				req.Header.Set("X-Forwarded-Proto", tt.forwardedProto)
+			}
+
+			handler.ServeHTTP(httptest.NewRecorder(), req)
+
+			assert.Equal(t, tt.expectedScheme, scheme)
+		})
+	}
+}
+
+func TestParseCIDRList_Invalid(t *testing.T) {
+	_, err := ParseCIDRList("10.0.0.0/8,not-a-cidr")
+	assert.Error(t, err)
+}
@@ -0,0 +1,24 @@
+// Package middleware предоставляет middleware компоненты для HTTP сервера
+package middleware
+
+import (
+	"net"
+	"net/http"
+	"strings"
+)
+
+// NewTrustedSubnetMiddleware создает middleware, пропускающий только запросы,
+// у которых IP из заголовка X-Real-IP входит в доверенную подсеть.
+// Если подсеть не задана, все запросы отклоняются с кодом 403.
+func NewTrustedSubnetMiddleware(subnet *net.IPNet) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ip := net.ParseIP(strings.TrimSpace(r.Header.Get("X-Real-IP")))
+			if subnet == nil || ip == nil || !subnet.Contains(ip) {
+				http.Error(w, "Forbidden", http.StatusForbidden)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
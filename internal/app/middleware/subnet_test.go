@@ -0,0 +1,45 @@
+package middleware
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTrustedSubnetMiddleware(t *testing.T) {
+	_, subnet, err := net.ParseCIDR("10.0.0.0/8")
+	require.NoError(t, err)
+
+	tests := []struct {
+		name           string
+		subnet         *net.IPNet
+		realIP         string
+		expectedStatus int
+	}{
+		{name: "IP в подсети", subnet: subnet, realIP: "10.1.2.3", expectedStatus: http.StatusOK},
+		{name: "IP вне подсети", subnet: subnet, realIP: "192.168.1.1", expectedStatus: http.StatusForbidden},
+		{name: "без заголовка", subnet: subnet, realIP: "", expectedStatus: http.StatusForbidden},
+		{name: "подсеть не задана", subnet: nil, realIP: "10.1.2.3", expectedStatus: http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := NewTrustedSubnetMiddleware(tt.subnet)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusOK)
+			}))
+
+			req := httptest.NewRequest(http.MethodPost, "/api/internal/gc", nil)
+			if tt.realIP != "" {
+				req.Header.Set("X-Real-IP", tt.realIP)
+			}
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+
+			assert.Equal(t, tt.expectedStatus, rr.Code)
+		})
+	}
+}
@@ -0,0 +1,207 @@
+// Package storage предоставляет различные реализации хранилища URL
+package storage
+
+import (
+	"bytes"
+	"compress/zlib"
+	"context"
+	"encoding/base64"
+	"io"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/m-molecula741/shortener/internal/app/usecase"
+)
+
+// compressedPrefix помечает сжатые значения, чтобы записи, сохраненные
+// до включения сжатия, и короткие несжатые URL читались без изменений.
+// Нулевой байт не встречается в URL, поэтому сжатое значение всегда двоичное
+// и хранилища сохраняют его как байты (в PostgreSQL - в столбце original_url_bin).
+const compressedPrefix = "\x00zlib:"
+
+// legacyCompressedPrefix помечает сжатые значения прежнего формата - zlib в base64,
+// сохраненные в текстовом столбце. Они только читаются.
+const legacyCompressedPrefix = "zlib:"
+
+// CompressedStorage сжимает оригинальные URL zlib перед сохранением во вложенное хранилище.
+// Сжатый URL сохраняется, только если он короче исходного, остальные хранятся как есть.
+//
+// Дубликаты ищутся по исходному URL: он передается вложенному хранилищу ключом
+// дедупликации. Для записей прежнего формата хеш в PostgreSQL посчитан от сжатого
+// значения, поэтому повторное сокращение такого URL создает новую ссылку.
+// При совместном использовании с EncryptedStorage сжатие должно выполняться первым,
+// то есть CompressedStorage оборачивает EncryptedStorage, а не наоборот.
+type CompressedStorage struct {
+	next usecase.URLStorage
+}
+
+// NewCompressedStorage создает декоратор хранилища со сжатием оригинальных URL
+func NewCompressedStorage(next usecase.URLStorage) *CompressedStorage {
+	return &CompressedStorage{next: next}
+}
+
+// compress сжимает URL, если это уменьшает размер хранимого значения
+func (s *CompressedStorage) compress(url string) string {
+	var buf bytes.Buffer
+	zw, _ := zlib.NewWriterLevel(&buf, zlib.BestCompression)
+	zw.Write([]byte(url))
+	zw.Close()
+
+	compressed := compressedPrefix + buf.String()
+	// URL, похожий на сжатое значение, сжимаем всегда, чтобы не перепутать его при чтении
+	if len(compressed) >= len(url) && !strings.HasPrefix(url, compressedPrefix) && !strings.HasPrefix(url, legacyCompressedPrefix) {
+		return url
+	}
+	return compressed
+}
+
+// pack сжимает URL, сохраняя исходный URL ключом дедупликации
+func (s *CompressedStorage) pack(url usecase.URLPair) usecase.URLPair {
+	compressed := s.compress(url.OriginalURL)
+	if compressed != url.OriginalURL {
+		url.DedupKey = url.Key()
+		url.OriginalURL = compressed
+	}
+	return url
+}
+
+// isBinary проверяет, что значение нельзя сохранить как текст: в JSON невалидный UTF-8
+// заменяется, а текстовый столбец PostgreSQL не принимает нулевой байт
+func isBinary(value string) bool {
+	return !utf8.ValidString(value) || strings.IndexByte(value, 0) >= 0
+}
+
+// decompress распаковывает URL; значения без префикса возвращаются как есть
+func (s *CompressedStorage) decompress(value string) (string, error) {
+	var data []byte
+	switch {
+	case strings.HasPrefix(value, compressedPrefix):
+		data = []byte(strings.TrimPrefix(value, compressedPrefix))
+	case strings.HasPrefix(value, legacyCompressedPrefix):
+		var err error
+		if data, err = base64.RawURLEncoding.DecodeString(strings.TrimPrefix(value, legacyCompressedPrefix)); err != nil {
+			return "", err
+		}
+	default:
+		return value, nil
+	}
+
+	zr, err := zlib.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return "", err
+	}
+	defer zr.Close()
+
+	url, err := io.ReadAll(zr)
+	if err != nil {
+		return "", err
+	}
+	return string(url), nil
+}
+
+// Save сжимает и сохраняет URL
+func (s *CompressedStorage) Save(ctx context.Context, url usecase.URLPair) error {
+	return s.next.Save(ctx, s.pack(url))
+}
+
+// Get получает и распаковывает URL
+func (s *CompressedStorage) Get(ctx context.Context, shortID string) (string, error) {
+	value, err := s.next.Get(ctx, shortID)
+	if err != nil {
+		return "", err
+	}
+	return s.decompress(value)
+}
+
+// SaveBatch сжимает и сохраняет множество URL
+func (s *CompressedStorage) SaveBatch(ctx context.Context, urls []usecase.URLPair) error {
+	compressed := make([]usecase.URLPair, len(urls))
+	for i, url := range urls {
+		compressed[i] = s.pack(url)
+	}
+	return s.next.SaveBatch(ctx, compressed)
+}
+
+// GetUserURLs получает URL пользователя и распаковывает их
+func (s *CompressedStorage) GetUserURLs(ctx context.Context, userID string, limit, offset int) ([]usecase.UserURL, int, error) {
+	urls, total, err := s.next.GetUserURLs(ctx, userID, limit, offset)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	for i := range urls {
+		if urls[i].OriginalURL, err = s.decompress(urls[i].OriginalURL); err != nil {
+			return nil, 0, err
+		}
+	}
+	return urls, total, nil
+}
+
+// GetUserURL получает URL пользователя и распаковывает его
+func (s *CompressedStorage) GetUserURL(ctx context.Context, userID, shortID string) (usecase.UserURL, error) {
+	url, err := s.next.GetUserURL(ctx, userID, shortID)
+	if err != nil {
+		return usecase.UserURL{}, err
+	}
+
+	if url.OriginalURL, err = s.decompress(url.OriginalURL); err != nil {
+		return usecase.UserURL{}, err
+	}
+	return url, nil
+}
+
+// DeleteAllUserURLs помечает все URL пользователя как удаленные
+func (s *CompressedStorage) DeleteAllUserURLs(ctx context.Context, userID string) (int64, error) {
+	return s.next.DeleteAllUserURLs(ctx, userID)
+}
+
+// BatchDeleteUserURLs помечает URL пользователя как удаленные
+func (s *CompressedStorage) BatchDeleteUserURLs(ctx context.Context, userID string, shortIDs []string) error {
+	return s.next.BatchDeleteUserURLs(ctx, userID, shortIDs)
+}
+
+// DeleteUserURLsWithResult удаляет URL пользователя и возвращает итог по каждому ID
+func (s *CompressedStorage) DeleteUserURLsWithResult(ctx context.Context, userID string, shortIDs []string) (usecase.DeleteResult, error) {
+	return s.next.DeleteUserURLsWithResult(ctx, userID, shortIDs)
+}
+
+// PurgeExpired удаляет истекшие и удаленные ссылки
+func (s *CompressedStorage) PurgeExpired(ctx context.Context, before time.Time) (int64, error) {
+	return s.next.PurgeExpired(ctx, before)
+}
+
+// Reset удаляет все ссылки
+func (s *CompressedStorage) Reset(ctx context.Context) (int64, error) {
+	return s.next.Reset(ctx)
+}
+
+// GetURLOwner получает создателя URL
+func (s *CompressedStorage) GetURLOwner(ctx context.Context, shortID string) (usecase.URLOwner, error) {
+	return s.next.GetURLOwner(ctx, shortID)
+}
+
+// Stats получает количество ссылок и пользователей
+func (s *CompressedStorage) Stats(ctx context.Context) (int, int, error) {
+	return s.next.Stats(ctx)
+}
+
+// RecentPublicURLs получает последние публичные URL и распаковывает их
+func (s *CompressedStorage) RecentPublicURLs(ctx context.Context, limit int) ([]usecase.PublicURL, error) {
+	urls, err := s.next.RecentPublicURLs(ctx, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range urls {
+		if urls[i].OriginalURL, err = s.decompress(urls[i].OriginalURL); err != nil {
+			return nil, err
+		}
+	}
+	return urls, nil
+}
+
+// IncrementVisits увеличивает счетчик переходов по короткому URL
+func (s *CompressedStorage) IncrementVisits(ctx context.Context, shortID string) error {
+	return s.next.IncrementVisits(ctx, shortID)
+}
@@ -0,0 +1,116 @@
+package storage
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/m-molecula741/shortener/internal/app/usecase"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCompressedStorage(t *testing.T) {
+	backend, err := NewInMemoryStorage(filepath.Join(t.TempDir(), "urls.json"), Options{})
+	require.NoError(t, err)
+
+	store := NewCompressedStorage(backend)
+
+	longURL := "https://example.com/landing?" + strings.Repeat("utm_source=newsletter&utm_medium=email&", 20)
+
+	require.NoError(t, store.Save(context.Background(), usecase.URLPair{ShortID: "abc123", OriginalURL: longURL}))
+
+	// Во вложенном хранилище длинный URL лежит в сжатом виде
+	raw, err := backend.Get(context.Background(), "abc123")
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(raw, compressedPrefix))
+	assert.Less(t, len(raw), len(longURL))
+
+	got, err := store.Get(context.Background(), "abc123")
+	require.NoError(t, err)
+	assert.Equal(t, longURL, got)
+
+	// Дубликаты ищутся по исходному URL
+	err = store.Save(context.Background(), usecase.URLPair{ShortID: "def456", OriginalURL: longURL})
+	conflictErr, isConflict := usecase.IsURLConflict(err)
+	require.True(t, isConflict)
+	assert.Equal(t, "abc123", conflictErr.ExistingShortURL)
+}
+
+func TestCompressedStorage_BinaryBackup(t *testing.T) {
+	filePath := filepath.Join(t.TempDir(), "urls.json")
+	backend, err := NewInMemoryStorage(filePath, Options{})
+	require.NoError(t, err)
+
+	longURL := "https://example.com/landing?" + strings.Repeat("utm_source=newsletter&utm_medium=email&", 20)
+	require.NoError(t, NewCompressedStorage(backend).Save(context.Background(), usecase.URLPair{ShortID: "abc123", OriginalURL: longURL}))
+	require.NoError(t, backend.Backup())
+
+	// Сжатое значение двоичное и переживает сохранение в JSON без искажений
+	backend, err = NewInMemoryStorage(filePath, Options{})
+	require.NoError(t, err)
+	store := NewCompressedStorage(backend)
+
+	got, err := store.Get(context.Background(), "abc123")
+	require.NoError(t, err)
+	assert.Equal(t, longURL, got)
+
+	err = store.Save(context.Background(), usecase.URLPair{ShortID: "def456", OriginalURL: longURL})
+	_, isConflict := usecase.IsURLConflict(err)
+	assert.True(t, isConflict)
+}
+
+func TestCompressedStorage_WithEncryption(t *testing.T) {
+	backend, err := NewInMemoryStorage(filepath.Join(t.TempDir(), "urls.json"), Options{})
+	require.NoError(t, err)
+	encrypted, err := NewEncryptedStorage(backend, "test-key")
+	require.NoError(t, err)
+	store := NewCompressedStorage(encrypted)
+
+	longURL := "https://example.com/landing?" + strings.Repeat("utm_source=newsletter&utm_medium=email&", 20)
+	require.NoError(t, store.Save(context.Background(), usecase.URLPair{ShortID: "abc123", OriginalURL: longURL}))
+
+	got, err := store.Get(context.Background(), "abc123")
+	require.NoError(t, err)
+	assert.Equal(t, longURL, got)
+
+	// HMAC считается от исходного URL, а не от сжатого значения
+	err = store.Save(context.Background(), usecase.URLPair{ShortID: "def456", OriginalURL: longURL})
+	conflictErr, isConflict := usecase.IsURLConflict(err)
+	require.True(t, isConflict)
+	assert.Equal(t, "abc123", conflictErr.ExistingShortURL)
+}
+
+func TestCompressedStorage_ShortAndLegacyRecords(t *testing.T) {
+	backend, err := NewInMemoryStorage(filepath.Join(t.TempDir(), "urls.json"), Options{})
+	require.NoError(t, err)
+	require.NoError(t, backend.SaveBatch(context.Background(), []usecase.URLPair{
+		{ShortID: "legacy1", OriginalURL: "https://legacy.example.com", UserID: "user1"},
+		// Сжатое значение прежнего формата: zlib в base64
+		{ShortID: "legacy2", OriginalURL: legacyCompressedPrefix + "eNrKKCkpKLbS10-tSMwtyEnVS87P1c9JTU9MrgQMAIOHCck"},
+	}))
+
+	store := NewCompressedStorage(backend)
+
+	// Короткий URL сжатием не уменьшается и хранится как есть
+	require.NoError(t, store.Save(context.Background(), usecase.URLPair{ShortID: "short1", OriginalURL: "https://a.io"}))
+	raw, err := backend.Get(context.Background(), "short1")
+	require.NoError(t, err)
+	assert.Equal(t, "https://a.io", raw)
+
+	// URL, совпадающий по виду со сжатым значением, читается без искажений
+	require.NoError(t, store.Save(context.Background(), usecase.URLPair{ShortID: "prefix1", OriginalURL: legacyCompressedPrefix + "x"}))
+	got, err := store.Get(context.Background(), "prefix1")
+	require.NoError(t, err)
+	assert.Equal(t, legacyCompressedPrefix+"x", got)
+
+	got, err = store.Get(context.Background(), "legacy2")
+	require.NoError(t, err)
+	assert.Equal(t, "https://example.com/legacy", got)
+
+	urls, _, err := store.GetUserURLs(context.Background(), "user1", 0, 0)
+	require.NoError(t, err)
+	require.Len(t, urls, 1)
+	assert.Equal(t, "https://legacy.example.com", urls[0].OriginalURL)
+}
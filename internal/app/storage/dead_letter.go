@@ -0,0 +1,63 @@
+// Package storage предоставляет различные реализации хранилища URL
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/m-molecula741/shortener/internal/app/usecase"
+)
+
+// DeadLetterRecord представляет неудавшийся запрос на удаление в файле dead-letter
+type DeadLetterRecord struct {
+	usecase.DeleteRequest
+	Error    string    `json:"error"`
+	FailedAt time.Time `json:"failed_at"`
+}
+
+// FileDeadLetterQueue дописывает неудавшиеся запросы на удаление в файл по одной
+// JSON-записи на строку. Файл можно изучить и выполнить запросы повторно.
+type FileDeadLetterQueue struct {
+	mu       sync.Mutex
+	filePath string
+}
+
+// NewFileDeadLetterQueue создает очередь dead-letter в файле filePath
+func NewFileDeadLetterQueue(filePath string) *FileDeadLetterQueue {
+	return &FileDeadLetterQueue{filePath: filePath}
+}
+
+// Put дописывает запрос и причину ошибки в конец файла
+func (q *FileDeadLetterQueue) Put(ctx context.Context, req usecase.DeleteRequest, cause error) error {
+	record := DeadLetterRecord{
+		DeleteRequest: req,
+		FailedAt:      time.Now().UTC(),
+	}
+	if cause != nil {
+		record.Error = cause.Error()
+	}
+
+	data, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("cannot marshal dead-letter record: %w", err)
+	}
+	data = append(data, '\n')
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	file, err := os.OpenFile(q.filePath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("cannot open dead-letter file: %w", err)
+	}
+	defer file.Close()
+
+	if _, err := file.Write(data); err != nil {
+		return fmt.Errorf("cannot write dead-letter file: %w", err)
+	}
+	return nil
+}
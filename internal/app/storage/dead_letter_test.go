@@ -0,0 +1,49 @@
+package storage
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/m-molecula741/shortener/internal/app/usecase"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFileDeadLetterQueue_Put(t *testing.T) {
+	filePath := filepath.Join(t.TempDir(), "dead_letters.jsonl")
+	queue := NewFileDeadLetterQueue(filePath)
+
+	requests := []usecase.DeleteRequest{
+		{UserID: "user1", ShortIDs: []string{"abc123"}},
+		{UserID: "user2", ShortIDs: []string{"def456", "ghi789"}},
+	}
+	for _, req := range requests {
+		require.NoError(t, queue.Put(context.Background(), req, errors.New("db error")))
+	}
+
+	file, err := os.Open(filePath)
+	require.NoError(t, err)
+	defer file.Close()
+
+	// Каждая запись на отдельной строке, чтобы файл можно было дописывать и разбирать построчно
+	var records []DeadLetterRecord
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var record DeadLetterRecord
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &record))
+		records = append(records, record)
+	}
+	require.NoError(t, scanner.Err())
+
+	require.Len(t, records, 2)
+	for i, record := range records {
+		assert.Equal(t, requests[i], record.DeleteRequest)
+		assert.Equal(t, "db error", record.Error)
+		assert.False(t, record.FailedAt.IsZero())
+	}
+}
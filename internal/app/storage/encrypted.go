@@ -0,0 +1,263 @@
+// Package storage предоставляет различные реализации хранилища URL
+package storage
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/m-molecula741/shortener/internal/app/usecase"
+)
+
+// encryptedPrefix помечает зашифрованные значения, чтобы записи, сохраненные
+// до включения шифрования, продолжали читаться без изменений
+const encryptedPrefix = "enc:"
+
+// EncryptedStorage шифрует оригинальные URL перед сохранением во вложенное хранилище.
+// Короткий ID хранится открыто, чтобы по нему можно было искать.
+//
+// Nonce случайный, поэтому одинаковые URL дают разный шифротекст и по нему нельзя
+// понять, что ссылки совпадают. Дубликаты ищутся по ключу дедупликации - HMAC
+// от открытого текста, - который хранилище сохраняет отдельно в виде хеша.
+//
+// URL шифруются первым ключом связки, расшифровываются любым из ключей,
+// поэтому при смене ключа прежний переносится в список предыдущих. HMAC считается
+// текущим ключом, так что после смены ключа повторное сокращение URL, сохраненного
+// под прежним ключом, создает новую ссылку.
+type EncryptedStorage struct {
+	next usecase.URLStorage
+	keys []urlKey
+}
+
+// urlKey содержит шифр и ключ HMAC для дедупликации, полученные из одного секретного ключа
+type urlKey struct {
+	gcm    cipher.AEAD
+	macKey []byte
+}
+
+// dedupKeyPrefix отличает ключи дедупликации зашифрованных URL от открытых URL
+const dedupKeyPrefix = "hmac:"
+
+// ErrEmptyURLKey возвращается, если ключ шифрования URL не задан
+var ErrEmptyURLKey = errors.New("URL encryption key is empty")
+
+// NewEncryptedStorage создает декоратор хранилища с шифрованием AES-GCM.
+// Новые URL шифруются ключом key, а previousKeys используются только для расшифровки.
+func NewEncryptedStorage(next usecase.URLStorage, key string, previousKeys ...string) (*EncryptedStorage, error) {
+	if key == "" {
+		return nil, ErrEmptyURLKey
+	}
+
+	s := &EncryptedStorage{next: next}
+	for _, secret := range append([]string{key}, previousKeys...) {
+		secret = strings.TrimSpace(secret)
+		if secret == "" {
+			continue
+		}
+
+		k, err := newURLKey(secret)
+		if err != nil {
+			return nil, err
+		}
+		s.keys = append(s.keys, k)
+	}
+	return s, nil
+}
+
+// newURLKey выводит ключ шифрования и ключ HMAC из секретного ключа
+func newURLKey(secret string) (urlKey, error) {
+	encKey := sha256.Sum256([]byte("url-encryption:" + secret))
+	macKey := sha256.Sum256([]byte("url-dedup:" + secret))
+
+	block, err := aes.NewCipher(encKey[:])
+	if err != nil {
+		return urlKey{}, err
+	}
+
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return urlKey{}, err
+	}
+
+	return urlKey{gcm: gcm, macKey: macKey[:]}, nil
+}
+
+// encrypt шифрует URL текущим ключом со случайным nonce
+func (s *EncryptedStorage) encrypt(plaintext string) (string, error) {
+	key := s.keys[0]
+
+	nonce := make([]byte, key.gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", fmt.Errorf("cannot generate nonce: %w", err)
+	}
+
+	ciphertext := key.gcm.Seal(nonce, nonce, []byte(plaintext), nil)
+	return encryptedPrefix + base64.RawURLEncoding.EncodeToString(ciphertext), nil
+}
+
+// dedupKey вычисляет HMAC текущим ключом от значения, по которому ищутся дубликаты
+func (s *EncryptedStorage) dedupKey(value string) string {
+	mac := hmac.New(sha256.New, s.keys[0].macKey)
+	mac.Write([]byte(value))
+	return dedupKeyPrefix + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// seal шифрует URL и заменяет ключ дедупликации на HMAC, чтобы открытый текст
+// не попал в хранилище и через него
+func (s *EncryptedStorage) seal(url usecase.URLPair) (usecase.URLPair, error) {
+	encrypted, err := s.encrypt(url.OriginalURL)
+	if err != nil {
+		return usecase.URLPair{}, err
+	}
+
+	url.DedupKey = s.dedupKey(url.Key())
+	url.OriginalURL = encrypted
+	return url, nil
+}
+
+// decrypt расшифровывает URL; значения без префикса возвращаются как есть
+func (s *EncryptedStorage) decrypt(value string) (string, error) {
+	if !strings.HasPrefix(value, encryptedPrefix) {
+		return value, nil
+	}
+
+	data, err := base64.RawURLEncoding.DecodeString(strings.TrimPrefix(value, encryptedPrefix))
+	if err != nil {
+		return "", err
+	}
+
+	// Размер nonce одинаков для всех ключей связки
+	nonceSize := s.keys[0].gcm.NonceSize()
+	if len(data) < nonceSize {
+		return "", errors.New("ciphertext too short")
+	}
+
+	for _, key := range s.keys {
+		plaintext, err := key.gcm.Open(nil, data[:nonceSize], data[nonceSize:], nil)
+		if err == nil {
+			return string(plaintext), nil
+		}
+	}
+	return "", errors.New("cannot decrypt URL with any of the configured keys")
+}
+
+// Save шифрует и сохраняет URL
+func (s *EncryptedStorage) Save(ctx context.Context, url usecase.URLPair) error {
+	url, err := s.seal(url)
+	if err != nil {
+		return err
+	}
+	return s.next.Save(ctx, url)
+}
+
+// Get получает и расшифровывает URL
+func (s *EncryptedStorage) Get(ctx context.Context, shortID string) (string, error) {
+	value, err := s.next.Get(ctx, shortID)
+	if err != nil {
+		return "", err
+	}
+	return s.decrypt(value)
+}
+
+// SaveBatch шифрует и сохраняет множество URL
+func (s *EncryptedStorage) SaveBatch(ctx context.Context, urls []usecase.URLPair) error {
+	encrypted := make([]usecase.URLPair, len(urls))
+	for i, url := range urls {
+		var err error
+		if encrypted[i], err = s.seal(url); err != nil {
+			return err
+		}
+	}
+	return s.next.SaveBatch(ctx, encrypted)
+}
+
+// GetUserURLs получает URL пользователя и расшифровывает их
+func (s *EncryptedStorage) GetUserURLs(ctx context.Context, userID string, limit, offset int) ([]usecase.UserURL, int, error) {
+	urls, total, err := s.next.GetUserURLs(ctx, userID, limit, offset)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	for i := range urls {
+		if urls[i].OriginalURL, err = s.decrypt(urls[i].OriginalURL); err != nil {
+			return nil, 0, err
+		}
+	}
+	return urls, total, nil
+}
+
+// GetUserURL получает URL пользователя и расшифровывает его
+func (s *EncryptedStorage) GetUserURL(ctx context.Context, userID, shortID string) (usecase.UserURL, error) {
+	url, err := s.next.GetUserURL(ctx, userID, shortID)
+	if err != nil {
+		return usecase.UserURL{}, err
+	}
+
+	if url.OriginalURL, err = s.decrypt(url.OriginalURL); err != nil {
+		return usecase.UserURL{}, err
+	}
+	return url, nil
+}
+
+// DeleteAllUserURLs помечает все URL пользователя как удаленные
+func (s *EncryptedStorage) DeleteAllUserURLs(ctx context.Context, userID string) (int64, error) {
+	return s.next.DeleteAllUserURLs(ctx, userID)
+}
+
+// BatchDeleteUserURLs помечает URL пользователя как удаленные
+func (s *EncryptedStorage) BatchDeleteUserURLs(ctx context.Context, userID string, shortIDs []string) error {
+	return s.next.BatchDeleteUserURLs(ctx, userID, shortIDs)
+}
+
+// DeleteUserURLsWithResult удаляет URL пользователя и возвращает итог по каждому ID
+func (s *EncryptedStorage) DeleteUserURLsWithResult(ctx context.Context, userID string, shortIDs []string) (usecase.DeleteResult, error) {
+	return s.next.DeleteUserURLsWithResult(ctx, userID, shortIDs)
+}
+
+// PurgeExpired удаляет истекшие и удаленные ссылки
+func (s *EncryptedStorage) PurgeExpired(ctx context.Context, before time.Time) (int64, error) {
+	return s.next.PurgeExpired(ctx, before)
+}
+
+// Reset удаляет все ссылки
+func (s *EncryptedStorage) Reset(ctx context.Context) (int64, error) {
+	return s.next.Reset(ctx)
+}
+
+// GetURLOwner получает создателя URL
+func (s *EncryptedStorage) GetURLOwner(ctx context.Context, shortID string) (usecase.URLOwner, error) {
+	return s.next.GetURLOwner(ctx, shortID)
+}
+
+// Stats получает количество ссылок и пользователей
+func (s *EncryptedStorage) Stats(ctx context.Context) (int, int, error) {
+	return s.next.Stats(ctx)
+}
+
+// RecentPublicURLs получает последние публичные URL и расшифровывает их
+func (s *EncryptedStorage) RecentPublicURLs(ctx context.Context, limit int) ([]usecase.PublicURL, error) {
+	urls, err := s.next.RecentPublicURLs(ctx, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range urls {
+		if urls[i].OriginalURL, err = s.decrypt(urls[i].OriginalURL); err != nil {
+			return nil, err
+		}
+	}
+	return urls, nil
+}
+
+// IncrementVisits увеличивает счетчик переходов по короткому URL
+func (s *EncryptedStorage) IncrementVisits(ctx context.Context, shortID string) error {
+	return s.next.IncrementVisits(ctx, shortID)
+}
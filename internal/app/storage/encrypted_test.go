@@ -0,0 +1,118 @@
+package storage
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/m-molecula741/shortener/internal/app/usecase"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEncryptedStorage(t *testing.T) {
+	backend, err := NewInMemoryStorage(filepath.Join(t.TempDir(), "urls.json"), Options{})
+	require.NoError(t, err)
+
+	store, err := NewEncryptedStorage(backend, "test-key")
+	require.NoError(t, err)
+
+	const originalURL = "https://example.com/private"
+
+	require.NoError(t, store.Save(context.Background(), usecase.URLPair{ShortID: "abc123", OriginalURL: originalURL}))
+
+	// Во вложенном хранилище URL лежит в зашифрованном виде
+	raw, err := backend.Get(context.Background(), "abc123")
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(raw, encryptedPrefix))
+	assert.NotContains(t, raw, "example.com")
+
+	got, err := store.Get(context.Background(), "abc123")
+	require.NoError(t, err)
+	assert.Equal(t, originalURL, got)
+
+	// Дубликат находится по HMAC, хотя шифротекст каждый раз новый
+	err = store.Save(context.Background(), usecase.URLPair{ShortID: "def456", OriginalURL: originalURL})
+	conflictErr, isConflict := usecase.IsURLConflict(err)
+	require.True(t, isConflict)
+	assert.Equal(t, "abc123", conflictErr.ExistingShortURL)
+
+	first, err := store.encrypt(originalURL)
+	require.NoError(t, err)
+	second, err := store.encrypt(originalURL)
+	require.NoError(t, err)
+	assert.NotEqual(t, first, second, "nonce случайный")
+}
+
+func TestEncryptedStorage_DedupAfterRestart(t *testing.T) {
+	filePath := filepath.Join(t.TempDir(), "urls.json")
+	backend, err := NewInMemoryStorage(filePath, Options{})
+	require.NoError(t, err)
+
+	store, err := NewEncryptedStorage(backend, "test-key")
+	require.NoError(t, err)
+	require.NoError(t, store.Save(context.Background(), usecase.URLPair{ShortID: "abc123", OriginalURL: "https://example.com"}))
+	require.NoError(t, backend.Backup())
+
+	// Ключ дедупликации сохраняется в файле вместе с шифротекстом
+	data, err := os.ReadFile(filePath)
+	require.NoError(t, err)
+	assert.Contains(t, string(data), dedupKeyPrefix)
+	assert.NotContains(t, string(data), "example.com")
+
+	backend, err = NewInMemoryStorage(filePath, Options{})
+	require.NoError(t, err)
+	store, err = NewEncryptedStorage(backend, "test-key")
+	require.NoError(t, err)
+
+	err = store.Save(context.Background(), usecase.URLPair{ShortID: "def456", OriginalURL: "https://example.com"})
+	conflictErr, isConflict := usecase.IsURLConflict(err)
+	require.True(t, isConflict)
+	assert.Equal(t, "abc123", conflictErr.ExistingShortURL)
+}
+
+func TestEncryptedStorage_KeyRotation(t *testing.T) {
+	backend, err := NewInMemoryStorage(filepath.Join(t.TempDir(), "urls.json"), Options{})
+	require.NoError(t, err)
+
+	oldStore, err := NewEncryptedStorage(backend, "old-key")
+	require.NoError(t, err)
+	require.NoError(t, oldStore.Save(context.Background(), usecase.URLPair{ShortID: "old1", OriginalURL: "https://example.com/old"}))
+
+	// Новый ключ шифрует, прежний только расшифровывает
+	store, err := NewEncryptedStorage(backend, "new-key", "old-key")
+	require.NoError(t, err)
+	require.NoError(t, store.Save(context.Background(), usecase.URLPair{ShortID: "new1", OriginalURL: "https://example.com/new"}))
+
+	got, err := store.Get(context.Background(), "old1")
+	require.NoError(t, err)
+	assert.Equal(t, "https://example.com/old", got)
+
+	got, err = store.Get(context.Background(), "new1")
+	require.NoError(t, err)
+	assert.Equal(t, "https://example.com/new", got)
+
+	// Без нового ключа записи, зашифрованные им, не читаются
+	_, err = oldStore.Get(context.Background(), "new1")
+	assert.Error(t, err)
+
+	_, err = NewEncryptedStorage(backend, "")
+	assert.ErrorIs(t, err, ErrEmptyURLKey)
+}
+
+func TestEncryptedStorage_PlaintextRecords(t *testing.T) {
+	backend, err := NewInMemoryStorage(filepath.Join(t.TempDir(), "urls.json"), Options{})
+	require.NoError(t, err)
+	require.NoError(t, backend.SaveBatch(context.Background(), []usecase.URLPair{
+		{ShortID: "legacy1", OriginalURL: "https://legacy.example.com", UserID: "user1"},
+	}))
+
+	store, err := NewEncryptedStorage(backend, "test-key")
+	require.NoError(t, err)
+
+	got, err := store.Get(context.Background(), "legacy1")
+	require.NoError(t, err)
+	assert.Equal(t, "https://legacy.example.com", got)
+}
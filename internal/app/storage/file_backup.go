@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
+	"time"
 )
 
 // URLRecord представляет структуру для хранения URL в файле
@@ -12,12 +13,45 @@ type URLRecord struct {
 	UUID        string `json:"uuid"`
 	ShortURL    string `json:"short_url"`
 	OriginalURL string `json:"original_url"`
+	OriginalBin []byte `json:"original_url_bin,omitempty"` // двоичное значение (сжатый URL), OriginalURL при этом пуст
+	Visits      int64  `json:"visits,omitempty"`           // отсутствует в файлах старого формата и без PersistVisits
+	DedupKey    string `json:"dedup_key,omitempty"`        // ключ дедупликации, если он отличается от OriginalURL
+
+	ExpiresAt *time.Time `json:"expires_at,omitempty"` // время истечения ссылки, nil - бессрочно
+	DeletedAt *time.Time `json:"deleted_at,omitempty"` // время удаления ссылки пользователем, nil - не удалена
+}
+
+// newURLRecord создает запись файла; двоичное значение сохраняется в OriginalBin,
+// так как JSON-строка исказила бы его
+func newURLRecord(uuid, shortURL, value, dedupKey string) URLRecord {
+	record := URLRecord{
+		UUID:     uuid,
+		ShortURL: shortURL,
+		DedupKey: dedupKey,
+	}
+	if isBinary(value) {
+		record.OriginalBin = []byte(value)
+	} else {
+		record.OriginalURL = value
+	}
+	return record
+}
+
+// value возвращает сохраненное значение записи
+func (r URLRecord) value() string {
+	if r.OriginalBin != nil {
+		return string(r.OriginalBin)
+	}
+	return r.OriginalURL
 }
 
 // FileBackup реализует файловое хранилище URL с возможностью бэкапа
 type FileBackup struct {
 	filePath string
 	records  map[string]URLRecord // ключ - shortURL для быстрого поиска существующих записей
+
+	// removed содержит записи, удаленные из памяти, но еще остающиеся в файле до следующего сохранения
+	removed map[string]struct{}
 }
 
 // NewFileBackup создает новый экземпляр FileBackup
@@ -25,35 +59,88 @@ func NewFileBackup(filePath string) *FileBackup {
 	return &FileBackup{
 		filePath: filePath,
 		records:  make(map[string]URLRecord),
+		removed:  make(map[string]struct{}),
 	}
 }
 
 // Clear очищает все записи в памяти
 func (fb *FileBackup) Clear() error {
 	fb.records = make(map[string]URLRecord)
+	fb.removed = make(map[string]struct{})
 	return nil
 }
 
-// SaveURL сохраняет URL в память и в файл
-func (fb *FileBackup) SaveURL(uuid, shortURL, originalURL string) error {
-	// Проверяем, существует ли уже запись с таким shortURL
-	if existingRecord, exists := fb.records[shortURL]; exists {
-		// Если URL изменился, обновляем его, сохраняя старый UUID
-		if existingRecord.OriginalURL != originalURL {
-			existingRecord.OriginalURL = originalURL
-			fb.records[shortURL] = existingRecord
+// Remove удаляет запись; из файла она пропадет при следующем сохранении
+func (fb *FileBackup) Remove(shortURL string) {
+	if _, exists := fb.records[shortURL]; exists {
+		delete(fb.records, shortURL)
+		fb.removed[shortURL] = struct{}{}
+	}
+}
+
+// SaveURL сохраняет запись в память и в файл.
+// Для уже сохраненного shortURL запись обновляется с прежними UUID и счетчиком переходов.
+func (fb *FileBackup) SaveURL(record URLRecord) error {
+	if existingRecord, exists := fb.records[record.ShortURL]; exists {
+		record.UUID = existingRecord.UUID
+		record.Visits = existingRecord.Visits
+	}
+	fb.records[record.ShortURL] = record
+
+	return fb.saveToFile()
+}
+
+// SaveVisits записывает счетчики переходов в уже сохраненные записи и сохраняет файл
+func (fb *FileBackup) SaveVisits(visits map[string]int64) error {
+	for shortURL, record := range fb.records {
+		record.Visits = visits[shortURL]
+		fb.records[shortURL] = record
+	}
+	return fb.saveToFile()
+}
+
+// Visits возвращает счетчики переходов загруженных записей
+func (fb *FileBackup) Visits() map[string]int64 {
+	visits := make(map[string]int64)
+	for shortURL, record := range fb.records {
+		if record.Visits > 0 {
+			visits[shortURL] = record.Visits
 		}
-	} else {
-		// Создаем новую запись только для новых shortURL
-		record := URLRecord{
-			UUID:        uuid,
-			ShortURL:    shortURL,
-			OriginalURL: originalURL,
+	}
+	return visits
+}
+
+// DedupKeys возвращает ключи дедупликации загруженных записей, отличающиеся от URL
+func (fb *FileBackup) DedupKeys() map[string]string {
+	keys := make(map[string]string)
+	for shortURL, record := range fb.records {
+		if record.DedupKey != "" {
+			keys[shortURL] = record.DedupKey
 		}
-		fb.records[shortURL] = record
 	}
+	return keys
+}
 
-	return fb.saveToFile()
+// Expiry возвращает время истечения загруженных записей со сроком действия
+func (fb *FileBackup) Expiry() map[string]time.Time {
+	expiry := make(map[string]time.Time)
+	for shortURL, record := range fb.records {
+		if record.ExpiresAt != nil {
+			expiry[shortURL] = *record.ExpiresAt
+		}
+	}
+	return expiry
+}
+
+// Deleted возвращает время удаления загруженных записей, удаленных пользователями
+func (fb *FileBackup) Deleted() map[string]time.Time {
+	deleted := make(map[string]time.Time)
+	for shortURL, record := range fb.records {
+		if record.DeletedAt != nil {
+			deleted[shortURL] = *record.DeletedAt
+		}
+	}
+	return deleted
 }
 
 // saveToFile сохраняет все записи в файл
@@ -76,6 +163,7 @@ func (fb *FileBackup) saveToFile() error {
 		return fmt.Errorf("cannot encode records: %w", err)
 	}
 
+	fb.removed = make(map[string]struct{})
 	return nil
 }
 
@@ -106,7 +194,7 @@ func (fb *FileBackup) LoadURLs() (map[string]string, error) {
 	// Преобразуем в map для возврата
 	urls := make(map[string]string)
 	for shortURL, record := range fb.records {
-		urls[shortURL] = record.OriginalURL
+		urls[shortURL] = record.value()
 	}
 
 	return urls, nil
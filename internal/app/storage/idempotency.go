@@ -0,0 +1,107 @@
+// Package storage предоставляет различные реализации хранилища URL
+package storage
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/m-molecula741/shortener/internal/app/usecase"
+)
+
+// idempotencyPendingTTL - сколько ключ остается занятым незавершенным запросом.
+// Ограничивает блокировку ключа, если процесс упал, не освободив его.
+const idempotencyPendingTTL = time.Minute
+
+// pendingTTL возвращает время жизни занятого, но еще не завершенного ключа
+func pendingTTL(ttl time.Duration) time.Duration {
+	return min(ttl, idempotencyPendingTTL)
+}
+
+// idempotencyEntry представляет сохраненный результат запроса с ключом идемпотентности
+type idempotencyEntry struct {
+	record    usecase.IdempotencyRecord
+	expiresAt time.Time
+}
+
+// InMemoryIdempotencyStore хранит ключи идемпотентности в памяти с ограниченным временем жизни.
+// Подходит для одного экземпляра сервиса: ключи не разделяются между репликами.
+type InMemoryIdempotencyStore struct {
+	mu        sync.Mutex
+	ttl       time.Duration
+	entries   map[string]idempotencyEntry
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+// NewInMemoryIdempotencyStore создает новое хранилище ключей идемпотентности с заданным TTL
+func NewInMemoryIdempotencyStore(ttl time.Duration) *InMemoryIdempotencyStore {
+	return &InMemoryIdempotencyStore{
+		ttl:       ttl,
+		entries:   make(map[string]idempotencyEntry),
+		lastSweep: time.Now(),
+		now:       time.Now,
+	}
+}
+
+// Reserve занимает ключ, если он свободен или истек, иначе возвращает его запись
+func (s *InMemoryIdempotencyStore) Reserve(ctx context.Context, key, fingerprint string) (usecase.IdempotencyRecord, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	s.sweepLocked(now)
+
+	if entry, exists := s.entries[key]; exists && now.Before(entry.expiresAt) {
+		return entry.record, false, nil
+	}
+
+	s.entries[key] = idempotencyEntry{
+		record:    usecase.IdempotencyRecord{Fingerprint: fingerprint},
+		expiresAt: now.Add(pendingTTL(s.ttl)),
+	}
+	return usecase.IdempotencyRecord{}, true, nil
+}
+
+// Complete сохраняет результат по ключу на время TTL
+func (s *InMemoryIdempotencyStore) Complete(ctx context.Context, key, fingerprint, value string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.entries[key] = idempotencyEntry{
+		record:    usecase.IdempotencyRecord{Fingerprint: fingerprint, Value: value},
+		expiresAt: s.now().Add(s.ttl),
+	}
+	return nil
+}
+
+// Release освобождает ключ
+func (s *InMemoryIdempotencyStore) Release(ctx context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.entries, key)
+	return nil
+}
+
+// Clear удаляет все сохраненные ключи
+func (s *InMemoryIdempotencyStore) Clear(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.entries = make(map[string]idempotencyEntry)
+	return nil
+}
+
+// sweepLocked периодически удаляет истекшие ключи, чтобы карта не росла бесконечно
+func (s *InMemoryIdempotencyStore) sweepLocked(now time.Time) {
+	if now.Sub(s.lastSweep) <= s.ttl {
+		return
+	}
+	for k, entry := range s.entries {
+		if !now.Before(entry.expiresAt) {
+			delete(s.entries, k)
+		}
+	}
+	s.lastSweep = now
+}
@@ -0,0 +1,187 @@
+package storage
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/m-molecula741/shortener/internal/app/usecase"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testIdempotencyTTL = time.Hour
+
+// newTestInMemoryIdempotencyStore создает хранилище в памяти с управляемыми часами
+func newTestInMemoryIdempotencyStore() (*InMemoryIdempotencyStore, func(time.Duration)) {
+	now := time.Now()
+	store := NewInMemoryIdempotencyStore(testIdempotencyTTL)
+	store.now = func() time.Time { return now }
+	store.lastSweep = now
+	return store, func(d time.Duration) { now = now.Add(d) }
+}
+
+// newTestRedisIdempotencyStore создает хранилище в Redis на встроенном сервере miniredis
+func newTestRedisIdempotencyStore(t *testing.T) (*RedisIdempotencyStore, func(time.Duration)) {
+	server := miniredis.RunT(t)
+	store, err := NewRedisIdempotencyStore(context.Background(), "redis://"+server.Addr(), testIdempotencyTTL)
+	require.NoError(t, err)
+	t.Cleanup(func() { store.Close() })
+	return store, server.FastForward
+}
+
+func TestIdempotencyStores(t *testing.T) {
+	stores := []struct {
+		name  string
+		setup func(t *testing.T) (usecase.IdempotencyStore, func(time.Duration))
+	}{
+		{
+			name: "в памяти",
+			setup: func(t *testing.T) (usecase.IdempotencyStore, func(time.Duration)) {
+				return newTestInMemoryIdempotencyStore()
+			},
+		},
+		{
+			name: "redis",
+			setup: func(t *testing.T) (usecase.IdempotencyStore, func(time.Duration)) {
+				return newTestRedisIdempotencyStore(t)
+			},
+		},
+	}
+
+	for _, st := range stores {
+		t.Run(st.name, func(t *testing.T) {
+			ctx := context.Background()
+
+			t.Run("ключ занимается один раз", func(t *testing.T) {
+				store, _ := st.setup(t)
+
+				_, reserved, err := store.Reserve(ctx, "key", "https://example.com")
+				require.NoError(t, err)
+				assert.True(t, reserved)
+
+				record, reserved, err := store.Reserve(ctx, "key", "https://example.com")
+				require.NoError(t, err)
+				assert.False(t, reserved)
+				assert.True(t, record.Pending())
+				assert.Equal(t, "https://example.com", record.Fingerprint)
+			})
+
+			t.Run("завершенный ключ возвращает результат", func(t *testing.T) {
+				store, _ := st.setup(t)
+
+				_, _, err := store.Reserve(ctx, "key", "https://example.com")
+				require.NoError(t, err)
+				require.NoError(t, store.Complete(ctx, "key", "https://example.com", "http://localhost/abc"))
+
+				record, reserved, err := store.Reserve(ctx, "key", "https://other.example.com")
+				require.NoError(t, err)
+				assert.False(t, reserved)
+				assert.Equal(t, usecase.IdempotencyRecord{Fingerprint: "https://example.com", Value: "http://localhost/abc"}, record)
+			})
+
+			t.Run("освобожденный ключ занимается снова", func(t *testing.T) {
+				store, _ := st.setup(t)
+
+				_, _, err := store.Reserve(ctx, "key", "https://example.com")
+				require.NoError(t, err)
+				require.NoError(t, store.Release(ctx, "key"))
+
+				_, reserved, err := store.Reserve(ctx, "key", "https://example.com")
+				require.NoError(t, err)
+				assert.True(t, reserved)
+			})
+
+			t.Run("результат истекает через TTL", func(t *testing.T) {
+				store, advance := st.setup(t)
+
+				_, _, err := store.Reserve(ctx, "key", "https://example.com")
+				require.NoError(t, err)
+				require.NoError(t, store.Complete(ctx, "key", "https://example.com", "http://localhost/abc"))
+
+				advance(testIdempotencyTTL - time.Second)
+				_, reserved, err := store.Reserve(ctx, "key", "https://example.com")
+				require.NoError(t, err)
+				assert.False(t, reserved)
+
+				advance(2 * time.Second)
+				_, reserved, err = store.Reserve(ctx, "key", "https://example.com")
+				require.NoError(t, err)
+				assert.True(t, reserved)
+			})
+
+			t.Run("незавершенный ключ освобождается раньше TTL", func(t *testing.T) {
+				store, advance := st.setup(t)
+
+				_, _, err := store.Reserve(ctx, "key", "https://example.com")
+				require.NoError(t, err)
+
+				advance(idempotencyPendingTTL + time.Second)
+				_, reserved, err := store.Reserve(ctx, "key", "https://example.com")
+				require.NoError(t, err)
+				assert.True(t, reserved)
+			})
+
+			t.Run("очистка удаляет все ключи", func(t *testing.T) {
+				store, _ := st.setup(t)
+
+				for _, key := range []string{"key1", "key2"} {
+					_, _, err := store.Reserve(ctx, key, "https://example.com")
+					require.NoError(t, err)
+				}
+				require.NoError(t, store.Clear(ctx))
+
+				for _, key := range []string{"key1", "key2"} {
+					_, reserved, err := store.Reserve(ctx, key, "https://example.com")
+					require.NoError(t, err)
+					assert.True(t, reserved)
+				}
+			})
+		})
+	}
+}
+
+func TestInMemoryIdempotencyStore_Sweep(t *testing.T) {
+	ctx := context.Background()
+	store, advance := newTestInMemoryIdempotencyStore()
+
+	for _, key := range []string{"old1", "old2"} {
+		_, _, err := store.Reserve(ctx, key, "https://example.com")
+		require.NoError(t, err)
+		require.NoError(t, store.Complete(ctx, key, "https://example.com", "http://localhost/abc"))
+	}
+
+	// До истечения интервала очистки истекшие ключи остаются в карте
+	advance(testIdempotencyTTL / 2)
+	_, _, err := store.Reserve(ctx, "fresh", "https://example.com")
+	require.NoError(t, err)
+	assert.Len(t, store.entries, 3)
+
+	// После интервала очистки удаляются все истекшие ключи, а не только запрошенный
+	advance(testIdempotencyTTL)
+	_, _, err = store.Reserve(ctx, "new", "https://example.com")
+	require.NoError(t, err)
+	assert.Len(t, store.entries, 1)
+	assert.Contains(t, store.entries, "new")
+}
+
+func TestRedisIdempotencyStore_ClearKeepsOtherKeys(t *testing.T) {
+	server := miniredis.RunT(t)
+	store, err := NewRedisIdempotencyStore(context.Background(), "redis://"+server.Addr(), testIdempotencyTTL)
+	require.NoError(t, err)
+	defer store.Close()
+
+	require.NoError(t, server.Set("other:key", "value"))
+	_, _, err = store.Reserve(context.Background(), "key", "https://example.com")
+	require.NoError(t, err)
+
+	require.NoError(t, store.Clear(context.Background()))
+	assert.True(t, server.Exists("other:key"))
+	assert.False(t, server.Exists(redisIdempotencyPrefix+"key"))
+}
+
+func TestNewRedisIdempotencyStore_InvalidURL(t *testing.T) {
+	_, err := NewRedisIdempotencyStore(context.Background(), "http://localhost", testIdempotencyTTL)
+	assert.Error(t, err)
+}
@@ -0,0 +1,158 @@
+// Package storage предоставляет различные реализации хранилища URL
+package storage
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/m-molecula741/shortener/internal/app/logger"
+	"github.com/m-molecula741/shortener/internal/app/usecase"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// instrumentedStorage оборачивает URLStorage и измеряет время выполнения операций.
+// Декоратор не меняет поведение хранилища и подходит для любого бэкенда.
+type instrumentedStorage struct {
+	next     usecase.URLStorage
+	backend  string
+	duration *prometheus.HistogramVec
+	opts     InstrumentOptions
+}
+
+// InstrumentOptions содержит дополнительные настройки декоратора метрик хранилища
+type InstrumentOptions struct {
+	// SlowQueryThreshold - длительность операции, начиная с которой она
+	// записывается в лог как медленная; 0 - не логировать
+	SlowQueryThreshold time.Duration
+}
+
+// NewInstrumentedStorage создает декоратор хранилища, записывающий длительность операций
+// в гистограмму shortener_storage_operation_duration_seconds с метками backend и operation
+func NewInstrumentedStorage(next usecase.URLStorage, backend string, registerer prometheus.Registerer) (usecase.URLStorage, error) {
+	return NewInstrumentedStorageWithOptions(next, backend, registerer, InstrumentOptions{})
+}
+
+// NewInstrumentedStorageWithOptions создает декоратор метрик хранилища с дополнительными настройками
+func NewInstrumentedStorageWithOptions(next usecase.URLStorage, backend string, registerer prometheus.Registerer, opts InstrumentOptions) (usecase.URLStorage, error) {
+	duration := prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "shortener_storage_operation_duration_seconds",
+		Help:    "Duration of URL storage operations.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"backend", "operation"})
+
+	if err := registerer.Register(duration); err != nil {
+		var alreadyRegistered prometheus.AlreadyRegisteredError
+		if !errors.As(err, &alreadyRegistered) {
+			return nil, err
+		}
+		duration = alreadyRegistered.ExistingCollector.(*prometheus.HistogramVec)
+	}
+
+	return &instrumentedStorage{
+		next:     next,
+		backend:  backend,
+		duration: duration,
+		opts:     opts,
+	}, nil
+}
+
+// observe записывает длительность операции с момента start
+// и предупреждает в логе, если операция дольше SlowQueryThreshold
+func (s *instrumentedStorage) observe(operation string, start time.Time) {
+	elapsed := time.Since(start)
+	s.duration.WithLabelValues(s.backend, operation).Observe(elapsed.Seconds())
+
+	if s.opts.SlowQueryThreshold > 0 && elapsed >= s.opts.SlowQueryThreshold {
+		logger.Warn().
+			Str("backend", s.backend).
+			Str("operation", operation).
+			Dur("duration", elapsed).
+			Dur("threshold", s.opts.SlowQueryThreshold).
+			Msg("Slow storage operation")
+	}
+}
+
+// Save сохраняет URL и измеряет время операции
+func (s *instrumentedStorage) Save(ctx context.Context, url usecase.URLPair) error {
+	defer s.observe("save", time.Now())
+	return s.next.Save(ctx, url)
+}
+
+// Get получает URL и измеряет время операции
+func (s *instrumentedStorage) Get(ctx context.Context, shortID string) (string, error) {
+	defer s.observe("get", time.Now())
+	return s.next.Get(ctx, shortID)
+}
+
+// SaveBatch сохраняет множество URL и измеряет время операции
+func (s *instrumentedStorage) SaveBatch(ctx context.Context, urls []usecase.URLPair) error {
+	defer s.observe("save_batch", time.Now())
+	return s.next.SaveBatch(ctx, urls)
+}
+
+// GetUserURLs получает URL пользователя и измеряет время операции
+func (s *instrumentedStorage) GetUserURLs(ctx context.Context, userID string, limit, offset int) ([]usecase.UserURL, int, error) {
+	defer s.observe("get_user_urls", time.Now())
+	return s.next.GetUserURLs(ctx, userID, limit, offset)
+}
+
+// GetUserURL получает URL пользователя и измеряет время операции
+func (s *instrumentedStorage) GetUserURL(ctx context.Context, userID, shortID string) (usecase.UserURL, error) {
+	defer s.observe("get_user_url", time.Now())
+	return s.next.GetUserURL(ctx, userID, shortID)
+}
+
+// DeleteAllUserURLs помечает все URL пользователя удаленными и измеряет время операции
+func (s *instrumentedStorage) DeleteAllUserURLs(ctx context.Context, userID string) (int64, error) {
+	defer s.observe("delete_all_user_urls", time.Now())
+	return s.next.DeleteAllUserURLs(ctx, userID)
+}
+
+// BatchDeleteUserURLs помечает URL пользователя удаленными и измеряет время операции
+func (s *instrumentedStorage) BatchDeleteUserURLs(ctx context.Context, userID string, shortIDs []string) error {
+	defer s.observe("batch_delete_user_urls", time.Now())
+	return s.next.BatchDeleteUserURLs(ctx, userID, shortIDs)
+}
+
+// DeleteUserURLsWithResult синхронно удаляет URL пользователя и измеряет время операции
+func (s *instrumentedStorage) DeleteUserURLsWithResult(ctx context.Context, userID string, shortIDs []string) (usecase.DeleteResult, error) {
+	defer s.observe("delete_user_urls_with_result", time.Now())
+	return s.next.DeleteUserURLsWithResult(ctx, userID, shortIDs)
+}
+
+// PurgeExpired удаляет истекшие и удаленные ссылки и измеряет время операции
+func (s *instrumentedStorage) PurgeExpired(ctx context.Context, before time.Time) (int64, error) {
+	defer s.observe("purge_expired", time.Now())
+	return s.next.PurgeExpired(ctx, before)
+}
+
+// Reset удаляет все ссылки и измеряет время операции
+func (s *instrumentedStorage) Reset(ctx context.Context) (int64, error) {
+	defer s.observe("reset", time.Now())
+	return s.next.Reset(ctx)
+}
+
+// GetURLOwner получает создателя URL и измеряет время операции
+func (s *instrumentedStorage) GetURLOwner(ctx context.Context, shortID string) (usecase.URLOwner, error) {
+	defer s.observe("get_url_owner", time.Now())
+	return s.next.GetURLOwner(ctx, shortID)
+}
+
+// RecentPublicURLs получает последние публичные URL и измеряет время операции
+func (s *instrumentedStorage) RecentPublicURLs(ctx context.Context, limit int) ([]usecase.PublicURL, error) {
+	defer s.observe("recent_public_urls", time.Now())
+	return s.next.RecentPublicURLs(ctx, limit)
+}
+
+// Stats получает количество ссылок и пользователей и измеряет время операции
+func (s *instrumentedStorage) Stats(ctx context.Context) (int, int, error) {
+	defer s.observe("stats", time.Now())
+	return s.next.Stats(ctx)
+}
+
+// IncrementVisits увеличивает счетчик переходов и измеряет время операции
+func (s *instrumentedStorage) IncrementVisits(ctx context.Context, shortID string) error {
+	defer s.observe("increment_visits", time.Now())
+	return s.next.IncrementVisits(ctx, shortID)
+}
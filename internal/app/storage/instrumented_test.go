@@ -0,0 +1,57 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/m-molecula741/shortener/internal/app/logger"
+	"github.com/m-molecula741/shortener/internal/app/usecase"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInstrumentedStorage_SlowQuery(t *testing.T) {
+	var buf bytes.Buffer
+	log := logger.GetLogger()
+	prev := *log
+	*log = zerolog.New(&buf)
+	t.Cleanup(func() { *log = prev })
+
+	tests := []struct {
+		name      string
+		threshold time.Duration
+		wantLog   bool
+	}{
+		{name: "disabled", threshold: 0, wantLog: false},
+		{name: "fast operation", threshold: time.Hour, wantLog: false},
+		{name: "slow operation", threshold: time.Nanosecond, wantLog: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			buf.Reset()
+
+			memory, err := NewInMemoryStorage(filepath.Join(t.TempDir(), "urls.json"), Options{})
+			require.NoError(t, err)
+			store, err := NewInstrumentedStorageWithOptions(memory, "memory", prometheus.NewRegistry(), InstrumentOptions{
+				SlowQueryThreshold: tt.threshold,
+			})
+			require.NoError(t, err)
+
+			require.NoError(t, store.Save(context.Background(), usecase.URLPair{ShortID: "abc123", OriginalURL: "https://example.com"}))
+
+			if !tt.wantLog {
+				assert.Empty(t, buf.String())
+				return
+			}
+			assert.Contains(t, buf.String(), `"level":"warn"`)
+			assert.Contains(t, buf.String(), `"operation":"save"`)
+			assert.Contains(t, buf.String(), `"backend":"memory"`)
+		})
+	}
+}
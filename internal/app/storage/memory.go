@@ -2,10 +2,13 @@
 package storage
 
 import (
+	"container/list"
 	"context"
-	"errors"
 	"fmt"
+	"slices"
+	"strings"
 	"sync"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/m-molecula741/shortener/internal/app/usecase"
@@ -13,21 +16,40 @@ import (
 
 // InMemoryStorage представляет хранилище URL в памяти
 type InMemoryStorage struct {
-	mu     sync.Mutex
-	urls   map[string]string
-	users  map[string][]string // userID -> []shortID
-	backup *FileBackup
+	mu      sync.Mutex
+	urls    map[string]string
+	users   map[string][]string  // userID -> []shortID
+	visits  map[string]int64     // shortID -> количество переходов
+	expiry  map[string]time.Time // shortID -> время истечения ссылки
+	created map[string]time.Time // shortID -> время создания ссылки
+	public  map[string]bool      // shortID -> ссылка показывается в публичной ленте
+	deleted map[string]time.Time // shortID -> время удаления ссылки пользователем
+	keys    map[string]string    // shortID -> ключ дедупликации, если он отличается от URL
+	backup  *FileBackup
+	opts    Options
+
+	// Порядок обращений к ссылкам для вытеснения EvictLRU, nil при других политиках.
+	// В начале списка ссылка, к которой дольше всего не обращались.
+	recent      *list.List
+	recentIndex map[string]*list.Element
 }
 
 // NewInMemoryStorage создает новый экземпляр InMemoryStorage
-func NewInMemoryStorage(filePath string) (*InMemoryStorage, error) {
+func NewInMemoryStorage(filePath string, opts Options) (*InMemoryStorage, error) {
 	backup := NewFileBackup(filePath)
 
 	// Создаем хранилище
 	s := &InMemoryStorage{
-		urls:   make(map[string]string),
-		users:  make(map[string][]string),
-		backup: backup,
+		urls:    make(map[string]string),
+		users:   make(map[string][]string),
+		visits:  make(map[string]int64),
+		expiry:  make(map[string]time.Time),
+		created: make(map[string]time.Time),
+		public:  make(map[string]bool),
+		deleted: make(map[string]time.Time),
+		keys:    make(map[string]string),
+		backup:  backup,
+		opts:    opts,
 	}
 
 	// Загружаем существующие URL из файла
@@ -35,35 +57,70 @@ func NewInMemoryStorage(filePath string) (*InMemoryStorage, error) {
 		return nil, err
 	} else {
 		s.urls = urls
+		s.keys = backup.DedupKeys()
+		s.deleted = backup.Deleted()
+		s.expiry = backup.Expiry()
+	}
+
+	if opts.PersistVisits {
+		s.visits = backup.Visits()
+	}
+
+	if opts.MaxURLs > 0 && opts.Eviction == EvictLRU {
+		s.recent = list.New()
+		s.recentIndex = make(map[string]*list.Element)
+		s.syncRecencyLocked()
 	}
 
 	return s, nil
 }
 
-// Save сохраняет URL в памяти
-func (s *InMemoryStorage) Save(shortID, url string) error {
+// Save сохраняет URL в памяти вместе с владельцем, сроком действия и признаком публичности
+func (s *InMemoryStorage) Save(ctx context.Context, url usecase.URLPair) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	// Проверяем, есть ли уже такой URL
-	for existingShortID, existingURL := range s.urls {
-		if existingURL == url {
-			return &usecase.ErrURLConflict{ExistingShortURL: existingShortID}
+	// Проверяем, есть ли уже такой URL; удаленные и истекшие ссылки дубликатами не считаются
+	if !s.opts.DisableDedup {
+		now := time.Now()
+		for existingShortID := range s.urls {
+			if s.keyLocked(existingShortID) == url.Key() && s.isLiveLocked(existingShortID, now) {
+				return &usecase.ErrURLConflict{ExistingShortURL: existingShortID}
+			}
 		}
 	}
 
-	s.urls[shortID] = url
+	// Занятый другой ссылкой ID не перезаписывается: сервис повторит с новым ID
+	if _, exists := s.urls[url.ShortID]; exists {
+		if s.keyLocked(url.ShortID) != url.Key() {
+			return usecase.ErrShortIDCollision
+		}
+	} else if err := s.reserveLocked(1); err != nil {
+		return err
+	}
+
+	s.storeLocked(url)
+	s.created[url.ShortID] = time.Now()
+	s.touchLocked(url.ShortID)
+	s.setAttributesLocked(url)
 	return nil
 }
 
 // Get получает URL из памяти
-func (s *InMemoryStorage) Get(shortID string) (string, error) {
+func (s *InMemoryStorage) Get(ctx context.Context, shortID string) (string, error) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 	url, exists := s.urls[shortID]
 	if !exists {
 		return "", ErrNotFound
 	}
+	if _, deleted := s.deleted[shortID]; deleted {
+		return "", &usecase.ErrURLDeleted{}
+	}
+	if expiresAt, ok := s.expiry[shortID]; ok && !time.Now().Before(expiresAt) {
+		return "", &usecase.ErrURLExpired{}
+	}
+	s.touchLocked(shortID)
 	return url, nil
 }
 
@@ -72,20 +129,99 @@ func (s *InMemoryStorage) Backup() error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	// Сохраняем все URL
+	// Удаленные ссылки сохраняются с временем удаления, чтобы после перезапуска
+	// они по-прежнему отвечали 410 и удалялись фоновой очисткой
 	for shortID, url := range s.urls {
 		// Генерируем UUID только для новых записей, если запись уже есть в файле - используем существующий UUID
-		if err := s.backup.SaveURL(uuid.New().String(), shortID, url); err != nil {
+		record := newURLRecord(uuid.New().String(), shortID, url, s.keys[shortID])
+		if expiresAt, ok := s.expiry[shortID]; ok {
+			record.ExpiresAt = &expiresAt
+		}
+		if deletedAt, deleted := s.deleted[shortID]; deleted {
+			record.DeletedAt = &deletedAt
+		}
+		if err := s.backup.SaveURL(record); err != nil {
 			return fmt.Errorf("cannot backup URL: %w", err)
 		}
 	}
 
+	// Очищенные и вытесненные записи убираются из файла, даже если ссылок не осталось
+	if len(s.backup.removed) > 0 {
+		if err := s.backup.saveToFile(); err != nil {
+			return fmt.Errorf("cannot backup URLs: %w", err)
+		}
+	}
+
+	if s.opts.PersistVisits {
+		if err := s.backup.SaveVisits(s.visits); err != nil {
+			return fmt.Errorf("cannot backup visits: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// Reload перечитывает файл хранилища и атомарно подменяет данные в памяти.
+// URL, созданные после последнего бэкапа и отсутствующие в файле, сохраняются,
+// чтобы перезагрузка не приводила к потере данных.
+func (s *InMemoryStorage) Reload() error {
+	backup := NewFileBackup(s.backup.filePath)
+
+	urls, err := backup.LoadURLs()
+	if err != nil {
+		return fmt.Errorf("cannot reload storage: %w", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Записи, очищенные или вытесненные после последнего бэкапа, еще есть в файле
+	for shortID := range s.backup.removed {
+		delete(urls, shortID)
+		backup.Remove(shortID)
+	}
+
+	keys := backup.DedupKeys()
+	for shortID, url := range s.urls {
+		if _, exists := backup.records[shortID]; !exists {
+			urls[shortID] = url
+			if key, ok := s.keys[shortID]; ok {
+				keys[shortID] = key
+			}
+		}
+	}
+
+	// Удаление необратимо: ссылка остается удаленной, если она удалена в памяти или в файле
+	for shortID, deletedAt := range backup.Deleted() {
+		if _, deleted := s.deleted[shortID]; !deleted {
+			s.deleted[shortID] = deletedAt
+		}
+	}
+	for shortID, expiresAt := range backup.Expiry() {
+		if _, ok := s.expiry[shortID]; !ok {
+			s.expiry[shortID] = expiresAt
+		}
+	}
+
+	// Счетчики в памяти новее файла, из файла берутся только недостающие
+	if s.opts.PersistVisits {
+		for shortID, visits := range backup.Visits() {
+			if _, exists := s.visits[shortID]; !exists {
+				s.visits[shortID] = visits
+			}
+		}
+	}
+
+	s.urls = urls
+	s.keys = keys
+	s.backup = backup
+	s.syncRecencyLocked()
 	return nil
 }
 
 // Ошибки для хранилища
 var (
-	ErrNotFound = errors.New("url not found")
+	ErrNotFound = usecase.ErrURLNotFound
 )
 
 // SaveBatch сохраняет множество URL за одну операцию
@@ -93,81 +229,513 @@ func (s *InMemoryStorage) SaveBatch(ctx context.Context, urls []usecase.URLPair)
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
+	// ID, занятый другой ссылкой, отклоняет весь batch до изменений: иначе срок действия
+	// и владелец batch достались бы чужой ссылке
+	for _, url := range urls {
+		if _, exists := s.urls[url.ShortID]; exists && s.keyLocked(url.ShortID) != url.Key() {
+			return usecase.ErrShortIDCollision
+		}
+	}
+
+	// Место освобождается заранее, чтобы batch не сохранился частично. Уже сохраненные
+	// ссылки batch отмечаются использованными, чтобы не вытеснить их же.
+	newIDs := make(map[string]struct{})
+	for _, url := range urls {
+		if _, exists := s.urls[url.ShortID]; exists {
+			s.touchLocked(url.ShortID)
+		} else {
+			newIDs[url.ShortID] = struct{}{}
+		}
+	}
+	if err := s.reserveLocked(len(newIDs)); err != nil {
+		return err
+	}
+
 	// Сохраняем в память
 	for _, url := range urls {
 		// Сохраняем URL если его еще нет
 		if _, exists := s.urls[url.ShortID]; !exists {
-			s.urls[url.ShortID] = url.OriginalURL
-		}
-
-		// Связываем с пользователем если указан userID
-		if url.UserID != "" {
-			found := false
-			if shortIDs, exists := s.users[url.UserID]; exists {
-				for _, existingShortID := range shortIDs {
-					if existingShortID == url.ShortID {
-						found = true
-						break
-					}
-				}
-			}
-
-			// Добавляем связь если еще не существует
-			if !found {
-				s.users[url.UserID] = append(s.users[url.UserID], url.ShortID)
-			}
+			s.storeLocked(url)
+			s.created[url.ShortID] = time.Now()
+			s.touchLocked(url.ShortID)
 		}
+
+		s.setAttributesLocked(url)
 	}
 
 	return nil
 }
 
-// GetUserURLs получает все URL пользователя
-func (s *InMemoryStorage) GetUserURLs(ctx context.Context, userID string) ([]usecase.UserURL, error) {
+// storeLocked сохраняет значение ссылки и ее ключ дедупликации
+func (s *InMemoryStorage) storeLocked(url usecase.URLPair) {
+	s.urls[url.ShortID] = url.OriginalURL
+	if url.DedupKey != "" {
+		s.keys[url.ShortID] = url.DedupKey
+	} else {
+		delete(s.keys, url.ShortID)
+	}
+}
+
+// keyLocked возвращает ключ дедупликации сохраненной ссылки
+func (s *InMemoryStorage) keyLocked(shortID string) string {
+	if key, ok := s.keys[shortID]; ok {
+		return key
+	}
+	return s.urls[shortID]
+}
+
+// setAttributesLocked сохраняет срок действия и признак публичности ссылки
+// и связывает ее с пользователем, если указан userID
+func (s *InMemoryStorage) setAttributesLocked(url usecase.URLPair) {
+	if !url.ExpiresAt.IsZero() {
+		s.expiry[url.ShortID] = url.ExpiresAt
+	}
+	if url.Public {
+		s.public[url.ShortID] = true
+	}
+	if url.UserID != "" && !slices.Contains(s.users[url.UserID], url.ShortID) {
+		s.users[url.UserID] = append(s.users[url.UserID], url.ShortID)
+	}
+}
+
+// GetUserURLs получает страницу URL пользователя в порядке создания и их общее количество
+func (s *InMemoryStorage) GetUserURLs(ctx context.Context, userID string, limit, offset int) ([]usecase.UserURL, int, error) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
 	shortIDs, exists := s.users[userID]
 	if !exists {
-		return nil, nil
+		return nil, 0, nil
 	}
 
 	urls := make([]usecase.UserURL, 0, len(shortIDs))
 	for _, shortID := range shortIDs {
 		originalURL, exists := s.urls[shortID]
-		if !exists {
+		if _, deleted := s.deleted[shortID]; !exists || deleted {
 			continue
 		}
 
 		urls = append(urls, usecase.UserURL{
-			ShortURL:    fmt.Sprintf("http://localhost:8080/%s", shortID),
-			OriginalURL: originalURL,
+			ShortID:      shortID,
+			OriginalURL:  originalURL,
+			Visits:       s.visits[shortID],
+			VisitsCapped: s.opts.visitsCapped(s.visits[shortID]),
+			CreatedAt:    s.created[shortID],
 		})
 	}
 
-	return urls, nil
+	return paginate(urls, limit, offset), len(urls), nil
+}
+
+// paginate возвращает не более limit элементов после первых offset; limit <= 0 - без ограничения
+func paginate[T any](items []T, limit, offset int) []T {
+	if offset >= len(items) {
+		return nil
+	}
+	items = items[offset:]
+	if limit > 0 && len(items) > limit {
+		items = items[:limit]
+	}
+	return items
 }
 
-// BatchDeleteUserURLs помечает URL пользователя как удаленные
+// GetUserURL получает URL, только если он принадлежит пользователю
+func (s *InMemoryStorage) GetUserURL(ctx context.Context, userID, shortID string) (usecase.UserURL, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, userShortID := range s.users[userID] {
+		if userShortID != shortID {
+			continue
+		}
+
+		originalURL, exists := s.urls[shortID]
+		if _, deleted := s.deleted[shortID]; !exists || deleted {
+			break
+		}
+
+		return usecase.UserURL{
+			ShortID:      shortID,
+			OriginalURL:  originalURL,
+			Visits:       s.visits[shortID],
+			VisitsCapped: s.opts.visitsCapped(s.visits[shortID]),
+		}, nil
+	}
+
+	return usecase.UserURL{}, ErrNotFound
+}
+
+// BatchDeleteUserURLs помечает URL пользователя как удаленные.
+// Как и в PostgreSQL, запись остается, и Get возвращает для нее usecase.ErrURLDeleted.
 func (s *InMemoryStorage) BatchDeleteUserURLs(ctx context.Context, userID string, shortIDs []string) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
+	now := time.Now()
 	for _, shortID := range shortIDs {
-		if url, exists := s.urls[shortID]; exists {
-			if urlWithUser, hasUser := s.users[userID]; hasUser {
-				for i, userURL := range urlWithUser {
-					if userURL == shortID {
-						// Удаляем из списка пользователя
-						s.users[userID] = append(urlWithUser[:i], urlWithUser[i+1:]...)
-						break
-					}
+		if _, exists := s.urls[shortID]; !exists || !slices.Contains(s.users[userID], shortID) {
+			continue
+		}
+		if _, deleted := s.deleted[shortID]; !deleted {
+			s.markDeletedLocked(shortID, now)
+		}
+	}
+
+	return nil
+}
+
+// DeleteAllUserURLs помечает все URL пользователя как удаленные и возвращает их количество
+func (s *InMemoryStorage) DeleteAllUserURLs(ctx context.Context, userID string) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var deleted int64
+	now := time.Now()
+	for _, shortID := range s.users[userID] {
+		if _, exists := s.urls[shortID]; !exists {
+			continue
+		}
+		if _, alreadyDeleted := s.deleted[shortID]; !alreadyDeleted {
+			s.markDeletedLocked(shortID, now)
+			deleted++
+		}
+	}
+
+	return deleted, nil
+}
+
+// DeleteUserURLsWithResult удаляет URL пользователя и возвращает итог по каждому ID
+func (s *InMemoryStorage) DeleteUserURLsWithResult(ctx context.Context, userID string, shortIDs []string) (usecase.DeleteResult, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	result := usecase.DeleteResult{
+		Deleted:        []string{},
+		AlreadyDeleted: []string{},
+		NotFound:       []string{},
+	}
+
+	now := time.Now()
+	seen := make(map[string]bool, len(shortIDs))
+	for _, shortID := range shortIDs {
+		if seen[shortID] {
+			continue
+		}
+		seen[shortID] = true
+
+		userURLs := s.users[userID]
+		index := -1
+		for i, userURL := range userURLs {
+			if userURL == shortID {
+				index = i
+				break
+			}
+		}
+
+		if _, exists := s.urls[shortID]; !exists || index == -1 {
+			result.NotFound = append(result.NotFound, shortID)
+			continue
+		}
+		if _, deleted := s.deleted[shortID]; deleted {
+			result.AlreadyDeleted = append(result.AlreadyDeleted, shortID)
+			continue
+		}
+
+		s.markDeletedLocked(shortID, now)
+		result.Deleted = append(result.Deleted, shortID)
+	}
+
+	return result, nil
+}
+
+// PurgeExpired физически удаляет ссылки с истекшим сроком действия и ссылки,
+// удаленные пользователями раньше before, и возвращает их количество
+func (s *InMemoryStorage) PurgeExpired(ctx context.Context, before time.Time) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	var purged int64
+	purge := func(shortID string) {
+		if _, exists := s.urls[shortID]; exists {
+			purged++
+		}
+		delete(s.urls, shortID)
+		delete(s.visits, shortID)
+		delete(s.expiry, shortID)
+		delete(s.created, shortID)
+		delete(s.public, shortID)
+		delete(s.deleted, shortID)
+		delete(s.keys, shortID)
+		s.forgetLocked(shortID)
+		s.backup.Remove(shortID)
+	}
+
+	for shortID, expiresAt := range s.expiry {
+		if !now.Before(expiresAt) {
+			purge(shortID)
+		}
+	}
+	for shortID, deletedAt := range s.deleted {
+		if deletedAt.Before(before) {
+			purge(shortID)
+		}
+	}
+
+	// Убираем из списков пользователей ссылки, которых больше нет
+	if purged > 0 {
+		for userID, shortIDs := range s.users {
+			kept := shortIDs[:0]
+			for _, shortID := range shortIDs {
+				if _, exists := s.urls[shortID]; exists {
+					kept = append(kept, shortID)
 				}
 			}
-			delete(s.urls, shortID)
-			_ = url
+			s.users[userID] = kept
+		}
+	}
+
+	return purged, nil
+}
+
+// Stats возвращает количество неудаленных и неистекших ссылок и пользователей, у которых они есть
+func (s *InMemoryStorage) Stats(ctx context.Context) (int, int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	isLive := func(shortID string) bool {
+		return s.isLiveLocked(shortID, now)
+	}
+
+	urls := 0
+	for shortID := range s.urls {
+		if isLive(shortID) {
+			urls++
+		}
+	}
+
+	users := 0
+	for _, shortIDs := range s.users {
+		if slices.ContainsFunc(shortIDs, isLive) {
+			users++
+		}
+	}
+	return urls, users, nil
+}
+
+// isLiveLocked проверяет, что ссылка хранится, не удалена пользователем и не истекла к now
+func (s *InMemoryStorage) isLiveLocked(shortID string, now time.Time) bool {
+	if _, exists := s.urls[shortID]; !exists {
+		return false
+	}
+	if _, deleted := s.deleted[shortID]; deleted {
+		return false
+	}
+	expiresAt, ok := s.expiry[shortID]
+	return !ok || now.Before(expiresAt)
+}
+
+// Reset удаляет все ссылки из памяти и файла бэкапа и возвращает их количество
+func (s *InMemoryStorage) Reset(ctx context.Context) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	removed := int64(len(s.urls))
+
+	s.urls = make(map[string]string)
+	s.users = make(map[string][]string)
+	s.visits = make(map[string]int64)
+	s.expiry = make(map[string]time.Time)
+	s.created = make(map[string]time.Time)
+	s.public = make(map[string]bool)
+	s.deleted = make(map[string]time.Time)
+	s.keys = make(map[string]string)
+	if s.recent != nil {
+		s.recent.Init()
+		s.recentIndex = make(map[string]*list.Element)
+	}
+
+	if err := s.backup.Clear(); err != nil {
+		return 0, fmt.Errorf("cannot reset backup: %w", err)
+	}
+	if err := s.backup.saveToFile(); err != nil {
+		return 0, fmt.Errorf("cannot reset backup: %w", err)
+	}
+
+	return removed, nil
+}
+
+// GetURLOwner возвращает создателя URL и время создания.
+// Как и в PostgreSQL, удаленные пользователем URL тоже находятся.
+func (s *InMemoryStorage) GetURLOwner(ctx context.Context, shortID string) (usecase.URLOwner, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, exists := s.urls[shortID]; !exists {
+		return usecase.URLOwner{}, ErrNotFound
+	}
+
+	owner := usecase.URLOwner{CreatedAt: s.created[shortID]}
+	for userID, shortIDs := range s.users {
+		if slices.Contains(shortIDs, shortID) {
+			owner.UserID = userID
+			break
+		}
+	}
+
+	return owner, nil
+}
+
+// RecentPublicURLs возвращает не более limit последних публичных URL, новые первыми.
+// Истекшие URL не возвращаются.
+func (s *InMemoryStorage) RecentPublicURLs(ctx context.Context, limit int) ([]usecase.PublicURL, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	urls := make([]usecase.PublicURL, 0, len(s.public))
+	for shortID := range s.public {
+		originalURL, exists := s.urls[shortID]
+		if _, deleted := s.deleted[shortID]; !exists || deleted {
+			continue
+		}
+		if expiresAt, ok := s.expiry[shortID]; ok && !now.Before(expiresAt) {
+			continue
+		}
+
+		urls = append(urls, usecase.PublicURL{
+			ShortID:     shortID,
+			OriginalURL: originalURL,
+			CreatedAt:   s.created[shortID],
+		})
+	}
+
+	sortRecentFirst(urls)
+	if len(urls) > limit {
+		urls = urls[:limit]
+	}
+	return urls, nil
+}
+
+// sortRecentFirst сортирует публичные URL по времени создания, новые первыми.
+// При равном времени порядок определяется коротким ID, чтобы лента была стабильной.
+func sortRecentFirst(urls []usecase.PublicURL) {
+	slices.SortFunc(urls, func(a, b usecase.PublicURL) int {
+		if c := b.CreatedAt.Compare(a.CreatedAt); c != 0 {
+			return c
 		}
+		return strings.Compare(a.ShortID, b.ShortID)
+	})
+}
+
+// IncrementVisits увеличивает счетчик переходов по короткому URL
+func (s *InMemoryStorage) IncrementVisits(ctx context.Context, shortID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, exists := s.urls[shortID]; !exists {
+		return ErrNotFound
+	}
+
+	if s.opts.visitsCapped(s.visits[shortID]) {
+		return nil
 	}
 
+	s.visits[shortID]++
 	return nil
 }
+
+// reserveLocked освобождает место для count новых ссылок при достижении MaxURLs.
+// При EvictLRU вытесняются ссылки, к которым дольше всего не обращались,
+// при EvictReject возвращается usecase.ErrStorageFull.
+func (s *InMemoryStorage) reserveLocked(count int) error {
+	if s.opts.MaxURLs <= 0 || len(s.urls)+count <= s.opts.MaxURLs {
+		return nil
+	}
+	if s.recent == nil || count > s.opts.MaxURLs {
+		return usecase.ErrStorageFull
+	}
+
+	for len(s.urls)+count > s.opts.MaxURLs {
+		oldest := s.recent.Front()
+		if oldest == nil {
+			return usecase.ErrStorageFull
+		}
+		s.evictLocked(oldest.Value.(string))
+	}
+	return nil
+}
+
+// evictLocked удаляет ссылку и все связанные с ней данные
+func (s *InMemoryStorage) evictLocked(shortID string) {
+	delete(s.urls, shortID)
+	delete(s.visits, shortID)
+	delete(s.expiry, shortID)
+	delete(s.created, shortID)
+	delete(s.public, shortID)
+	delete(s.deleted, shortID)
+	delete(s.keys, shortID)
+	s.forgetLocked(shortID)
+	s.backup.Remove(shortID)
+
+	for userID, shortIDs := range s.users {
+		if i := slices.Index(shortIDs, shortID); i >= 0 {
+			s.users[userID] = slices.Delete(shortIDs, i, i+1)
+			break
+		}
+	}
+}
+
+// markDeletedLocked помечает ссылку удаленной. Удаленная ссылка продолжает занимать место,
+// поэтому при EvictLRU она становится первой на вытеснение.
+func (s *InMemoryStorage) markDeletedLocked(shortID string, deletedAt time.Time) {
+	s.deleted[shortID] = deletedAt
+	if s.recent == nil {
+		return
+	}
+	if elem, ok := s.recentIndex[shortID]; ok {
+		s.recent.MoveToFront(elem)
+	}
+}
+
+// touchLocked отмечает обращение к ссылке для вытеснения EvictLRU
+func (s *InMemoryStorage) touchLocked(shortID string) {
+	if s.recent == nil {
+		return
+	}
+	if elem, ok := s.recentIndex[shortID]; ok {
+		s.recent.MoveToBack(elem)
+		return
+	}
+	s.recentIndex[shortID] = s.recent.PushBack(shortID)
+}
+
+// forgetLocked убирает удаленную ссылку из порядка обращений
+func (s *InMemoryStorage) forgetLocked(shortID string) {
+	if s.recent == nil {
+		return
+	}
+	if elem, ok := s.recentIndex[shortID]; ok {
+		s.recent.Remove(elem)
+		delete(s.recentIndex, shortID)
+	}
+}
+
+// syncRecencyLocked приводит порядок обращений в соответствие с загруженными из файла ссылками.
+// Ссылки из файла считаются самыми давними, так как время обращения к ним неизвестно.
+func (s *InMemoryStorage) syncRecencyLocked() {
+	if s.recent == nil {
+		return
+	}
+	for shortID := range s.recentIndex {
+		if _, exists := s.urls[shortID]; !exists {
+			s.forgetLocked(shortID)
+		}
+	}
+	for shortID := range s.urls {
+		if _, tracked := s.recentIndex[shortID]; !tracked {
+			s.recentIndex[shortID] = s.recent.PushFront(shortID)
+		}
+	}
+}
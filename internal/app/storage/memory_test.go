@@ -0,0 +1,641 @@
+package storage
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/m-molecula741/shortener/internal/app/usecase"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInMemoryStorage_Expiry(t *testing.T) {
+	store, err := NewInMemoryStorage(filepath.Join(t.TempDir(), "urls.json"), Options{})
+	require.NoError(t, err)
+
+	require.NoError(t, store.SaveBatch(context.Background(), []usecase.URLPair{
+		{ShortID: "active", OriginalURL: "https://active.example.com", ExpiresAt: time.Now().Add(time.Hour)},
+		{ShortID: "expired", OriginalURL: "https://expired.example.com", ExpiresAt: time.Now().Add(-time.Second)},
+		{ShortID: "forever", OriginalURL: "https://forever.example.com"},
+	}))
+
+	url, err := store.Get(context.Background(), "active")
+	require.NoError(t, err)
+	assert.Equal(t, "https://active.example.com", url)
+
+	_, err = store.Get(context.Background(), "expired")
+	assert.True(t, usecase.IsURLExpired(err))
+
+	url, err = store.Get(context.Background(), "forever")
+	require.NoError(t, err)
+	assert.Equal(t, "https://forever.example.com", url)
+}
+
+func TestInMemoryStorage_SaveWithAttributes(t *testing.T) {
+	ctx := context.Background()
+	store, err := NewInMemoryStorage(filepath.Join(t.TempDir(), "urls.json"), Options{})
+	require.NoError(t, err)
+
+	require.NoError(t, store.Save(ctx, usecase.URLPair{
+		ShortID: "expired", OriginalURL: "https://expired.example.com", UserID: "user1", ExpiresAt: time.Now().Add(-time.Second),
+	}))
+	require.NoError(t, store.Save(ctx, usecase.URLPair{
+		ShortID: "public", OriginalURL: "https://public.example.com", UserID: "user1", Public: true,
+	}))
+
+	_, err = store.Get(ctx, "expired")
+	assert.True(t, usecase.IsURLExpired(err))
+
+	owner, err := store.GetURLOwner(ctx, "public")
+	require.NoError(t, err)
+	assert.Equal(t, "user1", owner.UserID)
+
+	public, err := store.RecentPublicURLs(ctx, 10)
+	require.NoError(t, err)
+	require.Len(t, public, 1)
+	assert.Equal(t, "public", public[0].ShortID)
+}
+
+func TestInMemoryStorage_PurgeExpired(t *testing.T) {
+	store, err := NewInMemoryStorage(filepath.Join(t.TempDir(), "urls.json"), Options{})
+	require.NoError(t, err)
+
+	require.NoError(t, store.SaveBatch(context.Background(), []usecase.URLPair{
+		{ShortID: "active", OriginalURL: "https://active.example.com", UserID: "user1", ExpiresAt: time.Now().Add(time.Hour)},
+		{ShortID: "expired", OriginalURL: "https://expired.example.com", UserID: "user1", ExpiresAt: time.Now().Add(-time.Second)},
+	}))
+
+	purged, err := store.PurgeExpired(context.Background(), time.Now())
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), purged)
+
+	_, err = store.Get(context.Background(), "expired")
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	urls, _, err := store.GetUserURLs(context.Background(), "user1", 0, 0)
+	require.NoError(t, err)
+	require.Len(t, urls, 1)
+	assert.Equal(t, "https://active.example.com", urls[0].OriginalURL)
+}
+
+func TestInMemoryStorage_ExpiryBackup(t *testing.T) {
+	ctx := context.Background()
+	filePath := filepath.Join(t.TempDir(), "urls.json")
+	store, err := NewInMemoryStorage(filePath, Options{})
+	require.NoError(t, err)
+
+	require.NoError(t, store.SaveBatch(ctx, []usecase.URLPair{
+		{ShortID: "active", OriginalURL: "https://active.example.com", ExpiresAt: time.Now().Add(time.Hour)},
+		{ShortID: "expired", OriginalURL: "https://expired.example.com", ExpiresAt: time.Now().Add(-time.Second)},
+	}))
+	require.NoError(t, store.Backup())
+
+	// Срок действия сохраняется в файле: после перезапуска истекшая ссылка не становится бессрочной
+	reopened, err := NewInMemoryStorage(filePath, Options{})
+	require.NoError(t, err)
+	_, err = reopened.Get(ctx, "expired")
+	assert.True(t, usecase.IsURLExpired(err))
+
+	// Очищенная ссылка не возвращается ни после перечитывания файла, ни после бэкапа и перезапуска
+	purged, err := store.PurgeExpired(ctx, time.Now())
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), purged)
+
+	require.NoError(t, store.Reload())
+	_, err = store.Get(ctx, "expired")
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	require.NoError(t, store.Backup())
+	reopened, err = NewInMemoryStorage(filePath, Options{})
+	require.NoError(t, err)
+	_, err = reopened.Get(ctx, "expired")
+	assert.ErrorIs(t, err, ErrNotFound)
+	_, err = reopened.Get(ctx, "active")
+	assert.NoError(t, err)
+}
+
+func TestInMemoryStorage_EvictionBackup(t *testing.T) {
+	ctx := context.Background()
+	filePath := filepath.Join(t.TempDir(), "urls.json")
+	store, err := NewInMemoryStorage(filePath, Options{MaxURLs: 1, Eviction: EvictLRU})
+	require.NoError(t, err)
+
+	require.NoError(t, store.Save(ctx, usecase.URLPair{ShortID: "a", OriginalURL: "https://a.example.com"}))
+	require.NoError(t, store.Backup())
+	require.NoError(t, store.Save(ctx, usecase.URLPair{ShortID: "b", OriginalURL: "https://b.example.com"}))
+	require.NoError(t, store.Backup())
+
+	// Вытесненная ссылка удалена и из файла
+	reopened, err := NewInMemoryStorage(filePath, Options{})
+	require.NoError(t, err)
+	_, err = reopened.Get(ctx, "a")
+	assert.ErrorIs(t, err, ErrNotFound)
+	_, err = reopened.Get(ctx, "b")
+	assert.NoError(t, err)
+}
+
+func TestInMemoryStorage_Stats(t *testing.T) {
+	ctx := context.Background()
+	store, err := NewInMemoryStorage(filepath.Join(t.TempDir(), "urls.json"), Options{})
+	require.NoError(t, err)
+
+	urls, users, err := store.Stats(ctx)
+	require.NoError(t, err)
+	assert.Zero(t, urls)
+	assert.Zero(t, users)
+
+	require.NoError(t, store.Save(ctx, usecase.URLPair{ShortID: "anon1", OriginalURL: "https://example.com/anon"}))
+	require.NoError(t, store.SaveBatch(ctx, []usecase.URLPair{
+		{ShortID: "u1a", OriginalURL: "https://example.com/1a", UserID: "user1"},
+		{ShortID: "u1b", OriginalURL: "https://example.com/1b", UserID: "user1"},
+		{ShortID: "u2a", OriginalURL: "https://example.com/2a", UserID: "user2"},
+	}))
+
+	urls, users, err = store.Stats(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 4, urls)
+	assert.Equal(t, 2, users)
+
+	// Удаленные ссылки не учитываются, как и пользователь без неудаленных ссылок
+	require.NoError(t, store.BatchDeleteUserURLs(ctx, "user2", []string{"u2a"}))
+	require.NoError(t, store.BatchDeleteUserURLs(ctx, "user1", []string{"u1a"}))
+
+	urls, users, err = store.Stats(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 2, urls)
+	assert.Equal(t, 1, users)
+
+	// Истекшие ссылки не учитываются, как и пользователь только с истекшими ссылками
+	require.NoError(t, store.SaveBatch(ctx, []usecase.URLPair{
+		{ShortID: "u3a", OriginalURL: "https://example.com/3a", UserID: "user3", ExpiresAt: time.Now().Add(-time.Second)},
+		{ShortID: "u3b", OriginalURL: "https://example.com/3b", UserID: "user3", ExpiresAt: time.Now().Add(time.Hour)},
+		{ShortID: "u4a", OriginalURL: "https://example.com/4a", UserID: "user4", ExpiresAt: time.Now().Add(-time.Second)},
+	}))
+
+	urls, users, err = store.Stats(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 3, urls)
+	assert.Equal(t, 2, users)
+}
+
+func TestInMemoryStorage_SoftDelete(t *testing.T) {
+	ctx := context.Background()
+	store, err := NewInMemoryStorage(filepath.Join(t.TempDir(), "urls.json"), Options{})
+	require.NoError(t, err)
+
+	service := usecase.NewURLService(store, "http://localhost:8080/", nil, usecase.Options{})
+	shortURL, err := service.ShortenWithUser(ctx, "https://example.com", "user1", usecase.ShortenOptions{})
+	require.NoError(t, err)
+	shortID := shortURL[len("http://localhost:8080/"):]
+
+	// Чужой URL не удаляется
+	require.NoError(t, store.BatchDeleteUserURLs(ctx, "user2", []string{shortID}))
+	_, err = store.Get(ctx, shortID)
+	require.NoError(t, err)
+
+	require.NoError(t, store.BatchDeleteUserURLs(ctx, "user1", []string{shortID}))
+
+	_, err = store.Get(ctx, shortID)
+	assert.True(t, usecase.IsURLDeleted(err))
+	_, err = service.Expand(ctx, shortID)
+	assert.True(t, usecase.IsURLDeleted(err))
+
+	urls, _, err := store.GetUserURLs(ctx, "user1", 0, 0)
+	require.NoError(t, err)
+	assert.Empty(t, urls)
+
+	result, err := store.DeleteUserURLsWithResult(ctx, "user1", []string{shortID})
+	require.NoError(t, err)
+	assert.Equal(t, []string{shortID}, result.AlreadyDeleted)
+
+	// Удаленные раньше before записи удаляются физически
+	purged, err := store.PurgeExpired(ctx, time.Now().Add(time.Second))
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), purged)
+	_, err = store.Get(ctx, shortID)
+	assert.ErrorIs(t, err, ErrNotFound)
+}
+
+func TestInMemoryStorage_SoftDeleteBackup(t *testing.T) {
+	ctx := context.Background()
+	filePath := filepath.Join(t.TempDir(), "urls.json")
+	store, err := NewInMemoryStorage(filePath, Options{})
+	require.NoError(t, err)
+
+	require.NoError(t, store.SaveBatch(ctx, []usecase.URLPair{
+		{ShortID: "abc123", OriginalURL: "https://example.com", UserID: "user1"},
+	}))
+	require.NoError(t, store.Backup())
+	require.NoError(t, store.BatchDeleteUserURLs(ctx, "user1", []string{"abc123"}))
+	require.NoError(t, store.Backup())
+
+	// Удаленная ссылка не возвращается ни после перечитывания файла, ни после перезапуска
+	require.NoError(t, store.Reload())
+	_, err = store.Get(ctx, "abc123")
+	assert.True(t, usecase.IsURLDeleted(err))
+
+	reopened, err := NewInMemoryStorage(filePath, Options{})
+	require.NoError(t, err)
+	_, err = reopened.Get(ctx, "abc123")
+	assert.True(t, usecase.IsURLDeleted(err))
+}
+
+func TestInMemoryStorage_GetUserURL(t *testing.T) {
+	store, err := NewInMemoryStorage(filepath.Join(t.TempDir(), "urls.json"), Options{})
+	require.NoError(t, err)
+
+	require.NoError(t, store.SaveBatch(context.Background(), []usecase.URLPair{
+		{ShortID: "own123", OriginalURL: "https://own.example.com", UserID: "user1"},
+		{ShortID: "other123", OriginalURL: "https://other.example.com", UserID: "user2"},
+	}))
+
+	url, err := store.GetUserURL(context.Background(), "user1", "own123")
+	require.NoError(t, err)
+	assert.Equal(t, "https://own.example.com", url.OriginalURL)
+
+	// Чужой и несуществующий URL неразличимы
+	_, err = store.GetUserURL(context.Background(), "user1", "other123")
+	assert.ErrorIs(t, err, usecase.ErrURLNotFound)
+	_, err = store.GetUserURL(context.Background(), "user1", "missing")
+	assert.ErrorIs(t, err, usecase.ErrURLNotFound)
+}
+
+func TestInMemoryStorage_Reset(t *testing.T) {
+	filePath := filepath.Join(t.TempDir(), "urls.json")
+	store, err := NewInMemoryStorage(filePath, Options{})
+	require.NoError(t, err)
+
+	require.NoError(t, store.SaveBatch(context.Background(), []usecase.URLPair{
+		{ShortID: "abc123", OriginalURL: "https://one.example.com", UserID: "user1"},
+		{ShortID: "def456", OriginalURL: "https://two.example.com", UserID: "user1"},
+	}))
+	require.NoError(t, store.Backup())
+
+	removed, err := store.Reset(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), removed)
+
+	_, err = store.Get(context.Background(), "abc123")
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	urls, _, err := store.GetUserURLs(context.Background(), "user1", 0, 0)
+	require.NoError(t, err)
+	assert.Empty(t, urls)
+
+	// Файл бэкапа тоже очищен, поэтому ссылки не вернутся после перезапуска
+	reopened, err := NewInMemoryStorage(filePath, Options{})
+	require.NoError(t, err)
+	_, err = reopened.Get(context.Background(), "abc123")
+	assert.ErrorIs(t, err, ErrNotFound)
+}
+
+func TestInMemoryStorage_GetURLOwner(t *testing.T) {
+	store, err := NewInMemoryStorage(filepath.Join(t.TempDir(), "urls.json"), Options{})
+	require.NoError(t, err)
+
+	before := time.Now()
+	require.NoError(t, store.SaveBatch(context.Background(), []usecase.URLPair{
+		{ShortID: "own123", OriginalURL: "https://own.example.com", UserID: "user1"},
+	}))
+	require.NoError(t, store.Save(context.Background(), usecase.URLPair{ShortID: "anon123", OriginalURL: "https://anon.example.com"}))
+
+	owner, err := store.GetURLOwner(context.Background(), "own123")
+	require.NoError(t, err)
+	assert.Equal(t, "user1", owner.UserID)
+	assert.False(t, owner.CreatedAt.Before(before))
+
+	// Анонимная ссылка существует, но создатель неизвестен
+	owner, err = store.GetURLOwner(context.Background(), "anon123")
+	require.NoError(t, err)
+	assert.Empty(t, owner.UserID)
+
+	_, err = store.GetURLOwner(context.Background(), "missing")
+	assert.ErrorIs(t, err, usecase.ErrURLNotFound)
+}
+
+func TestInMemoryStorage_GetUserURLsBaseURL(t *testing.T) {
+	store, err := NewInMemoryStorage(filepath.Join(t.TempDir(), "urls.json"), Options{})
+	require.NoError(t, err)
+
+	require.NoError(t, store.SaveBatch(context.Background(), []usecase.URLPair{
+		{ShortID: "abc123", OriginalURL: "https://example.com", UserID: "user1"},
+	}))
+
+	// Хранилище возвращает только короткий ID, короткий URL собирает сервис
+	urls, _, err := store.GetUserURLs(context.Background(), "user1", 0, 0)
+	require.NoError(t, err)
+	require.Len(t, urls, 1)
+	assert.Equal(t, "abc123", urls[0].ShortID)
+	assert.Empty(t, urls[0].ShortURL)
+
+	service := usecase.NewURLService(store, "https://sho.rt/", nil, usecase.Options{})
+	urls, _, err = service.GetUserURLs(context.Background(), "user1", 0, 0)
+	require.NoError(t, err)
+	require.Len(t, urls, 1)
+	assert.Equal(t, "https://sho.rt/abc123", urls[0].ShortURL)
+}
+
+func TestInMemoryStorage_RecentPublicURLs(t *testing.T) {
+	store, err := NewInMemoryStorage(filepath.Join(t.TempDir(), "urls.json"), Options{})
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	require.NoError(t, store.SaveBatch(ctx, []usecase.URLPair{
+		{ShortID: "old123", OriginalURL: "https://old.example.com", Public: true},
+		{ShortID: "new123", OriginalURL: "https://new.example.com", Public: true},
+		{ShortID: "mid123", OriginalURL: "https://mid.example.com", Public: true},
+		{ShortID: "priv123", OriginalURL: "https://private.example.com"},
+		{ShortID: "gone123", OriginalURL: "https://expired.example.com", Public: true, ExpiresAt: time.Now().Add(-time.Minute)},
+	}))
+
+	now := time.Now()
+	store.created["old123"] = now.Add(-3 * time.Hour)
+	store.created["mid123"] = now.Add(-2 * time.Hour)
+	store.created["new123"] = now.Add(-time.Hour)
+
+	urls, err := store.RecentPublicURLs(ctx, 10)
+	require.NoError(t, err)
+	shortIDs := make([]string, len(urls))
+	for i, url := range urls {
+		shortIDs[i] = url.ShortID
+	}
+	// Приватные и истекшие ссылки в ленту не попадают
+	assert.Equal(t, []string{"new123", "mid123", "old123"}, shortIDs)
+	assert.Equal(t, "https://new.example.com", urls[0].OriginalURL)
+
+	urls, err = store.RecentPublicURLs(ctx, 2)
+	require.NoError(t, err)
+	assert.Len(t, urls, 2)
+}
+
+func TestInMemoryStorage_MaxURLs(t *testing.T) {
+	t.Run("reject", func(t *testing.T) {
+		store, err := NewInMemoryStorage(filepath.Join(t.TempDir(), "urls.json"), Options{MaxURLs: 2, Eviction: EvictReject})
+		require.NoError(t, err)
+
+		require.NoError(t, store.Save(context.Background(), usecase.URLPair{ShortID: "a", OriginalURL: "https://a.example.com"}))
+		require.NoError(t, store.Save(context.Background(), usecase.URLPair{ShortID: "b", OriginalURL: "https://b.example.com"}))
+		assert.ErrorIs(t, store.Save(context.Background(), usecase.URLPair{ShortID: "c", OriginalURL: "https://c.example.com"}), usecase.ErrStorageFull)
+
+		// batch не сохраняется частично
+		err = store.SaveBatch(context.Background(), []usecase.URLPair{
+			{ShortID: "b", OriginalURL: "https://b.example.com", UserID: "user1"},
+			{ShortID: "d", OriginalURL: "https://d.example.com"},
+		})
+		assert.ErrorIs(t, err, usecase.ErrStorageFull)
+		_, err = store.Get(context.Background(), "d")
+		assert.ErrorIs(t, err, usecase.ErrURLNotFound)
+
+		// Обновление уже сохраненной ссылки не требует места
+		require.NoError(t, store.SaveBatch(context.Background(), []usecase.URLPair{
+			{ShortID: "a", OriginalURL: "https://a.example.com", UserID: "user1"},
+		}))
+	})
+
+	t.Run("lru", func(t *testing.T) {
+		store, err := NewInMemoryStorage(filepath.Join(t.TempDir(), "urls.json"), Options{MaxURLs: 2, Eviction: EvictLRU})
+		require.NoError(t, err)
+
+		require.NoError(t, store.SaveBatch(context.Background(), []usecase.URLPair{
+			{ShortID: "a", OriginalURL: "https://a.example.com", UserID: "user1"},
+		}))
+		require.NoError(t, store.Save(context.Background(), usecase.URLPair{ShortID: "b", OriginalURL: "https://b.example.com"}))
+
+		// Обращение к a делает давней ссылку b
+		_, err = store.Get(context.Background(), "a")
+		require.NoError(t, err)
+		require.NoError(t, store.Save(context.Background(), usecase.URLPair{ShortID: "c", OriginalURL: "https://c.example.com"}))
+
+		_, err = store.Get(context.Background(), "b")
+		assert.ErrorIs(t, err, usecase.ErrURLNotFound)
+		// Список пользователя не считается обращением и не меняет порядок
+		urls, _, err := store.GetUserURLs(context.Background(), "user1", 0, 0)
+		require.NoError(t, err)
+		assert.Len(t, urls, 1)
+
+		// Следующей вытесняется a вместе со связью с пользователем
+		require.NoError(t, store.Save(context.Background(), usecase.URLPair{ShortID: "d", OriginalURL: "https://d.example.com"}))
+		_, err = store.Get(context.Background(), "a")
+		assert.ErrorIs(t, err, usecase.ErrURLNotFound)
+		urls, _, err = store.GetUserURLs(context.Background(), "user1", 0, 0)
+		require.NoError(t, err)
+		assert.Empty(t, urls)
+	})
+}
+
+func TestInMemoryStorage_VisitCap(t *testing.T) {
+	store, err := NewInMemoryStorage(filepath.Join(t.TempDir(), "urls.json"), Options{VisitCap: 3})
+	require.NoError(t, err)
+
+	require.NoError(t, store.SaveBatch(context.Background(), []usecase.URLPair{
+		{ShortID: "hot123", OriginalURL: "https://hot.example.com", UserID: "user1"},
+	}))
+
+	for i := 0; i < 2; i++ {
+		require.NoError(t, store.IncrementVisits(context.Background(), "hot123"))
+	}
+	url, err := store.GetUserURL(context.Background(), "user1", "hot123")
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), url.Visits)
+	assert.False(t, url.VisitsCapped)
+
+	for i := 0; i < 5; i++ {
+		require.NoError(t, store.IncrementVisits(context.Background(), "hot123"))
+	}
+	url, err = store.GetUserURL(context.Background(), "user1", "hot123")
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), url.Visits)
+	assert.True(t, url.VisitsCapped)
+}
+
+func TestInMemoryStorage_PersistVisits(t *testing.T) {
+	tests := []struct {
+		name          string
+		persistVisits bool
+		wantVisits    int64
+	}{
+		{name: "visits persisted", persistVisits: true, wantVisits: 2},
+		{name: "visits not persisted", persistVisits: false, wantVisits: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filePath := filepath.Join(t.TempDir(), "urls.json")
+			store, err := NewInMemoryStorage(filePath, Options{PersistVisits: tt.persistVisits})
+			require.NoError(t, err)
+
+			require.NoError(t, store.SaveBatch(context.Background(), []usecase.URLPair{
+				{ShortID: "abc123", OriginalURL: "https://example.com", UserID: "user1"},
+			}))
+			require.NoError(t, store.IncrementVisits(context.Background(), "abc123"))
+			require.NoError(t, store.IncrementVisits(context.Background(), "abc123"))
+			require.NoError(t, store.Backup())
+
+			reopened, err := NewInMemoryStorage(filePath, Options{PersistVisits: tt.persistVisits})
+			require.NoError(t, err)
+			require.NoError(t, reopened.SaveBatch(context.Background(), []usecase.URLPair{
+				{ShortID: "abc123", OriginalURL: "https://example.com", UserID: "user1"},
+			}))
+
+			url, err := reopened.GetUserURL(context.Background(), "user1", "abc123")
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantVisits, url.Visits)
+		})
+	}
+}
+
+func TestInMemoryStorage_LoadWithoutVisits(t *testing.T) {
+	// Файл старого формата без поля visits
+	filePath := filepath.Join(t.TempDir(), "urls.json")
+	require.NoError(t, os.WriteFile(filePath, []byte(`[{"uuid":"1","short_url":"abc123","original_url":"https://example.com"}]`), 0644))
+
+	store, err := NewInMemoryStorage(filePath, Options{PersistVisits: true})
+	require.NoError(t, err)
+
+	url, err := store.Get(context.Background(), "abc123")
+	require.NoError(t, err)
+	assert.Equal(t, "https://example.com", url)
+	assert.Empty(t, store.visits)
+}
+
+func TestInMemoryStorage_GetUserURLsPagination(t *testing.T) {
+	ctx := context.Background()
+	store, err := NewInMemoryStorage(filepath.Join(t.TempDir(), "urls.json"), Options{})
+	require.NoError(t, err)
+
+	require.NoError(t, store.SaveBatch(ctx, []usecase.URLPair{
+		{ShortID: "id1", OriginalURL: "https://example.com/1", UserID: "user1"},
+		{ShortID: "id2", OriginalURL: "https://example.com/2", UserID: "user1"},
+		{ShortID: "id3", OriginalURL: "https://example.com/3", UserID: "user1"},
+		{ShortID: "id4", OriginalURL: "https://example.com/4", UserID: "user1"},
+		{ShortID: "id5", OriginalURL: "https://example.com/5", UserID: "user1"},
+	}))
+	require.NoError(t, store.BatchDeleteUserURLs(ctx, "user1", []string{"id2"}))
+
+	tests := []struct {
+		name     string
+		limit    int
+		offset   int
+		expected []string
+	}{
+		{name: "без ограничения", expected: []string{"id1", "id3", "id4", "id5"}},
+		{name: "первая страница", limit: 2, expected: []string{"id1", "id3"}},
+		{name: "вторая страница", limit: 2, offset: 2, expected: []string{"id4", "id5"}},
+		{name: "неполная страница", limit: 3, offset: 3, expected: []string{"id5"}},
+		{name: "offset за концом списка", limit: 2, offset: 10, expected: nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			urls, total, err := store.GetUserURLs(ctx, "user1", tt.limit, tt.offset)
+			require.NoError(t, err)
+			assert.Equal(t, 4, total)
+
+			var shortIDs []string
+			for _, url := range urls {
+				shortIDs = append(shortIDs, url.ShortID)
+			}
+			assert.Equal(t, tt.expected, shortIDs)
+		})
+	}
+}
+
+func TestInMemoryStorage_DeleteAllUserURLs(t *testing.T) {
+	ctx := context.Background()
+	store, err := NewInMemoryStorage(filepath.Join(t.TempDir(), "urls.json"), Options{})
+	require.NoError(t, err)
+
+	require.NoError(t, store.SaveBatch(ctx, []usecase.URLPair{
+		{ShortID: "own1", OriginalURL: "https://example.com/1", UserID: "user1"},
+		{ShortID: "own2", OriginalURL: "https://example.com/2", UserID: "user1"},
+		{ShortID: "own3", OriginalURL: "https://example.com/3", UserID: "user1"},
+		{ShortID: "other", OriginalURL: "https://example.com/other", UserID: "user2"},
+	}))
+	require.NoError(t, store.BatchDeleteUserURLs(ctx, "user1", []string{"own3"}))
+
+	deleted, err := store.DeleteAllUserURLs(ctx, "user1")
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), deleted, "уже удаленная ссылка не учитывается")
+
+	_, err = store.Get(ctx, "own1")
+	assert.True(t, usecase.IsURLDeleted(err))
+	urls, total, err := store.GetUserURLs(ctx, "user1", 0, 0)
+	require.NoError(t, err)
+	assert.Empty(t, urls)
+	assert.Zero(t, total)
+
+	// Ссылки других пользователей не затрагиваются
+	url, err := store.Get(ctx, "other")
+	require.NoError(t, err)
+	assert.Equal(t, "https://example.com/other", url)
+
+	deleted, err = store.DeleteAllUserURLs(ctx, "user1")
+	require.NoError(t, err)
+	assert.Zero(t, deleted)
+}
+
+func TestInMemoryStorage_ShortIDCollision(t *testing.T) {
+	ctx := context.Background()
+	store, err := NewInMemoryStorage(filepath.Join(t.TempDir(), "urls.json"), Options{DisableDedup: true})
+	require.NoError(t, err)
+
+	require.NoError(t, store.Save(ctx, usecase.URLPair{ShortID: "abcd", OriginalURL: "https://owner.example.com"}))
+	require.NoError(t, store.SaveBatch(ctx, []usecase.URLPair{
+		{ShortID: "efgh", OriginalURL: "https://batch.example.com", UserID: "user1"},
+	}))
+
+	// Занятый ID не перезаписывается другой ссылкой
+	err = store.Save(ctx, usecase.URLPair{ShortID: "abcd", OriginalURL: "https://attacker.example.com"})
+	assert.ErrorIs(t, err, usecase.ErrShortIDCollision)
+
+	// Batch с занятым ID отклоняется целиком, без частичных изменений
+	err = store.SaveBatch(ctx, []usecase.URLPair{
+		{ShortID: "new1", OriginalURL: "https://new.example.com", UserID: "user2"},
+		{ShortID: "efgh", OriginalURL: "https://attacker.example.com", UserID: "user2", ExpiresAt: time.Now().Add(-time.Second)},
+	})
+	assert.ErrorIs(t, err, usecase.ErrShortIDCollision)
+
+	originalURL, err := store.Get(ctx, "abcd")
+	require.NoError(t, err)
+	assert.Equal(t, "https://owner.example.com", originalURL)
+
+	originalURL, err = store.Get(ctx, "efgh")
+	require.NoError(t, err)
+	assert.Equal(t, "https://batch.example.com", originalURL)
+
+	_, err = store.Get(ctx, "new1")
+	assert.ErrorIs(t, err, ErrNotFound)
+	urls, _, err := store.GetUserURLs(ctx, "user2", 0, 0)
+	require.NoError(t, err)
+	assert.Empty(t, urls)
+
+	// Повторное сохранение той же ссылки под тем же ID не считается коллизией
+	require.NoError(t, store.Save(ctx, usecase.URLPair{ShortID: "abcd", OriginalURL: "https://owner.example.com"}))
+	require.NoError(t, store.SaveBatch(ctx, []usecase.URLPair{
+		{ShortID: "abcd", OriginalURL: "https://owner.example.com", UserID: "user1"},
+	}))
+}
+
+func TestInMemoryStorage_DedupSkipsDeadLinks(t *testing.T) {
+	ctx := context.Background()
+	store, err := NewInMemoryStorage(filepath.Join(t.TempDir(), "urls.json"), Options{})
+	require.NoError(t, err)
+
+	require.NoError(t, store.SaveBatch(ctx, []usecase.URLPair{
+		{ShortID: "expired", OriginalURL: "https://expired.example.com", ExpiresAt: time.Now().Add(-time.Second)},
+		{ShortID: "deleted", OriginalURL: "https://deleted.example.com", UserID: "user1"},
+	}))
+	require.NoError(t, store.BatchDeleteUserURLs(ctx, "user1", []string{"deleted"}))
+
+	// Истекшая и удаленная ссылки не возвращаются как существующие, URL сокращается заново
+	require.NoError(t, store.Save(ctx, usecase.URLPair{ShortID: "new1", OriginalURL: "https://expired.example.com"}))
+	require.NoError(t, store.Save(ctx, usecase.URLPair{ShortID: "new2", OriginalURL: "https://deleted.example.com"}))
+
+	err = store.Save(ctx, usecase.URLPair{ShortID: "new3", OriginalURL: "https://expired.example.com"})
+	conflictErr, isConflict := usecase.IsURLConflict(err)
+	require.True(t, isConflict)
+	assert.Equal(t, "new1", conflictErr.ExistingShortURL)
+}
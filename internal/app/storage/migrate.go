@@ -0,0 +1,146 @@
+package storage
+
+import (
+	"context"
+	"embed"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/m-molecula741/shortener/internal/app/logger"
+)
+
+// migrationFiles содержит SQL миграции схемы PostgreSQL. Имя файла - номер версии и описание:
+// 0001_create_urls.sql. Примененные версии записываются в таблицу schema_migrations.
+//
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+// noTransactionDirective в первой строке файла отключает транзакцию для миграции. Нужна для
+// команд, которые нельзя выполнять в транзакции (CREATE INDEX CONCURRENTLY). Такой файл должен
+// содержать одну идемпотентную команду: при сбое до записи версии она выполнится повторно.
+const noTransactionDirective = "-- migrate:no-transaction"
+
+// migrationLockKey - ключ advisory lock, под которым реплики по очереди применяют миграции
+const migrationLockKey int64 = 7_310_593_858_563_514_368
+
+// migration - одна версия схемы
+type migration struct {
+	version int64
+	name    string
+	sql     string
+	noTx    bool
+}
+
+// embeddedMigrations возвращает миграции, встроенные в исполняемый файл
+func embeddedMigrations() ([]migration, error) {
+	fsys, err := fs.Sub(migrationFiles, "migrations")
+	if err != nil {
+		return nil, err
+	}
+	return loadMigrations(fsys)
+}
+
+// loadMigrations читает миграции из fsys и сортирует их по версии
+func loadMigrations(fsys fs.FS) ([]migration, error) {
+	paths, err := fs.Glob(fsys, "*.sql")
+	if err != nil {
+		return nil, err
+	}
+
+	migrations := make([]migration, 0, len(paths))
+	seen := make(map[int64]string, len(paths))
+	for _, p := range paths {
+		name := strings.TrimSuffix(path.Base(p), ".sql")
+		prefix, _, _ := strings.Cut(name, "_")
+		version, err := strconv.ParseInt(prefix, 10, 64)
+		if err != nil || version <= 0 {
+			return nil, fmt.Errorf("invalid migration file name %q: must start with a positive version number", p)
+		}
+		if other, exists := seen[version]; exists {
+			return nil, fmt.Errorf("duplicate migration version %d: %s and %s", version, other, p)
+		}
+		seen[version] = p
+
+		sql, err := fs.ReadFile(fsys, p)
+		if err != nil {
+			return nil, err
+		}
+		migrations = append(migrations, migration{
+			version: version,
+			name:    name,
+			sql:     string(sql),
+			noTx:    strings.HasPrefix(string(sql), noTransactionDirective),
+		})
+	}
+
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].version < migrations[j].version })
+	return migrations, nil
+}
+
+// applyMigrations применяет еще не записанные в schema_migrations миграции и возвращает их количество.
+// Каждая миграция выполняется в транзакции вместе с записью версии, поэтому частично примененных
+// версий не бывает. Вызывающий код должен удерживать migrationLockKey.
+func applyMigrations(ctx context.Context, conn *pgx.Conn, migrations []migration) (int, error) {
+	_, err := conn.Exec(ctx, `
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			version BIGINT PRIMARY KEY,
+			name TEXT NOT NULL,
+			applied_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+		)
+	`)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create schema_migrations: %w", err)
+	}
+
+	rows, err := conn.Query(ctx, `SELECT version FROM schema_migrations`)
+	if err != nil {
+		return 0, fmt.Errorf("failed to query applied migrations: %w", err)
+	}
+	versions, err := pgx.CollectRows(rows, pgx.RowTo[int64])
+	if err != nil {
+		return 0, fmt.Errorf("failed to query applied migrations: %w", err)
+	}
+	applied := make(map[int64]bool, len(versions))
+	for _, version := range versions {
+		applied[version] = true
+	}
+
+	count := 0
+	for _, m := range migrations {
+		if applied[m.version] {
+			continue
+		}
+		if err := applyMigration(ctx, conn, m); err != nil {
+			return count, fmt.Errorf("failed to apply migration %s: %w", m.name, err)
+		}
+		logger.Info().Int64("version", m.version).Str("name", m.name).Msg("Database migration applied")
+		count++
+	}
+	return count, nil
+}
+
+// applyMigration выполняет миграцию и записывает ее версию
+func applyMigration(ctx context.Context, conn *pgx.Conn, m migration) error {
+	const record = `INSERT INTO schema_migrations (version, name) VALUES ($1, $2)`
+
+	if m.noTx {
+		if _, err := conn.Exec(ctx, m.sql); err != nil {
+			return err
+		}
+		_, err := conn.Exec(ctx, record, m.version, m.name)
+		return err
+	}
+
+	return pgx.BeginFunc(ctx, conn, func(tx pgx.Tx) error {
+		if _, err := tx.Exec(ctx, m.sql); err != nil {
+			return err
+		}
+		_, err := tx.Exec(ctx, record, m.version, m.name)
+		return err
+	})
+}
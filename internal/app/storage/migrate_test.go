@@ -0,0 +1,182 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/m-molecula741/shortener/internal/app/usecase"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadMigrations(t *testing.T) {
+	tests := []struct {
+		name        string
+		files       fstest.MapFS
+		expected    []migration
+		expectedErr bool
+	}{
+		{
+			name: "сортировка по версии",
+			files: fstest.MapFS{
+				"0010_add_index.sql":  {Data: []byte("-- migrate:no-transaction\nCREATE INDEX CONCURRENTLY i ON t(c);")},
+				"0002_add_column.sql": {Data: []byte("ALTER TABLE t ADD COLUMN c INT;")},
+				"README.md":           {Data: []byte("не миграция")},
+			},
+			expected: []migration{
+				{version: 2, name: "0002_add_column", sql: "ALTER TABLE t ADD COLUMN c INT;"},
+				{version: 10, name: "0010_add_index", sql: "-- migrate:no-transaction\nCREATE INDEX CONCURRENTLY i ON t(c);", noTx: true},
+			},
+		},
+		{
+			name:        "имя без версии",
+			files:       fstest.MapFS{"add_column.sql": {Data: []byte("SELECT 1;")}},
+			expectedErr: true,
+		},
+		{
+			name: "повторная версия",
+			files: fstest.MapFS{
+				"0001_a.sql": {Data: []byte("SELECT 1;")},
+				"1_b.sql":    {Data: []byte("SELECT 2;")},
+			},
+			expectedErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			migrations, err := loadMigrations(tt.files)
+			if tt.expectedErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, migrations)
+		})
+	}
+}
+
+func TestEmbeddedMigrations(t *testing.T) {
+	migrations, err := embeddedMigrations()
+	require.NoError(t, err)
+	require.NotEmpty(t, migrations)
+
+	// Версии идут подряд: пропуск обычно означает переименованный или потерянный файл
+	for i, m := range migrations {
+		assert.Equal(t, int64(i+1), m.version, m.name)
+	}
+}
+
+// testDatabaseDSNEnv - переменная окружения с DSN тестовой БД; без нее тесты с БД пропускаются
+const testDatabaseDSNEnv = "TEST_DATABASE_DSN"
+
+// newTestSchemaDSN создает в тестовой БД отдельную схему и возвращает DSN, в котором она
+// указана в search_path. Схема удаляется после теста.
+func newTestSchemaDSN(t *testing.T) string {
+	t.Helper()
+
+	dsn := os.Getenv(testDatabaseDSNEnv)
+	if dsn == "" {
+		t.Skipf("%s is not set", testDatabaseDSNEnv)
+	}
+
+	ctx := context.Background()
+	conn, err := pgx.Connect(ctx, dsn)
+	require.NoError(t, err)
+	defer conn.Close(ctx)
+
+	schema := fmt.Sprintf("test_migrations_%d", time.Now().UnixNano())
+	_, err = conn.Exec(ctx, "CREATE SCHEMA "+schema)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		conn, err := pgx.Connect(context.Background(), dsn)
+		if err != nil {
+			return
+		}
+		defer conn.Close(context.Background())
+		conn.Exec(context.Background(), "DROP SCHEMA "+schema+" CASCADE")
+	})
+
+	u, err := url.Parse(dsn)
+	require.NoError(t, err)
+	query := u.Query()
+	query.Set("search_path", schema)
+	u.RawQuery = query.Encode()
+	return u.String()
+}
+
+func TestPostgresStorage_Migrate(t *testing.T) {
+	dsn := newTestSchemaDSN(t)
+	ctx := context.Background()
+
+	migrations, err := embeddedMigrations()
+	require.NoError(t, err)
+
+	store, err := NewPostgresStorage(ctx, dsn, nil, Options{})
+	require.NoError(t, err)
+
+	var recorded int
+	require.NoError(t, store.pool.QueryRow(ctx, `SELECT count(*) FROM schema_migrations`).Scan(&recorded))
+	assert.Equal(t, len(migrations), recorded)
+
+	require.NoError(t, store.Save(ctx, usecase.URLPair{ShortID: "abc123", OriginalURL: "https://example.com"}))
+	store.Close()
+
+	// Повторный запуск ничего не применяет и не трогает данные
+	store, err = NewPostgresStorage(ctx, dsn, nil, Options{})
+	require.NoError(t, err)
+	defer store.Close()
+
+	applied, err := store.migrate(ctx)
+	require.NoError(t, err)
+	assert.Zero(t, applied)
+
+	originalURL, err := store.Get(ctx, "abc123")
+	require.NoError(t, err)
+	assert.Equal(t, "https://example.com", originalURL)
+}
+
+func TestPostgresStorage_Migrate_ExistingSchema(t *testing.T) {
+	dsn := newTestSchemaDSN(t)
+	ctx := context.Background()
+
+	// Таблица, созданная версией сервиса до появления schema_migrations
+	conn, err := pgx.Connect(ctx, dsn)
+	require.NoError(t, err)
+	_, err = conn.Exec(ctx, `
+		CREATE TABLE urls (
+			short_id VARCHAR(8) PRIMARY KEY,
+			original_url TEXT NOT NULL,
+			user_id VARCHAR(36),
+			is_deleted BOOLEAN DEFAULT FALSE,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+		);
+		INSERT INTO urls (short_id, original_url) VALUES ('old12345', 'https://example.com/old');
+	`)
+	require.NoError(t, err)
+	conn.Close(ctx)
+
+	store, err := NewPostgresStorage(ctx, dsn, nil, Options{})
+	require.NoError(t, err)
+	defer store.Close()
+
+	originalURL, err := store.Get(ctx, "old12345")
+	require.NoError(t, err)
+	assert.Equal(t, "https://example.com/old", originalURL)
+
+	// Столбцы из миграций добавлены, short_id расширен
+	require.NoError(t, store.Save(ctx, usecase.URLPair{ShortID: "abcdefghijklmnopqrstuvwxyz", OriginalURL: "https://example.com/new"}))
+	require.NoError(t, store.IncrementVisits(ctx, "old12345"))
+
+	// Строка, сохраненная до миграции, получила хеш и находится как дубликат
+	err = store.Save(ctx, usecase.URLPair{ShortID: "dup12345", OriginalURL: "https://example.com/old"})
+	conflictErr, isConflict := usecase.IsURLConflict(err)
+	require.True(t, isConflict)
+	assert.Equal(t, "old12345", conflictErr.ExistingShortURL)
+}
@@ -0,0 +1,7 @@
+CREATE TABLE IF NOT EXISTS urls (
+	short_id VARCHAR(32) PRIMARY KEY,
+	original_url TEXT NOT NULL,
+	user_id VARCHAR(36),
+	is_deleted BOOLEAN DEFAULT FALSE,
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
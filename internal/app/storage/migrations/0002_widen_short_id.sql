@@ -0,0 +1,9 @@
+-- Увеличение длины VARCHAR меняет только метаданные и не перезаписывает таблицу,
+-- но берет эксклюзивную блокировку, поэтому выполняется только для старой схемы
+DO $$
+BEGIN
+	IF (SELECT character_maximum_length FROM information_schema.columns
+		WHERE table_schema = current_schema() AND table_name = 'urls' AND column_name = 'short_id') < 32 THEN
+		ALTER TABLE urls ALTER COLUMN short_id TYPE VARCHAR(32);
+	END IF;
+END $$;
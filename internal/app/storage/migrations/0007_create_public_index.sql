@@ -0,0 +1,2 @@
+-- migrate:no-transaction
+CREATE INDEX CONCURRENTLY IF NOT EXISTS idx_urls_public_created_at ON urls(created_at DESC) WHERE is_public;
@@ -0,0 +1 @@
+CREATE SEQUENCE IF NOT EXISTS short_id_seq START WITH 1 INCREMENT BY 1000;
@@ -0,0 +1,5 @@
+-- Хеш ключа дедупликации: уникальный индекс по нему заменяет индекс по полному original_url,
+-- который не находит дубликаты зашифрованных URL. Столбец допускает NULL и добавляется
+-- без перезаписи таблицы; строки, сохраненные раньше, получают хеш открытого original_url.
+ALTER TABLE urls ADD COLUMN IF NOT EXISTS original_url_hash BYTEA;
+UPDATE urls SET original_url_hash = sha256(convert_to(original_url, 'UTF8')) WHERE original_url_hash IS NULL;
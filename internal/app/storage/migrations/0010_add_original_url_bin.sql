@@ -0,0 +1,5 @@
+-- Сжатые URL хранятся байтами: TEXT не принимает нулевой байт и невалидный UTF-8, а base64
+-- увеличивал значение на треть. В таких строках original_url пуст (NULL). Снятие NOT NULL
+-- меняет только метаданные и не перезаписывает таблицу.
+ALTER TABLE urls ADD COLUMN IF NOT EXISTS original_url_bin BYTEA;
+ALTER TABLE urls ALTER COLUMN original_url DROP NOT NULL;
@@ -0,0 +1,58 @@
+// Package storage предоставляет различные реализации хранилища URL
+package storage
+
+import (
+	"fmt"
+	"time"
+)
+
+// EvictionPolicy определяет поведение хранилища в памяти при достижении MaxURLs
+type EvictionPolicy string
+
+// Политики вытеснения
+const (
+	EvictReject EvictionPolicy = "reject" // новые ссылки отклоняются с usecase.ErrStorageFull
+	EvictLRU    EvictionPolicy = "lru"    // вытесняется ссылка, к которой дольше всего не обращались
+)
+
+// ParseEvictionPolicy разбирает название политики; пустая строка означает EvictReject
+func ParseEvictionPolicy(value string) (EvictionPolicy, error) {
+	switch policy := EvictionPolicy(value); policy {
+	case "":
+		return EvictReject, nil
+	case EvictReject, EvictLRU:
+		return policy, nil
+	}
+	return "", fmt.Errorf("unknown eviction policy %q", value)
+}
+
+// Options содержит общие настройки хранилищ URL
+type Options struct {
+	// DisableDedup отключает дедупликацию по original_url: каждое сокращение
+	// создает новый короткий URL, а ErrURLConflict никогда не возвращается
+	DisableDedup bool
+
+	// VisitCap - порог, после которого счетчик переходов перестает увеличиваться,
+	// чтобы популярные ссылки не нагружали хранилище записью; 0 - без ограничения
+	VisitCap int64
+
+	// PersistVisits сохраняет счетчики переходов в файл бэкапа вместе с URL
+	// и восстанавливает их при запуске; влияет только на хранилище в памяти
+	PersistVisits bool
+
+	// MaxURLs ограничивает количество ссылок в хранилище в памяти, 0 - без ограничения.
+	// Поведение при достижении ограничения задает Eviction.
+	MaxURLs  int
+	Eviction EvictionPolicy
+
+	// ConnectRetries - число повторных проверок подключения к PostgreSQL при создании хранилища,
+	// например пока БД в соседнем контейнере еще запускается; 0 - без повторов.
+	// ConnectBackoff - пауза перед первым повтором, удваивается с каждой попыткой, 0 - 500 мс.
+	ConnectRetries int
+	ConnectBackoff time.Duration
+}
+
+// visitsCapped сообщает, достиг ли счетчик переходов порога и перестал быть точным
+func (o Options) visitsCapped(visits int64) bool {
+	return o.VisitCap > 0 && visits >= o.VisitCap
+}
@@ -3,13 +3,16 @@ package storage
 
 import (
 	"context"
+	"crypto/sha256"
 	"errors"
 	"fmt"
 	"time"
 
 	"github.com/jackc/pgerrcode"
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/m-molecula741/shortener/internal/app/logger"
 	"github.com/m-molecula741/shortener/internal/app/usecase"
 )
 
@@ -24,10 +27,17 @@ type PoolConfig struct {
 // PostgresStorage реализует хранение URL в PostgreSQL
 type PostgresStorage struct {
 	pool *pgxpool.Pool
+	opts Options
+
+	// ctx - родительский контекст хранилища для операций, которым вызывающий код
+	// не передает собственный контекст (Ping)
+	ctx context.Context
 }
 
-// NewPostgresStorage создает новый экземпляр PostgresStorage с оптимизированными настройками пула соединений
-func NewPostgresStorage(dsn string, poolCfg *PoolConfig) (*PostgresStorage, error) {
+// NewPostgresStorage создает новый экземпляр PostgresStorage с оптимизированными настройками пула соединений.
+// ctx используется для подключения и применения миграций, а также сохраняется для операций
+// без собственного контекста, поэтому должен жить столько же, сколько хранилище.
+func NewPostgresStorage(ctx context.Context, dsn string, poolCfg *PoolConfig, opts Options) (*PostgresStorage, error) {
 	config, err := pgxpool.ParseConfig(dsn)
 	if err != nil {
 		return nil, err
@@ -48,72 +58,209 @@ func NewPostgresStorage(dsn string, poolCfg *PoolConfig) (*PostgresStorage, erro
 	}
 	config.HealthCheckPeriod = 1 * time.Minute // Период проверки здоровья соединений
 
-	pool, err := pgxpool.NewWithConfig(context.Background(), config)
+	pool, err := pgxpool.NewWithConfig(ctx, config)
 	if err != nil {
 		return nil, err
 	}
 
+	if err := pingWithRetry(ctx, pool.Ping, opts.ConnectRetries, opts.ConnectBackoff); err != nil {
+		pool.Close()
+		return nil, err
+	}
+
 	storage := &PostgresStorage{
 		pool: pool,
+		opts: opts,
+		ctx:  ctx,
 	}
 
-	// Создаем таблицу при инициализации
-	if err := storage.createTable(); err != nil {
+	// Приводим схему к актуальной версии при инициализации
+	if _, err := storage.migrate(ctx); err != nil {
 		pool.Close()
-		return nil, fmt.Errorf("failed to create table: %w", err)
+		return nil, fmt.Errorf("failed to migrate database: %w", err)
 	}
 
 	return storage, nil
 }
 
-func (s *PostgresStorage) createTable() error {
-	query := `
-		CREATE TABLE IF NOT EXISTS urls (
-			short_id VARCHAR(8) PRIMARY KEY,
-			original_url TEXT NOT NULL,
-			user_id VARCHAR(36),
-			is_deleted BOOLEAN DEFAULT FALSE,
-			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
-		);
-		CREATE UNIQUE INDEX IF NOT EXISTS idx_urls_original_url ON urls(original_url);
-	`
-	_, err := s.pool.Exec(context.Background(), query)
-	return err
+// defaultConnectBackoff - пауза перед первой повторной проверкой подключения, дальше она удваивается
+const defaultConnectBackoff = 500 * time.Millisecond
+
+// pingWithRetry проверяет подключение к БД, повторяя проверку до retries раз
+// с удваивающейся паузой. Отмена ctx прерывает ожидание.
+func pingWithRetry(ctx context.Context, ping func(context.Context) error, retries int, backoff time.Duration) error {
+	if backoff <= 0 {
+		backoff = defaultConnectBackoff
+	}
+
+	err := ping(ctx)
+	for attempt := 1; err != nil && attempt <= retries; attempt++ {
+		logger.Warn().
+			Err(err).
+			Int("attempt", attempt).
+			Dur("backoff", backoff).
+			Msg("Database is not available, retrying")
+
+		timer := time.NewTimer(backoff)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return fmt.Errorf("failed to connect to database: %w", errors.Join(ctx.Err(), err))
+		case <-timer.C:
+		}
+		backoff *= 2
+		err = ping(ctx)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to connect to database after %d attempts: %w", retries+1, err)
+	}
+	return nil
+}
+
+// migrationLockTimeout ограничивает ожидание блокировки таблицы при миграции. ALTER TABLE
+// в очереди за долгой транзакцией блокирует все последующие запросы к таблице, поэтому
+// лучше быстро завершить запуск ошибкой, чем остановить работающие реплики.
+const migrationLockTimeout = "5s"
+
+// shortIDConstraint - имя ограничения первичного ключа short_id, которое PostgreSQL
+// создает по умолчанию для таблицы urls
+const shortIDConstraint = "urls_pkey"
+
+// dedupIndex - уникальный индекс по хешу ключа дедупликации, см. urlHash
+const dedupIndex = "idx_urls_original_url_hash"
+
+// migrate применяет миграции схемы из migrationFiles и возвращает количество примененных.
+// Миграции должны быть безопасны для работающей таблицы:
+//   - новые столбцы либо допускают NULL, либо имеют константный DEFAULT и добавляются
+//     без перезаписи таблицы (PostgreSQL 11+);
+//   - индексы строятся CONCURRENTLY, не блокируя запись;
+//   - команды выполняются с lock_timeout.
+//
+// Реплики, запускающиеся одновременно, применяют миграции по очереди под advisory lock.
+// Запросы читают столбцы, добавленные миграциями, через COALESCE, чтобы строки,
+// созданные до миграции или старой версией сервиса, не приводили к ошибкам.
+func (s *PostgresStorage) migrate(ctx context.Context) (int, error) {
+	migrations, err := embeddedMigrations()
+	if err != nil {
+		return 0, err
+	}
+
+	// Блокировки и lock_timeout действуют в пределах сессии, поэтому все выполняется на одном соединении
+	conn, err := s.pool.Acquire(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer conn.Release()
+
+	// Ожидание другой реплики ограничено только ctx: ее миграции могут строить индексы долго
+	if _, err := conn.Exec(ctx, "SELECT pg_advisory_lock($1)", migrationLockKey); err != nil {
+		return 0, fmt.Errorf("failed to acquire migration lock: %w", err)
+	}
+	defer conn.Exec(context.Background(), "SELECT pg_advisory_unlock($1)", migrationLockKey)
+
+	if _, err := conn.Exec(ctx, "SET lock_timeout = '"+migrationLockTimeout+"'"); err != nil {
+		return 0, err
+	}
+	// Соединение вернется в пул, поэтому ограничение снимается после миграций
+	defer conn.Exec(context.Background(), "RESET lock_timeout")
+
+	applied, err := applyMigrations(ctx, conn.Conn(), migrations)
+	if err != nil {
+		return applied, err
+	}
+
+	// Уникальный индекс по хешу обеспечивает дедупликацию и зависит от настройки,
+	// а не от версии схемы. При ее отключении индекс удаляется; повторное включение упадет,
+	// если в таблице уже есть дубликаты. Удаленные ссылки в индекс не входят, истекшие
+	// помечаются удаленными при повторном сокращении (см. retireExpired). Прежний индекс
+	// по полному original_url дедупликацией больше не используется и удаляется всегда.
+	dedupIndexes := []string{
+		`CREATE UNIQUE INDEX CONCURRENTLY IF NOT EXISTS ` + dedupIndex + ` ON urls(original_url_hash) WHERE is_deleted IS NOT TRUE`,
+		`DROP INDEX CONCURRENTLY IF EXISTS idx_urls_original_url`,
+	}
+	if s.opts.DisableDedup {
+		dedupIndexes[0] = `DROP INDEX CONCURRENTLY IF EXISTS ` + dedupIndex
+	}
+	for _, query := range dedupIndexes {
+		if _, err := conn.Exec(ctx, query); err != nil {
+			return applied, fmt.Errorf("failed to update dedup index: %w", err)
+		}
+	}
+	return applied, nil
 }
 
-// Save сохраняет URL в PostgreSQL
-func (s *PostgresStorage) Save(shortID, url string) error {
+// Save сохраняет URL в PostgreSQL вместе с владельцем, сроком действия и признаком публичности
+// одной вставкой. Анонимные ссылки сохраняются с user_id NULL.
+func (s *PostgresStorage) Save(ctx context.Context, url usecase.URLPair) error {
 	query := `
-		INSERT INTO urls (short_id, original_url) 
-		VALUES ($1, $2)
+		INSERT INTO urls (short_id, original_url, original_url_bin, user_id, expires_at, is_public, original_url_hash) 
+		VALUES ($1, $2, $3, NULLIF($4, ''), $5, $6, $7)
 	`
-	_, err := s.pool.Exec(context.Background(), query, shortID, url)
-	if err != nil {
+	text, bin := originalURLColumns(url.OriginalURL)
+	hash := urlHash(url)
+
+	// Вторая попытка нужна, только если дубликат оказался истекшей ссылкой
+	for attempt := 1; ; attempt++ {
+		_, err := s.pool.Exec(ctx, query, url.ShortID, text, bin, url.UserID, nullableTime(url.ExpiresAt), url.Public, hash)
+		if err == nil {
+			return nil
+		}
+
 		var pgErr *pgconn.PgError
-		if errors.As(err, &pgErr) && pgErr.Code == pgerrcode.UniqueViolation {
-			// Если нарушение уникальности по original_url, находим существующий short_id
-			if pgErr.ConstraintName == "idx_urls_original_url" {
-				var existingShortID string
-				selectQuery := `SELECT short_id FROM urls WHERE original_url = $1`
-				err := s.pool.QueryRow(context.Background(), selectQuery, url).Scan(&existingShortID)
-				if err != nil {
-					return fmt.Errorf("failed to get existing short_id: %w", err)
-				}
-				return &usecase.ErrURLConflict{ExistingShortURL: existingShortID}
-			}
+		if !errors.As(err, &pgErr) || pgErr.Code != pgerrcode.UniqueViolation {
+			return err
+		}
+		// Сгенерированный short_id уже занят другой ссылкой: сервис повторит с новым ID
+		if pgErr.ConstraintName == shortIDConstraint {
+			return usecase.ErrShortIDCollision
+		}
+		if s.opts.DisableDedup || pgErr.ConstraintName != dedupIndex {
+			return err
+		}
+
+		// Если нарушение уникальности по хешу URL, находим существующий short_id
+		var existingShortID string
+		var expiresAt *time.Time
+		selectQuery := `SELECT short_id, expires_at FROM urls WHERE original_url_hash = $1 AND is_deleted IS NOT TRUE`
+		if err := s.pool.QueryRow(ctx, selectQuery, hash).Scan(&existingShortID, &expiresAt); err != nil {
+			return fmt.Errorf("failed to get existing short_id: %w", err)
 		}
-		return err
+		if expiresAt == nil || time.Now().Before(*expiresAt) || attempt > 1 {
+			return &usecase.ErrURLConflict{ExistingShortURL: existingShortID}
+		}
+		if err := retireExpired(ctx, s.pool, [][]byte{hash}); err != nil {
+			return err
+		}
+	}
+}
+
+// execer выполняет команды в пуле соединений или в транзакции
+type execer interface {
+	Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error)
+}
+
+// retireExpired помечает удаленными истекшие ссылки с указанными хешами, чтобы они
+// вышли из уникального индекса и не мешали сократить тот же URL заново
+func retireExpired(ctx context.Context, db execer, hashes [][]byte) error {
+	_, err := db.Exec(ctx, `
+		UPDATE urls SET is_deleted = TRUE, deleted_at = NOW()
+		WHERE original_url_hash = ANY($1) AND is_deleted IS NOT TRUE AND expires_at <= NOW()
+	`, hashes)
+	if err != nil {
+		return fmt.Errorf("failed to retire expired URLs: %w", err)
 	}
 	return nil
 }
 
 // Get получает оригинальный URL по короткому ID
-func (s *PostgresStorage) Get(shortID string) (string, error) {
-	var originalURL string
+func (s *PostgresStorage) Get(ctx context.Context, shortID string) (string, error) {
+	var text *string
+	var bin []byte
 	var isDeleted bool
-	query := `SELECT original_url, is_deleted FROM urls WHERE short_id = $1`
+	var expiresAt *time.Time
+	query := `SELECT original_url, original_url_bin, COALESCE(is_deleted, FALSE), expires_at FROM urls WHERE short_id = $1`
 
-	err := s.pool.QueryRow(context.Background(), query, shortID).Scan(&originalURL, &isDeleted)
+	err := s.pool.QueryRow(ctx, query, shortID).Scan(&text, &bin, &isDeleted, &expiresAt)
 	if err != nil {
 		return "", fmt.Errorf("URL not found: %w", err)
 	}
@@ -123,12 +270,16 @@ func (s *PostgresStorage) Get(shortID string) (string, error) {
 		return "", &usecase.ErrURLDeleted{}
 	}
 
-	return originalURL, nil
+	if expiresAt != nil && !time.Now().Before(*expiresAt) {
+		return "", &usecase.ErrURLExpired{}
+	}
+
+	return originalURLValue(text, bin), nil
 }
 
 // Ping проверяет соединение с базой данных
 func (s *PostgresStorage) Ping() error {
-	return s.pool.Ping(context.Background())
+	return s.pool.Ping(s.ctx)
 }
 
 // Close закрывает соединение с базой данных
@@ -146,17 +297,63 @@ func (s *PostgresStorage) SaveBatch(ctx context.Context, urls []usecase.URLPair)
 	}
 	defer tx.Rollback(ctx) // Откатываем транзакцию в случае ошибки
 
+	// Уже сохраненные URL находим одним запросом до вставки, чтобы число обращений
+	// к БД не зависело от количества конфликтов
+	existing := make(map[string]string)
+	if !s.opts.DisableDedup {
+		if existing, err = s.findExistingShortIDs(ctx, tx, urls); err != nil {
+			return err
+		}
+	}
+
+	// Существующая запись обновляется, только если это та же ссылка без владельца.
+	// Иначе short_id занят другой ссылкой, строка не меняется и это коллизия.
 	query := `
-		INSERT INTO urls (short_id, original_url, user_id) 
-		VALUES ($1, $2, $3) 
-		ON CONFLICT (short_id) DO UPDATE SET user_id = EXCLUDED.user_id WHERE urls.user_id IS NULL
+		INSERT INTO urls (short_id, original_url, original_url_bin, user_id, expires_at, is_public, original_url_hash) 
+		VALUES ($1, $2, $3, $4, $5, $6, $7) 
+		ON CONFLICT (short_id) DO UPDATE SET user_id = EXCLUDED.user_id, expires_at = EXCLUDED.expires_at, is_public = EXCLUDED.is_public
+		WHERE urls.user_id IS NULL AND urls.original_url_hash = EXCLUDED.original_url_hash
 	`
 
-	// Выполняем все вставки в рамках одной транзакции
+	batch := &pgx.Batch{}
+	queued := make([]string, 0, len(urls))
+	var conflicts map[string]string
 	for _, url := range urls {
-		_, err := tx.Exec(ctx, query, url.ShortID, url.OriginalURL, url.UserID)
-		if err != nil {
-			return fmt.Errorf("failed to save URL %s: %w", url.ShortID, err)
+		hash := urlHash(url)
+
+		// URL уже сохранен под другим ID (в БД или ранее в этом же batch)
+		if existingShortID, found := existing[string(hash)]; found && existingShortID != url.ShortID {
+			if conflicts == nil {
+				conflicts = make(map[string]string)
+			}
+			conflicts[url.ShortID] = existingShortID
+			continue
+		}
+		if !s.opts.DisableDedup {
+			existing[string(hash)] = url.ShortID
+		}
+
+		text, bin := originalURLColumns(url.OriginalURL)
+		batch.Queue(query, url.ShortID, text, bin, url.UserID, nullableTime(url.ExpiresAt), url.Public, hash)
+		queued = append(queued, url.ShortID)
+	}
+
+	// Все вставки отправляются одним обращением к БД
+	if len(queued) > 0 {
+		results := tx.SendBatch(ctx, batch)
+		for _, shortID := range queued {
+			tag, err := results.Exec()
+			if err != nil {
+				results.Close()
+				return fmt.Errorf("failed to save URL %s: %w", shortID, err)
+			}
+			if tag.RowsAffected() == 0 {
+				results.Close()
+				return fmt.Errorf("failed to save URL %s: %w", shortID, usecase.ErrShortIDCollision)
+			}
+		}
+		if err := results.Close(); err != nil {
+			return fmt.Errorf("failed to save URLs: %w", err)
 		}
 	}
 
@@ -165,37 +362,170 @@ func (s *PostgresStorage) SaveBatch(ctx context.Context, urls []usecase.URLPair)
 		return fmt.Errorf("failed to commit transaction: %w", err)
 	}
 
+	if len(conflicts) > 0 {
+		return &usecase.ErrBatchConflict{Existing: conflicts}
+	}
 	return nil
 }
 
-// GetUserURLs получает все URL пользователя
-func (s *PostgresStorage) GetUserURLs(ctx context.Context, userID string) ([]usecase.UserURL, error) {
-	query := `SELECT short_id, original_url FROM urls WHERE user_id = $1 AND is_deleted = FALSE`
+// nullableTime возвращает nil для нулевого времени, чтобы в БД сохранился NULL
+func nullableTime(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	return &t
+}
+
+// originalURLColumns раскладывает значение по столбцам: текст сохраняется в original_url,
+// двоичное значение (сжатый URL) - в original_url_bin
+func originalURLColumns(value string) (*string, []byte) {
+	if isBinary(value) {
+		return nil, []byte(value)
+	}
+	return &value, nil
+}
+
+// originalURLValue собирает значение из столбцов original_url и original_url_bin
+func originalURLValue(text *string, bin []byte) string {
+	if bin != nil {
+		return string(bin)
+	}
+	if text != nil {
+		return *text
+	}
+	return ""
+}
+
+// urlHash возвращает SHA-256 ключа дедупликации ссылки. Индексируется хеш, а не сам URL:
+// он фиксированного размера и совпадает для зашифрованных копий одного URL.
+func urlHash(url usecase.URLPair) []byte {
+	sum := sha256.Sum256([]byte(url.Key()))
+	return sum[:]
+}
+
+// findExistingShortIDs возвращает short_id уже сохраненных URL из batch по их хешу
+func (s *PostgresStorage) findExistingShortIDs(ctx context.Context, tx pgx.Tx, urls []usecase.URLPair) (map[string]string, error) {
+	hashes := make([][]byte, len(urls))
+	for i, url := range urls {
+		hashes[i] = urlHash(url)
+	}
+
+	// Истекшие дубликаты не возвращаются, а освобождают место для новой ссылки
+	if err := retireExpired(ctx, tx, hashes); err != nil {
+		return nil, err
+	}
+
+	rows, err := tx.Query(ctx, `SELECT original_url_hash, short_id FROM urls WHERE original_url_hash = ANY($1) AND is_deleted IS NOT TRUE`, hashes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query existing URLs: %w", err)
+	}
+	defer rows.Close()
+
+	existing := make(map[string]string)
+	for rows.Next() {
+		var hash []byte
+		var shortID string
+		if err := rows.Scan(&hash, &shortID); err != nil {
+			return nil, fmt.Errorf("failed to scan existing URL: %w", err)
+		}
+		existing[string(hash)] = shortID
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows iteration error: %w", err)
+	}
+
+	return existing, nil
+}
+
+// GetUserURLs получает страницу URL пользователя в порядке создания и их общее количество.
+// Общее количество считается в том же запросе оконной функцией; отдельный запрос нужен,
+// только если offset вышел за пределы списка.
+func (s *PostgresStorage) GetUserURLs(ctx context.Context, userID string, limit, offset int) ([]usecase.UserURL, int, error) {
+	query := `
+		SELECT short_id, original_url, original_url_bin, COALESCE(visits, 0), created_at, count(*) OVER ()
+		FROM urls
+		WHERE user_id = $1 AND is_deleted IS NOT TRUE
+		ORDER BY created_at, short_id
+		LIMIT $2 OFFSET $3
+	`
 
-	rows, err := s.pool.Query(ctx, query, userID)
+	// LIMIT NULL в PostgreSQL означает отсутствие ограничения
+	var limitArg any
+	if limit > 0 {
+		limitArg = limit
+	}
+
+	rows, err := s.pool.Query(ctx, query, userID, limitArg, offset)
 	if err != nil {
-		return nil, fmt.Errorf("failed to query user URLs: %w", err)
+		return nil, 0, fmt.Errorf("failed to query user URLs: %w", err)
 	}
 	defer rows.Close()
 
 	var urls []usecase.UserURL
+	total := 0
 	for rows.Next() {
-		var shortID, originalURL string
-		if err := rows.Scan(&shortID, &originalURL); err != nil {
-			return nil, fmt.Errorf("failed to scan row: %w", err)
+		// Прекращаем чтение, если клиент отключился или истек таймаут запроса
+		if err := ctx.Err(); err != nil {
+			return nil, 0, err
+		}
+
+		var shortID string
+		var text *string
+		var bin []byte
+		var visits int64
+		var createdAt time.Time
+		if err := rows.Scan(&shortID, &text, &bin, &visits, &createdAt, &total); err != nil {
+			return nil, 0, fmt.Errorf("failed to scan row: %w", err)
 		}
 
 		urls = append(urls, usecase.UserURL{
-			ShortURL:    fmt.Sprintf("http://localhost:8080/%s", shortID),
-			OriginalURL: originalURL,
+			ShortID:      shortID,
+			OriginalURL:  originalURLValue(text, bin),
+			Visits:       visits,
+			VisitsCapped: s.opts.visitsCapped(visits),
+			CreatedAt:    createdAt,
 		})
 	}
 
 	if err := rows.Err(); err != nil {
-		return nil, fmt.Errorf("rows iteration error: %w", err)
+		return nil, 0, fmt.Errorf("rows iteration error: %w", err)
 	}
 
-	return urls, nil
+	if len(urls) == 0 && offset > 0 {
+		err := s.pool.QueryRow(ctx, `SELECT count(*) FROM urls WHERE user_id = $1 AND is_deleted IS NOT TRUE`, userID).Scan(&total)
+		if err != nil {
+			return nil, 0, fmt.Errorf("failed to count user URLs: %w", err)
+		}
+	}
+
+	return urls, total, nil
+}
+
+// GetUserURL получает URL, только если он принадлежит пользователю и не удален
+func (s *PostgresStorage) GetUserURL(ctx context.Context, userID, shortID string) (usecase.UserURL, error) {
+	query := `
+		SELECT original_url, original_url_bin, COALESCE(visits, 0) FROM urls
+		WHERE short_id = $1 AND user_id = $2 AND is_deleted IS NOT TRUE
+	`
+
+	var text *string
+	var bin []byte
+	var visits int64
+	err := s.pool.QueryRow(ctx, query, shortID, userID).Scan(&text, &bin, &visits)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return usecase.UserURL{}, usecase.ErrURLNotFound
+	}
+	if err != nil {
+		return usecase.UserURL{}, fmt.Errorf("failed to query user URL: %w", err)
+	}
+
+	return usecase.UserURL{
+		ShortID:      shortID,
+		OriginalURL:  originalURLValue(text, bin),
+		Visits:       visits,
+		VisitsCapped: s.opts.visitsCapped(visits),
+	}, nil
 }
 
 // BatchDeleteUserURLs помечает URL пользователя как удаленные
@@ -214,8 +544,8 @@ func (s *PostgresStorage) BatchDeleteUserURLs(ctx context.Context, userID string
 	// Обновляем is_deleted для указанных URL только если они принадлежат пользователю
 	query := `
 		UPDATE urls 
-		SET is_deleted = TRUE 
-		WHERE user_id = $1 AND short_id = ANY($2)
+		SET is_deleted = TRUE, deleted_at = NOW() 
+		WHERE user_id = $1 AND short_id = ANY($2) AND is_deleted IS NOT TRUE
 	`
 
 	_, err = tx.Exec(ctx, query, userID, shortIDs)
@@ -230,3 +560,247 @@ func (s *PostgresStorage) BatchDeleteUserURLs(ctx context.Context, userID string
 
 	return nil
 }
+
+// DeleteAllUserURLs помечает все URL пользователя как удаленные одним запросом
+// и возвращает их количество
+func (s *PostgresStorage) DeleteAllUserURLs(ctx context.Context, userID string) (int64, error) {
+	tag, err := s.pool.Exec(ctx, `
+		UPDATE urls
+		SET is_deleted = TRUE, deleted_at = NOW()
+		WHERE user_id = $1 AND is_deleted IS NOT TRUE
+	`, userID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to mark user URLs as deleted: %w", err)
+	}
+	return tag.RowsAffected(), nil
+}
+
+// DeleteUserURLsWithResult помечает URL пользователя как удаленные и возвращает итог по каждому ID
+func (s *PostgresStorage) DeleteUserURLsWithResult(ctx context.Context, userID string, shortIDs []string) (usecase.DeleteResult, error) {
+	result := usecase.DeleteResult{
+		Deleted:        []string{},
+		AlreadyDeleted: []string{},
+		NotFound:       []string{},
+	}
+
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		return result, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	// Блокируем строки пользователя, чтобы итог соответствовал фактическому обновлению
+	rows, err := tx.Query(ctx, `
+		SELECT short_id, COALESCE(is_deleted, FALSE) FROM urls
+		WHERE user_id = $1 AND short_id = ANY($2)
+		FOR UPDATE
+	`, userID, shortIDs)
+	if err != nil {
+		return result, fmt.Errorf("failed to query user URLs: %w", err)
+	}
+
+	states := make(map[string]bool, len(shortIDs))
+	for rows.Next() {
+		var shortID string
+		var isDeleted bool
+		if err := rows.Scan(&shortID, &isDeleted); err != nil {
+			rows.Close()
+			return result, fmt.Errorf("failed to scan row: %w", err)
+		}
+		states[shortID] = isDeleted
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return result, fmt.Errorf("rows iteration error: %w", err)
+	}
+
+	seen := make(map[string]bool, len(shortIDs))
+	for _, shortID := range shortIDs {
+		if seen[shortID] {
+			continue
+		}
+		seen[shortID] = true
+
+		isDeleted, exists := states[shortID]
+		switch {
+		case !exists:
+			result.NotFound = append(result.NotFound, shortID)
+		case isDeleted:
+			result.AlreadyDeleted = append(result.AlreadyDeleted, shortID)
+		default:
+			result.Deleted = append(result.Deleted, shortID)
+		}
+	}
+
+	if len(result.Deleted) > 0 {
+		query := `UPDATE urls SET is_deleted = TRUE, deleted_at = NOW() WHERE user_id = $1 AND short_id = ANY($2)`
+		if _, err := tx.Exec(ctx, query, userID, result.Deleted); err != nil {
+			return result, fmt.Errorf("failed to mark URLs as deleted: %w", err)
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return result, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return result, nil
+}
+
+// PurgeExpired физически удаляет ссылки с истекшим сроком действия и ссылки,
+// помеченные удаленными раньше before. Записи, удаленные до появления deleted_at, тоже удаляются.
+func (s *PostgresStorage) PurgeExpired(ctx context.Context, before time.Time) (int64, error) {
+	query := `
+		DELETE FROM urls
+		WHERE (is_deleted = TRUE AND (deleted_at IS NULL OR deleted_at < $1))
+			OR expires_at < NOW()
+	`
+
+	tag, err := s.pool.Exec(ctx, query, before)
+	if err != nil {
+		return 0, fmt.Errorf("failed to purge URLs: %w", err)
+	}
+
+	return tag.RowsAffected(), nil
+}
+
+// Reset удаляет все ссылки и возвращает их количество.
+// Используется только в тестовых окружениях.
+func (s *PostgresStorage) Reset(ctx context.Context) (int64, error) {
+	tag, err := s.pool.Exec(ctx, `DELETE FROM urls`)
+	if err != nil {
+		return 0, fmt.Errorf("failed to reset URLs: %w", err)
+	}
+
+	return tag.RowsAffected(), nil
+}
+
+// Stats возвращает количество неудаленных и неистекших ссылок и пользователей, у которых они есть
+func (s *PostgresStorage) Stats(ctx context.Context) (int, int, error) {
+	query := `
+		SELECT count(*), count(DISTINCT user_id) FILTER (WHERE user_id <> '')
+		FROM urls
+		WHERE is_deleted IS NOT TRUE AND (expires_at IS NULL OR expires_at > NOW())
+	`
+
+	var urls, users int
+	if err := s.pool.QueryRow(ctx, query).Scan(&urls, &users); err != nil {
+		return 0, 0, fmt.Errorf("failed to query stats: %w", err)
+	}
+	return urls, users, nil
+}
+
+// GetURLOwner возвращает создателя URL и время создания, включая удаленные URL
+func (s *PostgresStorage) GetURLOwner(ctx context.Context, shortID string) (usecase.URLOwner, error) {
+	query := `SELECT COALESCE(user_id, ''), created_at FROM urls WHERE short_id = $1`
+
+	// created_at допускает NULL, для таких строк время создания неизвестно
+	var owner usecase.URLOwner
+	var createdAt *time.Time
+	err := s.pool.QueryRow(ctx, query, shortID).Scan(&owner.UserID, &createdAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return usecase.URLOwner{}, usecase.ErrURLNotFound
+	}
+	if err != nil {
+		return usecase.URLOwner{}, fmt.Errorf("failed to query URL owner: %w", err)
+	}
+	if createdAt != nil {
+		owner.CreatedAt = *createdAt
+	}
+
+	return owner, nil
+}
+
+// RecentPublicURLs возвращает не более limit последних публичных URL, новые первыми.
+// Удаленные и истекшие URL не возвращаются.
+func (s *PostgresStorage) RecentPublicURLs(ctx context.Context, limit int) ([]usecase.PublicURL, error) {
+	query := `
+		SELECT short_id, original_url, original_url_bin, created_at FROM urls
+		WHERE is_public AND is_deleted IS NOT TRUE AND created_at IS NOT NULL
+			AND (expires_at IS NULL OR expires_at > NOW())
+		ORDER BY created_at DESC
+		LIMIT $1
+	`
+
+	rows, err := s.pool.Query(ctx, query, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query public URLs: %w", err)
+	}
+	defer rows.Close()
+
+	var urls []usecase.PublicURL
+	for rows.Next() {
+		var url usecase.PublicURL
+		var text *string
+		var bin []byte
+		if err := rows.Scan(&url.ShortID, &text, &bin, &url.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan row: %w", err)
+		}
+		url.OriginalURL = originalURLValue(text, bin)
+		urls = append(urls, url)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows iteration error: %w", err)
+	}
+
+	return urls, nil
+}
+
+// PoolStats возвращает текущую статистику пула соединений
+func (s *PostgresStorage) PoolStats() usecase.PoolStats {
+	stat := s.pool.Stat()
+	return usecase.PoolStats{
+		TotalConns:        stat.TotalConns(),
+		IdleConns:         stat.IdleConns(),
+		AcquiredConns:     stat.AcquiredConns(),
+		ConstructingConns: stat.ConstructingConns(),
+		MaxConns:          stat.MaxConns(),
+		AcquireCount:      stat.AcquireCount(),
+		EmptyAcquireCount: stat.EmptyAcquireCount(),
+		CanceledAcquires:  stat.CanceledAcquireCount(),
+		AcquireDuration:   stat.AcquireDuration().Seconds(),
+		EmptyAcquireWait:  stat.EmptyAcquireWaitTime().Seconds(),
+	}
+}
+
+// CheckWritable проверяет, что БД принимает запись: пробная вставка выполняется
+// в транзакции и откатывается. Реплика в режиме только чтения вернет ошибку.
+func (s *PostgresStorage) CheckWritable(ctx context.Context) error {
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	// Символ ~ не встречается в генерируемых коротких ID, поэтому проба не конфликтует с данными
+	query := `INSERT INTO urls (short_id, original_url) VALUES ('~probe~', '~probe~')`
+	if _, err := tx.Exec(ctx, query); err != nil {
+		return fmt.Errorf("database is not writable: %w", err)
+	}
+
+	return nil
+}
+
+// idRangeSize - размер диапазона ID, совпадает с INCREMENT BY последовательности short_id_seq
+const idRangeSize = 1000
+
+// AllocateIDRange резервирует диапазон ID для последовательной генерации коротких ID.
+// nextval атомарен, поэтому реплики получают непересекающиеся диапазоны.
+func (s *PostgresStorage) AllocateIDRange(ctx context.Context) (int64, int64, error) {
+	var start int64
+	if err := s.pool.QueryRow(ctx, `SELECT nextval('short_id_seq')`).Scan(&start); err != nil {
+		return 0, 0, fmt.Errorf("failed to allocate ID range: %w", err)
+	}
+	return start, idRangeSize, nil
+}
+
+// IncrementVisits увеличивает счетчик переходов по короткому URL.
+// После достижения VisitCap строка больше не обновляется.
+func (s *PostgresStorage) IncrementVisits(ctx context.Context, shortID string) error {
+	query := `UPDATE urls SET visits = visits + 1 WHERE short_id = $1 AND ($2 = 0 OR visits < $2)`
+	_, err := s.pool.Exec(ctx, query, shortID, s.opts.VisitCap)
+	if err != nil {
+		return fmt.Errorf("failed to increment visits: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,329 @@
+// Package storage предоставляет различные реализации хранилища URL
+package storage
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/m-molecula741/shortener/internal/app/usecase"
+)
+
+// shardVirtualNodes - количество точек каждого шарда на кольце хеширования.
+// Больше точек - равномернее распределение ключей между шардами.
+const shardVirtualNodes = 128
+
+// ShardedStorage распределяет URL между несколькими хранилищами по короткому ID
+// с помощью консистентного хеширования. Операции с одним ID направляются в его шард,
+// операции по пользователю и обслуживающие операции выполняются на всех шардах.
+//
+// Ссылки распределяются по короткому ID, поэтому один original_url может оказаться
+// в разных шардах: дедупликация между шардами не работает, и шарды нужно создавать
+// с Options.DisableDedup. SaveBatch не атомарен между шардами.
+//
+// Перераспределения данных нет: набор и порядок шардов менять нельзя. Новый шард
+// забрал бы себе часть коротких ID, и уже сохраненные в других шардах ссылки
+// с этими ID перестали бы находиться.
+type ShardedStorage struct {
+	shards []usecase.URLStorage
+	ring   []ringPoint // точки кольца, отсортированные по хешу
+}
+
+// ringPoint - точка шарда на кольце консистентного хеширования
+type ringPoint struct {
+	hash  uint32
+	shard int
+}
+
+// NewShardedStorage создает хранилище, распределяющее URL между shards
+func NewShardedStorage(shards []usecase.URLStorage) (*ShardedStorage, error) {
+	if len(shards) == 0 {
+		return nil, errors.New("sharded storage requires at least one shard")
+	}
+
+	ring := make([]ringPoint, 0, len(shards)*shardVirtualNodes)
+	for i := range shards {
+		for v := 0; v < shardVirtualNodes; v++ {
+			ring = append(ring, ringPoint{hash: hashKey(fmt.Sprintf("shard-%d#%d", i, v)), shard: i})
+		}
+	}
+	sort.Slice(ring, func(a, b int) bool { return ring[a].hash < ring[b].hash })
+
+	return &ShardedStorage{shards: shards, ring: ring}, nil
+}
+
+// hashKey вычисляет позицию ключа на кольце по первым байтам MD5, как в ketama.
+// Простые хеши вроде FNV плохо перемешивают похожие короткие ключи.
+func hashKey(key string) uint32 {
+	sum := md5.Sum([]byte(key))
+	return binary.LittleEndian.Uint32(sum[:4])
+}
+
+// shardIndex возвращает номер шарда для короткого ID: первую точку кольца по часовой стрелке
+func (s *ShardedStorage) shardIndex(shortID string) int {
+	hash := hashKey(shortID)
+	i := sort.Search(len(s.ring), func(i int) bool { return s.ring[i].hash >= hash })
+	if i == len(s.ring) {
+		i = 0
+	}
+	return s.ring[i].shard
+}
+
+// shardFor возвращает шард для короткого ID
+func (s *ShardedStorage) shardFor(shortID string) usecase.URLStorage {
+	return s.shards[s.shardIndex(shortID)]
+}
+
+// groupByShard раскладывает короткие ID по шардам
+func (s *ShardedStorage) groupByShard(shortIDs []string) map[int][]string {
+	groups := make(map[int][]string)
+	for _, shortID := range shortIDs {
+		i := s.shardIndex(shortID)
+		groups[i] = append(groups[i], shortID)
+	}
+	return groups
+}
+
+// forEachShard параллельно выполняет fn на всех шардах и объединяет ошибки
+func (s *ShardedStorage) forEachShard(fn func(i int, shard usecase.URLStorage) error) error {
+	errs := make([]error, len(s.shards))
+
+	var wg sync.WaitGroup
+	for i, shard := range s.shards {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs[i] = fn(i, shard)
+		}()
+	}
+	wg.Wait()
+
+	return errors.Join(errs...)
+}
+
+// Save сохраняет URL в шард короткого ID
+func (s *ShardedStorage) Save(ctx context.Context, url usecase.URLPair) error {
+	return s.shardFor(url.ShortID).Save(ctx, url)
+}
+
+// Get получает URL из шарда короткого ID
+func (s *ShardedStorage) Get(ctx context.Context, shortID string) (string, error) {
+	return s.shardFor(shortID).Get(ctx, shortID)
+}
+
+// SaveBatch сохраняет URL, разложив их по шардам.
+// Конфликты со всех шардов объединяются в один ErrBatchConflict.
+func (s *ShardedStorage) SaveBatch(ctx context.Context, urls []usecase.URLPair) error {
+	groups := make(map[int][]usecase.URLPair)
+	for _, url := range urls {
+		i := s.shardIndex(url.ShortID)
+		groups[i] = append(groups[i], url)
+	}
+
+	existing := make(map[string]string)
+	for i, group := range groups {
+		err := s.shards[i].SaveBatch(ctx, group)
+		if conflictErr, isConflict := usecase.IsBatchConflict(err); isConflict {
+			for shortID, existingShortID := range conflictErr.Existing {
+				existing[shortID] = existingShortID
+			}
+			continue
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	if len(existing) > 0 {
+		return &usecase.ErrBatchConflict{Existing: existing}
+	}
+	return nil
+}
+
+// GetUserURLs собирает страницу URL пользователя со всех шардов в порядке создания.
+// Каждый шард отдает ссылки в порядке создания, поэтому с каждого достаточно первых
+// offset+limit ссылок: после слияния по created_at страница целиком среди них.
+func (s *ShardedStorage) GetUserURLs(ctx context.Context, userID string, limit, offset int) ([]usecase.UserURL, int, error) {
+	shardLimit := 0
+	if limit > 0 {
+		shardLimit = offset + limit
+	}
+
+	results := make([][]usecase.UserURL, len(s.shards))
+	totals := make([]int64, len(s.shards))
+	err := s.forEachShard(func(i int, shard usecase.URLStorage) error {
+		urls, total, err := shard.GetUserURLs(ctx, userID, shardLimit, 0)
+		results[i], totals[i] = urls, int64(total)
+		return err
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var urls []usecase.UserURL
+	for _, shardURLs := range results {
+		urls = append(urls, shardURLs...)
+	}
+	// Стабильная сортировка сохраняет порядок шарда для ссылок с одинаковым временем
+	slices.SortStableFunc(urls, func(a, b usecase.UserURL) int {
+		return a.CreatedAt.Compare(b.CreatedAt)
+	})
+	return paginate(urls, limit, offset), int(sum(totals)), nil
+}
+
+// GetUserURL получает URL пользователя из шарда короткого ID
+func (s *ShardedStorage) GetUserURL(ctx context.Context, userID, shortID string) (usecase.UserURL, error) {
+	return s.shardFor(shortID).GetUserURL(ctx, userID, shortID)
+}
+
+// BatchDeleteUserURLs помечает URL пользователя как удаленные в их шардах
+func (s *ShardedStorage) BatchDeleteUserURLs(ctx context.Context, userID string, shortIDs []string) error {
+	var errs []error
+	for i, group := range s.groupByShard(shortIDs) {
+		errs = append(errs, s.shards[i].BatchDeleteUserURLs(ctx, userID, group))
+	}
+	return errors.Join(errs...)
+}
+
+// DeleteAllUserURLs помечает все URL пользователя удаленными на всех шардах
+// и возвращает их общее количество
+func (s *ShardedStorage) DeleteAllUserURLs(ctx context.Context, userID string) (int64, error) {
+	deleted := make([]int64, len(s.shards))
+	err := s.forEachShard(func(i int, shard usecase.URLStorage) error {
+		var err error
+		deleted[i], err = shard.DeleteAllUserURLs(ctx, userID)
+		return err
+	})
+	return sum(deleted), err
+}
+
+// DeleteUserURLsWithResult удаляет URL пользователя в их шардах и объединяет итог
+func (s *ShardedStorage) DeleteUserURLsWithResult(ctx context.Context, userID string, shortIDs []string) (usecase.DeleteResult, error) {
+	result := usecase.DeleteResult{
+		Deleted:        []string{},
+		AlreadyDeleted: []string{},
+		NotFound:       []string{},
+	}
+
+	for i, group := range s.groupByShard(shortIDs) {
+		shardResult, err := s.shards[i].DeleteUserURLsWithResult(ctx, userID, group)
+		if err != nil {
+			return usecase.DeleteResult{}, err
+		}
+		result.Deleted = append(result.Deleted, shardResult.Deleted...)
+		result.AlreadyDeleted = append(result.AlreadyDeleted, shardResult.AlreadyDeleted...)
+		result.NotFound = append(result.NotFound, shardResult.NotFound...)
+	}
+
+	return result, nil
+}
+
+// IncrementVisits увеличивает счетчик переходов в шарде короткого ID
+func (s *ShardedStorage) IncrementVisits(ctx context.Context, shortID string) error {
+	return s.shardFor(shortID).IncrementVisits(ctx, shortID)
+}
+
+// PurgeExpired удаляет истекшие ссылки на всех шардах и возвращает их общее количество
+func (s *ShardedStorage) PurgeExpired(ctx context.Context, before time.Time) (int64, error) {
+	purged := make([]int64, len(s.shards))
+	err := s.forEachShard(func(i int, shard usecase.URLStorage) error {
+		var err error
+		purged[i], err = shard.PurgeExpired(ctx, before)
+		return err
+	})
+	return sum(purged), err
+}
+
+// Reset удаляет все ссылки на всех шардах и возвращает их общее количество
+func (s *ShardedStorage) Reset(ctx context.Context) (int64, error) {
+	removed := make([]int64, len(s.shards))
+	err := s.forEachShard(func(i int, shard usecase.URLStorage) error {
+		var err error
+		removed[i], err = shard.Reset(ctx)
+		return err
+	})
+	return sum(removed), err
+}
+
+// GetURLOwner получает создателя URL из шарда короткого ID
+func (s *ShardedStorage) GetURLOwner(ctx context.Context, shortID string) (usecase.URLOwner, error) {
+	return s.shardFor(shortID).GetURLOwner(ctx, shortID)
+}
+
+// RecentPublicURLs собирает последние публичные URL со всех шардов
+// и оставляет limit самых новых среди них
+func (s *ShardedStorage) RecentPublicURLs(ctx context.Context, limit int) ([]usecase.PublicURL, error) {
+	results := make([][]usecase.PublicURL, len(s.shards))
+	err := s.forEachShard(func(i int, shard usecase.URLStorage) error {
+		urls, err := shard.RecentPublicURLs(ctx, limit)
+		results[i] = urls
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var urls []usecase.PublicURL
+	for _, shardURLs := range results {
+		urls = append(urls, shardURLs...)
+	}
+	sortRecentFirst(urls)
+	if len(urls) > limit {
+		urls = urls[:limit]
+	}
+	return urls, nil
+}
+
+// Stats суммирует количество ссылок и пользователей всех шардов.
+// Пользователь со ссылками в нескольких шардах учитывается в каждом из них.
+func (s *ShardedStorage) Stats(ctx context.Context) (int, int, error) {
+	urls := make([]int64, len(s.shards))
+	users := make([]int64, len(s.shards))
+	err := s.forEachShard(func(i int, shard usecase.URLStorage) error {
+		shardURLs, shardUsers, err := shard.Stats(ctx)
+		urls[i], users[i] = int64(shardURLs), int64(shardUsers)
+		return err
+	})
+	if err != nil {
+		return 0, 0, err
+	}
+	return int(sum(urls)), int(sum(users)), nil
+}
+
+// Ping проверяет соединение со всеми шардами, поддерживающими ping
+func (s *ShardedStorage) Ping() error {
+	return s.forEachShard(func(i int, shard usecase.URLStorage) error {
+		if pinger, ok := shard.(usecase.DatabasePinger); ok {
+			if err := pinger.Ping(); err != nil {
+				return fmt.Errorf("shard %d: %w", i, err)
+			}
+		}
+		return nil
+	})
+}
+
+// Close закрывает соединения всех шардов
+func (s *ShardedStorage) Close() error {
+	var errs []error
+	for _, shard := range s.shards {
+		if pinger, ok := shard.(usecase.DatabasePinger); ok {
+			errs = append(errs, pinger.Close())
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// sum возвращает сумму значений
+func sum(values []int64) int64 {
+	var total int64
+	for _, v := range values {
+		total += v
+	}
+	return total
+}
@@ -0,0 +1,194 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"github.com/m-molecula741/shortener/internal/app/usecase"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestShards(t *testing.T, n int) []*InMemoryStorage {
+	t.Helper()
+	shards := make([]*InMemoryStorage, n)
+	for i := range shards {
+		shard, err := NewInMemoryStorage(filepath.Join(t.TempDir(), "urls.json"), Options{})
+		require.NoError(t, err)
+		shards[i] = shard
+	}
+	return shards
+}
+
+func newTestShardedStorage(t *testing.T, shards []*InMemoryStorage) *ShardedStorage {
+	t.Helper()
+	backends := make([]usecase.URLStorage, len(shards))
+	for i, shard := range shards {
+		backends[i] = shard
+	}
+	store, err := NewShardedStorage(backends)
+	require.NoError(t, err)
+	return store
+}
+
+func TestShardedStorage_Routing(t *testing.T) {
+	shards := newTestShards(t, 3)
+	store := newTestShardedStorage(t, shards)
+	ctx := context.Background()
+
+	var pairs []usecase.URLPair
+	for i := 0; i < 100; i++ {
+		pairs = append(pairs, usecase.URLPair{
+			ShortID:     fmt.Sprintf("id%03d", i),
+			OriginalURL: fmt.Sprintf("https://example.com/%d", i),
+			UserID:      "user1",
+		})
+	}
+	require.NoError(t, store.SaveBatch(ctx, pairs))
+
+	// Каждый ID лежит ровно в одном шарде, и все шарды получили часть ключей
+	for _, pair := range pairs {
+		url, err := store.Get(context.Background(), pair.ShortID)
+		require.NoError(t, err)
+		assert.Equal(t, pair.OriginalURL, url)
+
+		found := 0
+		for _, shard := range shards {
+			if _, err := shard.Get(context.Background(), pair.ShortID); err == nil {
+				found++
+			}
+		}
+		assert.Equal(t, 1, found, pair.ShortID)
+	}
+	for i, shard := range shards {
+		assert.NotEmpty(t, shard.urls, "shard %d", i)
+	}
+
+	// URL пользователя собираются со всех шардов
+	urls, _, err := store.GetUserURLs(ctx, "user1", 0, 0)
+	require.NoError(t, err)
+	assert.Len(t, urls, len(pairs))
+
+	result, err := store.DeleteUserURLsWithResult(ctx, "user1", []string{"id001", "id002", "missing"})
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"id001", "id002"}, result.Deleted)
+	assert.Equal(t, []string{"missing"}, result.NotFound)
+
+	// Статистика суммируется по шардам; пользователь есть в каждом шарде
+	urlCount, userCount, err := store.Stats(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, len(pairs)-2, urlCount)
+	assert.Equal(t, len(shards), userCount)
+
+	// Удаленные URL остаются в шардах с пометкой, Reset удаляет и их
+	removed, err := store.Reset(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, int64(len(pairs)), removed)
+}
+
+func TestShardedStorage_ConsistentHashing(t *testing.T) {
+	before := newTestShardedStorage(t, newTestShards(t, 3))
+	after := newTestShardedStorage(t, newTestShards(t, 4))
+
+	// При добавлении шарда ключи переезжают только на новый шард, примерно 1/4 из них
+	const keys = 10000
+	moved := 0
+	for i := 0; i < keys; i++ {
+		shortID := fmt.Sprintf("key%d", i)
+		from, to := before.shardIndex(shortID), after.shardIndex(shortID)
+		if from != to {
+			moved++
+			assert.Equal(t, 3, to, shortID)
+		}
+	}
+	assert.InDelta(t, keys/4, moved, keys/10)
+}
+
+func TestShardedStorage_GetUserURLsPagination(t *testing.T) {
+	store := newTestShardedStorage(t, newTestShards(t, 3))
+	ctx := context.Background()
+
+	var pairs []usecase.URLPair
+	for i := 0; i < 25; i++ {
+		pairs = append(pairs, usecase.URLPair{
+			ShortID:     fmt.Sprintf("id%03d", i),
+			OriginalURL: fmt.Sprintf("https://example.com/%d", i),
+			UserID:      "user1",
+		})
+	}
+	require.NoError(t, store.SaveBatch(ctx, pairs))
+
+	all, total, err := store.GetUserURLs(ctx, "user1", 0, 0)
+	require.NoError(t, err)
+	require.Len(t, all, len(pairs))
+	assert.Equal(t, len(pairs), total)
+
+	// Страницы идут подряд по общему списку без пропусков и повторов
+	var paged []usecase.UserURL
+	for offset := 0; offset < len(pairs); offset += 7 {
+		urls, total, err := store.GetUserURLs(ctx, "user1", 7, offset)
+		require.NoError(t, err)
+		assert.Equal(t, len(pairs), total)
+		assert.LessOrEqual(t, len(urls), 7)
+		paged = append(paged, urls...)
+	}
+	assert.Equal(t, all, paged)
+
+	urls, total, err := store.GetUserURLs(ctx, "user1", 7, 100)
+	require.NoError(t, err)
+	assert.Empty(t, urls)
+	assert.Equal(t, len(pairs), total)
+}
+
+func TestShardedStorage_GetUserURLsCreationOrder(t *testing.T) {
+	store := newTestShardedStorage(t, newTestShards(t, 3))
+	ctx := context.Background()
+
+	var want []string
+	for i := 0; i < 20; i++ {
+		shortID := fmt.Sprintf("id%03d", i)
+		require.NoError(t, store.Save(ctx, usecase.URLPair{
+			ShortID:     shortID,
+			OriginalURL: fmt.Sprintf("https://example.com/%d", i),
+			UserID:      "user1",
+		}))
+		want = append(want, shortID)
+	}
+
+	// Ссылки из разных шардов идут в порядке создания, в том числе постранично
+	var got []string
+	for offset := 0; offset < len(want); offset += 6 {
+		urls, _, err := store.GetUserURLs(ctx, "user1", 6, offset)
+		require.NoError(t, err)
+		for _, url := range urls {
+			got = append(got, url.ShortID)
+		}
+	}
+	assert.Equal(t, want, got)
+}
+
+func TestShardedStorage_DeleteAllUserURLs(t *testing.T) {
+	shards := newTestShards(t, 3)
+	store := newTestShardedStorage(t, shards)
+	ctx := context.Background()
+
+	var pairs []usecase.URLPair
+	for i := 0; i < 30; i++ {
+		pairs = append(pairs, usecase.URLPair{
+			ShortID:     fmt.Sprintf("id%03d", i),
+			OriginalURL: fmt.Sprintf("https://example.com/%d", i),
+			UserID:      "user1",
+		})
+	}
+	require.NoError(t, store.SaveBatch(ctx, pairs))
+
+	deleted, err := store.DeleteAllUserURLs(ctx, "user1")
+	require.NoError(t, err)
+	assert.Equal(t, int64(len(pairs)), deleted)
+
+	_, total, err := store.GetUserURLs(ctx, "user1", 0, 0)
+	require.NoError(t, err)
+	assert.Zero(t, total)
+}
@@ -0,0 +1,31 @@
+package usecase
+
+import (
+	"context"
+	"strings"
+)
+
+type baseURLKeyType struct{}
+
+// baseURLKey ключ контекста для базового адреса, определенного по запросу
+var baseURLKey = baseURLKeyType{}
+
+// WithBaseURL добавляет в контекст базовый адрес, от которого строятся короткие URL.
+// Используется, когда сервис обслуживает несколько доменов.
+func WithBaseURL(ctx context.Context, baseURL string) context.Context {
+	if baseURL == "" {
+		return ctx
+	}
+	if !strings.HasSuffix(baseURL, "/") {
+		baseURL += "/"
+	}
+	return context.WithValue(ctx, baseURLKey, baseURL)
+}
+
+// baseURLFor возвращает базовый адрес из контекста или настроенный по умолчанию
+func (s *URLService) baseURLFor(ctx context.Context) string {
+	if baseURL, ok := ctx.Value(baseURLKey).(string); ok {
+		return baseURL
+	}
+	return s.baseURL
+}
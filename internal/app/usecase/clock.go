@@ -0,0 +1,23 @@
+package usecase
+
+import "time"
+
+// Clock - источник времени сервиса. В тестах заменяется управляемыми часами,
+// чтобы проверять фоновую очистку без ожидания реальных интервалов.
+type Clock interface {
+	Now() time.Time
+	After(d time.Duration) <-chan time.Time
+}
+
+// realClock - системные часы
+type realClock struct{}
+
+// Now возвращает текущее время
+func (realClock) Now() time.Time {
+	return time.Now()
+}
+
+// After возвращает канал, в который придет время через d
+func (realClock) After(d time.Duration) <-chan time.Time {
+	return time.After(d)
+}
@@ -0,0 +1,71 @@
+package usecase
+
+import (
+	"context"
+	"fmt"
+)
+
+// ConflictStrategy определяет поведение при сокращении уже сохраненного original URL
+type ConflictStrategy string
+
+// Стратегии разрешения конфликтов
+const (
+	ConflictReturnExisting ConflictStrategy = "existing" // вернуть существующий короткий URL (409)
+	ConflictCreateNew      ConflictStrategy = "new"      // создать новый короткий URL
+	ConflictError          ConflictStrategy = "error"    // вернуть ошибку без существующего URL (409)
+)
+
+// ParseConflictStrategy разбирает название стратегии; пустая строка означает ConflictReturnExisting
+func ParseConflictStrategy(value string) (ConflictStrategy, error) {
+	switch strategy := ConflictStrategy(value); strategy {
+	case "":
+		return ConflictReturnExisting, nil
+	case ConflictReturnExisting, ConflictCreateNew, ConflictError:
+		return strategy, nil
+	}
+	return "", fmt.Errorf("unknown conflict strategy %q", value)
+}
+
+// resolveBatchConflict применяет стратегию к URL batch, уже сохраненным под другими ID.
+// Для ConflictReturnExisting в ответ подставляются существующие короткие URL,
+// для ConflictError такие URL попадают в ErrBatchPartialFailure.
+func (s *URLService) resolveBatchConflict(baseURL string, urlPairs []URLPair, responses []BatchShortenResponse, conflictErr *ErrBatchConflict) ([]BatchShortenResponse, error) {
+	if s.conflictStrategy != ConflictError {
+		for i, pair := range urlPairs {
+			if existingShortID, found := conflictErr.Existing[pair.ShortID]; found {
+				responses[i].ShortURL = baseURL + existingShortID
+			}
+		}
+		return responses, nil
+	}
+
+	succeeded := make([]BatchShortenResponse, 0, len(urlPairs))
+	var failed []BatchFailure
+	for i, pair := range urlPairs {
+		if _, found := conflictErr.Existing[pair.ShortID]; found {
+			failed = append(failed, BatchFailure{
+				CorrelationID:        responses[i].CorrelationID,
+				CorrelationIDNumeric: responses[i].CorrelationIDNumeric,
+				Reason:               BatchReasonConflict,
+			})
+			continue
+		}
+		succeeded = append(succeeded, responses[i])
+	}
+
+	if len(succeeded) == 0 {
+		return nil, ErrURLExists
+	}
+	return succeeded, &ErrBatchPartialFailure{Failed: failed}
+}
+
+// resolveConflict применяет стратегию к конфликту, найденному хранилищем.
+// Для ConflictCreateNew хранилище не проверяет дубликаты, поэтому конфликт не возникает.
+func (s *URLService) resolveConflict(ctx context.Context, conflictErr *ErrURLConflict) (string, error) {
+	if s.conflictStrategy == ConflictError {
+		return "", ErrURLExists
+	}
+
+	shortURL := s.baseURLFor(ctx) + conflictErr.ExistingShortURL
+	return shortURL, &ErrURLConflict{ExistingShortURL: shortURL}
+}
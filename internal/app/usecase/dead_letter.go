@@ -0,0 +1,58 @@
+package usecase
+
+import (
+	"context"
+	"time"
+
+	"github.com/m-molecula741/shortener/internal/app/logger"
+)
+
+// defaultDeleteRetryBackoff - пауза перед первым повтором удаления, дальше она удваивается
+const defaultDeleteRetryBackoff = 100 * time.Millisecond
+
+// deleteWithRetry применяет удаление в хранилище, повторяя его при ошибке
+// до deleteRetries раз с удваивающейся паузой. all удаляет все URL пользователя.
+func (s *URLService) deleteWithRetry(userID string, shortIDs []string, all bool) error {
+	apply := func() error {
+		if all {
+			_, err := s.storage.DeleteAllUserURLs(context.Background(), userID)
+			return err
+		}
+		return s.storage.BatchDeleteUserURLs(context.Background(), userID, shortIDs)
+	}
+
+	backoff := s.deleteRetryBackoff
+
+	err := apply()
+	for attempt := 0; err != nil && attempt < s.deleteRetries; attempt++ {
+		time.Sleep(backoff)
+		backoff *= 2
+		err = apply()
+	}
+	return err
+}
+
+// deadLetter передает запросы, которые не удалось применить после всех попыток,
+// в DeadLetterQueue, чтобы их можно было изучить и выполнить повторно.
+// Без очереди запросы только записываются в лог.
+func (s *URLService) deadLetter(requests []DeleteRequest, cause error) {
+	for _, req := range requests {
+		if s.deadLetters != nil {
+			if err := s.deadLetters.Put(context.Background(), req, cause); err != nil {
+				logger.Info().
+					Err(err).
+					Str("user_id", req.UserID).
+					Strs("short_ids", req.ShortIDs).
+					Msg("Failed to write delete request to dead-letter queue")
+				continue
+			}
+		} else {
+			logger.Info().
+				Err(cause).
+				Str("user_id", req.UserID).
+				Strs("short_ids", req.ShortIDs).
+				Msg("Delete request failed permanently")
+		}
+		s.deletesDeadLettered.Add(1)
+	}
+}
@@ -0,0 +1,43 @@
+package usecase
+
+import "github.com/m-molecula741/shortener/internal/app/logger"
+
+// DeleteStats содержит счетчики асинхронного удаления URL
+type DeleteStats struct {
+	Enqueued  int64 // запросы, принятые в очередь
+	Processed int64 // запросы, обработанные воркерами
+	Failed    int64 // обработанные запросы, которые не удалось применить в хранилище
+	Dropped   int64 // запросы, отклоненные из-за заполненной очереди
+
+	DeadLettered int64 // неудавшиеся запросы, переданные в dead-letter
+}
+
+// Unprocessed возвращает количество принятых, но не обработанных запросов
+func (st DeleteStats) Unprocessed() int64 {
+	return st.Enqueued - st.Processed
+}
+
+// DeleteStats возвращает текущие счетчики асинхронного удаления
+func (s *URLService) DeleteStats() DeleteStats {
+	return DeleteStats{
+		Enqueued:  s.deletesEnqueued.Load(),
+		Processed: s.deletesProcessed.Load(),
+		Failed:    s.deletesFailed.Load(),
+		Dropped:   s.deletesDropped.Load(),
+
+		DeadLettered: s.deletesDeadLettered.Load(),
+	}
+}
+
+// logDeleteStats выводит итог асинхронного удаления при остановке сервиса
+func (s *URLService) logDeleteStats() {
+	stats := s.DeleteStats()
+	logger.Info().
+		Int64("enqueued", stats.Enqueued).
+		Int64("processed", stats.Processed).
+		Int64("failed", stats.Failed).
+		Int64("dropped", stats.Dropped).
+		Int64("dead_lettered", stats.DeadLettered).
+		Int64("unprocessed", stats.Unprocessed()).
+		Msg("Delete workers stopped")
+}
@@ -26,6 +26,29 @@ func IsURLConflict(err error) (*ErrURLConflict, bool) {
 	return nil, false
 }
 
+// ErrURLNotFound возвращается, когда URL не найден или не принадлежит пользователю
+var ErrURLNotFound = errors.New("url not found")
+
+// ErrURLExists возвращается при повторном сокращении URL, если стратегия конфликтов
+// ConflictError запрещает возвращать существующий короткий URL
+var ErrURLExists = errors.New("URL already exists")
+
+// ErrShortIDCollision возвращается хранилищем, если сгенерированный короткий ID уже занят
+// другой ссылкой. В отличие от ErrURLConflict, повтор с новым ID может быть успешным.
+var ErrShortIDCollision = errors.New("short ID already exists")
+
+// ErrIdempotencyKeyMismatch возвращается, если ключ идемпотентности повторно
+// использован с другим URL
+var ErrIdempotencyKeyMismatch = errors.New("idempotency key is already used for another request")
+
+// ErrIdempotencyKeyInProgress возвращается, если запрос с тем же ключом идемпотентности
+// еще выполняется
+var ErrIdempotencyKeyInProgress = errors.New("request with this idempotency key is in progress")
+
+// ErrStorageFull возвращается, когда хранилище достигло ограничения на количество ссылок
+// и политика вытеснения запрещает добавлять новые
+var ErrStorageFull = errors.New("storage is full")
+
 // ErrURLDeleted представляет ошибку при попытке доступа к удаленному URL
 type ErrURLDeleted struct{}
 
@@ -40,5 +63,98 @@ func IsURLDeleted(err error) bool {
 	return ok
 }
 
+// ErrURLExpired представляет ошибку при доступе к ссылке с истекшим сроком действия
+type ErrURLExpired struct{}
+
+// Error реализует интерфейс error для ErrURLExpired
+func (e *ErrURLExpired) Error() string {
+	return "URL has expired"
+}
+
+// IsURLExpired проверяет, является ли ошибка признаком истекшей ссылки
+func IsURLExpired(err error) bool {
+	var expiredErr *ErrURLExpired
+	return errors.As(err, &expiredErr)
+}
+
 // ErrDeleteChannelFull возвращается, когда канал удаления переполнен
 var ErrDeleteChannelFull = errors.New("delete channel is full, try again later")
+
+// ErrPoolStatsUnavailable возвращается, если хранилище не использует пул соединений с БД
+var ErrPoolStatsUnavailable = errors.New("database pool stats are not available")
+
+// ErrBatchPartialFailure возвращается, когда часть URL из batch запроса не удалось сохранить.
+// Успешно сохраненные URL возвращаются вместе с этой ошибкой.
+type ErrBatchPartialFailure struct {
+	Failed []BatchFailure
+}
+
+// Error реализует интерфейс error для ErrBatchPartialFailure
+func (e *ErrBatchPartialFailure) Error() string {
+	return "batch partially failed"
+}
+
+// IsBatchPartialFailure проверяет, является ли ошибка частичным сохранением batch
+func IsBatchPartialFailure(err error) (*ErrBatchPartialFailure, bool) {
+	var partialErr *ErrBatchPartialFailure
+	if errors.As(err, &partialErr) {
+		return partialErr, true
+	}
+	return nil, false
+}
+
+// ErrBatchConflict возвращается хранилищем из SaveBatch, когда часть original URL уже сохранена
+// под другими короткими ID. Остальные URL batch при этом сохранены.
+type ErrBatchConflict struct {
+	Existing map[string]string // short_id из запроса -> short_id уже сохраненного URL
+}
+
+// Error реализует интерфейс error для ErrBatchConflict
+func (e *ErrBatchConflict) Error() string {
+	return "batch contains existing URLs"
+}
+
+// IsBatchConflict проверяет, является ли ошибка конфликтом URL в batch
+func IsBatchConflict(err error) (*ErrBatchConflict, bool) {
+	var conflictErr *ErrBatchConflict
+	if errors.As(err, &conflictErr) {
+		return conflictErr, true
+	}
+	return nil, false
+}
+
+// ErrDisallowedScheme возвращается, когда схема URL не входит в список разрешенных
+// (например, javascript: или data:)
+type ErrDisallowedScheme struct {
+	Scheme string
+}
+
+// Error реализует интерфейс error для ErrDisallowedScheme
+func (e *ErrDisallowedScheme) Error() string {
+	if e.Scheme == "" {
+		return "URL scheme is not allowed"
+	}
+	return "URL scheme is not allowed: " + e.Scheme
+}
+
+// IsDisallowedScheme проверяет, является ли ошибка запретом схемы URL
+func IsDisallowedScheme(err error) bool {
+	var schemeErr *ErrDisallowedScheme
+	return errors.As(err, &schemeErr)
+}
+
+// ErrBlockedURL возвращается, когда хост URL входит в список запрещенных доменов
+type ErrBlockedURL struct {
+	Host string
+}
+
+// Error реализует интерфейс error для ErrBlockedURL
+func (e *ErrBlockedURL) Error() string {
+	return "URL host is blocked: " + e.Host
+}
+
+// IsBlockedURL проверяет, является ли ошибка блокировкой URL
+func IsBlockedURL(err error) bool {
+	var blockedErr *ErrBlockedURL
+	return errors.As(err, &blockedErr)
+}
@@ -0,0 +1,18 @@
+package usecase
+
+// IdempotencyRecord - запись ключа идемпотентности
+type IdempotencyRecord struct {
+	Fingerprint string `json:"fingerprint"` // отпечаток запроса, занявшего ключ (нормализованный URL)
+	Value       string `json:"value"`       // результат запроса, пусто - запрос еще выполняется
+}
+
+// Pending сообщает, что запрос, занявший ключ, еще не завершился
+func (r IdempotencyRecord) Pending() bool {
+	return r.Value == ""
+}
+
+// scopedIdempotencyKey возвращает ключ идемпотентности, ограниченный пользователем,
+// чтобы разные пользователи не получали результаты запросов друг друга
+func scopedIdempotencyKey(userID, key string) string {
+	return userID + ":" + key
+}
@@ -0,0 +1,83 @@
+package usecase
+
+import (
+	"context"
+	"sync"
+)
+
+// ShortIDStats содержит счетчики сохранения ссылок со сгенерированным коротким ID
+type ShortIDStats struct {
+	Attempts   int64 // попытки сохранить ссылку с новым ID, включая повторы после коллизий
+	Collisions int64 // попытки, отклоненные хранилищем из-за уже занятого ID
+}
+
+// ShortIDStats возвращает текущие счетчики сохранения с новым коротким ID
+func (s *URLService) ShortIDStats() ShortIDStats {
+	return ShortIDStats{
+		Attempts:   s.shortIDAttempts.Load(),
+		Collisions: s.shortIDCollisions.Load(),
+	}
+}
+
+// randomIDGenerator генерирует случайные короткие идентификаторы заданной длины
+type randomIDGenerator struct {
+	length int
+}
+
+// NextID возвращает случайный короткий идентификатор
+func (g randomIDGenerator) NextID(ctx context.Context) (string, error) {
+	return generateShortID(g.length)
+}
+
+// base62Alphabet алфавит последовательных коротких идентификаторов
+const base62Alphabet = "0123456789abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ"
+
+// encodeBase62 кодирует неотрицательное число в base62
+func encodeBase62(n int64) string {
+	if n == 0 {
+		return base62Alphabet[:1]
+	}
+
+	var buf [11]byte // 62^11 > 2^63
+	i := len(buf)
+	for n > 0 {
+		i--
+		buf[i] = base62Alphabet[n%62]
+		n /= 62
+	}
+	return string(buf[i:])
+}
+
+// SequentialIDGenerator выдает последовательные идентификаторы в base62 из диапазонов,
+// зарезервированных через IDAllocator. Реплики получают разные диапазоны,
+// поэтому идентификаторы не пересекаются без проверки по хранилищу.
+type SequentialIDGenerator struct {
+	allocator IDAllocator
+
+	mu   sync.Mutex
+	next int64 // следующий свободный номер в текущем диапазоне
+	end  int64 // конец текущего диапазона (не включая)
+}
+
+// NewSequentialIDGenerator создает генератор последовательных идентификаторов
+func NewSequentialIDGenerator(allocator IDAllocator) *SequentialIDGenerator {
+	return &SequentialIDGenerator{allocator: allocator}
+}
+
+// NextID возвращает следующий идентификатор, при исчерпании диапазона резервирует новый
+func (g *SequentialIDGenerator) NextID(ctx context.Context) (string, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if g.next >= g.end {
+		start, size, err := g.allocator.AllocateIDRange(ctx)
+		if err != nil {
+			return "", err
+		}
+		g.next, g.end = start, start+size
+	}
+
+	id := g.next
+	g.next++
+	return encodeBase62(id), nil
+}
@@ -1,15 +1,67 @@
 // Package usecase предоставляет интерфейсы для бизнес-логики
 package usecase
 
-import "context"
+import (
+	"context"
+	"time"
+)
 
 // URLStorage определяет интерфейс для хранилища URL
 type URLStorage interface {
-	Save(shortID, url string) error
-	Get(shortID string) (string, error)
+	Save(ctx context.Context, url URLPair) error
+	Get(ctx context.Context, shortID string) (string, error)
 	SaveBatch(ctx context.Context, urls []URLPair) error
-	GetUserURLs(ctx context.Context, userID string) ([]UserURL, error)
+	GetUserURLs(ctx context.Context, userID string, limit, offset int) (urls []UserURL, total int, err error)
+	GetUserURL(ctx context.Context, userID, shortID string) (UserURL, error)
 	BatchDeleteUserURLs(ctx context.Context, userID string, shortIDs []string) error
+	DeleteAllUserURLs(ctx context.Context, userID string) (int64, error)
+	DeleteUserURLsWithResult(ctx context.Context, userID string, shortIDs []string) (DeleteResult, error)
+	IncrementVisits(ctx context.Context, shortID string) error
+	PurgeExpired(ctx context.Context, before time.Time) (int64, error)
+	Reset(ctx context.Context) (int64, error)
+	GetURLOwner(ctx context.Context, shortID string) (URLOwner, error)
+	RecentPublicURLs(ctx context.Context, limit int) ([]PublicURL, error)
+	Stats(ctx context.Context) (urls int, users int, err error)
+}
+
+// DeadLetterQueue принимает запросы на удаление, которые не удалось применить
+// после всех повторов, для последующего разбора и повторного выполнения
+type DeadLetterQueue interface {
+	Put(ctx context.Context, req DeleteRequest, cause error) error
+}
+
+// IDGenerator определяет интерфейс генератора коротких идентификаторов
+type IDGenerator interface {
+	NextID(ctx context.Context) (string, error)
+}
+
+// IDAllocator резервирует диапазоны числовых идентификаторов, не пересекающиеся
+// между репликами сервиса. Возвращает начало диапазона и его размер.
+type IDAllocator interface {
+	AllocateIDRange(ctx context.Context) (start, size int64, err error)
+}
+
+// IdempotencyStore определяет интерфейс хранилища ключей идемпотентности.
+// Хранилище отделено от URLStorage, чтобы его можно было разделять между репликами.
+type IdempotencyStore interface {
+	// Reserve атомарно занимает свободный ключ за запросом с отпечатком fingerprint
+	// (set-if-absent) и возвращает reserved=true. Если ключ уже занят, возвращает его запись.
+	Reserve(ctx context.Context, key, fingerprint string) (record IdempotencyRecord, reserved bool, err error)
+	// Complete сохраняет результат запроса по занятому ключу
+	Complete(ctx context.Context, key, fingerprint, value string) error
+	// Release освобождает ключ запроса, завершившегося ошибкой, чтобы его можно было повторить
+	Release(ctx context.Context, key string) error
+	Clear(ctx context.Context) error
+}
+
+// WriteChecker проверяет, что хранилище принимает запись (например, БД не в режиме только чтения)
+type WriteChecker interface {
+	CheckWritable(ctx context.Context) error
+}
+
+// PoolStatsProvider предоставляет статистику пула соединений с БД
+type PoolStatsProvider interface {
+	PoolStats() PoolStats
 }
 
 // DatabasePinger определяет интерфейс для проверки соединения с базой данных
@@ -17,3 +69,9 @@ type DatabasePinger interface {
 	Ping() error
 	Close() error
 }
+
+// Blocklist определяет интерфейс проверки хоста по списку запрещенных доменов.
+// Позволяет подключать как статический список, так и внешние сервисы проверки.
+type Blocklist interface {
+	IsBlocked(ctx context.Context, host string) (bool, error)
+}
@@ -0,0 +1,50 @@
+package usecase
+
+import (
+	"errors"
+	"time"
+)
+
+// defaultWorkerStallThreshold - время без прогресса воркеров удаления при непустой очереди,
+// после которого сервис считается неработоспособным
+const defaultWorkerStallThreshold = time.Minute
+
+// ErrDeleteWorkersStalled возвращается, если воркеры удаления не обрабатывают очередь
+var ErrDeleteWorkersStalled = errors.New("delete workers stalled")
+
+// enqueueDelete учитывает запрос, поставленный в очередь удаления.
+// Отсчет простоя начинается с момента, когда очередь стала непустой.
+func (s *URLService) enqueueDelete() {
+	s.deletesEnqueued.Add(1)
+	if s.pendingDeletes.Add(1) == 1 {
+		s.deleteHeartbeat.Store(time.Now().UnixNano())
+	}
+}
+
+// dropDelete отменяет учет запроса, который не поместился в очередь удаления
+func (s *URLService) dropDelete() {
+	s.deletesEnqueued.Add(-1)
+	s.pendingDeletes.Add(-1)
+	s.deletesDropped.Add(1)
+}
+
+// markDeleteProgress отмечает обработку запросов воркером удаления
+func (s *URLService) markDeleteProgress(processed int) {
+	s.deleteHeartbeat.Store(time.Now().UnixNano())
+	s.pendingDeletes.Add(-int64(processed))
+	s.deletesProcessed.Add(int64(processed))
+}
+
+// CheckLiveness проверяет, что воркеры удаления не зависли: если в очереди есть запросы,
+// а прогресса не было дольше порога, возвращается ErrDeleteWorkersStalled.
+func (s *URLService) CheckLiveness() error {
+	if s.pendingDeletes.Load() <= 0 {
+		return nil
+	}
+
+	lastProgress := time.Unix(0, s.deleteHeartbeat.Load())
+	if time.Since(lastProgress) > s.workerStallThreshold {
+		return ErrDeleteWorkersStalled
+	}
+	return nil
+}
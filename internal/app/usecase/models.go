@@ -1,26 +1,208 @@
 package usecase
 
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"time"
+)
+
 // URLPair пара URL для batch операций
 type URLPair struct {
 	ShortID     string
 	OriginalURL string
 	UserID      string
+	ExpiresAt   time.Time // время истечения ссылки, нулевое значение - бессрочно
+	Public      bool      // показывать ссылку в публичной ленте недавно созданных
+
+	// DedupKey - значение, по которому ищутся дубликаты, если сохраняемое значение
+	// OriginalURL от него отличается (например, зашифровано); пусто - сам OriginalURL
+	DedupKey string
+}
+
+// Key возвращает значение, по которому ищутся дубликаты ссылки
+func (p URLPair) Key() string {
+	if p.DedupKey != "" {
+		return p.DedupKey
+	}
+	return p.OriginalURL
+}
+
+// ShortenOptions содержит необязательные параметры создаваемой ссылки
+type ShortenOptions struct {
+	ExpiresAt      time.Time // время истечения ссылки, нулевое значение - бессрочно
+	Public         bool      // показывать ссылку в публичной ленте недавно созданных
+	IdempotencyKey string    // ключ идемпотентности запроса, пусто - без идемпотентности
 }
 
-// BatchShortenRequest запрос на сокращение URL в batch режиме
+// BatchShortenRequest запрос на сокращение URL в batch режиме.
+// correlation_id принимается строкой или числом и возвращается в ответе в том же виде.
 type BatchShortenRequest struct {
-	CorrelationID string `json:"correlation_id"`
-	OriginalURL   string `json:"original_url"`
+	CorrelationID        string `json:"correlation_id"`
+	CorrelationIDNumeric bool   `json:"-"` // клиент передал correlation_id числом
+	OriginalURL          string `json:"original_url"`
+}
+
+// UnmarshalJSON разбирает запрос, приводя числовой correlation_id к строке
+func (r *BatchShortenRequest) UnmarshalJSON(data []byte) error {
+	type plain BatchShortenRequest
+	aux := struct {
+		*plain
+		CorrelationID json.RawMessage `json:"correlation_id"`
+	}{plain: (*plain)(r)}
+	if err := json.Unmarshal(data, &aux); err != nil {
+		return err
+	}
+
+	var err error
+	r.CorrelationID, r.CorrelationIDNumeric, err = decodeCorrelationID(aux.CorrelationID)
+	return err
 }
 
 // BatchShortenResponse ответ на batch запрос
 type BatchShortenResponse struct {
-	CorrelationID string `json:"correlation_id"`
-	ShortURL      string `json:"short_url"`
+	CorrelationID        string `json:"correlation_id"`
+	CorrelationIDNumeric bool   `json:"-"` // вернуть correlation_id числом, как его передал клиент
+	ShortURL             string `json:"short_url"`
+}
+
+// MarshalJSON возвращает correlation_id в том виде, в котором его передал клиент
+func (r BatchShortenResponse) MarshalJSON() ([]byte, error) {
+	type plain BatchShortenResponse
+	return json.Marshal(struct {
+		CorrelationID json.RawMessage `json:"correlation_id"`
+		plain
+	}{MarshalCorrelationID(r.CorrelationID, r.CorrelationIDNumeric), plain(r)})
+}
+
+// Коды причин, по которым URL из batch не сохранен. Клиенту возвращается только код,
+// текст ошибки хранилища может раскрывать внутренние детали и пишется в лог.
+const (
+	BatchReasonConflict    = "conflict"     // URL уже сокращен, а стратегия конфликтов - error
+	BatchReasonBlocked     = "blocked"      // хост URL в списке запрещенных доменов
+	BatchReasonStorageFull = "storage_full" // достигнуто ограничение количества ссылок
+	BatchReasonInternal    = "internal"     // прочие ошибки
+)
+
+// BatchFailure описывает URL из batch запроса, который не удалось сохранить
+type BatchFailure struct {
+	CorrelationID        string `json:"correlation_id"`
+	CorrelationIDNumeric bool   `json:"-"` // вернуть correlation_id числом, как его передал клиент
+	Reason               string `json:"reason" enums:"conflict,blocked,storage_full,internal"`
+}
+
+// batchFailureReason возвращает код причины для ошибки сохранения URL из batch
+func batchFailureReason(err error) string {
+	_, isConflict := IsBatchConflict(err)
+	switch {
+	case isConflict, errors.Is(err, ErrURLExists):
+		return BatchReasonConflict
+	case IsBlockedURL(err):
+		return BatchReasonBlocked
+	case errors.Is(err, ErrStorageFull):
+		return BatchReasonStorageFull
+	default:
+		return BatchReasonInternal
+	}
+}
+
+// MarshalJSON возвращает correlation_id в том виде, в котором его передал клиент
+func (f BatchFailure) MarshalJSON() ([]byte, error) {
+	type plain BatchFailure
+	return json.Marshal(struct {
+		CorrelationID json.RawMessage `json:"correlation_id"`
+		plain
+	}{MarshalCorrelationID(f.CorrelationID, f.CorrelationIDNumeric), plain(f)})
+}
+
+// errInvalidCorrelationID - correlation_id не строка и не число
+var errInvalidCorrelationID = errors.New("correlation_id must be a string or a number")
+
+// decodeCorrelationID приводит correlation_id к строке и сообщает, был ли он числом.
+// Отсутствующий correlation_id и null дают пустую строку.
+func decodeCorrelationID(raw json.RawMessage) (string, bool, error) {
+	if len(raw) == 0 || bytes.Equal(raw, []byte("null")) {
+		return "", false, nil
+	}
+
+	if raw[0] == '"' {
+		var id string
+		err := json.Unmarshal(raw, &id)
+		return id, false, err
+	}
+
+	var number json.Number
+	if err := json.Unmarshal(raw, &number); err != nil {
+		return "", false, errInvalidCorrelationID
+	}
+	// Число сохраняется в исходной записи, чтобы вернуть клиенту ровно то, что он прислал
+	return number.String(), true, nil
+}
+
+// MarshalCorrelationID кодирует correlation_id числом или строкой
+func MarshalCorrelationID(id string, numeric bool) json.RawMessage {
+	if numeric {
+		return json.RawMessage(id)
+	}
+	encoded, _ := json.Marshal(id)
+	return encoded
+}
+
+// BatchMultiStatusResponse ответ на batch запрос, сохраненный частично
+type BatchMultiStatusResponse struct {
+	Succeeded []BatchShortenResponse `json:"succeeded"`
+	Failed    []BatchFailure         `json:"failed"`
 }
 
 // UserURL представляет URL пользователя
 type UserURL struct {
-	ShortURL    string `json:"short_url"`
-	OriginalURL string `json:"original_url"`
+	ShortID      string `json:"-"` // короткий идентификатор; ShortURL из него собирает сервис
+	ShortURL     string `json:"short_url"`
+	OriginalURL  string `json:"original_url"`
+	Visits       int64  `json:"visits"`
+	VisitsCapped bool   `json:"visits_capped"` // счетчик достиг VISIT_CAP и больше не растет, Visits - нижняя граница
+
+	CreatedAt time.Time `json:"-"` // время создания; по нему шардированное хранилище упорядочивает ссылки
+}
+
+// PublicURL представляет ссылку из публичной ленты недавно созданных
+type PublicURL struct {
+	ShortID     string    `json:"-"` // короткий идентификатор; ShortURL из него собирает сервис
+	ShortURL    string    `json:"short_url" example:"http://localhost:8080/abcd1234"`
+	OriginalURL string    `json:"original_url" example:"https://practicum.yandex.ru"`
+	CreatedAt   time.Time `json:"created_at" example:"2025-01-01T12:00:00Z"`
+}
+
+// PoolStats содержит текущую статистику пула соединений с БД
+type PoolStats struct {
+	TotalConns        int32   `json:"total_conns" example:"10"`
+	IdleConns         int32   `json:"idle_conns" example:"7"`
+	AcquiredConns     int32   `json:"acquired_conns" example:"3"`
+	ConstructingConns int32   `json:"constructing_conns" example:"0"`
+	MaxConns          int32   `json:"max_conns" example:"10"`
+	AcquireCount      int64   `json:"acquire_count" example:"1500"`
+	EmptyAcquireCount int64   `json:"empty_acquire_count" example:"12"` // получения, ожидавшие свободного соединения
+	CanceledAcquires  int64   `json:"canceled_acquire_count" example:"0"`
+	AcquireDuration   float64 `json:"acquire_duration_seconds" example:"0.35"`  // суммарное время получения соединений
+	EmptyAcquireWait  float64 `json:"empty_acquire_wait_seconds" example:"0.2"` // суммарное время ожидания свободного соединения
+}
+
+// Stats содержит основную статистику сервиса: количество неудаленных и неистекших ссылок
+// и пользователей, у которых есть хотя бы одна такая ссылка
+type Stats struct {
+	URLs  int `json:"urls" example:"1500"`
+	Users int `json:"users" example:"42"`
+}
+
+// URLOwner описывает создателя короткого URL для расследования злоупотреблений
+type URLOwner struct {
+	UserID    string    `json:"user_id" example:"3f8a9c1e-0b7d-4e5f-9a2b-6c1d8e7f0a3b"` // пусто для анонимных URL
+	CreatedAt time.Time `json:"created_at" example:"2025-01-01T12:00:00Z"`
+}
+
+// DeleteResult итог синхронного удаления URL пользователя
+type DeleteResult struct {
+	Deleted        []string `json:"deleted"`
+	AlreadyDeleted []string `json:"already_deleted"`
+	NotFound       []string `json:"not_found"`
 }
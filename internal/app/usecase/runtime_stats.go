@@ -0,0 +1,30 @@
+package usecase
+
+import "runtime"
+
+// RuntimeStats содержит показатели среды выполнения для быстрой диагностики без pprof
+type RuntimeStats struct {
+	Goroutines    int    `json:"goroutines" example:"42"`
+	AllocBytes    uint64 `json:"alloc_bytes" example:"4194304"` // память, занятая объектами кучи
+	SysBytes      uint64 `json:"sys_bytes" example:"16777216"`  // память, полученная от ОС
+	HeapObjects   uint64 `json:"heap_objects" example:"25000"`  // количество объектов в куче
+	GCCount       uint32 `json:"gc_count" example:"17"`         // число завершенных циклов GC
+	DeleteWorkers int32  `json:"delete_workers" example:"3"`    // запущенные воркеры асинхронного удаления
+}
+
+// RuntimeStats возвращает показатели среды выполнения и число воркеров удаления.
+// runtime.ReadMemStats ненадолго останавливает программу, поэтому метод не предназначен
+// для частого опроса - для этого есть метрики Prometheus.
+func (s *URLService) RuntimeStats() RuntimeStats {
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+
+	return RuntimeStats{
+		Goroutines:    runtime.NumGoroutine(),
+		AllocBytes:    mem.Alloc,
+		SysBytes:      mem.Sys,
+		HeapObjects:   mem.HeapObjects,
+		GCCount:       mem.NumGC,
+		DeleteWorkers: s.deleteWorkers.Load(),
+	}
+}
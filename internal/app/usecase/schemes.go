@@ -0,0 +1,102 @@
+// Package usecase содержит бизнес-логику сервиса сокращения URL
+package usecase
+
+import (
+	"net/url"
+	"strings"
+)
+
+// defaultAllowedSchemes - схемы URL, разрешенные по умолчанию
+var defaultAllowedSchemes = []string{"http", "https"}
+
+// defaultRedirectScheme - схема, подставляемая при редиректе на URL без схемы
+const defaultRedirectScheme = "https"
+
+// newSchemeSet строит множество разрешенных схем без учета регистра
+func newSchemeSet(schemes []string) map[string]bool {
+	if len(schemes) == 0 {
+		schemes = defaultAllowedSchemes
+	}
+
+	set := make(map[string]bool, len(schemes))
+	for _, scheme := range schemes {
+		scheme = strings.ToLower(strings.TrimSpace(scheme))
+		if scheme != "" {
+			set[scheme] = true
+		}
+	}
+	return set
+}
+
+// checkScheme проверяет, что схема URL разрешена.
+// URL без схемы пропускаются: они считаются относительными к схеме по умолчанию.
+func (s *URLService) checkScheme(rawURL string) error {
+	parsed, err := url.Parse(strings.TrimSpace(rawURL))
+	if err != nil {
+		return &ErrDisallowedScheme{}
+	}
+
+	if parsed.Scheme == "" {
+		return nil
+	}
+
+	scheme := strings.ToLower(parsed.Scheme)
+	if !s.allowedSchemes[scheme] {
+		return &ErrDisallowedScheme{Scheme: scheme}
+	}
+	return nil
+}
+
+// hostOf возвращает хост URL без порта. URL без схемы разбирается как http,
+// чтобы хост определялся и для записей вида example.com/path.
+func hostOf(rawURL string) string {
+	rawURL = strings.TrimSpace(rawURL)
+	parsed, err := url.Parse(rawURL)
+	if err == nil && parsed.Scheme == "" {
+		parsed, err = url.Parse("http://" + rawURL)
+	}
+	if err != nil {
+		return ""
+	}
+	return parsed.Hostname()
+}
+
+// normalizeURL отбрасывает фрагмент (#section), если включен StripFragments.
+// Фрагмент обрабатывается только браузером, а без него a.com#x и a.com#y
+// считаются одним URL при поиске дубликатов.
+func (s *URLService) normalizeURL(rawURL string) string {
+	if !s.stripFragments {
+		return rawURL
+	}
+	withoutFragment, _, _ := strings.Cut(rawURL, "#")
+	return withoutFragment
+}
+
+// normalizeBatch применяет normalizeURL к URL пакета, не изменяя исходный срез
+func (s *URLService) normalizeBatch(requests []BatchShortenRequest) []BatchShortenRequest {
+	if !s.stripFragments {
+		return requests
+	}
+	normalized := make([]BatchShortenRequest, len(requests))
+	for i, req := range requests {
+		req.OriginalURL = s.normalizeURL(req.OriginalURL)
+		normalized[i] = req
+	}
+	return normalized
+}
+
+// withDefaultScheme добавляет схему по умолчанию к URL без схемы, чтобы браузер
+// не считал Location относительным путем на домене сокращателя.
+// Например, example.com превращается в https://example.com.
+func (s *URLService) withDefaultScheme(rawURL string) string {
+	rawURL = strings.TrimSpace(rawURL)
+	parsed, err := url.Parse(rawURL)
+	if err != nil || parsed.Scheme != "" || rawURL == "" {
+		return rawURL
+	}
+
+	if strings.HasPrefix(rawURL, "//") {
+		return s.defaultScheme + ":" + rawURL
+	}
+	return s.defaultScheme + "://" + rawURL
+}
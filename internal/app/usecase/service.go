@@ -5,44 +5,174 @@ import (
 	"context"
 	"crypto/rand"
 	"encoding/base64"
+	"errors"
+	"math"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
+
+	"github.com/m-molecula741/shortener/internal/app/logger"
 )
 
 // DeleteRequest представляет запрос на удаление URL
 type DeleteRequest struct {
-	UserID   string
-	ShortIDs []string
+	UserID   string   `json:"user_id"`
+	ShortIDs []string `json:"short_ids"`
+	All      bool     `json:"all,omitempty"` // удалить все URL пользователя, ShortIDs не используются
+}
+
+// Options содержит дополнительные настройки URLService
+type Options struct {
+	IdempotencyStore IdempotencyStore  // хранилище ключей идемпотентности, nil - отключено
+	AllowedSchemes   []string          // разрешенные схемы URL, пусто - http и https
+	Blocklist        Blocklist         // список запрещенных доменов, nil - проверка отключена
+	DefaultScheme    string            // схема для сохраненных URL без схемы при редиректе, пусто - https
+	GCInterval       time.Duration     // интервал фоновой очистки истекших и удаленных ссылок, 0 - отключено
+	GCGracePeriod    time.Duration     // сколько хранить удаленные ссылки перед физическим удалением
+	ConflictStrategy ConflictStrategy  // поведение при повторном original URL, пусто - ConflictReturnExisting
+	IDGenerator      IDGenerator       // генератор коротких ID, nil - случайные ID длины ShortIDLength
+	ShortIDLength    int               // длина случайных коротких ID, 0 - RandomShortIDLength
+	ShortIDAttempts  int               // попыток сохранить URL с новым ID при ErrShortIDCollision, 0 - DefaultShortIDAttempts
+	WriteChecker     WriteChecker      // проверка записи для CheckReady, nil - только PingDB
+	PoolStats        PoolStatsProvider // статистика пула соединений с БД, nil - недоступна
+	StripFragments   bool              // отбрасывать #fragment перед сохранением и поиском дубликатов
+	Clock            Clock             // источник времени для фоновой очистки, nil - системные часы
+
+	// DeleteRetries - число повторов асинхронного удаления при ошибке хранилища, 0 - без повторов.
+	// DeleteRetryBackoff - пауза перед первым повтором, удваивается с каждой попыткой, 0 - 100 мс.
+	// Запросы, не примененные после всех попыток, передаются в DeadLetters.
+	DeleteRetries      int
+	DeleteRetryBackoff time.Duration
+	DeadLetters        DeadLetterQueue // очередь неудавшихся удалений, nil - только запись в лог
+
+	// WorkerStallThreshold - время без прогресса воркеров удаления при непустой очереди,
+	// после которого CheckLiveness сообщает о зависании, 0 - одна минута
+	WorkerStallThreshold time.Duration
 }
 
 // URLService реализует бизнес-логику работы с URL
 type URLService struct {
-	storage  URLStorage
-	baseURL  string
-	dbPinger DatabasePinger
+	storage     URLStorage
+	baseURL     string
+	dbPinger    DatabasePinger
+	idempotency IdempotencyStore
+	idGenerator IDGenerator
+	idAttempts  int
+	writeCheck  WriteChecker
+	poolStats   PoolStatsProvider
+
+	allowedSchemes   map[string]bool
+	conflictStrategy ConflictStrategy
+	blocklist        Blocklist
+	defaultScheme    string
+	stripFragments   bool
 
 	// Каналы для асинхронного удаления
-	deleteChan chan DeleteRequest
-	workerWG   sync.WaitGroup
+	deleteChan    chan DeleteRequest
+	workerWG      sync.WaitGroup
+	deleteWorkers atomic.Int32 // запущенные воркеры удаления
+
+	// Heartbeat воркеров удаления для проверки живости
+	deleteHeartbeat      atomic.Int64 // время последнего прогресса в UnixNano
+	pendingDeletes       atomic.Int64 // запросы, поставленные в очередь и еще не обработанные
+	workerStallThreshold time.Duration
+
+	// Повторы и dead-letter для удалений, которые не удалось применить
+	deleteRetries      int
+	deleteRetryBackoff time.Duration
+	deadLetters        DeadLetterQueue
+
+	// Счетчики асинхронного удаления для итога при остановке
+	deletesEnqueued  atomic.Int64
+	deletesProcessed atomic.Int64
+	deletesFailed    atomic.Int64
+	deletesDropped   atomic.Int64
+
+	deletesDeadLettered atomic.Int64
+
+	// Счетчики сохранений со сгенерированным коротким ID для метрик коллизий
+	shortIDAttempts   atomic.Int64
+	shortIDCollisions atomic.Int64
+
+	// Фоновая очистка истекших и удаленных ссылок
+	gcGracePeriod time.Duration
+	clock         Clock
+	gcStop        chan struct{}
+	gcWG          sync.WaitGroup
 }
 
 // NewURLService создает новый экземпляр URLService с настроенными воркерами для удаления
-func NewURLService(storage URLStorage, baseURL string, dbPinger DatabasePinger) *URLService {
+func NewURLService(storage URLStorage, baseURL string, dbPinger DatabasePinger, opts Options) *URLService {
 	if !strings.HasSuffix(baseURL, "/") {
 		baseURL = baseURL + "/"
 	}
 
+	if opts.DefaultScheme == "" {
+		opts.DefaultScheme = defaultRedirectScheme
+	}
+
+	if opts.ShortIDLength <= 0 {
+		opts.ShortIDLength = RandomShortIDLength
+	}
+
+	if opts.IDGenerator == nil {
+		opts.IDGenerator = randomIDGenerator{length: opts.ShortIDLength}
+	}
+
+	if opts.Clock == nil {
+		opts.Clock = realClock{}
+	}
+
+	if opts.ShortIDAttempts <= 0 {
+		opts.ShortIDAttempts = DefaultShortIDAttempts
+	}
+
+	if opts.DeleteRetryBackoff <= 0 {
+		opts.DeleteRetryBackoff = defaultDeleteRetryBackoff
+	}
+
+	if opts.WorkerStallThreshold <= 0 {
+		opts.WorkerStallThreshold = defaultWorkerStallThreshold
+	}
+
 	service := &URLService{
-		storage:    storage,
-		baseURL:    baseURL,
-		dbPinger:   dbPinger,
-		deleteChan: make(chan DeleteRequest, 100), // Буфер для 100 запросов
+		storage:     storage,
+		baseURL:     baseURL,
+		dbPinger:    dbPinger,
+		idempotency: opts.IdempotencyStore,
+		idGenerator: opts.IDGenerator,
+		idAttempts:  opts.ShortIDAttempts,
+		writeCheck:  opts.WriteChecker,
+		poolStats:   opts.PoolStats,
+		deleteChan:  make(chan DeleteRequest, 100), // Буфер для 100 запросов
+
+		allowedSchemes: newSchemeSet(opts.AllowedSchemes),
+		blocklist:      opts.Blocklist,
+		defaultScheme:  opts.DefaultScheme,
+		stripFragments: opts.StripFragments,
+
+		conflictStrategy: opts.ConflictStrategy,
+
+		gcGracePeriod: opts.GCGracePeriod,
+		clock:         opts.Clock,
+		gcStop:        make(chan struct{}),
+
+		workerStallThreshold: opts.WorkerStallThreshold,
+
+		deleteRetries:      opts.DeleteRetries,
+		deleteRetryBackoff: opts.DeleteRetryBackoff,
+		deadLetters:        opts.DeadLetters,
 	}
 
 	// Запускаем воркеры для обработки удаления
 	service.startDeleteWorkers()
 
+	if opts.GCInterval > 0 {
+		service.gcWG.Add(1)
+		go service.gcLoop(opts.GCInterval)
+	}
+
 	return service
 }
 
@@ -60,6 +190,9 @@ func (s *URLService) startDeleteWorkers() {
 func (s *URLService) deleteWorker() {
 	defer s.workerWG.Done()
 
+	s.deleteWorkers.Add(1)
+	defer s.deleteWorkers.Add(-1)
+
 	// Создаем каналы для fanIn паттерна
 	batchChan := make(chan []DeleteRequest, 10)
 
@@ -69,6 +202,7 @@ func (s *URLService) deleteWorker() {
 	// Обрабатываем batch запросы
 	for batch := range batchChan {
 		s.processBatch(batch)
+		s.markDeleteProgress(len(batch))
 	}
 }
 
@@ -124,15 +258,20 @@ func (s *URLService) batchCollector(batchChan chan<- []DeleteRequest) {
 func (s *URLService) processBatch(batch []DeleteRequest) {
 	// Группируем запросы по пользователям для batch update
 	userBatches := make(map[string][]string)
+	userRequests := make(map[string][]DeleteRequest)
+	deleteAll := make(map[string]bool)
 
 	for _, req := range batch {
 		userBatches[req.UserID] = append(userBatches[req.UserID], req.ShortIDs...)
+		userRequests[req.UserID] = append(userRequests[req.UserID], req)
+		deleteAll[req.UserID] = deleteAll[req.UserID] || req.All
 	}
 
-	// Обновляем БД для каждого пользователя
+	// Обновляем БД для каждого пользователя; удаление всех URL покрывает и отдельные ID
 	for userID, shortIDs := range userBatches {
-		if err := s.storage.BatchDeleteUserURLs(context.Background(), userID, shortIDs); err != nil {
-			_ = err
+		if err := s.deleteWithRetry(userID, shortIDs, deleteAll[userID]); err != nil {
+			s.deletesFailed.Add(int64(len(userRequests[userID])))
+			s.deadLetter(userRequests[userID], err)
 		}
 	}
 }
@@ -149,19 +288,104 @@ func (s *URLService) DeleteUserURLs(userID string, shortIDs []string) error {
 		ShortIDs: shortIDs,
 	}
 
+	// Учитываем запрос до отправки, чтобы воркер не успел обработать его раньше
+	s.enqueueDelete()
+
 	select {
 	case s.deleteChan <- req:
 		return nil
 	default:
 		// Канал заполнен, возвращаем ошибку
+		s.dropDelete()
 		return ErrDeleteChannelFull
 	}
 }
 
+// DeleteAllUserURLs добавляет запрос на асинхронное удаление всех URL пользователя.
+// Удаляются ссылки, существующие на момент обработки запроса воркером.
+func (s *URLService) DeleteAllUserURLs(userID string) error {
+	s.enqueueDelete()
+
+	select {
+	case s.deleteChan <- DeleteRequest{UserID: userID, All: true}:
+		return nil
+	default:
+		s.dropDelete()
+		return ErrDeleteChannelFull
+	}
+}
+
+// DeleteUserURLsSync синхронно удаляет URL пользователя и возвращает итог по каждому ID.
+// URL, не принадлежащие пользователю, попадают в NotFound, чтобы не раскрывать их существование.
+func (s *URLService) DeleteUserURLsSync(ctx context.Context, userID string, shortIDs []string) (DeleteResult, error) {
+	if len(shortIDs) == 0 {
+		return DeleteResult{}, nil
+	}
+	return s.storage.DeleteUserURLsWithResult(ctx, userID, shortIDs)
+}
+
+// PurgeExpired физически удаляет ссылки с истекшим сроком действия и ссылки,
+// удаленные раньше, чем GCGracePeriod назад. Возвращает количество удаленных ссылок.
+func (s *URLService) PurgeExpired(ctx context.Context) (int64, error) {
+	return s.storage.PurgeExpired(ctx, s.clock.Now().Add(-s.gcGracePeriod))
+}
+
+// Reset удаляет все ссылки и ключи идемпотентности, возвращает количество удаленных ссылок.
+// Предназначен для тестовых окружений.
+func (s *URLService) Reset(ctx context.Context) (int64, error) {
+	removed, err := s.storage.Reset(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	// Иначе повторный запрос с прежним ключом вернул бы удаленную ссылку
+	if s.idempotency != nil {
+		if err := s.idempotency.Clear(ctx); err != nil {
+			return removed, err
+		}
+	}
+
+	return removed, nil
+}
+
+// GetURLOwner возвращает создателя короткого URL, в том числе удаленного
+func (s *URLService) GetURLOwner(ctx context.Context, shortID string) (URLOwner, error) {
+	return s.storage.GetURLOwner(ctx, shortID)
+}
+
+// GetStats возвращает количество ссылок и пользователей в хранилище
+func (s *URLService) GetStats(ctx context.Context) (Stats, error) {
+	urls, users, err := s.storage.Stats(ctx)
+	if err != nil {
+		return Stats{}, err
+	}
+	return Stats{URLs: urls, Users: users}, nil
+}
+
+// gcLoop периодически очищает хранилище до вызова Close
+func (s *URLService) gcLoop(interval time.Duration) {
+	defer s.gcWG.Done()
+
+	for {
+		select {
+		case <-s.clock.After(interval):
+			// Ошибка очистки не критична, повторим на следующем тике
+			_, _ = s.PurgeExpired(context.Background())
+		case <-s.gcStop:
+			return
+		}
+	}
+}
+
 // Close закрывает сервис и ждет завершения всех воркеров
 func (s *URLService) Close() {
+	close(s.gcStop)
+	s.gcWG.Wait()
+
 	close(s.deleteChan)
 	s.workerWG.Wait()
+
+	s.logDeleteStats()
 }
 
 // Добавляем пул для строк
@@ -172,9 +396,26 @@ var bufferPool = sync.Pool{
 }
 
 // Shorten сокращает URL без привязки к пользователю
-func (s *URLService) Shorten(url string) (string, error) {
-	shortID, err := generateShortID()
+func (s *URLService) Shorten(ctx context.Context, url string) (string, error) {
+	return s.shorten(ctx, URLPair{OriginalURL: s.normalizeURL(url)})
+}
+
+// shorten сохраняет ссылку со всеми атрибутами одной записью и строит короткий URL
+// от базового адреса из контекста
+func (s *URLService) shorten(ctx context.Context, url URLPair) (string, error) {
+	if err := s.checkScheme(url.OriginalURL); err != nil {
+		return "", err
+	}
+
+	if err := s.checkBlocklist(ctx, url.OriginalURL); err != nil {
+		return "", err
+	}
+
+	shortID, err := s.saveWithNewID(ctx, url)
 	if err != nil {
+		if conflictErr, isConflict := IsURLConflict(err); isConflict {
+			return s.resolveConflict(ctx, conflictErr)
+		}
 		return "", err
 	}
 
@@ -183,28 +424,86 @@ func (s *URLService) Shorten(url string) (string, error) {
 	builder.Reset()
 	defer bufferPool.Put(builder)
 
-	builder.WriteString(s.baseURL)
-	if !strings.HasSuffix(s.baseURL, "/") {
-		builder.WriteString("/")
-	}
+	builder.WriteString(s.baseURLFor(ctx))
 	builder.WriteString(shortID)
-	shortURL := builder.String()
+	return builder.String(), nil
+}
 
-	if err := s.storage.Save(shortID, url); err != nil {
-		if conflictErr, isConflict := IsURLConflict(err); isConflict {
-			return s.baseURL + conflictErr.ExistingShortURL, &ErrURLConflict{
-				ExistingShortURL: s.baseURL + conflictErr.ExistingShortURL,
-			}
+// saveWithNewID сохраняет URL под новым коротким ID. Если ID уже занят другой ссылкой,
+// генерирует новый, делая не более idAttempts попыток.
+func (s *URLService) saveWithNewID(ctx context.Context, url URLPair) (string, error) {
+	for attempt := 1; ; attempt++ {
+		shortID, err := s.idGenerator.NextID(ctx)
+		if err != nil {
+			return "", err
 		}
-		return "", err
+
+		url.ShortID = shortID
+		s.shortIDAttempts.Add(1)
+		err = s.storage.Save(ctx, url)
+		if err == nil {
+			return shortID, nil
+		}
+		if errors.Is(err, ErrShortIDCollision) {
+			s.shortIDCollisions.Add(1)
+		}
+		if !errors.Is(err, ErrShortIDCollision) || attempt >= s.idAttempts {
+			return "", err
+		}
+	}
+}
+
+// ShortenWithUser сокращает URL, связывает его с пользователем и сохраняет параметры opts.
+// Если передан ключ идемпотентности, повторный запрос с тем же ключом
+// возвращает ранее созданный короткий URL без создания новой записи. Ключ занимается
+// атомарно, поэтому из одновременных запросов с одним ключом ссылку создает только первый,
+// остальные получают ErrIdempotencyKeyInProgress. Ключ, использованный с другим URL,
+// отклоняется с ErrIdempotencyKeyMismatch.
+func (s *URLService) ShortenWithUser(ctx context.Context, url, userID string, opts ShortenOptions) (string, error) {
+	url = s.normalizeURL(url)
+
+	if opts.IdempotencyKey == "" || s.idempotency == nil {
+		return s.shortenWithUser(ctx, url, userID, opts)
 	}
 
+	key := scopedIdempotencyKey(userID, opts.IdempotencyKey)
+	record, reserved, err := s.idempotency.Reserve(ctx, key, url)
+	if err != nil {
+		// Недоступное хранилище ключей не должно блокировать сокращение
+		return s.shortenWithUser(ctx, url, userID, opts)
+	}
+	if !reserved {
+		switch {
+		case record.Fingerprint != url:
+			return "", ErrIdempotencyKeyMismatch
+		case record.Pending():
+			return "", ErrIdempotencyKeyInProgress
+		}
+		return record.Value, nil
+	}
+
+	shortURL, err := s.shortenWithUser(ctx, url, userID, opts)
+	if err != nil {
+		_ = s.idempotency.Release(ctx, key)
+		return shortURL, err
+	}
+
+	// Ошибка сохранения ключа не должна ломать уже выполненное сокращение
+	_ = s.idempotency.Complete(ctx, key, url, shortURL)
+
 	return shortURL, nil
 }
 
-// ShortenWithUser сокращает URL и связывает его с пользователем
-func (s *URLService) ShortenWithUser(ctx context.Context, url, userID string) (string, error) {
-	shortURL, err := s.Shorten(url)
+// shortenWithUser сокращает URL и связывает его с пользователем без учета идемпотентности
+func (s *URLService) shortenWithUser(ctx context.Context, url, userID string, opts ShortenOptions) (string, error) {
+	// Владелец, срок действия и признак публичности сохраняются вместе со ссылкой,
+	// чтобы не появилось ссылки без срока действия при сбое отдельной записи атрибутов
+	shortURL, err := s.shorten(ctx, URLPair{
+		OriginalURL: url,
+		UserID:      userID,
+		ExpiresAt:   opts.ExpiresAt,
+		Public:      opts.Public,
+	})
 	if err != nil {
 		// Если это конфликт URL, возвращаем существующий URL
 		if _, isConflict := IsURLConflict(err); isConflict {
@@ -213,39 +512,88 @@ func (s *URLService) ShortenWithUser(ctx context.Context, url, userID string) (s
 		return "", err
 	}
 
-	// Если URL успешно создан и у нас есть userID, связываем его с пользователем
-	if userID != "" {
-		// Извлекаем shortID из shortURL
-		shortID := shortURL[len(s.baseURL):]
+	return shortURL, nil
+}
 
-		urlPair := URLPair{
-			ShortID:     shortID,
-			OriginalURL: url,
-			UserID:      userID,
-		}
+type skipVisitKeyType struct{}
 
-		// Обновляем запись с userID через SaveBatch
-		if err := s.storage.SaveBatch(ctx, []URLPair{urlPair}); err != nil {
-			_ = err
-		}
+// skipVisitKey ключ контекста для признака проверки ссылки без перехода
+var skipVisitKey = skipVisitKeyType{}
+
+// WithoutVisitCount помечает в контексте, что Expand не должен учитывать переход,
+// например для HEAD запросов программ проверки ссылок
+func WithoutVisitCount(ctx context.Context) context.Context {
+	return context.WithValue(ctx, skipVisitKey, true)
+}
+
+// Expand возвращает оригинальный URL по короткому идентификатору и учитывает переход
+func (s *URLService) Expand(ctx context.Context, shortID string) (string, error) {
+	originalURL, err := s.storage.Get(ctx, shortID)
+	if err != nil {
+		return "", err
 	}
 
-	return shortURL, nil
+	// Повторная проверка защищает от URL, сохраненных до введения ограничения
+	if err := s.checkScheme(originalURL); err != nil {
+		return "", err
+	}
+
+	originalURL = s.withDefaultScheme(originalURL)
+
+	// Ошибка подсчета переходов не должна мешать редиректу
+	if skip, _ := ctx.Value(skipVisitKey).(bool); !skip {
+		_ = s.storage.IncrementVisits(ctx, shortID)
+	}
+
+	return originalURL, nil
 }
 
-// Expand возвращает оригинальный URL по короткому идентификатору
-func (s *URLService) Expand(shortID string) (string, error) {
-	return s.storage.Get(shortID)
+// checkBlocklist проверяет, что хост URL не входит в список запрещенных доменов
+func (s *URLService) checkBlocklist(ctx context.Context, rawURL string) error {
+	if s.blocklist == nil {
+		return nil
+	}
+
+	host := hostOf(rawURL)
+	if host == "" {
+		return nil
+	}
+
+	blocked, err := s.blocklist.IsBlocked(ctx, host)
+	if err != nil {
+		return err
+	}
+	if blocked {
+		return &ErrBlockedURL{Host: host}
+	}
+	return nil
 }
 
-// generateShortID генерирует короткий идентификатор
-func generateShortID() (string, error) {
-	// Создаем фиксированный буфер каждый раз
-	b := make([]byte, 6)
+// RandomShortIDLength - длина случайного короткого идентификатора по умолчанию
+const RandomShortIDLength = 8
+
+// DefaultShortIDAttempts - число попыток сохранить URL с новым коротким ID по умолчанию
+const DefaultShortIDAttempts = 5
+
+// generateShortID генерирует случайный короткий идентификатор из length символов base64.
+// Каждый символ несет 6 бит, поэтому читается ceil(6*length/8) случайных байт.
+func generateShortID(length int) (string, error) {
+	b := make([]byte, (length*3+3)/4)
 	if _, err := rand.Read(b); err != nil {
 		return "", err
 	}
-	return base64.URLEncoding.WithPadding(base64.NoPadding).EncodeToString(b)[:8], nil
+	return base64.URLEncoding.WithPadding(base64.NoPadding).EncodeToString(b)[:length], nil
+}
+
+// ShortIDCollisionProbability оценивает вероятность хотя бы одной коллизии случайных коротких ID
+// длины length среди expectedURLs ссылок по границе задачи о днях рождения: p ≈ 1 - exp(-n²/2N).
+func ShortIDCollisionProbability(expectedURLs int64, length int) float64 {
+	if expectedURLs <= 1 {
+		return 0
+	}
+	space := math.Exp2(6 * float64(length))
+	n := float64(expectedURLs)
+	return -math.Expm1(-n * n / (2 * space))
 }
 
 // PingDB проверяет соединение с базой данных
@@ -256,78 +604,197 @@ func (s *URLService) PingDB() error {
 	return s.dbPinger.Ping()
 }
 
+// CheckReady проверяет готовность принимать запросы: соединение с БД
+// и, если настроено, возможность записи
+func (s *URLService) CheckReady(ctx context.Context) error {
+	if err := s.PingDB(); err != nil {
+		return err
+	}
+	if s.writeCheck == nil {
+		return nil
+	}
+	return s.writeCheck.CheckWritable(ctx)
+}
+
+// DBPoolStats возвращает статистику пула соединений с БД.
+// Для хранилищ без пула возвращает ErrPoolStatsUnavailable.
+func (s *URLService) DBPoolStats() (PoolStats, error) {
+	if s.poolStats == nil {
+		return PoolStats{}, ErrPoolStatsUnavailable
+	}
+	return s.poolStats.PoolStats(), nil
+}
+
 // ShortenBatch сокращает множество URL за одну операцию
 func (s *URLService) ShortenBatch(ctx context.Context, requests []BatchShortenRequest) ([]BatchShortenResponse, error) {
+	return s.ShortenBatchWithUser(ctx, requests, "")
+}
+
+// ShortenBatchWithUser сокращает множество URL за одну операцию с привязкой к пользователю.
+// Если сохранить batch целиком не удалось, URL сохраняются по одному и возвращается
+// ErrBatchPartialFailure со списком correlation_id, которые сохранить не удалось.
+func (s *URLService) ShortenBatchWithUser(ctx context.Context, requests []BatchShortenRequest, userID string) ([]BatchShortenResponse, error) {
 	if len(requests) == 0 {
 		return []BatchShortenResponse{}, nil
 	}
 
+	requests = s.normalizeBatch(requests)
+
+	for _, req := range requests {
+		if err := s.checkScheme(req.OriginalURL); err != nil {
+			return nil, err
+		}
+		if err := s.checkBlocklist(ctx, req.OriginalURL); err != nil {
+			return nil, err
+		}
+	}
+
+	baseURL := s.baseURLFor(ctx)
+
 	// Подготавливаем данные для batch сохранения
 	urlPairs := make([]URLPair, len(requests))
 	responses := make([]BatchShortenResponse, len(requests))
 
+	// Повторы original URL внутри batch получают тот же короткий ID и сохраняются один раз.
+	// При ConflictCreateNew каждый URL, как и вне batch, получает собственный ID.
+	shortIDs := make(map[string]string, len(requests))
+	uniquePairs := make([]URLPair, 0, len(requests))
+
 	for i, req := range requests {
-		shortID, err := generateShortID()
-		if err != nil {
-			return nil, err
+		shortID, seen := shortIDs[req.OriginalURL]
+		if !seen || s.conflictStrategy == ConflictCreateNew {
+			var err error
+			shortID, err = s.idGenerator.NextID(ctx)
+			if err != nil {
+				return nil, err
+			}
+			shortIDs[req.OriginalURL] = shortID
+			seen = false
 		}
 
 		urlPairs[i] = URLPair{
 			ShortID:     shortID,
 			OriginalURL: req.OriginalURL,
+			UserID:      userID,
+		}
+		if !seen {
+			uniquePairs = append(uniquePairs, urlPairs[i])
 		}
 
 		responses[i] = BatchShortenResponse{
-			CorrelationID: req.CorrelationID,
-			ShortURL:      s.baseURL + shortID,
+			CorrelationID:        req.CorrelationID,
+			CorrelationIDNumeric: req.CorrelationIDNumeric,
+			ShortURL:             baseURL + shortID,
 		}
 	}
 
 	// Сохраняем все URL одной операцией
-	if err := s.storage.SaveBatch(ctx, urlPairs); err != nil {
-		return nil, err
+	err := s.storage.SaveBatch(ctx, uniquePairs)
+	if err == nil {
+		return responses, nil
 	}
 
-	return responses, nil
-}
+	if conflictErr, isConflict := IsBatchConflict(err); isConflict {
+		return s.resolveBatchConflict(baseURL, urlPairs, responses, conflictErr)
+	}
 
-// ShortenBatchWithUser сокращает множество URL за одну операцию с привязкой к пользователю
-func (s *URLService) ShortenBatchWithUser(ctx context.Context, requests []BatchShortenRequest, userID string) ([]BatchShortenResponse, error) {
-	if len(requests) == 0 {
-		return []BatchShortenResponse{}, nil
+	// Для batch из одного URL повторная попытка ничего не даст
+	if len(uniquePairs) == 1 {
+		return nil, err
 	}
 
-	// Подготавливаем данные для batch сохранения
-	urlPairs := make([]URLPair, len(requests))
-	responses := make([]BatchShortenResponse, len(requests))
+	return s.saveBatchPartially(ctx, urlPairs, responses, err)
+}
 
-	for i, req := range requests {
-		shortID, err := generateShortID()
+// saveBatchPartially сохраняет URL по одному после неудачного сохранения batch целиком.
+// Возвращает успешно сохраненные URL и ErrBatchPartialFailure с причинами ошибок.
+// Если не удалось сохранить ни один URL, возвращается исходная ошибка batch.
+func (s *URLService) saveBatchPartially(ctx context.Context, urlPairs []URLPair, responses []BatchShortenResponse, batchErr error) ([]BatchShortenResponse, error) {
+	succeeded := make([]BatchShortenResponse, 0, len(urlPairs))
+	var failed []BatchFailure
+
+	// Повторы одного URL в batch имеют общий ID: сохраняем его один раз и переиспользуем итог
+	outcomes := make(map[string]error, len(urlPairs))
+	existing := make(map[string]string)
+
+	for i, pair := range urlPairs {
+		err, done := outcomes[pair.ShortID]
+		if !done {
+			err = s.storage.SaveBatch(ctx, []URLPair{pair})
+			if conflictErr, isConflict := IsBatchConflict(err); isConflict && s.conflictStrategy != ConflictError {
+				existing[pair.ShortID] = s.baseURLFor(ctx) + conflictErr.Existing[pair.ShortID]
+				err = nil
+			}
+			outcomes[pair.ShortID] = err
+		}
+		if shortURL, found := existing[pair.ShortID]; found {
+			responses[i].ShortURL = shortURL
+		}
 		if err != nil {
-			return nil, err
+			if !done {
+				logger.Error().
+					Err(err).
+					Str("short_id", pair.ShortID).
+					Msg("Failed to save URL from batch")
+			}
+			failed = append(failed, BatchFailure{
+				CorrelationID:        responses[i].CorrelationID,
+				CorrelationIDNumeric: responses[i].CorrelationIDNumeric,
+				Reason:               batchFailureReason(err),
+			})
+			continue
 		}
+		succeeded = append(succeeded, responses[i])
+	}
 
-		urlPairs[i] = URLPair{
-			ShortID:     shortID,
-			OriginalURL: req.OriginalURL,
-			UserID:      userID,
-		}
+	if len(succeeded) == 0 {
+		return nil, batchErr
+	}
 
-		responses[i] = BatchShortenResponse{
-			CorrelationID: req.CorrelationID,
-			ShortURL:      s.baseURL + shortID,
-		}
+	if len(failed) == 0 {
+		return succeeded, nil
 	}
 
-	// Сохраняем все URL одной операцией
-	if err := s.storage.SaveBatch(ctx, urlPairs); err != nil {
+	return succeeded, &ErrBatchPartialFailure{Failed: failed}
+}
+
+// GetUserURLs получает страницу URL пользователя: не более limit ссылок после первых offset
+// и общее количество ссылок пользователя. limit <= 0 - все ссылки начиная с offset.
+func (s *URLService) GetUserURLs(ctx context.Context, userID string, limit, offset int) ([]UserURL, int, error) {
+	urls, total, err := s.storage.GetUserURLs(ctx, userID, limit, offset)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	baseURL := s.baseURLFor(ctx)
+	for i := range urls {
+		urls[i].ShortURL = baseURL + urls[i].ShortID
+	}
+	return urls, total, nil
+}
+
+// RecentPublicURLs возвращает не более limit последних публичных ссылок, новые первыми
+func (s *URLService) RecentPublicURLs(ctx context.Context, limit int) ([]PublicURL, error) {
+	urls, err := s.storage.RecentPublicURLs(ctx, limit)
+	if err != nil {
 		return nil, err
 	}
 
-	return responses, nil
+	baseURL := s.baseURLFor(ctx)
+	for i := range urls {
+		urls[i].ShortURL = baseURL + urls[i].ShortID
+	}
+	return urls, nil
 }
 
-// GetUserURLs получает все URL пользователя
-func (s *URLService) GetUserURLs(ctx context.Context, userID string) ([]UserURL, error) {
-	return s.storage.GetUserURLs(ctx, userID)
+// GetUserURL возвращает URL, если он принадлежит пользователю.
+// Чужие и несуществующие URL одинаково возвращают ErrURLNotFound.
+func (s *URLService) GetUserURL(ctx context.Context, userID, shortID string) (UserURL, error) {
+	url, err := s.storage.GetUserURL(ctx, userID, shortID)
+	if err != nil {
+		return UserURL{}, err
+	}
+
+	url.ShortURL = s.baseURLFor(ctx) + shortID
+	return url, nil
 }
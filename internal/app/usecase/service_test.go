@@ -2,36 +2,52 @@ package usecase
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
+	"fmt"
 	"path"
+	"slices"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 const testBaseURL = "http://localhost:8080/"
 
 type MockURLStorage struct {
-	SaveFunc                func(shortID, url string) error
-	GetFunc                 func(shortID string) (string, error)
+	SaveFunc                func(ctx context.Context, url URLPair) error
+	GetFunc                 func(ctx context.Context, shortID string) (string, error)
 	SaveBatchFunc           func(ctx context.Context, urls []URLPair) error
-	GetUserURLsFunc         func(ctx context.Context, userID string) ([]UserURL, error)
+	GetUserURLsFunc         func(ctx context.Context, userID string, limit, offset int) ([]UserURL, int, error)
 	BatchDeleteUserURLsFunc func(ctx context.Context, userID string, shortIDs []string) error
+	DeleteAllUserURLsFunc   func(ctx context.Context, userID string) (int64, error)
+	DeleteWithResultFunc    func(ctx context.Context, userID string, shortIDs []string) (DeleteResult, error)
+	IncrementVisitsFunc     func(ctx context.Context, shortID string) error
+	PurgeExpiredFunc        func(ctx context.Context, before time.Time) (int64, error)
+	GetUserURLFunc          func(ctx context.Context, userID, shortID string) (UserURL, error)
+	ResetFunc               func(ctx context.Context) (int64, error)
+	GetURLOwnerFunc         func(ctx context.Context, shortID string) (URLOwner, error)
+	RecentPublicURLsFunc    func(ctx context.Context, limit int) ([]PublicURL, error)
+	StatsFunc               func(ctx context.Context) (int, int, error)
 	SaveBatchCallCount      int
 	LastSavedBatch          []URLPair
 }
 
-func (m *MockURLStorage) Save(shortID, url string) error {
+func (m *MockURLStorage) Save(ctx context.Context, url URLPair) error {
 	if m.SaveFunc != nil {
-		return m.SaveFunc(shortID, url)
+		return m.SaveFunc(ctx, url)
 	}
 	return nil
 }
 
-func (m *MockURLStorage) Get(shortID string) (string, error) {
+func (m *MockURLStorage) Get(ctx context.Context, shortID string) (string, error) {
 	if m.GetFunc != nil {
-		return m.GetFunc(shortID)
+		return m.GetFunc(ctx, shortID)
 	}
 	return "", errors.New("not implemented")
 }
@@ -45,11 +61,11 @@ func (m *MockURLStorage) SaveBatch(ctx context.Context, urls []URLPair) error {
 	return nil
 }
 
-func (m *MockURLStorage) GetUserURLs(ctx context.Context, userID string) ([]UserURL, error) {
+func (m *MockURLStorage) GetUserURLs(ctx context.Context, userID string, limit, offset int) ([]UserURL, int, error) {
 	if m.GetUserURLsFunc != nil {
-		return m.GetUserURLsFunc(ctx, userID)
+		return m.GetUserURLsFunc(ctx, userID, limit, offset)
 	}
-	return nil, nil
+	return nil, 0, nil
 }
 
 func (m *MockURLStorage) BatchDeleteUserURLs(ctx context.Context, userID string, shortIDs []string) error {
@@ -59,6 +75,69 @@ func (m *MockURLStorage) BatchDeleteUserURLs(ctx context.Context, userID string,
 	return nil
 }
 
+func (m *MockURLStorage) DeleteAllUserURLs(ctx context.Context, userID string) (int64, error) {
+	if m.DeleteAllUserURLsFunc != nil {
+		return m.DeleteAllUserURLsFunc(ctx, userID)
+	}
+	return 0, nil
+}
+
+func (m *MockURLStorage) DeleteUserURLsWithResult(ctx context.Context, userID string, shortIDs []string) (DeleteResult, error) {
+	if m.DeleteWithResultFunc != nil {
+		return m.DeleteWithResultFunc(ctx, userID, shortIDs)
+	}
+	return DeleteResult{}, nil
+}
+
+func (m *MockURLStorage) IncrementVisits(ctx context.Context, shortID string) error {
+	if m.IncrementVisitsFunc != nil {
+		return m.IncrementVisitsFunc(ctx, shortID)
+	}
+	return nil
+}
+
+func (m *MockURLStorage) GetUserURL(ctx context.Context, userID, shortID string) (UserURL, error) {
+	if m.GetUserURLFunc != nil {
+		return m.GetUserURLFunc(ctx, userID, shortID)
+	}
+	return UserURL{}, ErrURLNotFound
+}
+
+func (m *MockURLStorage) PurgeExpired(ctx context.Context, before time.Time) (int64, error) {
+	if m.PurgeExpiredFunc != nil {
+		return m.PurgeExpiredFunc(ctx, before)
+	}
+	return 0, nil
+}
+
+func (m *MockURLStorage) Reset(ctx context.Context) (int64, error) {
+	if m.ResetFunc != nil {
+		return m.ResetFunc(ctx)
+	}
+	return 0, nil
+}
+
+func (m *MockURLStorage) GetURLOwner(ctx context.Context, shortID string) (URLOwner, error) {
+	if m.GetURLOwnerFunc != nil {
+		return m.GetURLOwnerFunc(ctx, shortID)
+	}
+	return URLOwner{}, ErrURLNotFound
+}
+
+func (m *MockURLStorage) RecentPublicURLs(ctx context.Context, limit int) ([]PublicURL, error) {
+	if m.RecentPublicURLsFunc != nil {
+		return m.RecentPublicURLsFunc(ctx, limit)
+	}
+	return nil, nil
+}
+
+func (m *MockURLStorage) Stats(ctx context.Context) (int, int, error) {
+	if m.StatsFunc != nil {
+		return m.StatsFunc(ctx)
+	}
+	return 0, 0, nil
+}
+
 // MockDatabasePinger мок для DatabasePinger
 type MockDatabasePinger struct {
 	PingFunc  func() error
@@ -79,6 +158,43 @@ func (m *MockDatabasePinger) Close() error {
 	return nil
 }
 
+// mockIdempotencyStore мок для IdempotencyStore
+type mockIdempotencyStore struct {
+	mu      sync.Mutex
+	records map[string]IdempotencyRecord
+}
+
+func (m *mockIdempotencyStore) Reserve(ctx context.Context, key, fingerprint string) (IdempotencyRecord, bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if record, ok := m.records[key]; ok {
+		return record, false, nil
+	}
+	m.records[key] = IdempotencyRecord{Fingerprint: fingerprint}
+	return IdempotencyRecord{}, true, nil
+}
+
+func (m *mockIdempotencyStore) Complete(ctx context.Context, key, fingerprint, value string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.records[key] = IdempotencyRecord{Fingerprint: fingerprint, Value: value}
+	return nil
+}
+
+func (m *mockIdempotencyStore) Release(ctx context.Context, key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.records, key)
+	return nil
+}
+
+func (m *mockIdempotencyStore) Clear(ctx context.Context) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	clear(m.records)
+	return nil
+}
+
 func TestURLService_Shorten(t *testing.T) {
 	tests := []struct {
 		name             string
@@ -91,7 +207,7 @@ func TestURLService_Shorten(t *testing.T) {
 		{
 			name: "успешное сокращение URL",
 			storage: &MockURLStorage{
-				SaveFunc: func(shortID, url string) error {
+				SaveFunc: func(ctx context.Context, url URLPair) error {
 					return nil
 				},
 			},
@@ -101,7 +217,7 @@ func TestURLService_Shorten(t *testing.T) {
 		{
 			name: "ошибка сохранения",
 			storage: &MockURLStorage{
-				SaveFunc: func(shortID, url string) error {
+				SaveFunc: func(ctx context.Context, url URLPair) error {
 					return errors.New("storage error")
 				},
 			},
@@ -111,7 +227,7 @@ func TestURLService_Shorten(t *testing.T) {
 		{
 			name: "конфликт URL - URL уже существует",
 			storage: &MockURLStorage{
-				SaveFunc: func(shortID, url string) error {
+				SaveFunc: func(ctx context.Context, url URLPair) error {
 					return &ErrURLConflict{ExistingShortURL: "existing123"}
 				},
 			},
@@ -124,8 +240,8 @@ func TestURLService_Shorten(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			service := NewURLService(tt.storage, testBaseURL, nil)
-			got, err := service.Shorten(tt.url)
+			service := NewURLService(tt.storage, testBaseURL, nil, Options{})
+			got, err := service.Shorten(context.Background(), tt.url)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("URLService.Shorten() error = %v, wantErr %v", err, tt.wantErr)
 				return
@@ -138,7 +254,7 @@ func TestURLService_Shorten(t *testing.T) {
 			} else if !tt.wantErr {
 				assert.True(t, strings.HasPrefix(got, testBaseURL))
 				_, shortID := path.Split(got)
-				assert.Len(t, shortID, 8)
+				assert.Len(t, shortID, RandomShortIDLength)
 			}
 		})
 	}
@@ -155,7 +271,7 @@ func TestURLService_Expand(t *testing.T) {
 		{
 			name: "успешное получение оригинального URL",
 			storage: &MockURLStorage{
-				GetFunc: func(shortID string) (string, error) {
+				GetFunc: func(ctx context.Context, shortID string) (string, error) {
 					return "https://example.com", nil
 				},
 			},
@@ -166,7 +282,7 @@ func TestURLService_Expand(t *testing.T) {
 		{
 			name: "URL не найден",
 			storage: &MockURLStorage{
-				GetFunc: func(shortID string) (string, error) {
+				GetFunc: func(ctx context.Context, shortID string) (string, error) {
 					return "", errors.New("not found")
 				},
 			},
@@ -178,8 +294,8 @@ func TestURLService_Expand(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			service := NewURLService(tt.storage, testBaseURL, nil)
-			got, err := service.Expand(tt.shortID)
+			service := NewURLService(tt.storage, testBaseURL, nil, Options{})
+			got, err := service.Expand(context.Background(), tt.shortID)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("URLService.Expand() error = %v, wantErr %v", err, tt.wantErr)
 				return
@@ -191,6 +307,424 @@ func TestURLService_Expand(t *testing.T) {
 	}
 }
 
+func TestURLService_ExpandCountsVisits(t *testing.T) {
+	var visited []string
+	mockStorage := &MockURLStorage{
+		GetFunc: func(ctx context.Context, shortID string) (string, error) {
+			if shortID == "missing" {
+				return "", errors.New("not found")
+			}
+			return "https://example.com", nil
+		},
+		IncrementVisitsFunc: func(ctx context.Context, shortID string) error {
+			visited = append(visited, shortID)
+			return nil
+		},
+	}
+	service := NewURLService(mockStorage, testBaseURL, nil, Options{})
+
+	_, err := service.Expand(context.Background(), "abc123")
+	assert.NoError(t, err)
+	_, err = service.Expand(context.Background(), "missing")
+	assert.Error(t, err)
+
+	// Проверка ссылки без перехода не учитывается
+	_, err = service.Expand(WithoutVisitCount(context.Background()), "abc123")
+	assert.NoError(t, err)
+
+	assert.Equal(t, []string{"abc123"}, visited)
+}
+
+func TestURLService_DisallowedScheme(t *testing.T) {
+	mockStorage := &MockURLStorage{
+		SaveFunc: func(ctx context.Context, url URLPair) error {
+			return nil
+		},
+		SaveBatchFunc: func(ctx context.Context, urls []URLPair) error {
+			return nil
+		},
+		GetFunc: func(ctx context.Context, shortID string) (string, error) {
+			return "javascript:alert(1)", nil
+		},
+	}
+
+	tests := []struct {
+		name    string
+		schemes []string
+		url     string
+		wantErr bool
+	}{
+		{name: "http allowed by default", url: "http://example.com"},
+		{name: "https allowed by default", url: "HTTPS://example.com"},
+		{name: "without scheme", url: "example.com"},
+		{name: "javascript rejected", url: "javascript:alert(1)", wantErr: true},
+		{name: "data rejected", url: "data:text/html,<script>alert(1)</script>", wantErr: true},
+		{name: "custom list", schemes: []string{" ftp "}, url: "ftp://example.com"},
+		{name: "https rejected by custom list", schemes: []string{"ftp"}, url: "https://example.com", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service := NewURLService(mockStorage, testBaseURL, nil, Options{AllowedSchemes: tt.schemes})
+
+			_, err := service.ShortenWithUser(context.Background(), tt.url, "user1", ShortenOptions{})
+			assert.Equal(t, tt.wantErr, IsDisallowedScheme(err), "err = %v", err)
+
+			_, err = service.ShortenBatch(context.Background(), []BatchShortenRequest{
+				{CorrelationID: "1", OriginalURL: tt.url},
+			})
+			assert.Equal(t, tt.wantErr, IsDisallowedScheme(err), "err = %v", err)
+		})
+	}
+
+	t.Run("redirect to stored disallowed URL", func(t *testing.T) {
+		service := NewURLService(mockStorage, testBaseURL, nil, Options{})
+
+		_, err := service.Expand(context.Background(), "abc123")
+		assert.True(t, IsDisallowedScheme(err))
+	})
+}
+
+// mockBlocklist блокирует хосты из заданного множества
+type mockBlocklist map[string]bool
+
+func (m mockBlocklist) IsBlocked(ctx context.Context, host string) (bool, error) {
+	return m[host], nil
+}
+
+func TestURLService_Blocklist(t *testing.T) {
+	var saved []string
+	mockStorage := &MockURLStorage{
+		SaveFunc: func(ctx context.Context, url URLPair) error {
+			saved = append(saved, url.OriginalURL)
+			return nil
+		},
+		SaveBatchFunc: func(ctx context.Context, urls []URLPair) error {
+			return nil
+		},
+	}
+	service := NewURLService(mockStorage, testBaseURL, nil, Options{
+		Blocklist: mockBlocklist{"evil.com": true},
+	})
+
+	_, err := service.Shorten(context.Background(), "https://evil.com/login")
+	assert.True(t, IsBlockedURL(err))
+
+	_, err = service.Shorten(context.Background(), "evil.com/path")
+	assert.True(t, IsBlockedURL(err))
+
+	_, err = service.ShortenBatch(context.Background(), []BatchShortenRequest{
+		{CorrelationID: "1", OriginalURL: "https://example.com"},
+		{CorrelationID: "2", OriginalURL: "https://evil.com"},
+	})
+	assert.True(t, IsBlockedURL(err))
+
+	_, err = service.Shorten(context.Background(), "https://example.com")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"https://example.com"}, saved)
+}
+
+func TestURLService_StripFragments(t *testing.T) {
+	tests := []struct {
+		name  string
+		strip bool
+		url   string
+		want  string
+	}{
+		{name: "fragment kept by default", url: "https://a.com/page#x", want: "https://a.com/page#x"},
+		{name: "fragment stripped", strip: true, url: "https://a.com/page?q=1#x", want: "https://a.com/page?q=1"},
+		{name: "without fragment", strip: true, url: "https://a.com/page", want: "https://a.com/page"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var saved []string
+			mockStorage := &MockURLStorage{
+				SaveFunc: func(ctx context.Context, url URLPair) error {
+					saved = append(saved, url.OriginalURL)
+					return nil
+				},
+				SaveBatchFunc: func(ctx context.Context, urls []URLPair) error {
+					for _, pair := range urls {
+						saved = append(saved, pair.OriginalURL)
+					}
+					return nil
+				},
+			}
+			service := NewURLService(mockStorage, testBaseURL, nil, Options{StripFragments: tt.strip})
+
+			_, err := service.Shorten(context.Background(), tt.url)
+			assert.NoError(t, err)
+
+			requests := []BatchShortenRequest{{CorrelationID: "1", OriginalURL: tt.url}}
+			_, err = service.ShortenBatch(context.Background(), requests)
+			assert.NoError(t, err)
+
+			assert.Equal(t, []string{tt.want, tt.want}, saved)
+			assert.Equal(t, tt.url, requests[0].OriginalURL, "batch request must not be modified")
+		})
+	}
+}
+
+func TestURLService_ExpandAddsDefaultScheme(t *testing.T) {
+	tests := []struct {
+		name          string
+		stored        string
+		defaultScheme string
+		want          string
+	}{
+		{name: "with scheme", stored: "http://example.com", want: "http://example.com"},
+		{name: "without scheme", stored: "example.com/path?q=1", want: "https://example.com/path?q=1"},
+		{name: "protocol relative", stored: "//example.com", want: "https://example.com"},
+		{name: "custom default", stored: "example.com", defaultScheme: "http", want: "http://example.com"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockStorage := &MockURLStorage{
+				GetFunc: func(ctx context.Context, shortID string) (string, error) {
+					return tt.stored, nil
+				},
+			}
+			service := NewURLService(mockStorage, testBaseURL, nil, Options{DefaultScheme: tt.defaultScheme})
+
+			got, err := service.Expand(context.Background(), "abc123")
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestURLService_ShortenWithOptions(t *testing.T) {
+	var saved []URLPair
+	mockStorage := &MockURLStorage{
+		SaveFunc: func(ctx context.Context, url URLPair) error {
+			saved = append(saved, url)
+			return nil
+		},
+	}
+	service := NewURLService(mockStorage, testBaseURL, nil, Options{})
+
+	expiresAt := time.Now().Add(time.Hour)
+
+	// Владелец, срок действия и признак публичности сохраняются одной записью вместе со ссылкой
+	shortURL, err := service.ShortenWithUser(context.Background(), "https://example.com", "user1", ShortenOptions{ExpiresAt: expiresAt, Public: true})
+	require.NoError(t, err)
+	require.Len(t, saved, 1)
+	assert.Equal(t, URLPair{
+		ShortID:     strings.TrimPrefix(shortURL, testBaseURL),
+		OriginalURL: "https://example.com",
+		UserID:      "user1",
+		ExpiresAt:   expiresAt,
+		Public:      true,
+	}, saved[0])
+	assert.Zero(t, mockStorage.SaveBatchCallCount)
+}
+
+func TestURLService_ShortenWithOptionsSaveError(t *testing.T) {
+	saveErr := errors.New("database is unavailable")
+	mockStorage := &MockURLStorage{
+		SaveFunc: func(ctx context.Context, url URLPair) error {
+			return saveErr
+		},
+	}
+	service := NewURLService(mockStorage, testBaseURL, nil, Options{})
+
+	// Ссылка не сохраняется без срока действия: при ошибке записи нет ни ссылки, ни короткого URL
+	shortURL, err := service.ShortenWithUser(context.Background(), "https://example.com", "", ShortenOptions{ExpiresAt: time.Now().Add(time.Hour)})
+	assert.ErrorIs(t, err, saveErr)
+	assert.Empty(t, shortURL)
+	assert.Zero(t, mockStorage.SaveBatchCallCount)
+}
+
+func TestURLService_PurgeExpired(t *testing.T) {
+	var gotBefore time.Time
+	mockStorage := &MockURLStorage{
+		PurgeExpiredFunc: func(ctx context.Context, before time.Time) (int64, error) {
+			gotBefore = before
+			return 3, nil
+		},
+	}
+	service := NewURLService(mockStorage, testBaseURL, nil, Options{GCGracePeriod: time.Hour})
+	defer service.Close()
+
+	purged, err := service.PurgeExpired(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, int64(3), purged)
+	assert.WithinDuration(t, time.Now().Add(-time.Hour), gotBefore, time.Second)
+}
+
+// fakeClock - управляемые часы: время идет только при вызове Advance
+type fakeClock struct {
+	mu      sync.Mutex
+	now     time.Time
+	waiters []fakeWaiter
+}
+
+// fakeWaiter - канал After, ожидающий момента deadline
+type fakeWaiter struct {
+	deadline time.Time
+	ch       chan time.Time
+}
+
+func newFakeClock(now time.Time) *fakeClock {
+	return &fakeClock{now: now}
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) After(d time.Duration) <-chan time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	ch := make(chan time.Time, 1)
+	c.waiters = append(c.waiters, fakeWaiter{deadline: c.now.Add(d), ch: ch})
+	return ch
+}
+
+// Waiters возвращает количество ожидающих каналов After
+func (c *fakeClock) Waiters() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.waiters)
+}
+
+// Advance сдвигает время на d и срабатывает каналы, срок которых наступил
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+
+	pending := c.waiters[:0]
+	for _, w := range c.waiters {
+		if w.deadline.After(c.now) {
+			pending = append(pending, w)
+			continue
+		}
+		w.ch <- c.now
+	}
+	c.waiters = pending
+}
+
+func TestURLService_PurgeExpiredSweeper(t *testing.T) {
+	start := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	clock := newFakeClock(start)
+
+	var mu sync.Mutex
+	var calls []time.Time
+	mockStorage := &MockURLStorage{
+		PurgeExpiredFunc: func(ctx context.Context, before time.Time) (int64, error) {
+			mu.Lock()
+			defer mu.Unlock()
+			calls = append(calls, before)
+			return 1, nil
+		},
+	}
+	purgeCalls := func() []time.Time {
+		mu.Lock()
+		defer mu.Unlock()
+		return slices.Clone(calls)
+	}
+
+	service := NewURLService(mockStorage, testBaseURL, nil, Options{
+		GCInterval:    time.Minute,
+		GCGracePeriod: time.Hour,
+		Clock:         clock,
+	})
+
+	// До истечения интервала очистка не запускается
+	require.Eventually(t, func() bool { return clock.Waiters() == 1 }, time.Second, time.Millisecond)
+	clock.Advance(30 * time.Second)
+	assert.Empty(t, purgeCalls())
+
+	clock.Advance(30 * time.Second)
+	require.Eventually(t, func() bool { return len(purgeCalls()) == 1 }, time.Second, time.Millisecond)
+	assert.Equal(t, start.Add(time.Minute-time.Hour), purgeCalls()[0])
+
+	// После очистки ожидается следующий интервал
+	require.Eventually(t, func() bool { return clock.Waiters() == 1 }, time.Second, time.Millisecond)
+	clock.Advance(time.Minute)
+	require.Eventually(t, func() bool { return len(purgeCalls()) == 2 }, time.Second, time.Millisecond)
+	assert.Equal(t, start.Add(2*time.Minute-time.Hour), purgeCalls()[1])
+
+	// После Close очистка больше не запускается
+	service.Close()
+	clock.Advance(time.Hour)
+	assert.Len(t, purgeCalls(), 2)
+}
+
+func TestURLService_ExpandExpired(t *testing.T) {
+	mockStorage := &MockURLStorage{
+		GetFunc: func(ctx context.Context, shortID string) (string, error) {
+			return "", &ErrURLExpired{}
+		},
+		IncrementVisitsFunc: func(ctx context.Context, shortID string) error {
+			t.Error("переход по истекшей ссылке не должен учитываться")
+			return nil
+		},
+	}
+	service := NewURLService(mockStorage, testBaseURL, nil, Options{})
+	defer service.Close()
+
+	_, err := service.Expand(context.Background(), "abc123")
+	assert.True(t, IsURLExpired(err))
+}
+
+func TestURLService_ConflictStrategy(t *testing.T) {
+	mockStorage := &MockURLStorage{
+		SaveFunc: func(ctx context.Context, url URLPair) error {
+			return &ErrURLConflict{ExistingShortURL: "existing1"}
+		},
+	}
+
+	t.Run("existing", func(t *testing.T) {
+		service := NewURLService(mockStorage, testBaseURL, nil, Options{})
+
+		shortURL, err := service.Shorten(context.Background(), "https://example.com")
+		conflictErr, isConflict := IsURLConflict(err)
+		assert.True(t, isConflict)
+		assert.Equal(t, testBaseURL+"existing1", shortURL)
+		assert.Equal(t, testBaseURL+"existing1", conflictErr.ExistingShortURL)
+	})
+
+	t.Run("error", func(t *testing.T) {
+		service := NewURLService(mockStorage, testBaseURL, nil, Options{ConflictStrategy: ConflictError})
+
+		shortURL, err := service.Shorten(context.Background(), "https://example.com")
+		assert.ErrorIs(t, err, ErrURLExists)
+		assert.Empty(t, shortURL)
+	})
+}
+
+func TestParseConflictStrategy(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    ConflictStrategy
+		wantErr bool
+	}{
+		{value: "", want: ConflictReturnExisting},
+		{value: "existing", want: ConflictReturnExisting},
+		{value: "new", want: ConflictCreateNew},
+		{value: "error", want: ConflictError},
+		{value: "ignore", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			got, err := ParseConflictStrategy(tt.value)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
 func Test_generateShortID(t *testing.T) {
 	tests := []struct {
 		name        string
@@ -200,13 +734,31 @@ func Test_generateShortID(t *testing.T) {
 	}{
 		{
 			name:        "генерация короткого ID",
-			wantLen:     8,
+			wantLen:     RandomShortIDLength,
 			wantErr:     false,
 			checkUnique: false,
 		},
 		{
 			name:        "уникальность генерации",
-			wantLen:     8,
+			wantLen:     RandomShortIDLength,
+			wantErr:     false,
+			checkUnique: true,
+		},
+		{
+			name:        "минимальная длина",
+			wantLen:     4,
+			wantErr:     false,
+			checkUnique: false,
+		},
+		{
+			name:        "длина не кратна 4",
+			wantLen:     13,
+			wantErr:     false,
+			checkUnique: false,
+		},
+		{
+			name:        "максимальная длина",
+			wantLen:     32,
 			wantErr:     false,
 			checkUnique: true,
 		},
@@ -214,7 +766,7 @@ func Test_generateShortID(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got, err := generateShortID()
+			got, err := generateShortID(tt.wantLen)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("generateShortID() error = %v, wantErr %v", err, tt.wantErr)
 				return
@@ -224,7 +776,7 @@ func Test_generateShortID(t *testing.T) {
 			assert.NotEmpty(t, got)
 
 			if tt.checkUnique {
-				got2, err2 := generateShortID()
+				got2, err2 := generateShortID(tt.wantLen)
 				assert.NoError(t, err2)
 				assert.NotEqual(t, got, got2)
 			}
@@ -232,6 +784,172 @@ func Test_generateShortID(t *testing.T) {
 	}
 }
 
+// mockIDAllocator выдает диапазоны подряд, начиная с start
+type mockIDAllocator struct {
+	start, size int64
+	calls       int
+}
+
+func (m *mockIDAllocator) AllocateIDRange(ctx context.Context) (int64, int64, error) {
+	start := m.start + int64(m.calls)*m.size
+	m.calls++
+	return start, m.size, nil
+}
+
+func TestSequentialIDGenerator(t *testing.T) {
+	allocator := &mockIDAllocator{start: 60, size: 3}
+	generator := NewSequentialIDGenerator(allocator)
+
+	var ids []string
+	for i := 0; i < 5; i++ {
+		id, err := generator.NextID(context.Background())
+		assert.NoError(t, err)
+		ids = append(ids, id)
+	}
+
+	// 60..62 из первого диапазона, 63..64 из второго
+	assert.Equal(t, []string{"Y", "Z", "10", "11", "12"}, ids)
+	assert.Equal(t, 2, allocator.calls)
+}
+
+func TestURLService_SequentialIDs(t *testing.T) {
+	storage := &MockURLStorage{
+		SaveFunc: func(ctx context.Context, url URLPair) error {
+			return nil
+		},
+	}
+	service := NewURLService(storage, testBaseURL, nil, Options{
+		IDGenerator: NewSequentialIDGenerator(&mockIDAllocator{start: 1, size: 1000}),
+	})
+	defer service.Close()
+
+	shortURL, err := service.Shorten(context.Background(), "https://example.com")
+	assert.NoError(t, err)
+	assert.Equal(t, testBaseURL+"1", shortURL)
+}
+
+func TestURLService_ShortIDCollisionRetry(t *testing.T) {
+	tests := []struct {
+		name          string
+		collisions    int
+		attempts      int
+		expectedErr   error
+		expectedSaves int
+	}{
+		{name: "две коллизии, затем успех", collisions: 2, expectedSaves: 3},
+		{name: "коллизии на всех попытках", collisions: 10, attempts: 3, expectedErr: ErrShortIDCollision, expectedSaves: 3},
+		{name: "без коллизий", expectedSaves: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var savedIDs []string
+			storage := &MockURLStorage{
+				SaveFunc: func(ctx context.Context, url URLPair) error {
+					savedIDs = append(savedIDs, url.ShortID)
+					if len(savedIDs) <= tt.collisions {
+						return ErrShortIDCollision
+					}
+					return nil
+				},
+			}
+			service := NewURLService(storage, testBaseURL, nil, Options{
+				IDGenerator:     NewSequentialIDGenerator(&mockIDAllocator{start: 1, size: 1000}),
+				ShortIDAttempts: tt.attempts,
+			})
+			defer service.Close()
+
+			shortURL, err := service.Shorten(context.Background(), "https://example.com")
+			assert.Len(t, savedIDs, tt.expectedSaves)
+			assert.Equal(t, ShortIDStats{
+				Attempts:   int64(tt.expectedSaves),
+				Collisions: int64(min(tt.collisions, tt.expectedSaves)),
+			}, service.ShortIDStats())
+			if tt.expectedErr != nil {
+				assert.ErrorIs(t, err, tt.expectedErr)
+				assert.Empty(t, shortURL)
+				return
+			}
+			require.NoError(t, err)
+			// Каждая попытка использует новый ID, в ответе - последний сохраненный
+			assert.Equal(t, testBaseURL+savedIDs[len(savedIDs)-1], shortURL)
+			assert.Len(t, slices.Compact(slices.Clone(savedIDs)), len(savedIDs))
+		})
+	}
+}
+
+func TestShortIDCollisionProbability(t *testing.T) {
+	assert.Equal(t, 0.0, ShortIDCollisionProbability(0, RandomShortIDLength))
+	assert.Equal(t, 0.0, ShortIDCollisionProbability(1, RandomShortIDLength))
+
+	// Пространство 2^48: при миллионе ссылок вероятность около 0.18%
+	assert.InDelta(t, 0.00178, ShortIDCollisionProbability(1_000_000, RandomShortIDLength), 0.0001)
+
+	// При 2^24 (~16.7 млн) ссылок вероятность 1 - e^(-1/2)
+	assert.InDelta(t, 0.3935, ShortIDCollisionProbability(1<<24, RandomShortIDLength), 0.0001)
+
+	// Пространство 2^24 для 4 символов: 2^12 ссылок дают ту же вероятность
+	assert.InDelta(t, 0.3935, ShortIDCollisionProbability(1<<12, 4), 0.0001)
+}
+
+func TestURLService_ShortIDLength(t *testing.T) {
+	for _, length := range []int{4, RandomShortIDLength, 32} {
+		service := NewURLService(&MockURLStorage{}, testBaseURL, nil, Options{ShortIDLength: length})
+
+		shortURL, err := service.Shorten(context.Background(), "https://example.com")
+		assert.NoError(t, err)
+		assert.Len(t, strings.TrimPrefix(shortURL, testBaseURL), length)
+
+		service.Close()
+	}
+}
+
+// mockWriteChecker мок для WriteChecker
+type mockWriteChecker struct {
+	err   error
+	calls int
+}
+
+func (m *mockWriteChecker) CheckWritable(ctx context.Context) error {
+	m.calls++
+	return m.err
+}
+
+func TestURLService_CheckReady(t *testing.T) {
+	pingErr := errors.New("ping failed")
+	writeErr := errors.New("read-only")
+
+	tests := []struct {
+		name       string
+		pingErr    error
+		checker    *mockWriteChecker
+		wantErr    error
+		wantChecks int
+	}{
+		{name: "без проверки записи", wantErr: nil},
+		{name: "запись доступна", checker: &mockWriteChecker{}, wantChecks: 1},
+		{name: "БД только для чтения", checker: &mockWriteChecker{err: writeErr}, wantErr: writeErr, wantChecks: 1},
+		{name: "ping не проходит", pingErr: pingErr, checker: &mockWriteChecker{}, wantErr: pingErr},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := Options{}
+			if tt.checker != nil {
+				opts.WriteChecker = tt.checker
+			}
+			pinger := &MockDatabasePinger{PingFunc: func() error { return tt.pingErr }}
+			service := NewURLService(&MockURLStorage{}, testBaseURL, pinger, opts)
+			defer service.Close()
+
+			assert.ErrorIs(t, service.CheckReady(context.Background()), tt.wantErr)
+			if tt.checker != nil {
+				assert.Equal(t, tt.wantChecks, tt.checker.calls)
+			}
+		})
+	}
+}
+
 func TestURLService_PingDB(t *testing.T) {
 	tests := []struct {
 		name     string
@@ -265,7 +983,7 @@ func TestURLService_PingDB(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			storage := &MockURLStorage{}
-			service := NewURLService(storage, testBaseURL, tt.dbPinger)
+			service := NewURLService(storage, testBaseURL, tt.dbPinger, Options{})
 
 			if err := service.PingDB(); (err != nil) != tt.wantErr {
 				t.Errorf("URLService.PingDB() error = %v, wantErr %v", err, tt.wantErr)
@@ -274,6 +992,45 @@ func TestURLService_PingDB(t *testing.T) {
 	}
 }
 
+func TestURLService_ShortenBatchDuplicateURLs(t *testing.T) {
+	requests := []BatchShortenRequest{
+		{CorrelationID: "a", OriginalURL: "https://example.com"},
+		{CorrelationID: "b", OriginalURL: "https://google.com"},
+		{CorrelationID: "c", OriginalURL: "https://example.com"},
+	}
+
+	tests := []struct {
+		name       string
+		strategy   ConflictStrategy
+		expectSame bool
+	}{
+		{name: "повторы получают общий короткий URL", expectSame: true},
+		{name: "ConflictCreateNew создает отдельные ссылки", strategy: ConflictCreateNew},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service := NewURLService(&MockURLStorage{}, testBaseURL, nil, Options{ConflictStrategy: tt.strategy})
+			defer service.Close()
+
+			responses, err := service.ShortenBatchWithUser(context.Background(), requests, "user1")
+			require.NoError(t, err)
+			require.Len(t, responses, len(requests))
+
+			byCorrelationID := make(map[string]string, len(responses))
+			for _, response := range responses {
+				byCorrelationID[response.CorrelationID] = response.ShortURL
+			}
+			assert.NotEqual(t, byCorrelationID["a"], byCorrelationID["b"])
+			if tt.expectSame {
+				assert.Equal(t, byCorrelationID["a"], byCorrelationID["c"])
+			} else {
+				assert.NotEqual(t, byCorrelationID["a"], byCorrelationID["c"])
+			}
+		})
+	}
+}
+
 func TestURLService_ShortenBatch(t *testing.T) {
 	tests := []struct {
 		name                string
@@ -297,6 +1054,20 @@ func TestURLService_ShortenBatch(t *testing.T) {
 			expectedCallCount:   1,
 			expectedBatchLength: 3,
 		},
+		{
+			name: "повторы URL сохраняются один раз",
+			requests: []BatchShortenRequest{
+				{CorrelationID: "1", OriginalURL: "https://example.com"},
+				{CorrelationID: "2", OriginalURL: "https://google.com"},
+				{CorrelationID: "3", OriginalURL: "https://example.com"},
+			},
+			mockSaveBatchFunc: func(ctx context.Context, urls []URLPair) error {
+				return nil
+			},
+			wantErr:             false,
+			expectedCallCount:   1,
+			expectedBatchLength: 2,
+		},
 		{
 			name:                "пустой batch запрос",
 			requests:            []BatchShortenRequest{},
@@ -324,7 +1095,7 @@ func TestURLService_ShortenBatch(t *testing.T) {
 			mockStorage := &MockURLStorage{
 				SaveBatchFunc: tt.mockSaveBatchFunc,
 			}
-			service := NewURLService(mockStorage, "http://localhost:8080/", nil)
+			service := NewURLService(mockStorage, "http://localhost:8080/", nil, Options{})
 
 			responses, err := service.ShortenBatch(context.Background(), tt.requests)
 
@@ -353,6 +1124,462 @@ func TestURLService_ShortenBatch(t *testing.T) {
 	}
 }
 
+func TestURLService_ShortenBatchCorrelationIDForm(t *testing.T) {
+	tests := []struct {
+		name              string
+		body              string
+		wantCorrelationID string
+		wantResponse      string
+		wantErr           bool
+	}{
+		{
+			name:              "числовой correlation_id возвращается числом",
+			body:              `{"correlation_id": 1, "original_url": "https://example.com"}`,
+			wantCorrelationID: "1",
+			wantResponse:      `1`,
+		},
+		{
+			name:              "строковый correlation_id возвращается строкой",
+			body:              `{"correlation_id": "1", "original_url": "https://example.com"}`,
+			wantCorrelationID: "1",
+			wantResponse:      `"1"`,
+		},
+		{
+			name:              "отсутствующий correlation_id возвращается пустой строкой",
+			body:              `{"original_url": "https://example.com"}`,
+			wantCorrelationID: "",
+			wantResponse:      `""`,
+		},
+		{
+			name:              "дробное число сохраняет исходную запись",
+			body:              `{"correlation_id": 1.50, "original_url": "https://example.com"}`,
+			wantCorrelationID: "1.50",
+			wantResponse:      `1.50`,
+		},
+		{
+			name:    "correlation_id другого типа отклоняется",
+			body:    `{"correlation_id": true, "original_url": "https://example.com"}`,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var req BatchShortenRequest
+			err := json.Unmarshal([]byte(tt.body), &req)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantCorrelationID, req.CorrelationID)
+			assert.Equal(t, "https://example.com", req.OriginalURL)
+
+			service := NewURLService(&MockURLStorage{}, testBaseURL, nil, Options{})
+			responses, err := service.ShortenBatch(context.Background(), []BatchShortenRequest{req})
+			require.NoError(t, err)
+			require.Len(t, responses, 1)
+
+			encoded, err := json.Marshal(responses[0])
+			require.NoError(t, err)
+			var fields map[string]json.RawMessage
+			require.NoError(t, json.Unmarshal(encoded, &fields))
+			assert.Equal(t, tt.wantResponse, string(fields["correlation_id"]))
+			assert.JSONEq(t, `"`+responses[0].ShortURL+`"`, string(fields["short_url"]))
+
+			failure, err := json.Marshal(BatchFailure{
+				CorrelationID:        req.CorrelationID,
+				CorrelationIDNumeric: req.CorrelationIDNumeric,
+				Reason:               "failed",
+			})
+			require.NoError(t, err)
+			assert.JSONEq(t, `{"correlation_id": `+tt.wantResponse+`, "reason": "failed"}`, string(failure))
+		})
+	}
+}
+
+func TestURLService_Reset(t *testing.T) {
+	mockStorage := &MockURLStorage{
+		ResetFunc: func(ctx context.Context) (int64, error) {
+			return 4, nil
+		},
+	}
+	store := &mockIdempotencyStore{records: map[string]IdempotencyRecord{
+		"user1:key": {Fingerprint: "https://example.com", Value: testBaseURL + "abc123"},
+	}}
+	service := NewURLService(mockStorage, testBaseURL, nil, Options{IdempotencyStore: store})
+	defer service.Close()
+
+	removed, err := service.Reset(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, int64(4), removed)
+	assert.Empty(t, store.records, "ключи идемпотентности сбрасываются вместе со ссылками")
+}
+
+func TestURLService_DeleteStats(t *testing.T) {
+	storage := &MockURLStorage{
+		BatchDeleteUserURLsFunc: func(ctx context.Context, userID string, shortIDs []string) error {
+			if userID == "broken" {
+				return errors.New("db error")
+			}
+			return nil
+		},
+	}
+	service := NewURLService(storage, testBaseURL, nil, Options{})
+
+	assert.NoError(t, service.DeleteUserURLs("user1", []string{"abc123"}))
+	assert.NoError(t, service.DeleteUserURLs("user1", []string{"def456"}))
+	assert.NoError(t, service.DeleteUserURLs("broken", []string{"ghi789"}))
+	assert.NoError(t, service.DeleteUserURLs("user1", nil), "пустой запрос не ставится в очередь")
+
+	// Close дожидается обработки всей очереди
+	service.Close()
+
+	stats := service.DeleteStats()
+	assert.Equal(t, DeleteStats{Enqueued: 3, Processed: 3, Failed: 1, DeadLettered: 1}, stats)
+	assert.Zero(t, stats.Unprocessed())
+}
+
+// mockDeadLetterQueue запоминает запросы, переданные в dead-letter
+type mockDeadLetterQueue struct {
+	mu       sync.Mutex
+	requests []DeleteRequest
+}
+
+func (m *mockDeadLetterQueue) Put(ctx context.Context, req DeleteRequest, cause error) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.requests = append(m.requests, req)
+	return nil
+}
+
+func TestURLService_RuntimeStats(t *testing.T) {
+	service := NewURLService(&MockURLStorage{}, testBaseURL, nil, Options{})
+
+	assert.Eventually(t, func() bool {
+		return service.RuntimeStats().DeleteWorkers == 3
+	}, time.Second, 10*time.Millisecond)
+
+	stats := service.RuntimeStats()
+	assert.Positive(t, stats.Goroutines)
+	assert.Positive(t, stats.AllocBytes)
+	assert.Positive(t, stats.SysBytes)
+
+	service.Close()
+	assert.Zero(t, service.RuntimeStats().DeleteWorkers)
+}
+
+func TestURLService_GetStats(t *testing.T) {
+	storage := &MockURLStorage{
+		StatsFunc: func(ctx context.Context) (int, int, error) {
+			return 15, 4, nil
+		},
+	}
+	service := NewURLService(storage, testBaseURL, nil, Options{})
+	defer service.Close()
+
+	stats, err := service.GetStats(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, Stats{URLs: 15, Users: 4}, stats)
+
+	storageErr := errors.New("db down")
+	storage.StatsFunc = func(ctx context.Context) (int, int, error) {
+		return 0, 0, storageErr
+	}
+	_, err = service.GetStats(context.Background())
+	assert.ErrorIs(t, err, storageErr)
+}
+
+func TestURLService_DeleteRetry(t *testing.T) {
+	tests := []struct {
+		name             string
+		failures         int // сколько первых попыток для пользователя завершаются ошибкой
+		wantAttempts     int
+		wantDeadLettered []DeleteRequest
+	}{
+		{name: "succeeds after retries", failures: 2, wantAttempts: 3},
+		{
+			name:             "dead-lettered after all retries",
+			failures:         10,
+			wantAttempts:     4,
+			wantDeadLettered: []DeleteRequest{{UserID: "user1", ShortIDs: []string{"abc123"}}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var attempts atomic.Int32
+			storage := &MockURLStorage{
+				BatchDeleteUserURLsFunc: func(ctx context.Context, userID string, shortIDs []string) error {
+					if int(attempts.Add(1)) <= tt.failures {
+						return errors.New("db error")
+					}
+					return nil
+				},
+			}
+			deadLetters := &mockDeadLetterQueue{}
+			service := NewURLService(storage, testBaseURL, nil, Options{
+				DeleteRetries:      3,
+				DeleteRetryBackoff: time.Millisecond,
+				DeadLetters:        deadLetters,
+			})
+
+			assert.NoError(t, service.DeleteUserURLs("user1", []string{"abc123"}))
+			service.Close()
+
+			assert.Equal(t, tt.wantAttempts, int(attempts.Load()))
+			assert.Equal(t, tt.wantDeadLettered, deadLetters.requests)
+			assert.Equal(t, int64(len(tt.wantDeadLettered)), service.DeleteStats().DeadLettered)
+		})
+	}
+}
+
+func TestURLService_CheckLiveness(t *testing.T) {
+	release := make(chan struct{})
+	storage := &MockURLStorage{
+		BatchDeleteUserURLsFunc: func(ctx context.Context, userID string, shortIDs []string) error {
+			// Имитируем зависший вызов БД
+			<-release
+			return nil
+		},
+	}
+	service := NewURLService(storage, testBaseURL, nil, Options{WorkerStallThreshold: 50 * time.Millisecond})
+	defer service.Close()
+	defer close(release)
+
+	assert.NoError(t, service.CheckLiveness(), "пустая очередь не считается зависанием")
+
+	assert.NoError(t, service.DeleteUserURLs("user1", []string{"abc123"}))
+	assert.NoError(t, service.CheckLiveness(), "порог простоя еще не превышен")
+
+	assert.Eventually(t, func() bool {
+		return errors.Is(service.CheckLiveness(), ErrDeleteWorkersStalled)
+	}, time.Second, 10*time.Millisecond)
+}
+
+func TestURLService_BaseURLFromContext(t *testing.T) {
+	storage := &MockURLStorage{
+		SaveFunc: func(ctx context.Context, url URLPair) error {
+			return nil
+		},
+		SaveBatchFunc: func(ctx context.Context, urls []URLPair) error {
+			return nil
+		},
+		GetUserURLsFunc: func(ctx context.Context, userID string, limit, offset int) ([]UserURL, int, error) {
+			return []UserURL{{ShortID: "abc123", OriginalURL: "https://example.com"}}, 1, nil
+		},
+	}
+	service := NewURLService(storage, testBaseURL, nil, Options{})
+	ctx := WithBaseURL(context.Background(), "https://sho.rt")
+
+	shortURL, err := service.ShortenWithUser(ctx, "https://example.com", "user1", ShortenOptions{})
+	assert.NoError(t, err)
+	assert.True(t, strings.HasPrefix(shortURL, "https://sho.rt/"))
+
+	batch, err := service.ShortenBatch(ctx, []BatchShortenRequest{{CorrelationID: "1", OriginalURL: "https://example.com"}})
+	assert.NoError(t, err)
+	assert.True(t, strings.HasPrefix(batch[0].ShortURL, "https://sho.rt/"))
+
+	urls, _, err := service.GetUserURLs(ctx, "user1", 0, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, "https://sho.rt/abc123", urls[0].ShortURL)
+
+	// Без базового адреса в контексте используется настроенный BaseURL
+	urls, _, err = service.GetUserURLs(context.Background(), "user1", 0, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, testBaseURL+"abc123", urls[0].ShortURL)
+}
+
+func TestURLService_ShortenBatchConflict(t *testing.T) {
+	// Хранилище сообщает, что https://existing.com уже сохранен как existing1
+	newStorage := func() *MockURLStorage {
+		return &MockURLStorage{
+			SaveBatchFunc: func(ctx context.Context, urls []URLPair) error {
+				for _, url := range urls {
+					if url.OriginalURL == "https://existing.com" {
+						return &ErrBatchConflict{Existing: map[string]string{url.ShortID: "existing1"}}
+					}
+				}
+				return nil
+			},
+		}
+	}
+
+	requests := []BatchShortenRequest{
+		{CorrelationID: "1", OriginalURL: "https://example.com"},
+		{CorrelationID: "2", OriginalURL: "https://existing.com"},
+	}
+
+	t.Run("existing", func(t *testing.T) {
+		service := NewURLService(newStorage(), testBaseURL, nil, Options{})
+		defer service.Close()
+
+		responses, err := service.ShortenBatch(context.Background(), requests)
+		assert.NoError(t, err)
+		assert.Len(t, responses, 2)
+		assert.NotEqual(t, testBaseURL+"existing1", responses[0].ShortURL)
+		assert.Equal(t, testBaseURL+"existing1", responses[1].ShortURL)
+	})
+
+	t.Run("error", func(t *testing.T) {
+		service := NewURLService(newStorage(), testBaseURL, nil, Options{ConflictStrategy: ConflictError})
+		defer service.Close()
+
+		responses, err := service.ShortenBatch(context.Background(), requests)
+		partialErr, isPartial := IsBatchPartialFailure(err)
+		assert.True(t, isPartial)
+		assert.Len(t, responses, 1)
+		assert.Equal(t, "1", responses[0].CorrelationID)
+		assert.Equal(t, []BatchFailure{{CorrelationID: "2", Reason: BatchReasonConflict}}, partialErr.Failed)
+
+		_, err = service.ShortenBatch(context.Background(), requests[1:])
+		assert.ErrorIs(t, err, ErrURLExists)
+	})
+}
+
+func TestURLService_ShortenBatchPartialFailure(t *testing.T) {
+	mockStorage := &MockURLStorage{
+		SaveBatchFunc: func(ctx context.Context, urls []URLPair) error {
+			if len(urls) > 1 || urls[0].OriginalURL == "https://bad.com" {
+				return errors.New("storage error")
+			}
+			return nil
+		},
+	}
+	service := NewURLService(mockStorage, testBaseURL, nil, Options{})
+
+	requests := []BatchShortenRequest{
+		{CorrelationID: "1", OriginalURL: "https://example.com"},
+		{CorrelationID: "2", OriginalURL: "https://bad.com"},
+		{CorrelationID: "3", OriginalURL: "https://github.com"},
+	}
+
+	responses, err := service.ShortenBatchWithUser(context.Background(), requests, "user1")
+
+	partialErr, isPartial := IsBatchPartialFailure(err)
+	assert.True(t, isPartial)
+	assert.Len(t, responses, 2)
+	assert.Equal(t, "1", responses[0].CorrelationID)
+	assert.Equal(t, "3", responses[1].CorrelationID)
+	assert.Len(t, partialErr.Failed, 1)
+	assert.Equal(t, "2", partialErr.Failed[0].CorrelationID)
+	// Текст ошибки хранилища клиенту не возвращается
+	assert.Equal(t, BatchReasonInternal, partialErr.Failed[0].Reason)
+}
+
+func TestBatchFailureReason(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want string
+	}{
+		{name: "URL уже сокращен", err: ErrURLExists, want: BatchReasonConflict},
+		{name: "конфликт batch", err: &ErrBatchConflict{Existing: map[string]string{"a": "b"}}, want: BatchReasonConflict},
+		{name: "запрещенный домен", err: &ErrBlockedURL{Host: "evil.com"}, want: BatchReasonBlocked},
+		{name: "хранилище заполнено", err: fmt.Errorf("save: %w", ErrStorageFull), want: BatchReasonStorageFull},
+		{name: "ошибка БД", err: errors.New("pq: connection refused to 10.0.0.5"), want: BatchReasonInternal},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, batchFailureReason(tt.err))
+		})
+	}
+}
+
+func TestURLService_ShortenWithUserIdempotency(t *testing.T) {
+	saveCount := 0
+	mockStorage := &MockURLStorage{
+		SaveFunc: func(ctx context.Context, url URLPair) error {
+			saveCount++
+			return nil
+		},
+	}
+	store := &mockIdempotencyStore{records: make(map[string]IdempotencyRecord)}
+	service := NewURLService(mockStorage, testBaseURL, nil, Options{IdempotencyStore: store})
+
+	ctx := context.Background()
+	opts := ShortenOptions{IdempotencyKey: "key-1"}
+
+	first, err := service.ShortenWithUser(ctx, "https://example.com", "user1", opts)
+	assert.NoError(t, err)
+
+	second, err := service.ShortenWithUser(ctx, "https://example.com", "user1", opts)
+	assert.NoError(t, err)
+	assert.Equal(t, first, second)
+	assert.Equal(t, 1, saveCount)
+
+	// Тот же ключ другого пользователя не должен возвращать чужой результат
+	_, err = service.ShortenWithUser(ctx, "https://example.com", "user2", opts)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, saveCount)
+
+	// Тот же ключ с другим URL отклоняется
+	_, err = service.ShortenWithUser(ctx, "https://other.example.com", "user1", opts)
+	assert.ErrorIs(t, err, ErrIdempotencyKeyMismatch)
+	assert.Equal(t, 2, saveCount)
+}
+
+func TestURLService_ShortenWithUserIdempotencyConcurrent(t *testing.T) {
+	var saveCount atomic.Int32
+	release := make(chan struct{})
+	mockStorage := &MockURLStorage{
+		SaveFunc: func(ctx context.Context, url URLPair) error {
+			saveCount.Add(1)
+			<-release
+			return nil
+		},
+	}
+	store := &mockIdempotencyStore{records: make(map[string]IdempotencyRecord)}
+	service := NewURLService(mockStorage, testBaseURL, nil, Options{IdempotencyStore: store})
+
+	ctx := context.Background()
+	opts := ShortenOptions{IdempotencyKey: "key-1"}
+
+	// Первый запрос занимает ключ и ждет сохранения
+	done := make(chan error, 1)
+	go func() {
+		_, err := service.ShortenWithUser(ctx, "https://example.com", "user1", opts)
+		done <- err
+	}()
+	require.Eventually(t, func() bool { return saveCount.Load() == 1 }, time.Second, time.Millisecond)
+
+	// Повтор во время выполнения не создает вторую ссылку
+	_, err := service.ShortenWithUser(ctx, "https://example.com", "user1", opts)
+	assert.ErrorIs(t, err, ErrIdempotencyKeyInProgress)
+
+	close(release)
+	require.NoError(t, <-done)
+	assert.Equal(t, int32(1), saveCount.Load())
+}
+
+func TestURLService_ShortenWithUserIdempotencyRelease(t *testing.T) {
+	saveErr := errors.New("database is unavailable")
+	fail := true
+	mockStorage := &MockURLStorage{
+		SaveFunc: func(ctx context.Context, url URLPair) error {
+			if fail {
+				return saveErr
+			}
+			return nil
+		},
+	}
+	store := &mockIdempotencyStore{records: make(map[string]IdempotencyRecord)}
+	service := NewURLService(mockStorage, testBaseURL, nil, Options{IdempotencyStore: store})
+
+	ctx := context.Background()
+	opts := ShortenOptions{IdempotencyKey: "key-1"}
+
+	_, err := service.ShortenWithUser(ctx, "https://example.com", "user1", opts)
+	assert.ErrorIs(t, err, saveErr)
+
+	// После ошибки ключ освобожден и запрос можно повторить
+	fail = false
+	shortURL, err := service.ShortenWithUser(ctx, "https://example.com", "user1", opts)
+	assert.NoError(t, err)
+	assert.NotEmpty(t, shortURL)
+}
+
 func TestURLService_ShortenWithUser(t *testing.T) {
 	tests := []struct {
 		name             string
@@ -366,7 +1593,7 @@ func TestURLService_ShortenWithUser(t *testing.T) {
 		{
 			name: "успешное сокращение URL с пользователем",
 			storage: &MockURLStorage{
-				SaveFunc: func(shortID, url string) error {
+				SaveFunc: func(ctx context.Context, url URLPair) error {
 					return nil
 				},
 				SaveBatchFunc: func(ctx context.Context, urls []URLPair) error {
@@ -380,7 +1607,7 @@ func TestURLService_ShortenWithUser(t *testing.T) {
 		{
 			name: "конфликт URL - URL уже существует",
 			storage: &MockURLStorage{
-				SaveFunc: func(shortID, url string) error {
+				SaveFunc: func(ctx context.Context, url URLPair) error {
 					return &ErrURLConflict{ExistingShortURL: "existing123"}
 				},
 			},
@@ -393,7 +1620,7 @@ func TestURLService_ShortenWithUser(t *testing.T) {
 		{
 			name: "успешное сокращение URL без пользователя",
 			storage: &MockURLStorage{
-				SaveFunc: func(shortID, url string) error {
+				SaveFunc: func(ctx context.Context, url URLPair) error {
 					return nil
 				},
 			},
@@ -405,8 +1632,8 @@ func TestURLService_ShortenWithUser(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			service := NewURLService(tt.storage, testBaseURL, nil)
-			got, err := service.ShortenWithUser(context.Background(), tt.url, tt.userID)
+			service := NewURLService(tt.storage, testBaseURL, nil, Options{})
+			got, err := service.ShortenWithUser(context.Background(), tt.url, tt.userID, ShortenOptions{})
 			if (err != nil) != tt.wantErr {
 				t.Errorf("URLService.ShortenWithUser() error = %v, wantErr %v", err, tt.wantErr)
 				return
@@ -419,7 +1646,7 @@ func TestURLService_ShortenWithUser(t *testing.T) {
 			} else if !tt.wantErr {
 				assert.True(t, strings.HasPrefix(got, testBaseURL))
 				_, shortID := path.Split(got)
-				assert.Len(t, shortID, 8)
+				assert.Len(t, shortID, RandomShortIDLength)
 			}
 		})
 	}
@@ -427,46 +1654,46 @@ func TestURLService_ShortenWithUser(t *testing.T) {
 
 func BenchmarkURLService_Shorten(b *testing.B) {
 	storage := &MockURLStorage{
-		SaveFunc: func(shortID, url string) error {
+		SaveFunc: func(ctx context.Context, url URLPair) error {
 			return nil
 		},
 	}
-	service := NewURLService(storage, "http://localhost:8080/", nil)
+	service := NewURLService(storage, "http://localhost:8080/", nil, Options{})
 
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
-		_, _ = service.Shorten("http://example.com")
+		_, _ = service.Shorten(context.Background(), "http://example.com")
 	}
 }
 
 func BenchmarkURLService_ShortenWithUser(b *testing.B) {
 	storage := &MockURLStorage{
-		SaveFunc: func(shortID, url string) error {
+		SaveFunc: func(ctx context.Context, url URLPair) error {
 			return nil
 		},
 		SaveBatchFunc: func(ctx context.Context, urls []URLPair) error {
 			return nil
 		},
 	}
-	service := NewURLService(storage, "http://localhost:8080/", nil)
+	service := NewURLService(storage, "http://localhost:8080/", nil, Options{})
 
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
-		_, _ = service.ShortenWithUser(context.Background(), "http://example.com", "test-user")
+		_, _ = service.ShortenWithUser(context.Background(), "http://example.com", "test-user", ShortenOptions{})
 	}
 }
 
 func BenchmarkURLService_Expand(b *testing.B) {
 	storage := &MockURLStorage{
-		GetFunc: func(shortID string) (string, error) {
+		GetFunc: func(ctx context.Context, shortID string) (string, error) {
 			return "http://example.com", nil
 		},
 	}
-	service := NewURLService(storage, "http://localhost:8080/", nil)
+	service := NewURLService(storage, "http://localhost:8080/", nil, Options{})
 
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
-		_, _ = service.Expand("abc123")
+		_, _ = service.Expand(context.Background(), "abc123")
 	}
 }
 
@@ -476,7 +1703,7 @@ func BenchmarkURLService_ShortenBatch(b *testing.B) {
 			return nil
 		},
 	}
-	service := NewURLService(storage, "http://localhost:8080/", nil)
+	service := NewURLService(storage, "http://localhost:8080/", nil, Options{})
 
 	requests := []BatchShortenRequest{
 		{CorrelationID: "1", OriginalURL: "http://example1.com"},
@@ -491,24 +1718,24 @@ func BenchmarkURLService_ShortenBatch(b *testing.B) {
 
 func BenchmarkURLService_GetUserURLs(b *testing.B) {
 	storage := &MockURLStorage{
-		GetUserURLsFunc: func(ctx context.Context, userID string) ([]UserURL, error) {
+		GetUserURLsFunc: func(ctx context.Context, userID string, limit, offset int) ([]UserURL, int, error) {
 			return []UserURL{
 				{
-					ShortURL:    "http://localhost:8080/abc123",
+					ShortID:     "abc123",
 					OriginalURL: "http://example.com",
 				},
 				{
-					ShortURL:    "http://localhost:8080/def456",
+					ShortID:     "def456",
 					OriginalURL: "http://another.com",
 				},
-			}, nil
+			}, 2, nil
 		},
 	}
-	service := NewURLService(storage, "http://localhost:8080/", nil)
+	service := NewURLService(storage, "http://localhost:8080/", nil, Options{})
 
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
-		_, _ = service.GetUserURLs(context.Background(), "test-user")
+		_, _, _ = service.GetUserURLs(context.Background(), "test-user", 0, 0)
 	}
 }
 
@@ -518,7 +1745,7 @@ func BenchmarkURLService_DeleteUserURLs(b *testing.B) {
 			return nil
 		},
 	}
-	service := NewURLService(storage, "http://localhost:8080/", nil)
+	service := NewURLService(storage, "http://localhost:8080/", nil, Options{})
 
 	shortIDs := []string{"abc123", "def456"}
 
@@ -527,3 +1754,34 @@ func BenchmarkURLService_DeleteUserURLs(b *testing.B) {
 		_ = service.DeleteUserURLs("test-user", shortIDs)
 	}
 }
+
+func TestURLService_DeleteAllUserURLs(t *testing.T) {
+	var mu sync.Mutex
+	var deletedAll []string
+	var deletedIDs []string
+	storage := &MockURLStorage{
+		DeleteAllUserURLsFunc: func(ctx context.Context, userID string) (int64, error) {
+			mu.Lock()
+			defer mu.Unlock()
+			deletedAll = append(deletedAll, userID)
+			return 2, nil
+		},
+		BatchDeleteUserURLsFunc: func(ctx context.Context, userID string, shortIDs []string) error {
+			mu.Lock()
+			defer mu.Unlock()
+			deletedIDs = append(deletedIDs, shortIDs...)
+			return nil
+		},
+	}
+	service := NewURLService(storage, testBaseURL, nil, Options{})
+
+	assert.NoError(t, service.DeleteAllUserURLs("user1"))
+	assert.NoError(t, service.DeleteUserURLs("user2", []string{"def456"}))
+
+	// Close дожидается обработки всей очереди
+	service.Close()
+
+	assert.Equal(t, []string{"user1"}, deletedAll)
+	assert.Equal(t, []string{"def456"}, deletedIDs)
+	assert.Equal(t, DeleteStats{Enqueued: 2, Processed: 2}, service.DeleteStats())
+}